@@ -30,6 +30,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/facebookincubator/ttpforge/pkg/detections"
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/facebookincubator/ttpforge/pkg/repos"
 	"github.com/spf13/afero"
@@ -53,6 +54,9 @@ type TestConfig struct {
 // should not touch it
 type Config struct {
 	RepoSpecs []repos.Spec `yaml:"repos"`
+	// AlertSource configures where alerts are retrieved
+	// from when verifying the detections declared by TTPs
+	AlertSource *detections.SourceConfig `yaml:"alert_source,omitempty"`
 
 	repoCollection repos.RepoCollection
 	cfgFile        string
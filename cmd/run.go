@@ -22,9 +22,11 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/facebookincubator/ttpforge/pkg/backends"
 	"github.com/facebookincubator/ttpforge/pkg/blocks"
+	"github.com/facebookincubator/ttpforge/pkg/detections"
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/facebookincubator/ttpforge/pkg/parseutils"
 	"github.com/facebookincubator/ttpforge/pkg/repos"
@@ -73,9 +75,24 @@ func buildRunCommand(cfg *Config) *cobra.Command {
 				return fmt.Errorf("failed to resolve TTP reference %v: %v", ttpRef, err)
 			}
 
+			// set up the alert source used to verify detections - this
+			// must happen before the TTP is loaded, as LoadTTP copies the
+			// config into the execution context
+			if cfg.AlertSource != nil && !ttpCfg.NoDetections {
+				ttpCfg.AlertSource, err = detections.NewSource(*cfg.AlertSource)
+				if err != nil {
+					return fmt.Errorf("invalid alert_source config: %w", err)
+				}
+				ttpCfg.AlertPollInterval, err = cfg.AlertSource.GetPollInterval()
+				if err != nil {
+					return fmt.Errorf("invalid alert_source config: %w", err)
+				}
+			}
+
 			// load TTP and process argument values
 			// based on the TTPs argument value specifications
 			ttpCfg.Repo = foundRepo
+			ttpCfg.RepoCollection = cfg.repoCollection
 
 			ttp, execCtx, err := blocks.LoadTTP(ttpAbsPath, foundRepo.GetFs(), &ttpCfg, map[string]string{}, argsList)
 			if err != nil {
@@ -92,6 +109,7 @@ func buildRunCommand(cfg *Config) *cobra.Command {
 			execCtx.ConnPool = backends.NewConnectionPool()
 			defer execCtx.ConnPool.CloseAll()
 
+			runStart := time.Now()
 			runErr := ttp.Execute(*execCtx)
 			// Run clean up always
 			cleanupErr := ttp.RunCleanup(*execCtx)
@@ -100,15 +118,27 @@ func buildRunCommand(cfg *Config) *cobra.Command {
 				logging.L().Warnf("Failed to run cleanup: %v", cleanupErr)
 			}
 
+			// alerts may take a while to arrive, so
+			// only wait for them once cleanup is done
+			_, detectionErr := ttp.VerifyDetections(*execCtx, runStart)
+
 			if runErr != nil {
 				return fmt.Errorf("failed to run TTP at %v: %w", ttpAbsPath, runErr)
 			}
+			if cleanupErr != nil && ttpCfg.StrictCleanup {
+				return fmt.Errorf("failed to clean up TTP at %v: %w", ttpAbsPath, cleanupErr)
+			}
+			if detectionErr != nil {
+				return fmt.Errorf("failed to verify detections of TTP at %v: %w", ttpAbsPath, detectionErr)
+			}
 			return nil
 		},
 	}
 	runCmd.PersistentFlags().BoolVar(&ttpCfg.DryRun, "dry-run", false, "Parse arguments and validate TTP Contents, but do not actually run the TTP")
 	runCmd.PersistentFlags().BoolVar(&ttpCfg.NoCleanup, "no-cleanup", false, "Disable cleanup (useful for debugging and daisy-chaining TTPs)")
 	runCmd.PersistentFlags().BoolVar(&ttpCfg.NoChecks, "no-checks", false, "Skip/ignore checks")
+	runCmd.PersistentFlags().BoolVar(&ttpCfg.StrictCleanup, "strict-cleanup", false, "Exit with an error if cleanup fails or cleanup checks do not pass")
+	runCmd.PersistentFlags().BoolVar(&ttpCfg.NoDetections, "no-detections", false, "Skip verification of the detections declared by the TTP")
 	runCmd.PersistentFlags().BoolVar(&ttpCfg.NoProxy, "no-proxy", false, "Ignore proxy settings defined in TTPs")
 	runCmd.PersistentFlags().UintVar(&ttpCfg.CleanupDelaySeconds, "cleanup-delay-seconds", 0, "Wait this long after TTP execution before starting cleanup")
 	runCmd.Flags().StringArrayVarP(&argsList, "arg", "a", []string{}, "Variable input mapping for args to be used in place of inputs defined in each ttp file")
@@ -22,6 +22,7 @@ package cmd
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -302,3 +303,59 @@ func TestRunPathArguments(t *testing.T) {
 		})
 	}
 }
+
+// TestRunDetections checks that the detections declared by a TTP are
+// verified against the alert source configured in the config file
+func TestRunDetections(t *testing.T) {
+	repoPath, err := filepath.Abs(filepath.Join(testResourcesDir, "repos", testRepoName))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		alerts    string
+		args      []string
+		wantError bool
+	}{
+		{
+			name:   "Alert Raised",
+			alerts: `{"rule_id": "TEST-1"}` + "\n",
+		},
+		{
+			name:      "Alert Missing",
+			alerts:    `{"rule_id": "OTHER-1"}` + "\n",
+			wantError: true,
+		},
+		{
+			name:   "No Detections Flag",
+			alerts: `{"rule_id": "OTHER-1"}` + "\n",
+			args:   []string{"--no-detections"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			alertsPath := filepath.Join(tmpDir, "alerts.jsonl")
+			require.NoError(t, os.WriteFile(alertsPath, []byte(tc.alerts), 0644))
+			configPath := filepath.Join(tmpDir, "config.yaml")
+			config := fmt.Sprintf(`---
+repos:
+  - name: %v
+    path: %v
+alert_source:
+  type: jsonl
+  path: %v
+  poll_interval: 100ms
+`, testRepoName, repoPath, alertsPath)
+			require.NoError(t, os.WriteFile(configPath, []byte(config), 0644))
+
+			args := append([]string{"-c", configPath}, tc.args...)
+			checkRunCmdTestCase(t, runCmdTestCase{
+				name:           tc.name,
+				args:           append(args, testRepoName+"//detections/expected-detection.yaml"),
+				expectedStdout: "triggered\n",
+				wantError:      tc.wantError,
+			})
+		})
+	}
+}
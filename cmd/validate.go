@@ -49,7 +49,7 @@ without needing to provide all runtime arguments or match platform requirements.
 
 			fmt.Printf("Validating TTP: %s\n", ttpAbsPath)
 
-			result := validation.ValidateTTP(ttpAbsPath, foundRepo.GetFs(), foundRepo)
+			result := validation.ValidateTTP(ttpAbsPath, foundRepo.GetFs(), foundRepo, cfg.repoCollection)
 			result.Print()
 
 			if result.HasErrors() {
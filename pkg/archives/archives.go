@@ -0,0 +1,316 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package archives creates and extracts zip and tar archives on any
+// afero filesystem, so that the same code works on the local machine
+// and on remote hosts reached over SFTP.
+package archives
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Supported archive formats
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// Filter selects the files to archive or extract. Each glob is
+// matched against both the file name and its path within the
+// archive, such as "*.docx" or "Documents/*.pdf".
+type Filter struct {
+	Include []string
+	Exclude []string
+}
+
+// Validate checks that every glob in the filter is well formed
+func (f Filter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the file at the given archive
+// path is included and not excluded by the filter
+func (f Filter) Matches(entryPath string) bool {
+	if matchesAny(f.Exclude, entryPath) {
+		return false
+	}
+	return len(f.Include) == 0 || matchesAny(f.Include, entryPath)
+}
+
+// excludesDir reports whether an entire directory is excluded
+func (f Filter) excludesDir(entryPath string) bool {
+	return matchesAny(f.Exclude, entryPath)
+}
+
+func matchesAny(patterns []string, entryPath string) bool {
+	name := path.Base(entryPath)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, entryPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFormat returns the given format if it is set, and otherwise
+// infers the format from the extension of the archive path
+func ResolveFormat(format, archivePath string) (string, error) {
+	switch format {
+	case FormatZip, FormatTar, FormatTarGz:
+		return format, nil
+	case "tgz":
+		return FormatTarGz, nil
+	case "":
+	default:
+		return "", fmt.Errorf("invalid archive format %q - must be zip, tar or tar.gz", format)
+	}
+
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar, nil
+	default:
+		return "", fmt.Errorf("cannot infer archive format of %q - please specify format", archivePath)
+	}
+}
+
+// CreateOptions control how an archive is created
+type CreateOptions struct {
+	Format string
+	// Password encrypts the entries of zip archives
+	Password string
+	Filter   Filter
+}
+
+// Create writes an archive containing the given files and directories
+// (which are added recursively) to archivePath. Each source is stored
+// under its base name. Only regular files are archived - symbolic links
+// and other special files are skipped. It returns the paths of the
+// entries that were written.
+func Create(fsys afero.Fs, archivePath string, sources []string, opts CreateOptions) ([]string, error) {
+	if opts.Password != "" && opts.Format != FormatZip {
+		return nil, fmt.Errorf("password protection is only supported for zip archives")
+	}
+
+	f, err := fsys.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive %v: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	var w entryWriter
+	switch opts.Format {
+	case FormatZip:
+		w = &zipEntryWriter{zw: zip.NewWriter(f), password: opts.Password}
+	case FormatTar:
+		w = &tarEntryWriter{tw: tar.NewWriter(f)}
+	case FormatTarGz:
+		gz := gzip.NewWriter(f)
+		w = &tarEntryWriter{tw: tar.NewWriter(gz), gz: gz}
+	default:
+		return nil, fmt.Errorf("invalid archive format %q", opts.Format)
+	}
+
+	var entries []string
+	for _, source := range sources {
+		added, err := addSource(fsys, w, source, archivePath, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, added...)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive %v: %w", archivePath, err)
+	}
+	return entries, nil
+}
+
+// addSource adds a single source file or directory to the archive
+func addSource(fsys afero.Fs, w entryWriter, source, archivePath string, filter Filter) ([]string, error) {
+	source = filepath.Clean(source)
+	root := filepath.Dir(source)
+	var entries []string
+	err := afero.Walk(fsys, source, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		entryPath := filepath.ToSlash(rel)
+		if info.IsDir() {
+			if filePath != source && filter.excludesDir(entryPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// never add the archive to itself
+		if !info.Mode().IsRegular() || filepath.Clean(filePath) == filepath.Clean(archivePath) {
+			return nil
+		}
+		if !filter.Matches(entryPath) {
+			return nil
+		}
+
+		contents, err := afero.ReadFile(fsys, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %v: %w", filePath, err)
+		}
+		if err := w.WriteEntry(entryPath, info, contents); err != nil {
+			return fmt.Errorf("failed to archive %v: %w", filePath, err)
+		}
+		entries = append(entries, entryPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// entryWriter writes files to an archive of a particular format
+type entryWriter interface {
+	WriteEntry(name string, info os.FileInfo, contents []byte) error
+	Close() error
+}
+
+type zipEntryWriter struct {
+	zw       *zip.Writer
+	password string
+}
+
+func (w *zipEntryWriter) WriteEntry(name string, info os.FileInfo, contents []byte) error {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Method = zip.Deflate
+	if w.password == "" {
+		fw, err := w.zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = fw.Write(contents)
+		return err
+	}
+
+	// encrypted entries must be compressed
+	// and encrypted before they are written
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(contents); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	hdr.CRC32 = crc32.ChecksumIEEE(contents)
+	encrypted, err := encryptZipData(w.password, hdr.CRC32, compressed.Bytes())
+	if err != nil {
+		return err
+	}
+	hdr.Flags |= 0x1
+	hdr.CompressedSize64 = uint64(len(encrypted))
+	hdr.UncompressedSize64 = uint64(len(contents))
+	rw, err := w.zw.CreateRaw(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = rw.Write(encrypted)
+	return err
+}
+
+func (w *zipEntryWriter) Close() error {
+	return w.zw.Close()
+}
+
+type tarEntryWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func (w *tarEntryWriter) WriteEntry(name string, info os.FileInfo, contents []byte) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     int64(len(contents)),
+		ModTime:  info.ModTime(),
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := w.tw.Write(contents)
+	return err
+}
+
+func (w *tarEntryWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// readAll reads from r, failing if more than limit bytes are available,
+// to guard against decompression bombs
+func readAll(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("entry is larger than %d bytes", limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,313 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package archives
+
+import (
+	"archive/tar"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeSourceTree(t *testing.T) afero.Fs {
+	fsys := afero.NewMemMapFs()
+	files := map[string]string{
+		"/home/user/Documents/report.docx":     "quarterly report",
+		"/home/user/Documents/notes.txt":       "some notes",
+		"/home/user/Documents/cache/tmp.docx":  "cached",
+		"/home/user/Documents/deep/plan.pdf":   "the plan",
+		"/home/user/.ssh/id_rsa":               "not really a key",
+		"/home/user/Documents/deep/empty.docx": "",
+	}
+	for name, contents := range files {
+		require.NoError(t, afero.WriteFile(fsys, name, []byte(contents), 0644))
+	}
+	return fsys
+}
+
+func TestResolveFormat(t *testing.T) {
+	testCases := []struct {
+		name      string
+		format    string
+		path      string
+		expected  string
+		wantError bool
+	}{
+		{name: "Explicit Format", format: "tar", path: "/tmp/out.bin", expected: FormatTar},
+		{name: "Tgz Alias", format: "tgz", path: "/tmp/out", expected: FormatTarGz},
+		{name: "Zip Extension", path: "/tmp/OUT.ZIP", expected: FormatZip},
+		{name: "Tar Gz Extension", path: "/tmp/out.tar.gz", expected: FormatTarGz},
+		{name: "Tgz Extension", path: "/tmp/out.tgz", expected: FormatTarGz},
+		{name: "Tar Extension", path: "/tmp/out.tar", expected: FormatTar},
+		{name: "Unknown Extension", path: "/tmp/out.rar", wantError: true},
+		{name: "Invalid Format", format: "rar", path: "/tmp/out.zip", wantError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := ResolveFormat(tc.format, tc.path)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, format)
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name            string
+		createOpts      CreateOptions
+		extractOpts     ExtractOptions
+		sources         []string
+		expectedEntries []string
+		wantCreateError bool
+		wantExtractErr  bool
+	}{
+		{
+			name:       "Zip Directory",
+			createOpts: CreateOptions{Format: FormatZip},
+			sources:    []string{"/home/user/Documents"},
+			expectedEntries: []string{
+				"Documents/cache/tmp.docx",
+				"Documents/deep/empty.docx",
+				"Documents/deep/plan.pdf",
+				"Documents/notes.txt",
+				"Documents/report.docx",
+			},
+		},
+		{
+			name:            "Tar Single File",
+			createOpts:      CreateOptions{Format: FormatTar},
+			sources:         []string{"/home/user/.ssh/id_rsa"},
+			expectedEntries: []string{"id_rsa"},
+		},
+		{
+			name: "Tar Gz With Filters",
+			createOpts: CreateOptions{
+				Format: FormatTarGz,
+				Filter: Filter{Include: []string{"*.docx", "*.pdf"}, Exclude: []string{"cache"}},
+			},
+			sources: []string{"/home/user/Documents", "/home/user/.ssh"},
+			expectedEntries: []string{
+				"Documents/deep/empty.docx",
+				"Documents/deep/plan.pdf",
+				"Documents/report.docx",
+			},
+		},
+		{
+			name:        "Password Protected Zip",
+			createOpts:  CreateOptions{Format: FormatZip, Password: "infected"},
+			extractOpts: ExtractOptions{Password: "infected"},
+			sources:     []string{"/home/user/Documents/deep"},
+			expectedEntries: []string{
+				"deep/empty.docx",
+				"deep/plan.pdf",
+			},
+		},
+		{
+			name:           "Wrong Password",
+			createOpts:     CreateOptions{Format: FormatZip, Password: "infected"},
+			extractOpts:    ExtractOptions{Password: "wrong"},
+			sources:        []string{"/home/user/Documents/report.docx"},
+			wantExtractErr: true,
+		},
+		{
+			name:           "Missing Password",
+			createOpts:     CreateOptions{Format: FormatZip, Password: "infected"},
+			sources:        []string{"/home/user/Documents/report.docx"},
+			wantExtractErr: true,
+		},
+		{
+			name:            "Password With Tar",
+			createOpts:      CreateOptions{Format: FormatTar, Password: "infected"},
+			sources:         []string{"/home/user/Documents"},
+			wantCreateError: true,
+		},
+		{
+			name:            "Missing Source",
+			createOpts:      CreateOptions{Format: FormatZip},
+			sources:         []string{"/does/not/exist"},
+			wantCreateError: true,
+		},
+		{
+			name:        "Extract With Filter",
+			createOpts:  CreateOptions{Format: FormatZip},
+			extractOpts: ExtractOptions{Filter: Filter{Include: []string{"*.pdf"}}},
+			sources:     []string{"/home/user/Documents"},
+			expectedEntries: []string{
+				"Documents/deep/plan.pdf",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := makeSourceTree(t)
+			archivePath := "/tmp/staged.archive"
+			require.NoError(t, fsys.MkdirAll("/tmp", 0755))
+
+			entries, err := Create(fsys, archivePath, tc.sources, tc.createOpts)
+			if tc.wantCreateError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			extractOpts := tc.extractOpts
+			extractOpts.Format = tc.createOpts.Format
+			extracted, err := Extract(fsys, archivePath, "/tmp/out", extractOpts)
+			if tc.wantExtractErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if len(extractOpts.Filter.Include) == 0 {
+				sort.Strings(entries)
+				assert.Equal(t, tc.expectedEntries, entries)
+			}
+			var files []string
+			for _, file := range extracted.Files {
+				rel, err := filepath.Rel("/tmp/out", file)
+				require.NoError(t, err)
+				files = append(files, filepath.ToSlash(rel))
+
+				original := filepath.Join(filepath.Dir(tc.sources[0]), rel)
+				want, err := afero.ReadFile(fsys, original)
+				require.NoError(t, err)
+				got, err := afero.ReadFile(fsys, file)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+			sort.Strings(files)
+			assert.Equal(t, tc.expectedEntries, files)
+			assert.Equal(t, "/tmp/out", extracted.Dirs[0])
+		})
+	}
+}
+
+func TestExtractOverwrite(t *testing.T) {
+	fsys := makeSourceTree(t)
+	_, err := Create(fsys, "/tmp/notes.zip", []string{"/home/user/Documents/notes.txt"}, CreateOptions{Format: FormatZip})
+	require.NoError(t, err)
+	require.NoError(t, afero.WriteFile(fsys, "/tmp/out/notes.txt", []byte("existing"), 0644))
+
+	extracted, err := Extract(fsys, "/tmp/notes.zip", "/tmp/out", ExtractOptions{Format: FormatZip})
+	require.Error(t, err)
+	assert.Empty(t, extracted.Files)
+	assert.Empty(t, extracted.Dirs)
+
+	extracted, err = Extract(fsys, "/tmp/notes.zip", "/tmp/out", ExtractOptions{Format: FormatZip, Overwrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/out/notes.txt"}, extracted.Files)
+	contents, err := afero.ReadFile(fsys, "/tmp/out/notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "some notes", string(contents))
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fsys, "/src/evil", []byte("pwned"), 0644))
+	f, err := fsys.Create("/tmp/evil.tar")
+	require.NoError(t, err)
+	info, err := fsys.Stat("/src/evil")
+	require.NoError(t, err)
+	w := &tarEntryWriter{tw: tar.NewWriter(f)}
+	require.NoError(t, w.WriteEntry("../../etc/evil", info, []byte("pwned")))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	_, err = Extract(fsys, "/tmp/evil.tar", "/tmp/out", ExtractOptions{Format: FormatTar})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "outside of the destination directory")
+	exists, err := afero.Exists(fsys, "/etc/evil")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// writeTar writes a tar archive holding the given entries in order
+func writeTar(t *testing.T, fsys afero.Fs, archivePath string, entries [][2]string) {
+	require.NoError(t, afero.WriteFile(fsys, "/src/entry", nil, 0644))
+	info, err := fsys.Stat("/src/entry")
+	require.NoError(t, err)
+	f, err := fsys.Create(archivePath)
+	require.NoError(t, err)
+	w := &tarEntryWriter{tw: tar.NewWriter(f)}
+	for _, entry := range entries {
+		require.NoError(t, w.WriteEntry(entry[0], info, []byte(entry[1])))
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+}
+
+func TestExtractRepeatedEntry(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	writeTar(t, fsys, "/tmp/repeated.tar", [][2]string{
+		{"payload.txt", "first"},
+		{"payload.txt", "second"},
+	})
+
+	extracted, err := Extract(fsys, "/tmp/repeated.tar", "/tmp/out", ExtractOptions{Format: FormatTar, Overwrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/out/payload.txt"}, extracted.Files)
+	assert.Empty(t, extracted.Replaced)
+	contents, err := afero.ReadFile(fsys, "/tmp/out/payload.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(contents))
+}
+
+func TestExtractMaxSize(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxSize   int64
+		wantError bool
+	}{
+		{name: "Within Limit", maxSize: 10},
+		{name: "Over Limit", maxSize: 9, wantError: true},
+		{name: "Default Limit", maxSize: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+			writeTar(t, fsys, "/tmp/sized.tar", [][2]string{
+				{"a.txt", "12345"},
+				{"b.txt", "67890"},
+			})
+
+			extracted, err := Extract(fsys, "/tmp/sized.tar", "/tmp/out", ExtractOptions{Format: FormatTar, MaxSize: tc.maxSize})
+			if tc.wantError {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "larger than 9 bytes")
+				assert.Equal(t, []string{"/tmp/out/a.txt"}, extracted.Files)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"/tmp/out/a.txt", "/tmp/out/b.txt"}, extracted.Files)
+		})
+	}
+}
@@ -0,0 +1,324 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package archives
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// MaxEntrySize is the largest archive entry that will be extracted
+const MaxEntrySize = 1 << 30
+
+// MaxExtractedSize is the default limit on the
+// total size of the files extracted from an archive
+const MaxExtractedSize = 4 << 30
+
+// ExtractOptions control how an archive is extracted
+type ExtractOptions struct {
+	Format string
+	// Password decrypts the entries of encrypted zip archives
+	Password string
+	// Overwrite allows existing files to be replaced
+	Overwrite bool
+	Filter    Filter
+	// MaxSize limits the total size of the extracted
+	// files, defaulting to MaxExtractedSize
+	MaxSize int64
+}
+
+// Extracted lists what an extraction created
+type Extracted struct {
+	// Files are the paths of the files that were written
+	Files []string
+	// Replaced are the paths in Files that already existed
+	// and were overwritten, so they must not be removed
+	Replaced []string
+	// Dirs are the directories that did not exist
+	// before extraction, in the order they were created
+	Dirs []string
+}
+
+// Extract unpacks the archive at archivePath into the dest directory.
+// Entries that would be written outside of dest are rejected, and
+// symbolic links and other special files are skipped. The returned
+// Extracted is populated even when an error occurs, so that partial
+// extractions can be cleaned up.
+func Extract(fsys afero.Fs, archivePath, dest string, opts ExtractOptions) (*Extracted, error) {
+	f, err := fsys.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %v: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = MaxExtractedSize
+	}
+	x := &extractor{
+		fsys:    fsys,
+		dest:    filepath.Clean(dest),
+		opts:    opts,
+		result:  &Extracted{},
+		written: map[string]bool{},
+	}
+	if err := x.mkdirAll(x.dest); err != nil {
+		return x.result, err
+	}
+
+	switch opts.Format {
+	case FormatZip:
+		info, err := f.Stat()
+		if err != nil {
+			return x.result, err
+		}
+		err = x.extractZip(f, info.Size())
+		return x.result, err
+	case FormatTar:
+		return x.result, x.extractTar(f)
+	case FormatTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return x.result, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		defer gz.Close()
+		return x.result, x.extractTar(gz)
+	default:
+		return x.result, fmt.Errorf("invalid archive format %q", opts.Format)
+	}
+}
+
+type extractor struct {
+	fsys   afero.Fs
+	dest   string
+	opts   ExtractOptions
+	result *Extracted
+	// written holds the paths in result.Files, so that an entry
+	// repeated in the archive is not mistaken for an existing file
+	written map[string]bool
+	// size is the total size of the files written so far
+	size int64
+}
+
+// targetPath returns where an archive entry should be written,
+// rejecting entries that would escape the destination directory
+func (x *extractor) targetPath(name string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("archive entry %q is outside of the destination directory", name)
+	}
+	return filepath.Join(x.dest, filepath.FromSlash(cleaned)), nil
+}
+
+// mkdirAll creates dir and its missing parents,
+// recording each directory that it creates
+func (x *extractor) mkdirAll(dir string) error {
+	created, err := MkdirAll(x.fsys, dir)
+	x.result.Dirs = append(x.result.Dirs, created...)
+	return err
+}
+
+// MkdirAll creates dir and its missing parents, returning the
+// directories that it created in the order they were created.
+// The returned list is populated even when an error occurs.
+func MkdirAll(fsys afero.Fs, dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := fsys.Stat(d); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	var created []string
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := fsys.Mkdir(missing[i], 0755); err != nil {
+			return created, fmt.Errorf("failed to create directory %v: %w", missing[i], err)
+		}
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
+
+func (x *extractor) writeFile(name string, mode os.FileMode, contents []byte) error {
+	target, err := x.targetPath(name)
+	if err != nil {
+		return err
+	}
+	if err := x.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+	_, err = x.fsys.Stat(target)
+	existed := err == nil
+	if existed && !x.opts.Overwrite {
+		return fmt.Errorf("%v already exists - set overwrite: true to replace it", target)
+	}
+	if x.size+int64(len(contents)) > x.opts.MaxSize {
+		return x.errTooLarge()
+	}
+	if mode == 0 {
+		mode = 0644
+	}
+	if err := afero.WriteFile(x.fsys, target, contents, mode); err != nil {
+		return fmt.Errorf("failed to write %v: %w", target, err)
+	}
+	x.size += int64(len(contents))
+	if x.written[target] {
+		// written earlier by the same extraction
+		return nil
+	}
+	x.written[target] = true
+	x.result.Files = append(x.result.Files, target)
+	if existed {
+		x.result.Replaced = append(x.result.Replaced, target)
+	}
+	return nil
+}
+
+// readAll reads an archive entry, which may be no larger than
+// MaxEntrySize or than what is left of the total size limit
+func (x *extractor) readAll(r io.Reader) ([]byte, error) {
+	remaining := x.opts.MaxSize - x.size
+	if remaining >= MaxEntrySize {
+		return readAll(r, MaxEntrySize)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > remaining {
+		return nil, x.errTooLarge()
+	}
+	return data, nil
+}
+
+func (x *extractor) errTooLarge() error {
+	return fmt.Errorf("the extracted files are larger than %d bytes", x.opts.MaxSize)
+}
+
+func (x *extractor) extractZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || !zf.Mode().IsRegular() || !x.opts.Filter.Matches(strings.TrimPrefix(path.Clean(zf.Name), "/")) {
+			continue
+		}
+		contents, err := x.readZipFile(zf)
+		if err != nil {
+			return fmt.Errorf("failed to extract %v: %w", zf.Name, err)
+		}
+		if err := x.writeFile(zf.Name, zf.Mode().Perm(), contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) readZipFile(zf *zip.File) ([]byte, error) {
+	if zf.Flags&0x1 == 0 {
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return x.readAll(rc)
+	}
+
+	if x.opts.Password == "" {
+		return nil, fmt.Errorf("entry is encrypted - please specify password")
+	}
+	raw, err := zf.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := x.readAll(raw)
+	if err != nil {
+		return nil, err
+	}
+	// entries written with a data descriptor use a
+	// different check byte, so rely on the CRC instead
+	checkByte := int(zf.CRC32 >> 24)
+	if zf.Flags&0x8 != 0 {
+		checkByte = -1
+	}
+	compressed, err := decryptZipData(x.opts.Password, checkByte, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []byte
+	switch zf.Method {
+	case zip.Store:
+		contents = compressed
+	case zip.Deflate:
+		fr := flate.NewReader(bytes.NewReader(compressed))
+		defer fr.Close()
+		if contents, err = x.readAll(fr); err != nil {
+			return nil, errWrongPassword
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression method %d", zf.Method)
+	}
+	if crc32.ChecksumIEEE(contents) != zf.CRC32 {
+		return nil, errWrongPassword
+	}
+	return contents, nil
+}
+
+func (x *extractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg || !x.opts.Filter.Matches(strings.TrimPrefix(path.Clean(hdr.Name), "/")) {
+			continue
+		}
+		contents, err := x.readAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to extract %v: %w", hdr.Name, err)
+		}
+		if err := x.writeFile(hdr.Name, os.FileMode(hdr.Mode).Perm(), contents); err != nil {
+			return err
+		}
+	}
+}
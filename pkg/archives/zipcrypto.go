@@ -0,0 +1,111 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package archives
+
+import (
+	"crypto/rand"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// zipCryptoHeaderLen is the length of the encryption header
+// that precedes the data of each encrypted zip entry
+const zipCryptoHeaderLen = 12
+
+// errWrongPassword is returned when an encrypted
+// zip entry cannot be decrypted with the given password
+var errWrongPassword = errors.New("incorrect password")
+
+// zipCrypto implements the traditional PKWARE zip encryption
+// scheme. It is weak by modern standards, but it is what most
+// password-protected zip files staged by real attackers use and
+// it is supported by every unzip tool.
+type zipCrypto struct {
+	keys [3]uint32
+}
+
+func newZipCrypto(password string) *zipCrypto {
+	z := &zipCrypto{keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	return z
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+func (z *zipCrypto) update(b byte) {
+	z.keys[0] = crc32Update(z.keys[0], b)
+	z.keys[1] = (z.keys[1]+(z.keys[0]&0xff))*134775813 + 1
+	z.keys[2] = crc32Update(z.keys[2], byte(z.keys[1]>>24))
+}
+
+func (z *zipCrypto) streamByte() byte {
+	temp := z.keys[2] | 2
+	return byte((temp * (temp ^ 1)) >> 8)
+}
+
+func (z *zipCrypto) encrypt(data []byte) {
+	for i, p := range data {
+		data[i] = p ^ z.streamByte()
+		z.update(p)
+	}
+}
+
+func (z *zipCrypto) decrypt(data []byte) {
+	for i, c := range data {
+		p := c ^ z.streamByte()
+		data[i] = p
+		z.update(p)
+	}
+}
+
+// encryptZipData encrypts the (already compressed) data of a zip
+// entry whose uncompressed contents have the given CRC-32 checksum,
+// returning the encryption header followed by the encrypted data
+func encryptZipData(password string, crc uint32, data []byte) ([]byte, error) {
+	out := make([]byte, zipCryptoHeaderLen+len(data))
+	if _, err := io.ReadFull(rand.Reader, out[:zipCryptoHeaderLen-1]); err != nil {
+		return nil, err
+	}
+	// the last header byte lets readers detect a wrong password
+	out[zipCryptoHeaderLen-1] = byte(crc >> 24)
+	copy(out[zipCryptoHeaderLen:], data)
+	newZipCrypto(password).encrypt(out)
+	return out, nil
+}
+
+// decryptZipData decrypts the raw data of an encrypted zip entry,
+// returning the compressed data without the encryption header.
+// checkByte is the value expected at the end of the header, or
+// -1 if it should not be verified.
+func decryptZipData(password string, checkByte int, raw []byte) ([]byte, error) {
+	if len(raw) < zipCryptoHeaderLen {
+		return nil, errors.New("encrypted entry is truncated")
+	}
+	newZipCrypto(password).decrypt(raw)
+	if checkByte >= 0 && raw[zipCryptoHeaderLen-1] != byte(checkByte) {
+		return nil, errWrongPassword
+	}
+	return raw[zipCryptoHeaderLen:], nil
+}
@@ -20,6 +20,7 @@ THE SOFTWARE.
 package args
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -179,6 +180,7 @@ func GetValidArgTypes() []string {
 		"int",
 		"bool",
 		"path",
+		"list",
 	}
 }
 
@@ -207,7 +209,31 @@ func (spec Spec) convertArgToType(val string) (any, error) {
 			return nil, fmt.Errorf("failed to process argument of type `path`: %w", err)
 		}
 		return absPath, nil
+	case "list":
+		return parseList(val)
 	default:
 		return nil, fmt.Errorf("invalid type %v specified in configuration for argument %v", spec.Type, spec.Name)
 	}
 }
+
+// parseList converts the value of a `list` argument into a slice.
+// JSON arrays (such as list outputs passed from another step) are
+// decoded as-is; any other value is treated as a comma-separated list.
+func parseList(val string) ([]any, error) {
+	trimmed := strings.TrimSpace(val)
+	if strings.HasPrefix(trimmed, "[") {
+		var list []any
+		if err := json.Unmarshal([]byte(trimmed), &list); err != nil {
+			return nil, fmt.Errorf("invalid JSON list provided: %w", err)
+		}
+		return list, nil
+	}
+	list := []any{}
+	if trimmed == "" {
+		return list, nil
+	}
+	for _, item := range strings.Split(trimmed, ",") {
+		list = append(list, strings.TrimSpace(item))
+	}
+	return list, nil
+}
@@ -329,6 +329,47 @@ func TestValidateArgs(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "Parse List Arguments",
+			specs: []Spec{
+				{
+					Name: "comma_list",
+					Type: "list",
+				},
+				{
+					Name: "json_list",
+					Type: "list",
+				},
+				{
+					Name:    "default_list",
+					Type:    "list",
+					Default: new(""),
+				},
+			},
+			argKvStrs: []string{
+				"comma_list=a, b,c",
+				`json_list=[1234,"foo"]`,
+			},
+			expectedResult: map[string]any{
+				"comma_list":   []any{"a", "b", "c"},
+				"json_list":    []any{float64(1234), "foo"},
+				"default_list": []any{},
+			},
+			wantError: false,
+		},
+		{
+			name: "Invalid JSON List Argument",
+			specs: []Spec{
+				{
+					Name: "json_list",
+					Type: "list",
+				},
+			},
+			argKvStrs: []string{
+				`json_list=[1234,`,
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -21,11 +21,21 @@ package backends
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net"
+	"os/exec"
 
+	"github.com/facebookincubator/ttpforge/pkg/processutils"
 	"github.com/spf13/afero"
+	"golang.org/x/crypto/ssh"
 )
 
+// ErrNoProcessFound is returned (wrapped) by FindProcessesByName and
+// FindProcessesByCommandLine when the lookup succeeded but matched no
+// processes. Any other error means the lookup itself failed.
+var ErrNoProcessFound = processutils.ErrNoProcessFound
+
 // RemoteConfig holds the configuration for a remote execution target.
 // It is parsed from the `remote:` field on a TTPForge step.
 type RemoteConfig struct {
@@ -63,9 +73,90 @@ type ExecutionBackend interface {
 	// FindProcessesByName returns PIDs of processes matching the given name.
 	FindProcessesByName(name string) ([]int, error)
 
+	// FindProcessesByCommandLine returns PIDs of processes whose full
+	// command line matches the given regular expression (Go syntax).
+	FindProcessesByCommandLine(pattern string) ([]int, error)
+
 	// ProcessExists checks whether a process with the given PID exists.
 	ProcessExists(pid int) (bool, error)
 
 	// Close releases any resources held by the backend (e.g., SSH connections).
 	Close() error
 }
+
+// Dialer is implemented by backends that can open network connections
+// from the host on which they execute commands. Network checks use it
+// so that, for example, a port on a remote host's loopback interface
+// can be tested through an SSH connection.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// SpawnSpec describes a process to start in the background
+type SpawnSpec struct {
+	// Path is the program to run, which is looked up in
+	// the PATH if it does not contain a path separator
+	Path string
+	Args []string
+	// Argv0 overrides the program name seen by the
+	// process (and by process listings) if it is set
+	Argv0   string
+	Env     []string
+	WorkDir string
+	// Detach starts the process in a new session, so
+	// that it is not tied to TTPForge's terminal
+	Detach bool
+	// OutputFile receives the process's standard output and
+	// standard error - they are discarded if it is empty
+	OutputFile string
+}
+
+// ProcessSpawner is implemented by backends that can start a
+// process without waiting for it to exit, and later kill it
+// along with any processes that it started in turn.
+type ProcessSpawner interface {
+	SpawnProcess(spec SpawnSpec) (int, error)
+	KillProcessTree(pid int) error
+}
+
+// PTYSpec describes a command to run in a pseudo-terminal
+type PTYSpec struct {
+	Path    string
+	Args    []string
+	Env     []string
+	WorkDir string
+	// Rows and Cols set the size of the terminal
+	Rows int
+	Cols int
+}
+
+// PTYSession is a command running in a pseudo-terminal. Reads
+// return the output of the terminal, and writes are typed into it.
+type PTYSession interface {
+	io.ReadWriter
+	// Wait waits for the command to exit and returns its exit code
+	Wait() (int, error)
+	// Close ends the session, along with the command if it is still running
+	Close() error
+}
+
+// PTYStarter is implemented by backends that can run interactive
+// commands, such as those driven by expect steps, in a pseudo-terminal.
+type PTYStarter interface {
+	StartPTY(spec PTYSpec) (PTYSession, error)
+}
+
+// ExitCode returns the exit code carried by an error from RunCommand,
+// and false if the error means that the command did not run to
+// completion (for example, because it could not be started).
+func ExitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	var sshExitErr *ssh.ExitError
+	if errors.As(err, &sshExitErr) {
+		return sshExitErr.ExitStatus(), true
+	}
+	return 0, false
+}
@@ -22,10 +22,13 @@ package backends
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 
 	"github.com/facebookincubator/ttpforge/pkg/processutils"
@@ -104,6 +107,24 @@ func (b *LocalBackend) FindProcessesByName(name string) ([]int, error) {
 	return pids, nil
 }
 
+// FindProcessesByCommandLine returns PIDs of local processes
+// whose command line matches the regular expression.
+func (b *LocalBackend) FindProcessesByCommandLine(pattern string) ([]int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command line regex %q: %w", pattern, err)
+	}
+	pids32, err := processutils.GetPIDsByCommandLine(re)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, len(pids32))
+	for i, p := range pids32 {
+		pids[i] = int(p)
+	}
+	return pids, nil
+}
+
 // ProcessExists checks if a local process exists.
 func (b *LocalBackend) ProcessExists(pid int) (bool, error) {
 	if err := processutils.VerifyPIDExists(pid); err != nil {
@@ -112,6 +133,79 @@ func (b *LocalBackend) ProcessExists(pid int) (bool, error) {
 	return true, nil
 }
 
+// SpawnProcess starts a local process without waiting for it to exit.
+func (b *LocalBackend) SpawnProcess(spec SpawnSpec) (int, error) {
+	// @lint-ignore G204
+	cmd := exec.Command(spec.Path, spec.Args...)
+	if spec.Argv0 != "" {
+		cmd.Args[0] = spec.Argv0
+	}
+	if len(spec.Env) > 0 {
+		cmd.Env = spec.Env
+	}
+	if spec.WorkDir != "" {
+		cmd.Dir = spec.WorkDir
+	}
+	if spec.OutputFile != "" {
+		f, err := os.OpenFile(spec.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return 0, fmt.Errorf("failed to open output file: %w", err)
+		}
+		// the child process holds its own copy of the file descriptor
+		defer f.Close()
+		cmd.Stdout = f
+		cmd.Stderr = f
+	}
+	if spec.Detach {
+		cmd.SysProcAttr = detachedProcAttr()
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	// reap the process when it exits so that it
+	// does not linger as a zombie
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return cmd.Process.Pid, nil
+}
+
+// KillProcessTree kills a local process and all of its descendants.
+// The tree is listed before anything is killed, so that children
+// which are re-parented when their parent dies are still found.
+// If the process leads its own session, as detached processes do,
+// the rest of the session is killed too, which catches descendants
+// that had already been re-parented (for example, by double-forking).
+func (b *LocalBackend) KillProcessTree(pid int) error {
+	sid := detachedSession(pid)
+	tree, err := processutils.GetProcessTree(int32(pid))
+	if err != nil {
+		return fmt.Errorf("failed to list descendants of process %d: %w", pid, err)
+	}
+	var errs []error
+	for _, p := range tree {
+		if err := b.KillProcess(int(p)); err != nil {
+			// processes that exited in the meantime need not be killed
+			if exists, _ := b.ProcessExists(int(p)); exists {
+				errs = append(errs, fmt.Errorf("failed to kill process %d: %w", p, err))
+			}
+		}
+	}
+	if sid != 0 {
+		if err := killSession(sid); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DialContext opens a network connection from the local machine.
+func (b *LocalBackend) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
 // Close is a no-op for the local backend.
 func (b *LocalBackend) Close() error {
 	return nil
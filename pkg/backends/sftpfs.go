@@ -139,6 +139,25 @@ func (fs *SFTPFs) Stat(name string) (os.FileInfo, error) {
 	return fs.client.Stat(name)
 }
 
+// LstatIfPossible returns file info for a path on the remote host
+// without following symbolic links. It implements afero.Lstater.
+func (fs *SFTPFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	info, err := fs.client.Lstat(name)
+	return info, true, err
+}
+
+// ReadlinkIfPossible returns the target of a symbolic link on the
+// remote host. It implements afero.LinkReader.
+func (fs *SFTPFs) ReadlinkIfPossible(name string) (string, error) {
+	return fs.client.ReadLink(name)
+}
+
+// SymlinkIfPossible creates newname as a symbolic link to oldname
+// on the remote host. It implements afero.Symlinker.
+func (fs *SFTPFs) SymlinkIfPossible(oldname, newname string) error {
+	return fs.client.Symlink(oldname, newname)
+}
+
 // Name returns the name of this filesystem.
 func (fs *SFTPFs) Name() string {
 	return "SFTPFs"
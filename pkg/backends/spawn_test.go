@@ -0,0 +1,63 @@
+//go:build unix
+
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backends
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/processutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSpawnScripts runs the scripts used by the SSH backend
+// through the local shell, as the remote host would
+func TestSpawnScripts(t *testing.T) {
+	b := NewLocalBackend()
+	ctx := context.Background()
+	out := filepath.Join(t.TempDir(), "out.log")
+
+	stdout, _, err := b.RunCommand(ctx, "sh", "", []string{"-c", spawnScript, "sh", out, "", "", "sh", "-c", "echo started; sleep 300 & sleep 300"}, nil, "", nil, nil)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout))
+	require.NoError(t, err)
+
+	var tree []int32
+	require.Eventually(t, func() bool {
+		tree, err = processutils.GetProcessTree(int32(pid))
+		return err == nil && len(tree) == 3
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, _, err = b.RunCommand(ctx, "sh", "", []string{"-c", killTreeScript, "sh", strconv.Itoa(pid)}, nil, "", nil, nil)
+	require.NoError(t, err)
+	for _, p := range tree {
+		assert.Eventually(t, func() bool {
+			exists, _ := b.ProcessExists(int(p))
+			return !exists
+		}, 5*time.Second, 50*time.Millisecond, "process %d was not killed", p)
+	}
+}
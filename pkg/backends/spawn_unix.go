@@ -0,0 +1,66 @@
+//go:build unix
+
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backends
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v4/process"
+	"golang.org/x/sys/unix"
+)
+
+// detachedProcAttr starts a process in a new session
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// detachedSession returns the session that pid leads, which is the
+// case for detached processes, or 0 if it does not lead a session
+func detachedSession(pid int) int {
+	sid, err := unix.Getsid(pid)
+	if err != nil || sid != pid {
+		return 0
+	}
+	return sid
+}
+
+// killSession kills every process that remains in the session,
+// including double-forked or re-parented descendants of its
+// leader that are no longer part of the leader's process tree
+func killSession(sid int) error {
+	pids, err := process.Pids()
+	if err != nil {
+		return fmt.Errorf("failed to list processes in session %d: %w", sid, err)
+	}
+	var errs []error
+	for _, p := range pids {
+		if s, err := unix.Getsid(int(p)); err != nil || s != sid {
+			continue
+		}
+		if err := unix.Kill(int(p), unix.SIGKILL); err != nil && !errors.Is(err, unix.ESRCH) {
+			errs = append(errs, fmt.Errorf("failed to kill process %d: %w", p, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+//go:build unix
+
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backends
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/processutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orphanScript starts a process that is immediately re-parented
+// away from the spawned process, as a double-forking daemon would.
+// Only the orphan's command line contains "<marker>-orphan".
+const orphanScript = `(sh -c 'sleep 300; : $0' "$1-orphan" &); sleep 300`
+
+// waitForOrphan returns the PID of the orphan started by orphanScript
+func waitForOrphan(t *testing.T, marker string) int32 {
+	var pids []int32
+	require.Eventually(t, func() bool {
+		var err error
+		pids, err = processutils.GetPIDsByCommandLine(regexp.MustCompile(regexp.QuoteMeta(marker + "-orphan")))
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Len(t, pids, 1)
+	return pids[0]
+}
+
+func TestKillProcessTreeDetached(t *testing.T) {
+	b := NewLocalBackend()
+	marker := "ttpforge-detached-" + strconv.Itoa(time.Now().Nanosecond())
+	pid, err := b.SpawnProcess(SpawnSpec{
+		Path:   "sh",
+		Args:   []string{"-c", orphanScript, "sh", marker},
+		Detach: true,
+	})
+	require.NoError(t, err)
+	orphan := waitForOrphan(t, marker)
+
+	require.NoError(t, b.KillProcessTree(pid))
+	for _, p := range []int{int(orphan), pid} {
+		assert.Eventually(t, func() bool {
+			exists, _ := b.ProcessExists(p)
+			return !exists
+		}, 5*time.Second, 50*time.Millisecond, "process %d was not killed", p)
+	}
+}
+
+func TestKillTreeScriptDetached(t *testing.T) {
+	b := NewLocalBackend()
+	ctx := context.Background()
+	marker := "ttpforge-remote-detached-" + strconv.Itoa(time.Now().Nanosecond())
+
+	stdout, _, err := b.RunCommand(ctx, "sh", "", []string{"-c", spawnScript, "sh", "/dev/null", "1", "", "sh", "-c", orphanScript, "sh", marker}, nil, "", nil, nil)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout))
+	require.NoError(t, err)
+	orphan := waitForOrphan(t, marker)
+
+	_, _, err = b.RunCommand(ctx, "sh", "", []string{"-c", killTreeScript, "sh", strconv.Itoa(pid)}, nil, "", nil, nil)
+	require.NoError(t, err)
+	for _, p := range []int{int(orphan), pid} {
+		assert.Eventually(t, func() bool {
+			exists, _ := b.ProcessExists(p)
+			return !exists
+		}, 5*time.Second, 50*time.Millisecond, "process %d was not killed", p)
+	}
+}
@@ -22,10 +22,12 @@ package backends
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -215,34 +217,7 @@ func (b *SSHBackend) RunCommand(ctx context.Context, name string, stdin string,
 	}
 	defer session.Close()
 
-	// Build shell-specific command parts using the configured shell builder.
-	var cmdParts []string
-
-	for _, e := range env {
-		parts := strings.SplitN(e, "=", 2)
-		if len(parts) == 2 {
-			cmdParts = append(cmdParts, b.shell.setEnv(parts[0], parts[1]))
-		}
-	}
-
-	if workDir != "" {
-		cmdParts = append(cmdParts, b.shell.changeDir(workDir))
-	}
-
-	// Build the actual command
-	var command string
-	if len(args) > 0 {
-		quotedArgs := make([]string, len(args))
-		for i, arg := range args {
-			quotedArgs[i] = b.shell.quoteArg(arg)
-		}
-		command = name + " " + strings.Join(quotedArgs, " ")
-	} else {
-		command = name
-	}
-	cmdParts = append(cmdParts, command)
-
-	fullCmd := b.shell.chainCommands(cmdParts)
+	fullCmd := b.buildCommand(name, args, env, workDir)
 
 	if stdin != "" {
 		session.Stdin = strings.NewReader(stdin)
@@ -276,6 +251,38 @@ func (b *SSHBackend) RunCommand(ctx context.Context, name string, stdin string,
 	}
 }
 
+// buildCommand builds the command line that runs name with args, env
+// and workDir, using the configured shell builder.
+func (b *SSHBackend) buildCommand(name string, args []string, env []string, workDir string) string {
+	var cmdParts []string
+
+	for _, e := range env {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) == 2 {
+			cmdParts = append(cmdParts, b.shell.setEnv(parts[0], parts[1]))
+		}
+	}
+
+	if workDir != "" {
+		cmdParts = append(cmdParts, b.shell.changeDir(workDir))
+	}
+
+	// Build the actual command
+	var command string
+	if len(args) > 0 {
+		quotedArgs := make([]string, len(args))
+		for i, arg := range args {
+			quotedArgs[i] = b.shell.quoteArg(arg)
+		}
+		command = name + " " + strings.Join(quotedArgs, " ")
+	} else {
+		command = name
+	}
+	cmdParts = append(cmdParts, command)
+
+	return b.shell.chainCommands(cmdParts)
+}
+
 // ShellType returns the configured shell type for this backend.
 func (b *SSHBackend) ShellType() string {
 	return b.shellType
@@ -341,9 +348,14 @@ func (b *SSHBackend) FindProcessesByName(name string) ([]int, error) {
 		args = []string{"-x", name}
 	}
 
-	stdout, _, err := b.RunCommand(ctx, cmdName, "", args, nil, "", nil, nil)
+	stdout, stderr, err := b.RunCommand(ctx, cmdName, "", args, nil, "", nil, nil)
 	if err != nil {
-		return nil, fmt.Errorf("no process found with name: %s", name)
+		// pgrep exits with status 1 when nothing matches
+		var exitErr *ssh.ExitError
+		if cmdName == "pgrep" && errors.As(err, &exitErr) && exitErr.ExitStatus() == 1 {
+			return nil, fmt.Errorf("%w with name: %s", ErrNoProcessFound, name)
+		}
+		return nil, fmt.Errorf("failed to look up process %q on remote host: %s: %w", name, stderr, err)
 	}
 
 	var pids []int
@@ -368,6 +380,57 @@ func (b *SSHBackend) FindProcessesByName(name string) ([]int, error) {
 		}
 		pids = append(pids, pid)
 	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("%w with name: %s", ErrNoProcessFound, name)
+	}
+	return pids, nil
+}
+
+// FindProcessesByCommandLine returns PIDs of processes on the remote host
+// whose command line matches the regular expression. The process list is
+// fetched from the remote host and matched locally, so the pattern uses
+// Go regex syntax regardless of the remote shell.
+func (b *SSHBackend) FindProcessesByCommandLine(pattern string) ([]int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command line regex %q: %w", pattern, err)
+	}
+
+	ctx := context.Background()
+	var cmdName string
+	var args []string
+
+	switch b.shellType {
+	case "powershell":
+		cmdName = "powershell"
+		args = []string{"-Command", `Get-CimInstance Win32_Process | ForEach-Object { "$($_.ProcessId) $($_.CommandLine)" }`}
+	case "cmd":
+		return nil, fmt.Errorf("finding processes by command line is not supported with the cmd shell")
+	default:
+		cmdName = "ps"
+		args = []string{"-eo", "pid=,args="}
+	}
+
+	stdout, _, err := b.RunCommand(ctx, cmdName, "", args, nil, "", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes on remote host: %w", err)
+	}
+
+	var pids []int
+	for line := range strings.SplitSeq(stdout, "\n") {
+		pidStr, cmdline, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found || !re.MatchString(strings.TrimSpace(cmdline)) {
+			continue
+		}
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("%w with command line matching: %s", ErrNoProcessFound, pattern)
+	}
 	return pids, nil
 }
 
@@ -393,6 +456,160 @@ func (b *SSHBackend) ProcessExists(pid int) (bool, error) {
 	return err == nil, nil
 }
 
+// spawnScript starts a process in the background on a POSIX host and
+// prints its PID. Its arguments are the output file, whether to detach,
+// the argv[0] override and then the command itself. nohup, setsid and
+// bash all exec the next program, so $! is the PID of the command.
+const spawnScript = `out=$1 detach=$2 argv0=$3
+shift 3
+if [ -n "$argv0" ]; then set -- bash -c 'exec -a "$0" "$@"' "$argv0" "$@"; fi
+if [ -n "$detach" ]; then set -- setsid "$@"; fi
+nohup "$@" >"$out" 2>&1 </dev/null &
+echo $!`
+
+// SpawnProcess starts a process on the remote host without waiting for
+// it to exit. It is only supported for hosts with a POSIX shell.
+func (b *SSHBackend) SpawnProcess(spec SpawnSpec) (int, error) {
+	if _, ok := b.shell.(*posixShell); !ok {
+		return 0, fmt.Errorf("spawning processes is not supported for %s shells", b.shellType)
+	}
+	out := spec.OutputFile
+	if out == "" {
+		out = "/dev/null"
+	}
+	detach := ""
+	if spec.Detach {
+		detach = "1"
+	}
+	args := append([]string{"-c", spawnScript, "sh", out, detach, spec.Argv0, spec.Path}, spec.Args...)
+	stdout, stderr, err := b.RunCommand(context.Background(), "sh", "", args, spec.Env, spec.WorkDir, nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to spawn remote process: %s: %w", stderr, err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PID of remote process from %q: %w", stdout, err)
+	}
+	return pid, nil
+}
+
+// killTreeScript stops a process so that it cannot start any more
+// children, kills its descendants depth-first and then kills it.
+// If the process leads its own session, as detached processes do,
+// whatever remains of the session is killed too.
+const killTreeScript = `kill_tree() {
+  kill -STOP "$1" 2>/dev/null
+  for child in $(ps -A -o pid= -o ppid= | awk -v p="$1" '$2 == p { print $1 }'); do
+    kill_tree "$child"
+  done
+  kill -KILL "$1" 2>/dev/null
+}
+sid=$(ps -o sid= -p "$1" 2>/dev/null | tr -d ' ')
+kill_tree "$1"
+status=$?
+if [ "$sid" = "$1" ]; then
+  for member in $(ps -A -o pid= -o sid= | awk -v s="$sid" '$2 == s { print $1 }'); do
+    kill -KILL "$member" 2>/dev/null
+  done
+fi
+exit $status`
+
+// KillProcessTree kills a process on the remote host and all of its descendants.
+func (b *SSHBackend) KillProcessTree(pid int) error {
+	var cmdName string
+	var args []string
+	switch b.shellType {
+	case "powershell", "cmd":
+		cmdName = "taskkill"
+		args = []string{"/T", "/F", "/PID", strconv.Itoa(pid)}
+	default:
+		cmdName = "sh"
+		args = []string{"-c", killTreeScript, "sh", strconv.Itoa(pid)}
+	}
+	_, stderr, err := b.RunCommand(context.Background(), cmdName, "", args, nil, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to kill remote process tree %d: %s: %w", pid, stderr, err)
+	}
+	return nil
+}
+
+// sshPTYSession is a command running in a pseudo-terminal
+// that was allocated on the remote host
+type sshPTYSession struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  io.Reader
+}
+
+func (s *sshPTYSession) Read(p []byte) (int, error) {
+	return s.stdout.Read(p)
+}
+
+func (s *sshPTYSession) Write(p []byte) (int, error) {
+	return s.stdin.Write(p)
+}
+
+func (s *sshPTYSession) Wait() (int, error) {
+	err := s.session.Wait()
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	return 0, err
+}
+
+func (s *sshPTYSession) Close() error {
+	err := s.session.Close()
+	if errors.Is(err, io.EOF) {
+		// the session had already ended
+		return nil
+	}
+	return err
+}
+
+// StartPTY runs a command in a pseudo-terminal allocated on the remote host.
+func (b *SSHBackend) StartPTY(spec PTYSpec) (PTYSession, error) {
+	session, err := b.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 38400,
+		ssh.TTY_OP_OSPEED: 38400,
+	}
+	if err := session.RequestPty("xterm", spec.Rows, spec.Cols, modes); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to allocate remote PTY: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.Start(b.buildCommand(spec.Path, spec.Args, spec.Env, spec.WorkDir)); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start remote command: %w", err)
+	}
+	return &sshPTYSession{session: session, stdin: stdin, stdout: stdout}, nil
+}
+
+// DialContext opens a network connection from the remote host by
+// forwarding it over the SSH connection. Only TCP is supported.
+func (b *SSHBackend) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("network %q is not supported over SSH", network)
+	}
+	return b.client.DialContext(ctx, network, addr)
+}
+
 // Close closes the SFTP, SSH, bastion, and agent connections.
 func (b *SSHBackend) Close() error {
 	var errs []string
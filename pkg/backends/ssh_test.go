@@ -0,0 +1,183 @@
+//go:build unix
+
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package backends
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/creack/pty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// ptyServer is an SSH server that runs the commands of sessions
+// that request a PTY in local pseudo-terminals
+type ptyServer struct {
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu   sync.Mutex
+	cols uint32
+	rows uint32
+}
+
+func newPTYServer(t *testing.T) *ptyServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	s := &ptyServer{listener: listener, config: config}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handleConn(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ptyServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleSession(channel, requests)
+	}
+}
+
+func (s *ptyServer) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	hasPTY := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			// string term, uint32 cols, uint32 rows, ...
+			termLen := binary.BigEndian.Uint32(req.Payload)
+			dims := req.Payload[4+termLen:]
+			s.mu.Lock()
+			s.cols = binary.BigEndian.Uint32(dims)
+			s.rows = binary.BigEndian.Uint32(dims[4:])
+			s.mu.Unlock()
+			hasPTY = true
+			req.Reply(true, nil)
+		case "exec":
+			if !hasPTY {
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			command := string(req.Payload[4:])
+			cmd := exec.Command("sh", "-c", command)
+			ptm, err := pty.Start(cmd)
+			if err != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(ptm, channel) }()
+			_, _ = io.Copy(channel, ptm)
+			status := 0
+			var exitErr *exec.ExitError
+			if err := cmd.Wait(); errors.As(err, &exitErr) {
+				status = exitErr.ExitCode()
+			}
+			_, _ = channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, uint32(status)))
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func TestSSHStartPTY(t *testing.T) {
+	server := newPTYServer(t)
+	client, err := ssh.Dial("tcp", server.listener.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // #nosec G106 - test server
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	b := &SSHBackend{client: client, shell: &posixShell{}, shellType: "posix"}
+
+	workDir := t.TempDir()
+	session, err := b.StartPTY(PTYSpec{
+		Path:    "sh",
+		Args:    []string{"-c", `printf 'Name: '; read name; echo "Hi $name from $(pwd) with $GREETING"; exit 4`},
+		Env:     []string{"GREETING=it's me"},
+		WorkDir: workDir,
+		Rows:    30,
+		Cols:    120,
+	})
+	require.NoError(t, err)
+	defer session.Close()
+
+	reader := bufio.NewReader(session)
+	var prompt strings.Builder
+	for !strings.HasSuffix(prompt.String(), "Name: ") {
+		b, err := reader.ReadByte()
+		require.NoError(t, err)
+		prompt.WriteByte(b)
+	}
+	_, err = io.WriteString(session, "Bob\n")
+	require.NoError(t, err)
+
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	output := string(rest)
+	assert.Contains(t, output, "Bob\r\n", "the remote terminal should echo input")
+	assert.Contains(t, output, "Hi Bob from "+workDir+" with it's me\r\n")
+
+	exitCode, err := session.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 4, exitCode)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, uint32(120), server.cols)
+	assert.Equal(t, uint32(30), server.rows)
+}
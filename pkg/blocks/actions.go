@@ -22,6 +22,8 @@ package blocks
 import (
 	"fmt"
 	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
 )
 
 // DefaultExecutionTimeout is the default timeout for step execution.
@@ -50,8 +52,9 @@ type Action interface {
 // interface methods.
 // Every new action type should embed this struct
 type actionDefaults struct {
-	Description string `yaml:"description,omitempty"`
-	OutputVar   string `yaml:"outputvar,omitempty"`
+	Description string                  `yaml:"description,omitempty"`
+	OutputVar   string                  `yaml:"outputvar,omitempty"`
+	Outputs     map[string]outputs.Spec `yaml:"outputs,omitempty"`
 }
 
 // timedActionDefaults adds opt-in per-step deadline fields. Embed this only
@@ -119,6 +122,37 @@ func (ad *actionDefaults) IsNil() bool {
 	return false
 }
 
+// extractOutputs parses the outputs specified in the action's
+// `outputs:` section from the stdout of the provided result and
+// adds them to result.Outputs and result.TypedOutputs, alongside
+// any built-in outputs that the action has already populated.
+// If a specified output has the same name as a built-in output,
+// the specified output wins. Built-in outputs without a typed
+// value are recorded in result.TypedOutputs as strings.
+// Every action should call this at the end of a successful Execute.
+func (ad *actionDefaults) extractOutputs(result *ActResult) error {
+	parsedStrs, parsedVals, err := outputs.ParseTyped(ad.Outputs, result.Stdout)
+	if err != nil {
+		return err
+	}
+	if result.Outputs == nil {
+		result.Outputs = make(map[string]string, len(parsedStrs))
+	}
+	if result.TypedOutputs == nil {
+		result.TypedOutputs = make(map[string]any, len(result.Outputs)+len(parsedVals))
+	}
+	for name, value := range result.Outputs {
+		if _, ok := result.TypedOutputs[name]; !ok {
+			result.TypedOutputs[name] = value
+		}
+	}
+	for name, value := range parsedStrs {
+		result.Outputs[name] = value
+		result.TypedOutputs[name] = parsedVals[name]
+	}
+	return nil
+}
+
 // GetDescription returns the description field from the action
 func (ad *actionDefaults) GetDescription() string {
 	return ad.Description
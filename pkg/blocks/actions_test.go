@@ -23,10 +23,22 @@ import (
 	"testing"
 	"time"
 
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// failingOutputs returns output specs whose extraction fails for
+// actions that print nothing, for testing that such actions undo
+// their changes when they fail after making them
+func failingOutputs() actionDefaults {
+	return actionDefaults{
+		Outputs: map[string]outputs.Spec{
+			"missing": {Filters: []outputs.Filter{&outputs.JSONFilter{Path: "missing"}}},
+		},
+	}
+}
+
 func TestResolveTimeout(t *testing.T) {
 	testCases := []struct {
 		name          string
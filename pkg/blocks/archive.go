@@ -0,0 +1,252 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/facebookincubator/ttpforge/pkg/archives"
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// ArchiveStep packs files and directories into a zip or tar
+// archive, optionally protecting zip archives with a password.
+// Its intended use is simulating the staging of collected
+// data prior to exfiltration.
+type ArchiveStep struct {
+	actionDefaults `yaml:",inline"`
+	Path           string   `yaml:"archive,omitempty"`
+	Sources        []string `yaml:"sources,omitempty"`
+	Format         string   `yaml:"format,omitempty"`
+	Include        []string `yaml:"include,omitempty"`
+	Exclude        []string `yaml:"exclude,omitempty"`
+	Password       string   `yaml:"password,omitempty"`
+	Overwrite      bool     `yaml:"overwrite,omitempty"`
+	FileSystem     afero.Fs `yaml:"-,omitempty"`
+
+	// created records the archive and the directories
+	// made for it so that the default cleanup action
+	// can remove them
+	created *archives.Extracted
+}
+
+// NewArchiveStep creates a new ArchiveStep instance and returns a pointer to it.
+func NewArchiveStep() *ArchiveStep {
+	return &ArchiveStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *ArchiveStep) IsNil() bool {
+	switch s.Path {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *ArchiveStep) Validate(_ TTPExecutionContext) error {
+	if s.Path == "" {
+		return fmt.Errorf("archive field cannot be empty")
+	}
+	if len(s.Sources) == 0 {
+		return fmt.Errorf("sources field cannot be empty")
+	}
+	// the format is inferred from the path at execution time
+	// if it is not set, since the path may be templated
+	if s.Format != "" {
+		format, err := archives.ResolveFormat(s.Format, s.Path)
+		if err != nil {
+			return err
+		}
+		if s.Password != "" && format != archives.FormatZip {
+			return fmt.Errorf("password is only supported for zip archives")
+		}
+	}
+	return s.filter().Validate()
+}
+
+func (s *ArchiveStep) filter() archives.Filter {
+	return archives.Filter{Include: s.Include, Exclude: s.Exclude}
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *ArchiveStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Path, err = execCtx.templateStep(s.Path)
+	if err != nil {
+		return err
+	}
+	for i, source := range s.Sources {
+		s.Sources[i], err = execCtx.templateStep(source)
+		if err != nil {
+			return err
+		}
+	}
+	s.Password, err = execCtx.templateStep(s.Password)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *ArchiveStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	logging.L().Infof("Creating archive %v", s.Path)
+	fsys, err := execCtx.getFs(s.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := fileutils.ExpandPath(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	format, err := archives.ResolveFormat(s.Format, archivePath)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := afero.Exists(fsys, archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if exists && !s.Overwrite {
+		return nil, fmt.Errorf("path %v already exists and overwrite was not set", archivePath)
+	}
+
+	var sources []string
+	for _, source := range s.Sources {
+		expanded, err := fileutils.ExpandPath(source)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, expanded)
+	}
+
+	// an archive that already existed is only ever
+	// overwritten, never removed by cleanup
+	created := &archives.Extracted{Files: []string{archivePath}}
+	if exists {
+		created.Replaced = created.Files
+	}
+	created.Dirs, err = archives.MkdirAll(fsys, filepath.Dir(archivePath))
+	if err != nil {
+		return nil, rollbackArchive(fsys, created, err)
+	}
+	entries, err := archives.Create(fsys, archivePath, sources, archives.CreateOptions{
+		Format:   format,
+		Password: s.Password,
+		Filter:   s.filter(),
+	})
+	if err != nil {
+		return nil, rollbackArchive(fsys, created, err)
+	}
+	s.created = created
+	logging.L().Infof("Archived %d file(s) into %v", len(entries), archivePath)
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"path":  archivePath,
+			"files": strings.Join(entries, "\n"),
+		},
+		TypedOutputs: map[string]any{
+			"files": stringList(entries),
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rollbackArchive removes a partially written archive and the
+// directories created for it, as failed steps are not cleaned up
+func rollbackArchive(fsys afero.Fs, created *archives.Extracted, cause error) error {
+	if err := removeWritten(fsys, created); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to remove partial archive: %w", err))
+	}
+	return cause
+}
+
+// GetDefaultCleanupAction will instruct the calling code
+// to remove the archive created by this action
+func (s *ArchiveStep) GetDefaultCleanupAction() Action {
+	return &archiveCleanupAction{step: s}
+}
+
+// archiveCleanupAction removes the archive that an ArchiveStep
+// created along with any directories that were made for it,
+// leaving an archive that was overwritten in place
+type archiveCleanupAction struct {
+	actionDefaults
+	step *ArchiveStep
+}
+
+// IsNil is not needed here, as this is not a user-accessible step type
+func (a *archiveCleanupAction) IsNil() bool {
+	return false
+}
+
+// Validate is not needed here, as this is not a user-accessible step type
+func (a *archiveCleanupAction) Validate(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Template is not needed here, as this is not a user-accessible step type
+func (a *archiveCleanupAction) Template(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Execute removes the archive, followed by any directories
+// that were created for it, as long as they are now empty
+func (a *archiveCleanupAction) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	if a.step.created == nil {
+		logging.L().Info("No archive was created - skipping cleanup")
+		return &ActResult{}, nil
+	}
+	fsys, err := execCtx.getFs(a.step.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+	if err := removeWritten(fsys, a.step.created); err != nil {
+		return nil, err
+	}
+	return &ActResult{}, nil
+}
+
+// stringList converts a list of strings into the
+// representation used for list-valued typed outputs
+func stringList(values []string) []any {
+	list := make([]any, len(values))
+	for i, value := range values {
+		list[i] = value
+	}
+	return list
+}
@@ -0,0 +1,251 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"testing"
+
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestArchiveStepValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Valid Zip",
+			content: `archive: /tmp/staged.zip
+sources:
+  - /home/user/Documents`,
+		},
+		{
+			name:      "Missing Sources",
+			content:   `archive: /tmp/staged.zip`,
+			wantError: true,
+		},
+		{
+			name: "Invalid Format",
+			content: `archive: /tmp/staged.rar
+format: rar
+sources:
+  - /home/user/Documents`,
+			wantError: true,
+		},
+		{
+			name: "Password With Tar",
+			content: `archive: /tmp/staged.tar
+format: tar
+password: infected
+sources:
+  - /home/user/Documents`,
+			wantError: true,
+		},
+		{
+			name: "Invalid Glob",
+			content: `archive: /tmp/staged.zip
+include:
+  - "[docx"
+sources:
+  - /home/user/Documents`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step ArchiveStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestArchiveStepExecute(t *testing.T) {
+	testCases := []struct {
+		name          string
+		step          *ArchiveStep
+		fsysContents  map[string][]byte
+		expectedFiles []any
+		wantError     bool
+	}{
+		{
+			name: "Zip With Include",
+			step: &ArchiveStep{
+				Path:    "/tmp/staged.zip",
+				Sources: []string{"/home/user/Documents"},
+				Include: []string{"*.docx"},
+			},
+			expectedFiles: []any{"Documents/report.docx"},
+		},
+		{
+			name: "Password Protected Zip",
+			step: &ArchiveStep{
+				Path:     "/tmp/staged.zip",
+				Sources:  []string{"/home/user/Documents/notes.txt"},
+				Password: "infected",
+			},
+			expectedFiles: []any{"notes.txt"},
+		},
+		{
+			name: "Tar Gz With Exclude",
+			step: &ArchiveStep{
+				Path:    "/tmp/staged.tgz",
+				Sources: []string{"/home/user/Documents"},
+				Exclude: []string{"*.txt"},
+			},
+			expectedFiles: []any{"Documents/report.docx"},
+		},
+		{
+			name: "Already Exists (No Overwrite)",
+			step: &ArchiveStep{
+				Path:    "/tmp/existing.zip",
+				Sources: []string{"/home/user/Documents"},
+			},
+			fsysContents: map[string][]byte{
+				"/tmp/existing.zip": []byte("whoops"),
+			},
+			wantError: true,
+		},
+		{
+			name: "Cannot Infer Format",
+			step: &ArchiveStep{
+				Path:    "/tmp/staged",
+				Sources: []string{"/home/user/Documents"},
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents := map[string][]byte{
+				"/home/user/Documents/report.docx": []byte("quarterly report"),
+				"/home/user/Documents/notes.txt":   []byte("some notes"),
+			}
+			for name, data := range tc.fsysContents {
+				contents[name] = data
+			}
+			fsys, err := testutils.MakeAferoTestFs(contents)
+			require.NoError(t, err)
+			tc.step.FileSystem = fsys
+
+			execCtx := NewTTPExecutionContext()
+			require.NoError(t, tc.step.Template(execCtx))
+			result, err := tc.step.Execute(execCtx)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.step.Path, result.Outputs["path"])
+			assert.Equal(t, tc.expectedFiles, result.TypedOutputs["files"])
+			exists, err := afero.Exists(fsys, tc.step.Path)
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestArchiveStepDefaultCleanup(t *testing.T) {
+	testCases := []struct {
+		name          string
+		step          *ArchiveStep
+		fsysContents  map[string][]byte
+		expectRemoved []string
+		expectKept    []string
+	}{
+		{
+			name: "New Directories",
+			step: &ArchiveStep{
+				Path:    "/tmp/staging/exfil/staged.zip",
+				Sources: []string{"/home/user/Documents"},
+			},
+			expectRemoved: []string{"/tmp/staging/exfil/staged.zip", "/tmp/staging"},
+			expectKept:    []string{"/tmp"},
+		},
+		{
+			name: "Overwritten Archive",
+			step: &ArchiveStep{
+				Path:      "/tmp/existing.zip",
+				Sources:   []string{"/home/user/Documents"},
+				Overwrite: true,
+			},
+			fsysContents: map[string][]byte{
+				"/tmp/existing.zip": []byte("the user's archive"),
+			},
+			expectKept: []string{"/tmp/existing.zip"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents := map[string][]byte{
+				"/home/user/Documents/report.docx": []byte("quarterly report"),
+				"/tmp/unrelated.txt":               []byte("keeps /tmp around"),
+			}
+			for name, data := range tc.fsysContents {
+				contents[name] = data
+			}
+			fsys, err := testutils.MakeAferoTestFs(contents)
+			require.NoError(t, err)
+			tc.step.FileSystem = fsys
+
+			execCtx := NewTTPExecutionContext()
+			require.NoError(t, tc.step.Template(execCtx))
+			_, err = tc.step.Execute(execCtx)
+			require.NoError(t, err)
+
+			_, err = tc.step.GetDefaultCleanupAction().Execute(execCtx)
+			require.NoError(t, err)
+			for _, path := range tc.expectRemoved {
+				exists, err := afero.Exists(fsys, path)
+				require.NoError(t, err)
+				assert.False(t, exists, "%v should have been removed", path)
+			}
+			for _, path := range tc.expectKept {
+				exists, err := afero.Exists(fsys, path)
+				require.NoError(t, err)
+				assert.True(t, exists, "%v should have been kept", path)
+			}
+		})
+	}
+}
+
+func TestArchiveStepCleanupBeforeExecute(t *testing.T) {
+	step := &ArchiveStep{
+		Path:       "/tmp/staged.zip",
+		Sources:    []string{"/home/user/Documents"},
+		FileSystem: afero.NewMemMapFs(),
+	}
+	_, err := step.GetDefaultCleanupAction().Execute(NewTTPExecutionContext())
+	assert.NoError(t, err)
+}
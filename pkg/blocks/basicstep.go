@@ -25,17 +25,15 @@ import (
 	"strings"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
-	"github.com/facebookincubator/ttpforge/pkg/outputs"
 )
 
 // BasicStep is a type that represents a basic execution step.
 type BasicStep struct {
 	actionDefaults      `yaml:",inline"`
 	timedActionDefaults `yaml:",inline"`
-	ExecutorName        string                  `yaml:"executor,omitempty"`
-	Inline              string                  `yaml:"inline,flow"`
-	Environment         map[string]string       `yaml:"env,omitempty"`
-	Outputs             map[string]outputs.Spec `yaml:"outputs,omitempty"`
+	ExecutorName        string            `yaml:"executor,omitempty"`
+	Inline              string            `yaml:"inline,flow"`
+	Environment         map[string]string `yaml:"env,omitempty"`
 }
 
 // NewBasicStep creates a new BasicStep instance with an initialized Act struct.
@@ -101,8 +99,7 @@ func (b *BasicStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	result.Outputs, err = outputs.Parse(b.Outputs, result.Stdout)
-	if err != nil {
+	if err := b.extractOutputs(result); err != nil {
 		return nil, err
 	}
 	// Send stdout to the output variable
@@ -0,0 +1,438 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// BeaconDNS is the value of the beacon field for DNS check-ins
+const BeaconDNS = "dns"
+
+// DefaultBeaconInterval is the default time between check-ins
+const DefaultBeaconInterval = 60 * time.Second
+
+// minBeaconCheckInTimeout is the shortest time that an HTTP check-in
+// is given to complete, even if little of the duration remains
+const minBeaconCheckInTimeout = time.Second
+
+// uriPlaceholderRegex matches the placeholders that
+// are replaced in each check-in's URI
+var uriPlaceholderRegex = regexp.MustCompile(`\{(seq|rand:(\d+))\}`)
+
+const uriRandomAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// BeaconStep performs periodic check-ins with a command and control
+// server over HTTP(S) or DNS (T1071), with randomized timing and
+// request contents, so that network detections that key on beaconing
+// can be exercised with realistic traffic.
+type BeaconStep struct {
+	actionDefaults `yaml:",inline"`
+	HTTPTLSConfig  `yaml:",inline"`
+	Target         string        `yaml:"beacon,omitempty"`
+	DNS            *DNSTarget    `yaml:"dns,omitempty"`
+	Count          int           `yaml:"count,omitempty"`
+	Duration       string        `yaml:"duration,omitempty"`
+	Interval       string        `yaml:"interval,omitempty"`
+	Jitter         int           `yaml:"jitter,omitempty"`
+	Method         string        `yaml:"method,omitempty"`
+	URIs           []string      `yaml:"uris,omitempty"`
+	UserAgents     []string      `yaml:"user_agents,omitempty"`
+	Headers        []*HTTPHeader `yaml:"headers,omitempty"`
+	PayloadSize    string        `yaml:"payload_size,omitempty"`
+	Proxy          string        `yaml:"proxy,omitempty"`
+	FailOnError    bool          `yaml:"fail_on_error,omitempty"`
+
+	duration   time.Duration
+	interval   time.Duration
+	minPayload int
+	maxPayload int
+}
+
+// NewBeaconStep creates a new BeaconStep instance and returns a pointer to it.
+func NewBeaconStep() *BeaconStep {
+	return &BeaconStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *BeaconStep) IsNil() bool {
+	switch s.Target {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *BeaconStep) Validate(execCtx TTPExecutionContext) error {
+	if s.Target == BeaconDNS {
+		if s.DNS == nil {
+			return fmt.Errorf("dns must be specified for DNS check-ins")
+		}
+		if err := s.DNS.Validate(); err != nil {
+			return err
+		}
+		if s.Method != "" || len(s.URIs) > 0 || len(s.UserAgents) > 0 || len(s.Headers) > 0 || s.Proxy != "" || s.HTTPTLSConfig.isSet() {
+			return fmt.Errorf("HTTP settings cannot be used for DNS check-ins")
+		}
+	} else {
+		if s.DNS != nil {
+			return fmt.Errorf("dns can only be used with `beacon: %v`", BeaconDNS)
+		}
+		if !execCtx.containsStepTemplating(s.Target) {
+			uri, err := url.Parse(s.Target)
+			if err != nil {
+				return err
+			}
+			if uri.Host == "" || (uri.Scheme != "http" && uri.Scheme != "https") {
+				return fmt.Errorf("beacon must be an HTTP(S) URL or %q, got %v", BeaconDNS, s.Target)
+			}
+		}
+		if err := s.HTTPTLSConfig.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.Count < 0 {
+		return fmt.Errorf("count cannot be negative")
+	}
+	var err error
+	if s.Duration != "" {
+		if s.duration, err = time.ParseDuration(s.Duration); err != nil || s.duration <= 0 {
+			return fmt.Errorf("invalid duration %q", s.Duration)
+		}
+	}
+	if s.Count == 0 && s.Duration == "" {
+		return fmt.Errorf("at least one of count or duration must be specified")
+	}
+	s.interval = DefaultBeaconInterval
+	if s.Interval != "" {
+		if s.interval, err = time.ParseDuration(s.Interval); err != nil || s.interval <= 0 {
+			return fmt.Errorf("invalid interval %q", s.Interval)
+		}
+	}
+	if s.Jitter < 0 || s.Jitter > 100 {
+		return fmt.Errorf("jitter must be a percentage between 0 and 100")
+	}
+
+	switch strings.ToUpper(s.Method) {
+	case "", http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("method must be GET, POST or PUT, got %v", s.Method)
+	}
+	for _, uri := range s.URIs {
+		if !strings.HasPrefix(uri, "/") {
+			return fmt.Errorf("uri %q must start with /", uri)
+		}
+		for _, match := range uriPlaceholderRegex.FindAllStringSubmatch(uri, -1) {
+			if n, _ := strconv.Atoi(match[2]); match[2] != "" && (n < 1 || n > 64) {
+				return fmt.Errorf("the length in %v must be between 1 and 64", match[0])
+			}
+		}
+	}
+	for _, header := range s.Headers {
+		if header.Field == "" || header.Value == "" {
+			return fmt.Errorf("broken HTTP header %s: %s", header.Value, header.Field)
+		}
+	}
+	if s.minPayload, s.maxPayload, err = parseSizeRange(s.PayloadSize); err != nil {
+		return err
+	}
+	if s.maxPayload > 0 && strings.EqualFold(s.Method, http.MethodGet) {
+		return fmt.Errorf("payload_size cannot be used with GET requests")
+	}
+	return nil
+}
+
+// parseSizeRange parses a size such as "128", or a range of sizes such as "64-512"
+func parseSizeRange(size string) (int, int, error) {
+	if size == "" {
+		return 0, 0, nil
+	}
+	minStr, maxStr, isRange := strings.Cut(size, "-")
+	if !isRange {
+		maxStr = minStr
+	}
+	minSize, err := strconv.Atoi(strings.TrimSpace(minStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid payload_size %q", size)
+	}
+	maxSize, err := strconv.Atoi(strings.TrimSpace(maxStr))
+	if err != nil || minSize < 0 || maxSize < minSize {
+		return 0, 0, fmt.Errorf("invalid payload_size %q", size)
+	}
+	return minSize, maxSize, nil
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *BeaconStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Target, err = execCtx.templateStep(s.Target)
+	if err != nil {
+		return err
+	}
+	s.Proxy, err = execCtx.templateStep(s.Proxy)
+	if err != nil {
+		return err
+	}
+	for _, header := range s.Headers {
+		header.Value, err = execCtx.templateStep(header.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beaconRecord describes a single check-in
+type beaconRecord struct {
+	seq     int
+	time    time.Time
+	target  string
+	status  any
+	payload int
+	err     error
+}
+
+func (r *beaconRecord) output() map[string]any {
+	out := map[string]any{
+		"seq":     r.seq,
+		"time":    r.time.UTC().Format(time.RFC3339Nano),
+		"target":  r.target,
+		"payload": r.payload,
+	}
+	if r.err != nil {
+		out["error"] = r.err.Error()
+	} else {
+		out["status"] = r.status
+	}
+	return out
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *BeaconStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	if execCtx.Backend != nil {
+		return nil, fmt.Errorf("beacon action is not yet supported with remote: execution")
+	}
+
+	start := time.Now()
+	var checkIn func(record *beaconRecord, payload []byte)
+	if s.Target == BeaconDNS {
+		// every check-in must fit in a query name, even with the largest payload
+		if name := s.dnsQueryName(maxDNSIndex, make([]byte, s.maxPayload)); len(name) > maxDNSNameLength {
+			return nil, fmt.Errorf("payload_size %v is too large to fit in a query for %v", s.PayloadSize, s.DNS.Domain)
+		}
+		resolver := s.DNS.resolverAddress()
+		logging.L().Infof("Beaconing to %v through DNS queries to %v", s.DNS.Domain, resolver)
+		checkIn = func(record *beaconRecord, payload []byte) {
+			record.target = s.dnsQueryName(record.seq, payload)
+			var rcode dnsmessage.RCode
+			rcode, record.err = sendDNSQuery(resolver, record.target)
+			if record.err == nil {
+				record.status = strings.TrimPrefix(rcode.String(), "RCode")
+			}
+		}
+	} else {
+		base, err := url.Parse(s.Target)
+		if err != nil {
+			return nil, err
+		}
+		proxy := s.Proxy
+		if execCtx.Cfg.NoProxy {
+			proxy = ""
+		}
+		tr, err := newHTTPTransport(s.HTTPTLSConfig, proxy)
+		if err != nil {
+			return nil, err
+		}
+		client := &http.Client{Transport: tr}
+		logging.L().Infof("Beaconing to %v", s.Target)
+		checkIn = func(record *beaconRecord, payload []byte) {
+			target := base
+			if len(s.URIs) > 0 {
+				ref, err := url.Parse(expandURIPattern(s.URIs[mathrand.IntN(len(s.URIs))], record.seq))
+				if err != nil {
+					record.err = err
+					return
+				}
+				target = base.ResolveReference(ref)
+			}
+			record.target = target.String()
+			record.status, record.err = s.sendHTTPCheckIn(client, record.target, payload, s.checkInTimeout(time.Since(start)))
+		}
+	}
+
+	// check in immediately, and then after every (jittered) interval
+	// until the count is reached or the next check-in would be late
+	var records []*beaconRecord
+	for seq := 1; s.Count == 0 || seq <= s.Count; seq++ {
+		if seq > 1 {
+			wait := s.nextInterval()
+			if s.duration > 0 && time.Since(start)+wait > s.duration {
+				break
+			}
+			time.Sleep(wait)
+		}
+
+		payload := make([]byte, s.minPayload+mathrand.IntN(s.maxPayload-s.minPayload+1))
+		if _, err := rand.Read(payload); err != nil {
+			return nil, err
+		}
+		record := &beaconRecord{seq: seq, time: time.Now(), payload: len(payload)}
+		checkIn(record, payload)
+		records = append(records, record)
+		if record.err != nil {
+			logging.L().Warnf("Check-in %d to %v failed: %v", seq, record.target, record.err)
+			if s.FailOnError {
+				return nil, fmt.Errorf("check-in %d failed: %w", seq, record.err)
+			}
+			continue
+		}
+		logging.L().Infof("Check-in %d to %v: %v", seq, record.target, record.status)
+	}
+
+	beacons := make([]any, 0, len(records))
+	errCount := 0
+	for _, record := range records {
+		beacons = append(beacons, record.output())
+		if record.err != nil {
+			errCount++
+		}
+	}
+	logging.L().Infof("Completed %d check-in(s), %d of which failed", len(records), errCount)
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"count":   strconv.Itoa(len(records)),
+			"errors":  strconv.Itoa(errCount),
+			"beacons": outputs.Stringify(beacons),
+		},
+		TypedOutputs: map[string]any{
+			"count":   len(records),
+			"errors":  errCount,
+			"beacons": beacons,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// nextInterval returns the interval, randomly adjusted by up to the jitter percentage
+func (s *BeaconStep) nextInterval() time.Duration {
+	if s.Jitter == 0 {
+		return s.interval
+	}
+	factor := 1 + (mathrand.Float64()*2-1)*float64(s.Jitter)/100
+	return time.Duration(float64(s.interval) * factor)
+}
+
+// checkInTimeout returns how long an HTTP check-in may take: no longer
+// than the interval, so that a stalled server cannot delay the next
+// check-in, and no longer than the remaining duration, if it is set
+func (s *BeaconStep) checkInTimeout(elapsed time.Duration) time.Duration {
+	timeout := s.interval
+	if s.duration > 0 {
+		timeout = min(timeout, s.duration-elapsed)
+	}
+	return max(timeout, minBeaconCheckInTimeout)
+}
+
+// expandURIPattern replaces the placeholders in a URI pattern:
+// {seq} with the number of the check-in, and {rand:N} with N
+// random lowercase letters and digits
+func expandURIPattern(pattern string, seq int) string {
+	return uriPlaceholderRegex.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		match := uriPlaceholderRegex.FindStringSubmatch(placeholder)
+		if match[2] == "" {
+			return strconv.Itoa(seq)
+		}
+		n, _ := strconv.Atoi(match[2])
+		var b strings.Builder
+		for range n {
+			b.WriteByte(uriRandomAlphabet[mathrand.IntN(len(uriRandomAlphabet))])
+		}
+		return b.String()
+	})
+}
+
+// sendHTTPCheckIn sends a single check-in and returns the status code of the response
+func (s *BeaconStep) sendHTTPCheckIn(client *http.Client, target string, payload []byte, timeout time.Duration) (any, error) {
+	method := http.MethodGet
+	if s.Method != "" {
+		method = strings.ToUpper(s.Method)
+	} else if s.maxPayload > 0 {
+		method = http.MethodPost
+	}
+	var body io.Reader
+	if method != http.MethodGet {
+		body = bytes.NewReader(payload)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if len(s.UserAgents) > 0 {
+		req.Header.Set("User-Agent", s.UserAgents[mathrand.IntN(len(s.UserAgents))])
+	}
+	for _, header := range s.Headers {
+		req.Header.Set(header.Field, header.Value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// dnsQueryName encodes a check-in as a query name of
+// the form <payload>.<seq>.<domain>
+func (s *BeaconStep) dnsQueryName(seq int, payload []byte) string {
+	labels := append(dnsDataLabels(payload), strconv.Itoa(seq), strings.Trim(s.DNS.Domain, "."))
+	return strings.Join(labels, ".")
+}
@@ -0,0 +1,354 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBeaconValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Valid HTTP",
+			content: `beacon: https://c2.example.com
+count: 10
+interval: 30s
+jitter: 20
+uris:
+  - /api/{seq}/status
+  - /static/{rand:12}.js
+payload_size: 64-512`,
+		},
+		{
+			name: "Valid DNS",
+			content: `beacon: dns
+dns:
+  domain: c2.example.com
+  resolver: 10.0.0.53
+duration: 10m`,
+		},
+		{
+			name: "No Bound",
+			content: `beacon: https://c2.example.com
+interval: 30s`,
+			wantError: true,
+		},
+		{
+			name: "Not A URL",
+			content: `beacon: c2.example.com
+count: 1`,
+			wantError: true,
+		},
+		{
+			name: "DNS Without Target",
+			content: `beacon: dns
+count: 1`,
+			wantError: true,
+		},
+		{
+			name: "DNS With User Agents",
+			content: `beacon: dns
+count: 1
+dns:
+  domain: c2.example.com
+  resolver: 10.0.0.53
+user_agents: ["curl/8.0"]`,
+			wantError: true,
+		},
+		{
+			name: "Jitter Too Large",
+			content: `beacon: https://c2.example.com
+count: 1
+jitter: 150`,
+			wantError: true,
+		},
+		{
+			name: "Invalid Payload Range",
+			content: `beacon: https://c2.example.com
+count: 1
+payload_size: 512-64`,
+			wantError: true,
+		},
+		{
+			name: "Payload With GET",
+			content: `beacon: https://c2.example.com
+count: 1
+method: GET
+payload_size: "64"`,
+			wantError: true,
+		},
+		{
+			name: "Relative URI",
+			content: `beacon: https://c2.example.com
+count: 1
+uris: ["api/status"]`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step BeaconStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBeaconNextInterval(t *testing.T) {
+	step := &BeaconStep{interval: time.Second, Jitter: 20}
+	seen := make(map[time.Duration]bool)
+	for range 1000 {
+		interval := step.nextInterval()
+		assert.GreaterOrEqual(t, interval, 800*time.Millisecond)
+		assert.LessOrEqual(t, interval, 1200*time.Millisecond)
+		seen[interval] = true
+	}
+	assert.Greater(t, len(seen), 1, "jittered intervals should vary")
+
+	step.Jitter = 0
+	assert.Equal(t, time.Second, step.nextInterval())
+}
+
+func TestBeaconCheckInTimeout(t *testing.T) {
+	step := &BeaconStep{interval: 5 * time.Second}
+	assert.Equal(t, 5*time.Second, step.checkInTimeout(time.Minute))
+
+	step.duration = 12 * time.Second
+	assert.Equal(t, 5*time.Second, step.checkInTimeout(0))
+	assert.Equal(t, 2*time.Second, step.checkInTimeout(10*time.Second))
+	assert.Equal(t, minBeaconCheckInTimeout, step.checkInTimeout(12*time.Second))
+}
+
+func TestBeaconStalledServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	step := &BeaconStep{
+		Target:   server.URL,
+		Count:    1,
+		Interval: "10ms",
+	}
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	start := time.Now()
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, 1, result.TypedOutputs["errors"])
+}
+
+type beaconRequest struct {
+	method    string
+	path      string
+	userAgent string
+	bodySize  int
+}
+
+func TestBeaconHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var requests []beaconRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, beaconRequest{r.Method, r.URL.Path, r.UserAgent(), len(body)})
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	content := `beacon: ` + server.URL + `
+count: 6
+interval: 20ms
+jitter: 50
+uris:
+  - /api/{seq}/status
+  - /static/{rand:12}.js
+user_agents:
+  - agent-one
+  - agent-two
+payload_size: 16-32`
+	var step BeaconStep
+	require.NoError(t, yaml.Unmarshal([]byte(content), &step))
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	require.NoError(t, step.Template(execCtx))
+	start := time.Now()
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	// five intervals of at least 10ms each
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	require.Len(t, requests, 6)
+	pathRegex := regexp.MustCompile(`^(/api/\d/status|/static/[a-z0-9]{12}\.js)$`)
+	for _, req := range requests {
+		assert.Equal(t, http.MethodPost, req.method)
+		assert.Regexp(t, pathRegex, req.path)
+		assert.Contains(t, []string{"agent-one", "agent-two"}, req.userAgent)
+		assert.GreaterOrEqual(t, req.bodySize, 16)
+		assert.LessOrEqual(t, req.bodySize, 32)
+	}
+
+	assert.Equal(t, 6, result.TypedOutputs["count"])
+	assert.Equal(t, 0, result.TypedOutputs["errors"])
+	beacons := result.TypedOutputs["beacons"].([]any)
+	require.Len(t, beacons, 6)
+	var last time.Time
+	for i, beacon := range beacons {
+		record := beacon.(map[string]any)
+		assert.Equal(t, i+1, record["seq"])
+		assert.Equal(t, http.StatusNotFound, record["status"])
+		assert.Equal(t, requests[i].bodySize, record["payload"])
+		timestamp, err := time.Parse(time.RFC3339Nano, record["time"].(string))
+		require.NoError(t, err)
+		assert.True(t, timestamp.After(last))
+		last = timestamp
+	}
+	assert.Contains(t, result.Outputs["beacons"], `"status":404`)
+}
+
+func TestBeaconDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer server.Close()
+
+	step := &BeaconStep{
+		Target:   server.URL,
+		Duration: "130ms",
+		Interval: "50ms",
+	}
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	// check-ins at 0ms, 50ms and 100ms
+	assert.Equal(t, 3, result.TypedOutputs["count"])
+}
+
+func TestBeaconErrors(t *testing.T) {
+	// nothing listens on the address of a closed server
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	server.Close()
+
+	step := &BeaconStep{
+		Target:   server.URL,
+		Count:    3,
+		Interval: "1ms",
+	}
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.TypedOutputs["count"])
+	assert.Equal(t, 3, result.TypedOutputs["errors"])
+	assert.Contains(t, result.TypedOutputs["beacons"].([]any)[0], "error")
+
+	step.FailOnError = true
+	_, err = step.Execute(execCtx)
+	assert.ErrorContains(t, err, "check-in 1 failed")
+}
+
+func TestBeaconDNS(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	var mu sync.Mutex
+	var names []string
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) != 1 {
+				continue
+			}
+			mu.Lock()
+			names = append(names, msg.Questions[0].Name.String())
+			mu.Unlock()
+			msg.Header.Response = true
+			msg.Header.RCode = dnsmessage.RCodeNameError
+			if reply, err := msg.Pack(); err == nil {
+				_, _ = conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	step := &BeaconStep{
+		Target:      BeaconDNS,
+		DNS:         &DNSTarget{Domain: "c2.example.com", Resolver: conn.LocalAddr().String()},
+		Count:       3,
+		Interval:    "1ms",
+		PayloadSize: "20",
+	}
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, names, 3)
+	for i, name := range names {
+		// 20 bytes of payload are encoded as 32 base32 characters
+		assert.Regexp(t, `^[a-z2-7]{32}\.`+regexp.QuoteMeta(string(rune('1'+i)))+`\.c2\.example\.com\.$`, name)
+		record := result.TypedOutputs["beacons"].([]any)[i].(map[string]any)
+		assert.Equal(t, "NameError", record["status"])
+		assert.Equal(t, name, record["target"].(string)+".")
+	}
+}
+
+func TestBeaconDNSPayloadTooLarge(t *testing.T) {
+	step := &BeaconStep{
+		Target:      BeaconDNS,
+		DNS:         &DNSTarget{Domain: "c2.example.com", Resolver: "127.0.0.1"},
+		Count:       1,
+		PayloadSize: "200",
+	}
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	_, err := step.Execute(execCtx)
+	assert.ErrorContains(t, err, "too large")
+}
@@ -130,7 +130,11 @@ func (step *ChangeDirectoryStep) Execute(ctx TTPExecutionContext) (*ActResult, e
 	step.PreviousDir = ctx.Vars.WorkDir
 	ctx.Vars.WorkDir = step.Cd
 
-	return &ActResult{}, nil
+	result := &ActResult{}
+	if err := step.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // GetDefaultCleanupAction sets the directory back to the previous directory
@@ -128,7 +128,11 @@ func (s *ConnectStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 	}
 
 	logging.L().Infof("Connection %q established to %s", s.ConnectionName, s.Host)
-	return &ActResult{Stdout: "connected"}, nil
+	result := &ActResult{Stdout: "connected"}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // CanBeUsedInCompositeAction returns false — connect steps must be
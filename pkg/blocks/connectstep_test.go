@@ -67,11 +67,12 @@ func joinArgs(args []string) string {
 	return result
 }
 
-func (m *mockBackend) GetFs() (afero.Fs, error)                    { return m.fs, nil }
-func (m *mockBackend) KillProcess(_ int) error                     { return nil }
-func (m *mockBackend) FindProcessesByName(_ string) ([]int, error) { return nil, nil }
-func (m *mockBackend) ProcessExists(_ int) (bool, error)           { return false, nil }
-func (m *mockBackend) Close() error                                { return nil }
+func (m *mockBackend) GetFs() (afero.Fs, error)                           { return m.fs, nil }
+func (m *mockBackend) KillProcess(_ int) error                            { return nil }
+func (m *mockBackend) FindProcessesByName(_ string) ([]int, error)        { return nil, nil }
+func (m *mockBackend) FindProcessesByCommandLine(_ string) ([]int, error) { return nil, nil }
+func (m *mockBackend) ProcessExists(_ int) (bool, error)                  { return false, nil }
+func (m *mockBackend) Close() error                                       { return nil }
 
 func (m *mockBackend) getCommands() []string {
 	m.mu.Lock()
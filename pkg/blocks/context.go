@@ -26,12 +26,17 @@ import (
 	"io"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"github.com/facebookincubator/ttpforge/pkg/detections"
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
 	"github.com/facebookincubator/ttpforge/pkg/repos"
+	"github.com/spf13/afero"
 )
 
 const contextVariablePrefix = "$forge."
@@ -44,16 +49,28 @@ type TTPExecutionConfig struct {
 	NoCleanup           bool
 	NoChecks            bool
 	NoProxy             bool
+	StrictCleanup       bool
 	CleanupDelaySeconds uint
-	Repo                repos.Repo
-	Stdout              io.Writer
-	Stderr              io.Writer
+	NoDetections        bool
+	// AlertSource is queried to verify the detections
+	// declared by the TTP - it may be nil if no alert
+	// source is configured
+	AlertSource       detections.AlertSource
+	AlertPollInterval time.Duration
+	Repo              repos.Repo
+	RepoCollection    repos.RepoCollection
+	Stdout            io.Writer
+	Stderr            io.Writer
 }
 
 // TTPExecutionVars - mutable store to carry variables between steps
 type TTPExecutionVars struct {
 	WorkDir  string
 	StepVars map[string]string
+	// StepOutputs holds the typed outputs of each completed step,
+	// keyed by step name, so that step templates can use them directly
+	// (for example, {[{ range .StepOutputs.find_procs.pids }]})
+	StepOutputs map[string]map[string]any
 }
 
 // TTPExecutionContext - holds config and context for the currently executing TTP
@@ -64,6 +81,7 @@ type TTPExecutionContext struct {
 	StepResults       *StepResultsRecord
 	Backend           backends.ExecutionBackend
 	ConnPool          *backends.ConnectionPool
+	HTTPSessions      *HTTPSessions
 	actionResultsChan chan *ActResult
 	errorsChan        chan error
 	shutdownChan      chan bool
@@ -73,10 +91,12 @@ type TTPExecutionContext struct {
 func NewTTPExecutionContext() TTPExecutionContext {
 	return TTPExecutionContext{
 		Vars: &TTPExecutionVars{
-			WorkDir:  "/",
-			StepVars: make(map[string]string),
+			WorkDir:     "/",
+			StepVars:    make(map[string]string),
+			StepOutputs: make(map[string]map[string]any),
 		},
 		StepResults:       NewStepResultsRecord(),
+		HTTPSessions:      NewHTTPSessions(),
 		actionResultsChan: make(chan *ActResult, 1),
 		errorsChan:        make(chan error, 1),
 		shutdownChan:      SetupSignalHandler(),
@@ -87,6 +107,7 @@ func NewTTPExecutionContext() TTPExecutionContext {
 // and expands all of them to their appropriate values:
 //
 // * Step outputs: ($forge.steps.bar.outputs.baz)
+// * Elements of list/object step outputs: ($forge.steps.bar.outputs.baz[0].qux)
 //
 // **Parameters:**
 //
@@ -97,9 +118,7 @@ func NewTTPExecutionContext() TTPExecutionContext {
 // []string: the corresponding strings with variables expanded
 // error: an error if there is a problem
 func (c TTPExecutionContext) ExpandVariables(inStrs []string) ([]string, error) {
-	re := regexp.MustCompile(
-		`\$*` + regexp.QuoteMeta(contextVariablePrefix) + `[\w\.]*`,
-	)
+	re := contextVariableRegexp()
 	var expandedStrs []string
 	for _, inStr := range inStrs {
 		var failedMatch string
@@ -147,43 +166,152 @@ func (c TTPExecutionContext) containsStepTemplating(input string) bool {
 	return strings.Contains(input, stepTemplateLeftDelim)
 }
 
+// getFs returns fsys if it is set, so that steps can be tested against an
+// in-memory filesystem, otherwise the filesystem of the active backend,
+// falling back to the local one
+func (c TTPExecutionContext) getFs(fsys afero.Fs) (afero.Fs, error) {
+	if fsys != nil {
+		return fsys, nil
+	}
+	if c.Backend != nil {
+		backendFs, err := c.Backend.GetFs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get filesystem: %w", err)
+		}
+		return backendFs, nil
+	}
+	return afero.NewOsFs(), nil
+}
+
+// contextVariableRegexp matches variable expressions such as
+// $forge.steps.foo.outputs.bar[0] (along with any escaping $ signs)
+func contextVariableRegexp() *regexp.Regexp {
+	return regexp.MustCompile(
+		`\$*` + regexp.QuoteMeta(contextVariablePrefix) + `(?:[\w\.]|\[\d+\])*`,
+	)
+}
+
+// outputPathTokenRegexp matches a single component of a step output
+// path: a key name optionally followed by one or more list indices
+var outputPathTokenRegexp = regexp.MustCompile(`^(\w+)((?:\[\d+\])*)$`)
+
+// outputIndexRegexp extracts the list indices from a step output path component
+var outputIndexRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
 func (c TTPExecutionContext) processStepsVariable(path string) (string, error) {
+	val, err := c.resolveStepsVariable(path)
+	if err != nil {
+		return "", err
+	}
+
+	// references to a whole output (with no indices or nested keys)
+	// expand to the string form of that output, so that existing
+	// TTPs see the same values as they always have
+	tokens := strings.Split(path, ".")
+	if len(tokens) == 3 && tokens[1] == "outputs" {
+		if strVal, ok := c.StepResults.ByName[tokens[0]].Outputs[tokens[2]]; ok {
+			return strVal, nil
+		}
+	}
+	return outputs.Stringify(val), nil
+}
+
+// resolveStepsVariable resolves a step result reference,
+// retaining the type of the referenced value
+func (c TTPExecutionContext) resolveStepsVariable(path string) (any, error) {
 	tokens := strings.Split(path, ".")
 	if len(tokens) < 2 {
-		return "", fmt.Errorf("invalid step result reference: %v", "steps."+path)
+		return nil, fmt.Errorf("invalid step result reference: %v", "steps."+path)
 	}
 
 	stepName := tokens[0]
 	stepResult, ok := c.StepResults.ByName[stepName]
 	if !ok {
-		return "", fmt.Errorf("invalid step name in variable path: %v", "steps."+path)
+		return nil, fmt.Errorf("invalid step name in variable path: %v", "steps."+path)
 	}
 
 	fieldSelector := tokens[1]
 	switch fieldSelector {
 	case "stdout":
 		if len(tokens) != 2 {
-			return "", fmt.Errorf("invalid step result reference (should end at stdout): %v", "steps."+path)
+			return nil, fmt.Errorf("invalid step result reference (should end at stdout): %v", "steps."+path)
 		}
 		return stepResult.Stdout, nil
 	case "outputs":
-		if len(tokens) != 3 {
-			return "", fmt.Errorf("step output reference %v should be exactly one level deep (e.g. steps.foo.outputs.bar)", "steps."+path)
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("step output reference %v must name an output (e.g. steps.foo.outputs.bar)", "steps."+path)
 		}
-		key := tokens[2]
-		val, ok := stepResult.Outputs[key]
-		if !ok {
-			return "", fmt.Errorf("key %v not found in output of step %v", key, stepName)
+		return resolveStepOutput(&stepResult.ActResult, stepName, tokens[2:])
+	}
+	return nil, fmt.Errorf("invalid step result field selector: %v", fieldSelector)
+}
+
+// resolveStepOutput looks up the output named by the first path token,
+// then follows any list indices (such as [0]) and nested object keys
+// in the remaining tokens
+func resolveStepOutput(result *ActResult, stepName string, pathTokens []string) (any, error) {
+	var cur any
+	for tokenIdx, token := range pathTokens {
+		matches := outputPathTokenRegexp.FindStringSubmatch(token)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid output path component %q in output of step %v", token, stepName)
+		}
+		key, indices := matches[1], matches[2]
+
+		if tokenIdx == 0 {
+			strVal, hasStr := result.Outputs[key]
+			typedVal, hasTyped := result.TypedOutputs[key]
+			switch {
+			case hasTyped:
+				cur = typedVal
+			case hasStr:
+				cur = strVal
+			default:
+				return nil, fmt.Errorf("key %v not found in output of step %v", key, stepName)
+			}
+		} else {
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot access key %v of output %v of step %v: value is not an object", key, pathTokens[0], stepName)
+			}
+			cur, ok = obj[key]
+			if !ok {
+				return nil, fmt.Errorf("key %v not found in output %v of step %v", key, pathTokens[0], stepName)
+			}
+		}
+
+		for _, indexMatch := range outputIndexRegexp.FindAllStringSubmatch(indices, -1) {
+			idx, err := strconv.Atoi(indexMatch[1])
+			if err != nil {
+				return nil, err
+			}
+			list, ok := cur.([]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot index output %v of step %v: value is not a list", pathTokens[0], stepName)
+			}
+			if idx >= len(list) {
+				return nil, fmt.Errorf("index %d out of range for output %v of step %v (length %d)", idx, pathTokens[0], stepName, len(list))
+			}
+			cur = list[idx]
 		}
-		return val, nil
 	}
-	return "", fmt.Errorf("invalid step result field selector: %v", fieldSelector)
+	return cur, nil
 }
 
 func (c TTPExecutionContext) processMatch(match string) (string, error) {
 	if strings.HasPrefix(match, "$$") {
 		return strings.TrimPrefix(match, "$"), nil
 	}
+	path, err := parseVariableExpression(match)
+	if err != nil {
+		return "", err
+	}
+	return c.processStepsVariable(path)
+}
+
+// parseVariableExpression validates a variable expression
+// and returns its path relative to the `steps` prefix
+func parseVariableExpression(match string) (string, error) {
 	variableSpecifier := strings.TrimPrefix(match, contextVariablePrefix)
 	tokens := strings.Split(variableSpecifier, ".")
 	if slices.Contains(tokens, "") {
@@ -194,9 +322,8 @@ func (c TTPExecutionContext) processMatch(match string) (string, error) {
 	}
 
 	prefix := tokens[0]
-	path := strings.Join(tokens[1:], ".")
-	if prefix == "steps" {
-		return c.processStepsVariable(path)
+	if prefix != "steps" {
+		return "", fmt.Errorf("invalid variable prefix: %v", prefix)
 	}
-	return "", fmt.Errorf("invalid variable prefix: %v", prefix)
+	return strings.Join(tokens[1:], "."), nil
 }
@@ -47,9 +47,26 @@ func TestExpandVariablesStepResults(t *testing.T) {
 			},
 		},
 	}
+	stepResults.ByName["typed_step"] = &ExecutionResult{
+		ActResult: ActResult{
+			Outputs: map[string]string{
+				"pids": "[1234, 5678]",
+				"user": `{"name": "root", "uid": 0}`,
+			},
+			TypedOutputs: map[string]any{
+				"pids": []any{float64(1234), float64(5678)},
+				"user": map[string]any{
+					"name":   "root",
+					"uid":    float64(0),
+					"groups": []any{"wheel", "adm"},
+				},
+			},
+		},
+	}
 	stepResults.ByIndex = append(stepResults.ByIndex, stepResults.ByName["first_step"])
 	stepResults.ByIndex = append(stepResults.ByIndex, stepResults.ByName["second_step"])
 	stepResults.ByIndex = append(stepResults.ByIndex, stepResults.ByName["third_step"])
+	stepResults.ByIndex = append(stepResults.ByIndex, stepResults.ByName["typed_step"])
 	execCtx := TTPExecutionContext{
 		StepResults: stepResults,
 	}
@@ -131,6 +148,56 @@ func TestExpandVariablesStepResults(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "Whole Typed Output Keeps Original String",
+			stringsToExpand: []string{
+				"pids: $forge.steps.typed_step.outputs.pids",
+			},
+			expectedResult: []string{
+				"pids: [1234, 5678]",
+			},
+		},
+		{
+			name: "Typed Output List Index",
+			stringsToExpand: []string{
+				"kill $forge.steps.typed_step.outputs.pids[1]",
+			},
+			expectedResult: []string{
+				"kill 5678",
+			},
+		},
+		{
+			name: "Typed Output Nested Keys",
+			stringsToExpand: []string{
+				"$forge.steps.typed_step.outputs.user.name:$forge.steps.typed_step.outputs.user.groups[0]",
+				"groups: $forge.steps.typed_step.outputs.user.groups",
+			},
+			expectedResult: []string{
+				"root:wheel",
+				`groups: ["wheel","adm"]`,
+			},
+		},
+		{
+			name: "Typed Output Index Out Of Range",
+			stringsToExpand: []string{
+				"should fail: $forge.steps.typed_step.outputs.pids[2]",
+			},
+			wantError: true,
+		},
+		{
+			name: "Index Into Non-List Output",
+			stringsToExpand: []string{
+				"should fail: $forge.steps.typed_step.outputs.user[0]",
+			},
+			wantError: true,
+		},
+		{
+			name: "Nested Key Of Non-Object Output",
+			stringsToExpand: []string{
+				"should fail: $forge.steps.third_step.outputs.myresult.foo",
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -153,6 +220,7 @@ func TestTemplateStep(t *testing.T) {
 		name             string
 		stringToTemplate string
 		stepVars         map[string]string
+		stepOutputs      map[string]map[string]any
 		expectedResult   string
 		wantError        bool
 	}{
@@ -174,6 +242,15 @@ func TestTemplateStep(t *testing.T) {
 			expectedResult: "this is {{.StepVars.foo}}",
 			wantError:      false,
 		},
+		{
+			name:             "Range over typed step output",
+			stringToTemplate: `{[{ range .StepOutputs.find_procs.pids }]}kill {[{ . }]};{[{ end }]}`,
+			stepOutputs: map[string]map[string]any{
+				"find_procs": {"pids": []any{float64(1234), float64(5678)}},
+			},
+			expectedResult: "kill 1234;kill 5678;",
+			wantError:      false,
+		},
 		{
 			name:             "Errors on missing variable",
 			stringToTemplate: "this is {[{.StepVars.foo}]}",
@@ -188,6 +265,9 @@ func TestTemplateStep(t *testing.T) {
 			// Build execution context
 			execCtx := NewTTPExecutionContext()
 			execCtx.Vars.StepVars = tc.stepVars
+			if tc.stepOutputs != nil {
+				execCtx.Vars.StepOutputs = tc.stepOutputs
+			}
 
 			// test templating
 			result, err := execCtx.templateStep(tc.stringToTemplate)
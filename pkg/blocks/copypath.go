@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/spf13/afero"
@@ -181,16 +182,34 @@ func (s *CopyPathStep) Execute(execCtx TTPExecutionContext) (*ActResult, error)
 	}
 
 	// Copy the file or directory
+	var copied []string
 	if srcInfo.IsDir() {
-		err = aferoCopyDir(srcFs, dstFs, s.Source, s.Destination)
+		copied, err = aferoCopyDir(srcFs, dstFs, s.Source, s.Destination)
 	} else {
 		err = aferoCopyFile(srcFs, dstFs, s.Source, s.Destination, os.FileMode(mode))
+		copied = []string{s.Destination}
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &ActResult{}, nil
+	copiedVals := make([]any, len(copied))
+	for i, path := range copied {
+		copiedVals[i] = path
+	}
+	result := &ActResult{
+		Outputs: map[string]string{
+			"destination": s.Destination,
+			"files":       strings.Join(copied, "\n"),
+		},
+		TypedOutputs: map[string]any{
+			"files": copiedVals,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // aferoCopyFile copies a single file, reading from srcFs and writing to dstFs.
@@ -203,8 +222,10 @@ func aferoCopyFile(srcFs, dstFs afero.Fs, src, dst string, mode os.FileMode) err
 }
 
 // aferoCopyDir recursively copies a directory, reading from srcFs and writing to dstFs.
-func aferoCopyDir(srcFs, dstFs afero.Fs, src, dst string) error {
-	return afero.Walk(srcFs, src, func(path string, info os.FileInfo, err error) error {
+// It returns the destination paths of the files that were copied.
+func aferoCopyDir(srcFs, dstFs afero.Fs, src, dst string) ([]string, error) {
+	var copied []string
+	err := afero.Walk(srcFs, src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -220,8 +241,13 @@ func aferoCopyDir(srcFs, dstFs afero.Fs, src, dst string) error {
 			return dstFs.MkdirAll(dstPath, info.Mode())
 		}
 
-		return aferoCopyFile(srcFs, dstFs, path, dstPath, info.Mode())
+		if err := aferoCopyFile(srcFs, dstFs, path, dstPath, info.Mode()); err != nil {
+			return err
+		}
+		copied = append(copied, dstPath)
+		return nil
 	})
+	return copied, err
 }
 
 // GetDefaultCleanupAction will instruct the calling code
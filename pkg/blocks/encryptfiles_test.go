@@ -0,0 +1,306 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"os"
+	"testing"
+
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+var encryptTestFiles = map[string][]byte{
+	"/victim/a.docx":         []byte("first document"),
+	"/victim/b.txt":          []byte("second document"),
+	"/victim/sub/c.docx":     []byte("nested document"),
+	"/victim/sub/deep/d.pdf": []byte("deeply nested document"),
+	"/elsewhere/e.docx":      []byte("not a target"),
+}
+
+func TestEncryptFilesValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Valid",
+			content: `encrypt_files: /tmp/victim
+include:
+  - "*.docx"
+ransom_note: pay up`,
+		},
+		{
+			name: "Note Name Is Path",
+			content: `encrypt_files: /tmp/victim
+ransom_note_name: ../README.txt`,
+			wantError: true,
+		},
+		{
+			name: "Extension Without Dot",
+			content: `encrypt_files: /tmp/victim
+extension: locked`,
+			wantError: true,
+		},
+		{
+			name: "Negative Limit",
+			content: `encrypt_files: /tmp/victim
+max_files: -1`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step EncryptFilesStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestEncryptFilesExecuteAndCleanup(t *testing.T) {
+	testCases := []struct {
+		name          string
+		step          *EncryptFilesStep
+		noCleanup     bool
+		extraFiles    map[string][]byte
+		expectedFiles []any
+		expectedNotes []string
+		wantError     bool
+	}{
+		{
+			name: "Top Level Only",
+			step: &EncryptFilesStep{
+				Directory:  "/victim",
+				RansomNote: "your files are encrypted",
+			},
+			expectedFiles: []any{"/victim/a.docx.ttpforge_encrypted", "/victim/b.txt.ttpforge_encrypted"},
+			expectedNotes: []string{"/victim/README_RESTORE_FILES.txt"},
+		},
+		{
+			name: "Recursive With Include",
+			step: &EncryptFilesStep{
+				Directory:      "/victim",
+				Recursive:      true,
+				Include:        []string{"*.docx", "*.pdf"},
+				Extension:      ".locked",
+				RansomNote:     "your files are encrypted",
+				RansomNoteName: "HOW_TO_DECRYPT.txt",
+			},
+			expectedFiles: []any{"/victim/a.docx.locked", "/victim/sub/c.docx.locked", "/victim/sub/deep/d.pdf.locked"},
+			expectedNotes: []string{"/victim/HOW_TO_DECRYPT.txt", "/victim/sub/HOW_TO_DECRYPT.txt", "/victim/sub/deep/HOW_TO_DECRYPT.txt"},
+		},
+		{
+			name: "Too Many Files",
+			step: &EncryptFilesStep{
+				Directory: "/victim",
+				Recursive: true,
+				MaxFiles:  3,
+			},
+			wantError: true,
+		},
+		{
+			name: "File Too Large",
+			step: &EncryptFilesStep{
+				Directory:   "/victim",
+				MaxFileSize: 10,
+			},
+			wantError: true,
+		},
+		{
+			name: "Total Size Too Large",
+			step: &EncryptFilesStep{
+				Directory:    "/victim",
+				Recursive:    true,
+				MaxTotalSize: 40,
+			},
+			wantError: true,
+		},
+		{
+			name: "Root Directory",
+			step: &EncryptFilesStep{
+				Directory: "/",
+			},
+			wantError: true,
+		},
+		{
+			name: "No Cleanup",
+			step: &EncryptFilesStep{
+				Directory: "/victim",
+			},
+			noCleanup: true,
+			wantError: true,
+		},
+		{
+			name: "Existing Ransom Note Name",
+			step: &EncryptFilesStep{
+				Directory:  "/victim",
+				Recursive:  true,
+				RansomNote: "your files are encrypted",
+			},
+			extraFiles: map[string][]byte{
+				"/victim/sub/README_RESTORE_FILES.txt": []byte("the user's own readme"),
+			},
+			wantError: true,
+		},
+		{
+			name: "Failure Rolls Back",
+			step: &EncryptFilesStep{
+				Directory: "/victim",
+			},
+			extraFiles: map[string][]byte{
+				// blocks the encryption of b.txt, after a.docx was encrypted
+				"/victim/b.txt.ttpforge_encrypted": []byte("in the way"),
+			},
+			wantError: true,
+		},
+		{
+			name: "Outputs Failure Rolls Back",
+			step: &EncryptFilesStep{
+				actionDefaults: failingOutputs(),
+				Directory:      "/victim",
+				Recursive:      true,
+				RansomNote:     "your files are encrypted",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contents := make(map[string][]byte)
+			for name, data := range encryptTestFiles {
+				contents[name] = data
+			}
+			for name, data := range tc.extraFiles {
+				contents[name] = data
+			}
+			fsys, err := testutils.MakeAferoTestFs(contents)
+			require.NoError(t, err)
+			tc.step.FileSystem = fsys
+
+			execCtx := NewTTPExecutionContext()
+			execCtx.Cfg.NoCleanup = tc.noCleanup
+			require.NoError(t, tc.step.Validate(execCtx))
+			require.NoError(t, tc.step.Template(execCtx))
+			result, err := tc.step.Execute(execCtx)
+			if tc.wantError {
+				require.Error(t, err)
+				// nothing may be left modified
+				for name, data := range contents {
+					got, err := afero.ReadFile(fsys, name)
+					require.NoError(t, err)
+					assert.Equal(t, data, got)
+				}
+				// and no encrypted files or ransom notes may be left behind
+				require.NoError(t, afero.Walk(fsys, "/", func(path string, info os.FileInfo, err error) error {
+					if err == nil && !info.IsDir() {
+						assert.Contains(t, contents, path)
+					}
+					return err
+				}))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedFiles, result.TypedOutputs["files"])
+
+			for _, file := range tc.expectedFiles {
+				original := encryptTestFiles[file.(string)[:len(file.(string))-len(tc.step.extension())]]
+				encrypted, err := afero.ReadFile(fsys, file.(string))
+				require.NoError(t, err)
+				assert.NotContains(t, string(encrypted), string(original))
+			}
+			for _, note := range tc.expectedNotes {
+				exists, err := afero.Exists(fsys, note)
+				require.NoError(t, err)
+				assert.True(t, exists, "missing ransom note %v", note)
+			}
+
+			_, err = tc.step.GetDefaultCleanupAction().Execute(execCtx)
+			require.NoError(t, err)
+			for name, data := range contents {
+				got, err := afero.ReadFile(fsys, name)
+				require.NoError(t, err)
+				assert.Equal(t, data, got)
+			}
+			for _, file := range tc.expectedFiles {
+				exists, err := afero.Exists(fsys, file.(string))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			}
+			for _, note := range tc.expectedNotes {
+				exists, err := afero.Exists(fsys, note)
+				require.NoError(t, err)
+				assert.False(t, exists)
+			}
+		})
+	}
+}
+
+func TestEncryptFilesCleanupSpec(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Implicit Default Cleanup",
+			content: `name: encrypt
+encrypt_files: /tmp/victim`,
+		},
+		{
+			name: "Explicit Default Cleanup",
+			content: `name: encrypt
+encrypt_files: /tmp/victim
+cleanup: default`,
+		},
+		{
+			name: "Custom Cleanup",
+			content: `name: encrypt
+encrypt_files: /tmp/victim
+cleanup:
+  inline: echo "this would not decrypt anything"`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step Step
+			err := yaml.Unmarshal([]byte(tc.content), &step)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, &decryptFilesAction{}, step.cleanup)
+		})
+	}
+}
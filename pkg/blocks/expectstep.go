@@ -22,18 +22,31 @@ package blocks
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Netflix/go-expect"
 	"github.com/creack/pty"
+	"github.com/facebookincubator/ttpforge/pkg/backends"
 	"github.com/facebookincubator/ttpforge/pkg/logging"
-	"github.com/facebookincubator/ttpforge/pkg/outputs"
+	"golang.org/x/term"
 )
 
+// DefaultExpectTimeout is the default number of seconds to wait for each prompt
+const DefaultExpectTimeout = 120
+
+// expectReservedOutputs are the built-in outputs of an expect
+// step, which named capture groups in prompts cannot replace
+var expectReservedOutputs = []string{"exit_code"}
+
 // ExpectStep represents an expect command.
 //
 // **Attributes:**
@@ -45,17 +58,21 @@ import (
 // Executor: Shell to use for executing the command.
 // Environment: Environment variables for the command.
 // Inline: Inline script to execute.
+// Transcript: Path of a file to save the session's output to.
 // CleanupStep: Command to run for cleanup after execution.
-// Outputs: Outputs generated by the command.
 type ExpectStep struct {
 	actionDefaults `yaml:",inline"`
-	Chdir          string                  `yaml:"chdir,omitempty"`
-	Timeout        int                     `yaml:"timeout,omitempty"`
-	TerminalWidth  int                     `yaml:"terminal_width,omitempty"`
-	Executor       string                  `yaml:"executor,omitempty"`
-	Expect         *ExpectSpec             `yaml:"expect,omitempty"`
-	Environment    map[string]string       `yaml:"env,omitempty"`
-	Outputs        map[string]outputs.Spec `yaml:"outputs,omitempty"`
+	Chdir          string            `yaml:"chdir,omitempty"`
+	Timeout        int               `yaml:"timeout,omitempty"`
+	TerminalWidth  int               `yaml:"terminal_width,omitempty"`
+	Executor       string            `yaml:"executor,omitempty"`
+	Expect         *ExpectSpec       `yaml:"expect,omitempty"`
+	Environment    map[string]string `yaml:"env,omitempty"`
+	Transcript     string            `yaml:"transcript,omitempty"`
+
+	// remote is set if the step runs on a remote: host,
+	// so its executor cannot be looked up locally
+	remote bool
 }
 
 // ExpectSpec represents the expect block in the expect step.
@@ -64,20 +81,33 @@ type ExpectStep struct {
 //
 // Inline: Inline script to execute.
 // Responses: List of expected prompts and responses.
+// ExitCode: The exit code that the script is expected to exit with.
 type ExpectSpec struct {
 	Inline    string     `yaml:"inline"`
 	Responses []Response `yaml:"responses"`
+	ExitCode  int        `yaml:"exit_code,omitempty"`
 }
 
 // Response represents a prompt-response pair.
 //
 // **Attributes:**
 //
-// Prompt: The expected prompt to match.
+// Prompt: The expected prompt to match. Named capture groups
+// in the prompt are saved as outputs of the step.
 // Response: The response to send when the prompt is matched.
+// Timeout: Seconds to wait for the prompt, overriding the step's timeout.
+// Optional: Whether the prompt may not appear, in which case
+// the step carries on with the next response.
+// EOF: Expect the session to end instead of a prompt.
+// Branches: Alternative responses, of which the first one whose
+// prompt appears is used.
 type Response struct {
-	Prompt   string `yaml:"prompt"`
-	Response string `yaml:"response"`
+	Prompt   string     `yaml:"prompt"`
+	Response string     `yaml:"response"`
+	Timeout  int        `yaml:"timeout,omitempty"`
+	Optional bool       `yaml:"optional,omitempty"`
+	EOF      bool       `yaml:"eof,omitempty"`
+	Branches []Response `yaml:"branches,omitempty"`
 }
 
 // NewExpectStep creates a new ExpectStep instance.
@@ -109,7 +139,7 @@ func (s *ExpectStep) IsNil() bool {
 // **Returns:**
 //
 // error: An error if validation fails.
-func (s *ExpectStep) Validate(_ TTPExecutionContext) error {
+func (s *ExpectStep) Validate(execCtx TTPExecutionContext) error {
 	if s.Expect == nil {
 		return fmt.Errorf("expectStep is nil")
 	}
@@ -124,13 +154,71 @@ func (s *ExpectStep) Validate(_ TTPExecutionContext) error {
 		s.Executor = "bash"
 	}
 
-	if _, err := exec.LookPath(s.Executor); err != nil {
-		return fmt.Errorf("executor not found: %w", err)
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+
+	for i := range s.Expect.Responses {
+		if err := s.Expect.Responses[i].validate(execCtx, true); err != nil {
+			return fmt.Errorf("invalid response %d: %w", i+1, err)
+		}
+	}
+
+	// the executor only needs to exist locally if the step isn't run
+	// remotely. At load time the backend has not been swapped in yet,
+	// so the step's remote: field is checked as well
+	if execCtx.Backend == nil && !s.remote {
+		if _, err := exec.LookPath(s.Executor); err != nil {
+			return fmt.Errorf("executor not found: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// validate checks that the response expects exactly one of a
+// prompt, the end of the session or one of several branches
+func (r *Response) validate(execCtx TTPExecutionContext, allowBranches bool) error {
+	set := 0
+	for _, isSet := range []bool{r.Prompt != "", r.EOF, len(r.Branches) > 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of prompt, eof and branches must be specified")
+	}
+	if r.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if r.EOF && r.Response != "" {
+		return fmt.Errorf("response cannot be used with eof")
+	}
+	if r.Prompt != "" && !execCtx.containsStepTemplating(r.Prompt) {
+		if _, err := compilePrompt(r.Prompt); err != nil {
+			return err
+		}
+	}
+	if len(r.Branches) > 0 {
+		if !allowBranches {
+			return fmt.Errorf("branches cannot be nested")
+		}
+		if r.Response != "" {
+			return fmt.Errorf("response cannot be used with branches")
+		}
+		for i := range r.Branches {
+			branch := &r.Branches[i]
+			if branch.Optional || branch.Timeout != 0 {
+				return fmt.Errorf("branch %d: optional and timeout must be set on the response rather than its branches", i+1)
+			}
+			if err := branch.validate(execCtx, false); err != nil {
+				return fmt.Errorf("branch %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
 // Template takes each applicable field in the step and replaces any template strings with their resolved values.
 //
 // **Returns:**
@@ -146,23 +234,52 @@ func (s *ExpectStep) Template(execCtx TTPExecutionContext) error {
 	if err != nil {
 		return err
 	}
+	s.Transcript, err = execCtx.templateStep(s.Transcript)
+	if err != nil {
+		return err
+	}
 	s.Expect.Inline, err = execCtx.templateStep(s.Expect.Inline)
 	if err != nil {
 		return err
 	}
 	for i := range s.Expect.Responses {
-		s.Expect.Responses[i].Prompt, err = execCtx.templateStep(s.Expect.Responses[i].Prompt)
-		if err != nil {
+		if err := s.Expect.Responses[i].template(execCtx); err != nil {
 			return err
 		}
-		s.Expect.Responses[i].Response, err = execCtx.templateStep(s.Expect.Responses[i].Response)
-		if err != nil {
+	}
+	return nil
+}
+
+// template replaces template strings in the prompt and response of
+// the response and its branches with their resolved values
+func (r *Response) template(execCtx TTPExecutionContext) error {
+	var err error
+	r.Prompt, err = execCtx.templateStep(r.Prompt)
+	if err != nil {
+		return err
+	}
+	r.Response, err = execCtx.templateStep(r.Response)
+	if err != nil {
+		return err
+	}
+	for i := range r.Branches {
+		if err := r.Branches[i].template(execCtx); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// expectSession is the command that an expect step interacts with
+type expectSession struct {
+	// send types a response into the command's terminal
+	send func(response string) error
+	// wait waits for the command to exit and returns its exit code
+	wait func() (int, error)
+	// stop kills the command if it is still running
+	stop func()
+}
+
 // Execute runs the step and returns an error if one occurs.
 //
 // **Parameters:**
@@ -175,12 +292,16 @@ func (s *ExpectStep) Template(execCtx TTPExecutionContext) error {
 // *ActResult: A pointer to the action result.
 // error: An error if execution fails.
 func (s *ExpectStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
-	if execCtx.Backend != nil {
-		return nil, fmt.Errorf("expect action is not yet supported with remote: execution")
-	}
 	if s == nil || s.Expect == nil {
 		return nil, fmt.Errorf("expect block must be provided")
 	}
+	var starter backends.PTYStarter
+	if execCtx.Backend != nil {
+		var ok bool
+		if starter, ok = execCtx.Backend.(backends.PTYStarter); !ok {
+			return nil, fmt.Errorf("expect action is not supported by the remote: backend")
+		}
+	}
 
 	originalDir, err := os.Getwd()
 	if err != nil {
@@ -195,7 +316,7 @@ func (s *ExpectStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 		}
 	}()
 
-	if s.Chdir != "" {
+	if s.Chdir != "" && starter == nil {
 		if err := os.Chdir(s.Chdir); err != nil {
 			return nil, fmt.Errorf("failed to change directory: %w", err)
 		}
@@ -207,6 +328,11 @@ func (s *ExpectStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 		return nil, fmt.Errorf("failed to create new console: %w", err)
 	}
 	defer console.Close()
+	if s.Transcript != "" {
+		// save the transcript even if the step fails, as
+		// that is when it is most useful
+		defer s.saveTranscript(execCtx, &transcript)
+	}
 
 	// Set PTY width to prevent long commands from being truncated/wrapped.
 	// Default 80 columns is too narrow for device CLI prompts + commands.
@@ -219,6 +345,55 @@ func (s *ExpectStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 		logging.L().Warnf("failed to set terminal width: %v", err)
 	}
 
+	var session *expectSession
+	if starter == nil {
+		session, err = s.startLocal(execCtx, console)
+	} else {
+		session, err = s.startRemote(execCtx, starter, console, termWidth)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer session.stop()
+
+	captures, err := s.respond(console, session)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.L().Debugf("Waiting for command to exit...")
+	if _, err := console.Expect(expect.EOF, expect.PTSClosed, expect.WithTimeout(s.timeout(nil))); err != nil {
+		return nil, fmt.Errorf("failed to expect EOF: %w", err)
+	}
+	exitCode, err := session.wait()
+	if err != nil {
+		return nil, fmt.Errorf("command failed: %w", err)
+	}
+	if exitCode != s.Expect.ExitCode {
+		return nil, fmt.Errorf("command exited with code %d, expected %d", exitCode, s.Expect.ExitCode)
+	}
+
+	result := &ActResult{
+		Stdout: transcript.String(),
+		Outputs: map[string]string{
+			"exit_code": strconv.Itoa(exitCode),
+		},
+		TypedOutputs: map[string]any{
+			"exit_code": exitCode,
+		},
+	}
+	for name, value := range captures {
+		result.Outputs[name] = value
+		result.TypedOutputs[name] = value
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// startLocal starts the command in the console's terminal
+func (s *ExpectStep) startLocal(execCtx TTPExecutionContext, console *expect.Console) (*expectSession, error) {
 	// Set TTP-level env vars first, then step-level (step overrides TTP)
 	for k, v := range execCtx.GlobalEnv {
 		if err := os.Setenv(k, v); err != nil {
@@ -241,57 +416,265 @@ func (s *ExpectStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 		return nil, fmt.Errorf("failed to start command: %w", err)
 	}
 
-	done := make(chan error, 1)
-	go func() {
-		defer close(done)
-		for _, response := range s.Expect.Responses {
-			logging.L().Debugf("Waiting for prompt: %s\n", response.Prompt)
-			re := regexp.MustCompile(response.Prompt)
-			timeout := 120 // Default timeout is 120 seconds
-			if s.Timeout > 0 {
-				timeout = s.Timeout // Use the provided timeout if it is greater than 0
+	// close our end of the terminal, so that the console
+	// reaches EOF as soon as the command exits
+	if err := console.Tty().Close(); err != nil {
+		logging.L().Warnf("failed to close console Tty: %v", err)
+	}
+
+	return &expectSession{
+		send: func(response string) error {
+			_, err := console.SendLine(response)
+			return err
+		},
+		wait: func() (int, error) {
+			err := cmd.Wait()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return exitErr.ExitCode(), nil
+			}
+			return 0, err
+		},
+		stop: func() {
+			if cmd.ProcessState == nil {
+				_ = cmd.Process.Kill()
+				_ = cmd.Wait()
 			}
-			matched, err := console.Expect(expect.Regexp(re), expect.WithTimeout(time.Duration(timeout)*time.Second))
+		},
+	}, nil
+}
+
+// startRemote starts the command in a terminal on the remote host, and
+// relays its output through the console's terminal so that prompts are
+// matched in the same way as for local commands
+func (s *ExpectStep) startRemote(execCtx TTPExecutionContext, starter backends.PTYStarter, console *expect.Console, termWidth int) (*expectSession, error) {
+	var env []string
+	for k, v := range execCtx.GlobalEnv {
+		if _, ok := s.Environment[k]; !ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	for k, v := range s.Environment {
+		env = append(env, k+"="+v)
+	}
+
+	// the remote terminal already echoes input and translates line
+	// endings, so the local one must pass its output through unchanged
+	tty := console.Tty()
+	if _, err := term.MakeRaw(int(tty.Fd())); err != nil {
+		return nil, fmt.Errorf("failed to relay remote terminal: %w", err)
+	}
+
+	ptySession, err := starter.StartPTY(backends.PTYSpec{
+		Path:    s.Executor,
+		Args:    []string{"-c", s.Expect.Inline},
+		Env:     env,
+		WorkDir: s.Chdir,
+		Rows:    24,
+		Cols:    termWidth,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	relayed := make(chan struct{})
+	go func() {
+		defer close(relayed)
+		_, _ = io.Copy(tty, ptySession)
+		// the console reaches EOF once the remote terminal is closed
+		_ = tty.Close()
+	}()
+
+	return &expectSession{
+		send: func(response string) error {
+			_, err := io.WriteString(ptySession, response+"\n")
+			return err
+		},
+		wait: func() (int, error) {
+			<-relayed
+			return ptySession.Wait()
+		},
+		stop: func() {
+			_ = ptySession.Close()
+		},
+	}, nil
+}
+
+// expectCandidate is a prompt, or the end of the
+// session, that the step is waiting for
+type expectCandidate struct {
+	// index is the index of the response in the step
+	index    int
+	response *Response
+	re       *regexp.Regexp
+}
+
+// respond waits for each prompt in turn and sends its response, and
+// returns the values of the named capture groups in the matched prompts.
+// An optional response is waited for along with the responses after it,
+// up to and including the next required one, and whichever prompt appears
+// first is responded to.
+func (s *ExpectStep) respond(console *expect.Console, session *expectSession) (map[string]string, error) {
+	captures := make(map[string]string)
+	responses := s.Expect.Responses
+	for i := 0; i < len(responses); {
+		last := i
+		for last < len(responses)-1 && responses[last].Optional {
+			last++
+		}
+		var candidates []*expectCandidate
+		for j := i; j <= last; j++ {
+			c, err := responses[j].candidates(j)
 			if err != nil {
-				done <- fmt.Errorf("failed to expect %q: %w", re, err)
-				return
+				return nil, err
 			}
-			logging.L().Debugf("Matched prompt: %s\n", matched)
-			logging.L().Debugf("Sending response: %s\n", response.Response)
-			if _, err := console.SendLine(response.Response); err != nil {
-				done <- fmt.Errorf("failed to send response: %w", err)
-				return
+			candidates = append(candidates, c...)
+		}
+
+		logging.L().Debugf("Waiting for %s", describeCandidates(candidates))
+		matched, submatches, err := expectAny(console, candidates, s.timeout(&responses[last]))
+		if err != nil {
+			if responses[last].Optional && errors.Is(err, os.ErrDeadlineExceeded) {
+				logging.L().Debugf("Optional prompts did not appear, skipping remaining responses")
+				break
 			}
+			return nil, fmt.Errorf("failed to expect %s: %w", describeCandidates(candidates), err)
 		}
 
-		logging.L().Debugf("Closing console TTY...")
-		if err := console.Tty().Close(); err != nil {
-			done <- fmt.Errorf("failed to close console Tty: %w", err)
-			return
+		if matched.re == nil {
+			logging.L().Debugf("Session ended, skipping remaining responses")
+			break
+		}
+		logging.L().Debugf("Matched prompt: %s\n", submatches[0])
+		for k, name := range matched.re.SubexpNames() {
+			if name != "" {
+				captures[name] = submatches[k]
+			}
 		}
+		logging.L().Debugf("Sending response: %s\n", matched.response.Response)
+		if err := session.send(matched.response.Response); err != nil {
+			return nil, fmt.Errorf("failed to send response: %w", err)
+		}
+		i = matched.index + 1
+	}
+	return captures, nil
+}
 
-		logging.L().Debugf("Waiting for command to exit...")
-		if err := cmd.Wait(); err != nil {
-			done <- fmt.Errorf("command failed: %w", err)
-			return
+// compilePrompt compiles the regular expression of a prompt, checking
+// that its named capture groups do not clash with the built-in outputs
+func compilePrompt(prompt string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt %q: %w", prompt, err)
+	}
+	for _, name := range re.SubexpNames() {
+		if slices.Contains(expectReservedOutputs, name) {
+			return nil, fmt.Errorf("invalid prompt %q: capture group name %q is reserved for a built-in output", prompt, name)
 		}
-		done <- nil
-	}()
+	}
+	return re, nil
+}
 
-	select {
-	case err := <-done:
+// candidates returns the prompts that the response is waiting for
+func (r *Response) candidates(index int) ([]*expectCandidate, error) {
+	if len(r.Branches) == 0 {
+		c := &expectCandidate{index: index, response: r}
+		if !r.EOF {
+			var err error
+			if c.re, err = compilePrompt(r.Prompt); err != nil {
+				return nil, err
+			}
+		}
+		return []*expectCandidate{c}, nil
+	}
+	var candidates []*expectCandidate
+	for i := range r.Branches {
+		c, err := r.Branches[i].candidates(index)
 		if err != nil {
 			return nil, err
 		}
-	case <-time.After(120 * time.Second):
-		return nil, fmt.Errorf("command timed out")
+		candidates = append(candidates, c...)
 	}
+	return candidates, nil
+}
 
-	if _, err := console.ExpectEOF(); err != nil {
-		return nil, fmt.Errorf("failed to expect EOF: %w", err)
+// expectAny reads from the console until one of the candidates is
+// matched, and returns it along with the submatches of its prompt
+func expectAny(console *expect.Console, candidates []*expectCandidate, timeout time.Duration) (*expectCandidate, []string, error) {
+	opts := []expect.ExpectOpt{expect.WithTimeout(timeout)}
+	var res []*regexp.Regexp
+	var eof *expectCandidate
+	for _, c := range candidates {
+		if c.re != nil {
+			res = append(res, c.re)
+		} else if eof == nil {
+			eof = c
+		}
+	}
+	if len(res) > 0 {
+		opts = append(opts, expect.Regexp(res...))
+	}
+	if eof != nil {
+		opts = append(opts, expect.EOF, expect.PTSClosed)
 	}
 
-	return &ActResult{Stdout: transcript.String()}, nil
+	buf, err := console.Expect(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, c := range candidates {
+		if c.re == nil {
+			continue
+		}
+		if submatches := c.re.FindStringSubmatch(buf); submatches != nil {
+			return c, submatches, nil
+		}
+	}
+	if eof != nil {
+		return eof, nil, nil
+	}
+	return nil, nil, fmt.Errorf("no prompt matched %q", buf)
+}
+
+// describeCandidates describes the candidates for log and error messages
+func describeCandidates(candidates []*expectCandidate) string {
+	descriptions := make([]string, len(candidates))
+	for i, c := range candidates {
+		if c.re == nil {
+			descriptions[i] = "EOF"
+		} else {
+			descriptions[i] = fmt.Sprintf("%q", c.re)
+		}
+	}
+	return strings.Join(descriptions, " or ")
+}
+
+// timeout returns how long to wait for the prompt of a response,
+// or for the session to end if the response is nil
+func (s *ExpectStep) timeout(r *Response) time.Duration {
+	timeout := DefaultExpectTimeout
+	if s.Timeout > 0 {
+		timeout = s.Timeout // Use the provided timeout if it is greater than 0
+	}
+	if r != nil && r.Timeout > 0 {
+		timeout = r.Timeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// saveTranscript writes the output of the session to the transcript file
+func (s *ExpectStep) saveTranscript(execCtx TTPExecutionContext, transcript *bytes.Buffer) {
+	path := s.Transcript
+	if execCtx.Vars != nil {
+		if absPath, err := FetchAbs(path, execCtx.Vars.WorkDir); err == nil {
+			path = absPath
+		}
+	}
+	if err := os.WriteFile(path, transcript.Bytes(), 0600); err != nil {
+		logging.L().Errorf("failed to save transcript to %v: %v", path, err)
+		return
+	}
+	logging.L().Infof("Saved transcript of the session to %v", path)
 }
 
 // prepareCommand prepares the command to be executed.
@@ -759,3 +759,413 @@ func TestCanBeUsedInCompositeAction(t *testing.T) {
 		t.Errorf("Expected CanBeUsedInCompositeAction to return true, got false")
 	}
 }
+
+func TestExpectStepValidateResponses(t *testing.T) {
+	testCases := []struct {
+		name           string
+		responses      string
+		expectedErrTxt string
+	}{
+		{
+			name: "prompt and eof",
+			responses: `
+- prompt: "done"
+  eof: true`,
+			expectedErrTxt: "invalid response 1: exactly one of prompt, eof and branches must be specified",
+		},
+		{
+			name: "no prompt",
+			responses: `
+- response: "hello"`,
+			expectedErrTxt: "invalid response 1: exactly one of prompt, eof and branches must be specified",
+		},
+		{
+			name: "eof with response",
+			responses: `
+- eof: true
+  response: "hello"`,
+			expectedErrTxt: "invalid response 1: response cannot be used with eof",
+		},
+		{
+			name: "invalid prompt",
+			responses: `
+- prompt: "name("
+  response: "John"`,
+			expectedErrTxt: "invalid response 1: invalid prompt \"name(\": error parsing regexp: missing closing ): `name(`",
+		},
+		{
+			name: "negative timeout",
+			responses: `
+- prompt: "name"
+  response: "John"
+  timeout: -1`,
+			expectedErrTxt: "invalid response 1: timeout cannot be negative",
+		},
+		{
+			name: "nested branches",
+			responses: `
+- prompt: "name"
+  response: "John"
+- branches:
+    - branches:
+        - prompt: "age"
+          response: "30"`,
+			expectedErrTxt: "invalid response 2: branch 1: branches cannot be nested",
+		},
+		{
+			name: "optional branch",
+			responses: `
+- branches:
+    - prompt: "age"
+      response: "30"
+      optional: true`,
+			expectedErrTxt: "invalid response 1: branch 1: optional and timeout must be set on the response rather than its branches",
+		},
+		{
+			name: "reserved capture name",
+			responses: `
+- prompt: "exit (?P<exit_code>[0-9]+)"
+  response: "ok"`,
+			expectedErrTxt: "invalid response 1: invalid prompt \"exit (?P<exit_code>[0-9]+)\": capture group name \"exit_code\" is reserved for a built-in output",
+		},
+		{
+			name: "valid branches",
+			responses: `
+- prompt: "(?P<user>\\w+)@host"
+  response: "sudo -v"
+  optional: true
+  timeout: 5
+- branches:
+    - prompt: "[Pp]assword"
+      response: "secret"
+    - eof: true`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &ExpectSpec{Inline: "true"}
+			require.NoError(t, yaml.Unmarshal([]byte(tc.responses), &spec.Responses))
+			step := &ExpectStep{Expect: spec}
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.expectedErrTxt != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectedErrTxt, err.Error())
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExpectStepValidateRemote(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "remote executor is not looked up locally",
+			content: `name: remote_expect
+remote: target
+executor: executor-only-on-the-remote
+expect:
+  inline: login
+  responses:
+    - prompt: "Password: "
+      response: hunter2`,
+		},
+		{
+			name: "remote cleanup executor is not looked up locally",
+			content: `name: remote_cleanup
+inline: echo hello
+cleanup:
+  remote: target
+  executor: executor-only-on-the-remote
+  expect:
+    inline: logout
+    responses:
+      - prompt: "Bye"
+        response: "y"`,
+		},
+		{
+			name: "local executor is looked up",
+			content: `name: local_expect
+executor: executor-only-on-the-remote
+expect:
+  inline: login
+  responses:
+    - prompt: "Password: "
+      response: hunter2`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step Step
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			// the backend is only swapped in at execution
+			// time, so it is not set when the TTP is loaded
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.ErrorContains(t, err, "executor not found")
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// runExpectStep unmarshals, validates, templates and executes an expect step
+func runExpectStep(t *testing.T, execCtx TTPExecutionContext, content string) (*ActResult, error) {
+	var step ExpectStep
+	require.NoError(t, yaml.Unmarshal([]byte(content), &step))
+	require.NoError(t, step.Validate(execCtx))
+	require.NoError(t, step.Template(execCtx))
+	return step.Execute(execCtx)
+}
+
+func TestExpectStepResponses(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name                 string
+		content              string
+		wantError            bool
+		expectedOutputs      map[string]string
+		expectedTypedOutputs map[string]any
+		expectedOutput       []string
+	}{
+		{
+			name: "captures named groups",
+			content: `
+expect:
+  inline: |
+    printf 'Session ID: abc123\nUser: '
+    read user
+    echo "Welcome $user"
+  responses:
+    - prompt: "Session ID: (?P<session_id>\\w+)\\s+User: "
+      response: root
+`,
+			expectedOutputs: map[string]string{
+				"session_id": "abc123",
+				"exit_code":  "0",
+			},
+			expectedTypedOutputs: map[string]any{
+				"session_id": "abc123",
+				"exit_code":  0,
+			},
+			expectedOutput: []string{"Welcome root"},
+		},
+		{
+			name: "skips optional prompt that does not appear",
+			content: `
+expect:
+  inline: |
+    printf 'Password: '
+    read password
+    echo "got $password"
+  responses:
+    - prompt: "continue connecting"
+      response: "yes"
+      optional: true
+    - prompt: "Password: "
+      response: hunter2
+`,
+			expectedOutput: []string{"got hunter2"},
+		},
+		{
+			name: "responds to optional prompt that appears",
+			content: `
+expect:
+  inline: |
+    printf 'Are you sure you want to continue connecting? '
+    read answer
+    printf 'Password: '
+    read password
+    echo "got $answer $password"
+  responses:
+    - prompt: "continue connecting"
+      response: "yes"
+      optional: true
+    - prompt: "Password: "
+      response: hunter2
+`,
+			expectedOutput: []string{"got yes hunter2"},
+		},
+		{
+			name: "trailing optional prompt times out",
+			content: `
+expect:
+  inline: |
+    printf 'Name: '
+    read name
+    sleep 2
+  responses:
+    - prompt: "Name: "
+      response: John
+    - prompt: "Age: "
+      response: "30"
+      optional: true
+      timeout: 1
+`,
+		},
+		{
+			name: "follows matching branch",
+			content: `
+expect:
+  inline: |
+    printf '[sudo] password for root: '
+    read password
+    echo "authenticated with $password"
+  responses:
+    - branches:
+        - prompt: "Sorry, try again"
+          response: wrong
+        - prompt: "password for (?P<user>\\w+): "
+          response: hunter2
+`,
+			expectedOutputs: map[string]string{
+				"user": "root",
+			},
+			expectedOutput: []string{"authenticated with hunter2"},
+		},
+		{
+			name: "eof branch ends session",
+			content: `
+expect:
+  inline: echo cached credentials
+  responses:
+    - branches:
+        - prompt: "[Pp]assword"
+          response: hunter2
+        - eof: true
+    - prompt: "never"
+      response: "never"
+`,
+			expectedOutput: []string{"cached credentials"},
+		},
+		{
+			name: "expected exit code",
+			content: `
+expect:
+  inline: |
+    printf 'Continue? '
+    read answer
+    exit 3
+  exit_code: 3
+  responses:
+    - prompt: "Continue\\? "
+      response: "no"
+`,
+			expectedOutputs: map[string]string{
+				"exit_code": "3",
+			},
+		},
+		{
+			name: "unexpected exit code",
+			content: `
+expect:
+  inline: |
+    printf 'Continue? '
+    read answer
+    exit 1
+  responses:
+    - prompt: "Continue\\? "
+      response: "no"
+`,
+			wantError: true,
+		},
+		{
+			name: "per-response timeout",
+			content: `
+timeout: 60
+expect:
+  inline: sleep 10
+  responses:
+    - prompt: "never"
+      response: "never"
+      timeout: 1
+`,
+			wantError: true,
+		},
+		{
+			name: "unexpected eof",
+			content: `
+expect:
+  inline: echo done
+  responses:
+    - prompt: "never"
+      response: "never"
+`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			execCtx := NewTTPExecutionContext()
+			execCtx.Vars.WorkDir = t.TempDir()
+			start := time.Now()
+			result, err := runExpectStep(t, execCtx, "executor: sh\n"+tc.content)
+			assert.Less(t, time.Since(start), 10*time.Second)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for name, value := range tc.expectedOutputs {
+				assert.Equal(t, value, result.Outputs[name])
+			}
+			for name, value := range tc.expectedTypedOutputs {
+				assert.Equal(t, value, result.TypedOutputs[name])
+			}
+			for _, out := range tc.expectedOutput {
+				assert.Contains(t, result.Stdout, out)
+			}
+		})
+	}
+}
+
+func TestExpectStepTranscript(t *testing.T) {
+	tempDir := t.TempDir()
+	execCtx := NewTTPExecutionContext()
+	execCtx.Vars.WorkDir = tempDir
+
+	content := `
+executor: sh
+transcript: session.log
+expect:
+  inline: |
+    printf 'Name: '
+    read name
+    echo "Hello, $name"
+    exit 1
+  responses:
+    - prompt: "Name: "
+      response: John
+`
+	// the transcript is saved even if the step fails
+	_, err := runExpectStep(t, execCtx, content)
+	require.Error(t, err)
+	transcript, err := os.ReadFile(filepath.Join(tempDir, "session.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(transcript), "Name: ")
+	assert.Contains(t, string(transcript), "Hello, John")
+}
+
+func TestExpectStepRemoteUnsupported(t *testing.T) {
+	execCtx := NewTTPExecutionContext()
+	execCtx.Backend = newMockBackend("remote")
+	step := &ExpectStep{
+		Executor: "sh",
+		Expect: &ExpectSpec{
+			Inline:    "true",
+			Responses: []Response{{Prompt: "never", Response: "never"}},
+		},
+	}
+	_, err := step.Execute(execCtx)
+	assert.EqualError(t, err, "expect action is not supported by the remote: backend")
+}
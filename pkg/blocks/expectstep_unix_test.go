@@ -0,0 +1,114 @@
+//go:build unix
+
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/creack/pty"
+	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ptyBackend is a mock backend that runs commands in
+// local pseudo-terminals, as if they were remote ones
+type ptyBackend struct {
+	*mockBackend
+	specs []backends.PTYSpec
+}
+
+type localPTYSession struct {
+	*os.File
+	cmd *exec.Cmd
+}
+
+func (s *localPTYSession) Wait() (int, error) {
+	err := s.cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+func (s *localPTYSession) Read(p []byte) (int, error) {
+	n, err := s.File.Read(p)
+	if err != nil && n == 0 {
+		// reads fail with EIO once the command exits
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+func (b *ptyBackend) StartPTY(spec backends.PTYSpec) (backends.PTYSession, error) {
+	b.specs = append(b.specs, spec)
+	cmd := exec.Command(spec.Path, spec.Args...)
+	cmd.Env = append(os.Environ(), spec.Env...)
+	cmd.Dir = spec.WorkDir
+	ptm, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(spec.Rows), Cols: uint16(spec.Cols)})
+	if err != nil {
+		return nil, err
+	}
+	return &localPTYSession{File: ptm, cmd: cmd}, nil
+}
+
+func TestExpectStepRemote(t *testing.T) {
+	workDir := t.TempDir()
+	backend := &ptyBackend{mockBackend: newMockBackend("remote")}
+	execCtx := NewTTPExecutionContext()
+	execCtx.Backend = backend
+	execCtx.GlobalEnv = map[string]string{"GREETING": "Hello"}
+
+	content := fmt.Sprintf(`
+executor: sh
+chdir: %v
+terminal_width: 200
+env:
+  PASSWORD: hunter2
+expect:
+  inline: |
+    printf 'Password: '
+    read password
+    if [ "$password" = "$PASSWORD" ]; then echo "$GREETING from $(pwd)"; else exit 1; fi
+  responses:
+    - prompt: "Password: "
+      response: "{[{.StepVars.password}]}"
+`, workDir)
+	execCtx.Vars.StepVars = map[string]string{"password": "hunter2"}
+	result, err := runExpectStep(t, execCtx, content)
+	require.NoError(t, err)
+	assert.Contains(t, result.Stdout, "Hello from "+workDir)
+	assert.Equal(t, "0", result.Outputs["exit_code"])
+
+	require.Len(t, backend.specs, 1)
+	spec := backend.specs[0]
+	assert.Equal(t, "sh", spec.Path)
+	assert.Equal(t, workDir, spec.WorkDir)
+	assert.Equal(t, 200, spec.Cols)
+	assert.ElementsMatch(t, []string{"GREETING=Hello", "PASSWORD=hunter2"}, spec.Env)
+}
@@ -0,0 +1,251 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/facebookincubator/ttpforge/pkg/archives"
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// ExtractStep unpacks a zip or tar archive into a directory.
+// Its intended use is simulating the unpacking of tooling
+// or payloads that were delivered as an archive.
+type ExtractStep struct {
+	actionDefaults `yaml:",inline"`
+	Path           string   `yaml:"extract,omitempty"`
+	Destination    string   `yaml:"to,omitempty"`
+	Format         string   `yaml:"format,omitempty"`
+	Include        []string `yaml:"include,omitempty"`
+	Exclude        []string `yaml:"exclude,omitempty"`
+	Password       string   `yaml:"password,omitempty"`
+	Overwrite      bool     `yaml:"overwrite,omitempty"`
+	FileSystem     afero.Fs `yaml:"-,omitempty"`
+
+	// extracted records what was created so that
+	// the default cleanup action can remove it
+	extracted *archives.Extracted
+}
+
+// NewExtractStep creates a new ExtractStep instance and returns a pointer to it.
+func NewExtractStep() *ExtractStep {
+	return &ExtractStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *ExtractStep) IsNil() bool {
+	switch s.Path {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *ExtractStep) Validate(_ TTPExecutionContext) error {
+	if s.Path == "" {
+		return fmt.Errorf("extract field cannot be empty")
+	}
+	if s.Destination == "" {
+		return fmt.Errorf("to field cannot be empty")
+	}
+	if s.Format != "" {
+		format, err := archives.ResolveFormat(s.Format, s.Path)
+		if err != nil {
+			return err
+		}
+		if s.Password != "" && format != archives.FormatZip {
+			return fmt.Errorf("password is only supported for zip archives")
+		}
+	}
+	return s.filter().Validate()
+}
+
+func (s *ExtractStep) filter() archives.Filter {
+	return archives.Filter{Include: s.Include, Exclude: s.Exclude}
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *ExtractStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Path, err = execCtx.templateStep(s.Path)
+	if err != nil {
+		return err
+	}
+	s.Destination, err = execCtx.templateStep(s.Destination)
+	if err != nil {
+		return err
+	}
+	s.Password, err = execCtx.templateStep(s.Password)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *ExtractStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	logging.L().Infof("Extracting archive %v to %v", s.Path, s.Destination)
+	fsys, err := execCtx.getFs(s.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := fileutils.ExpandPath(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	dest, err := fileutils.ExpandPath(s.Destination)
+	if err != nil {
+		return nil, err
+	}
+	format, err := archives.ResolveFormat(s.Format, archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	s.extracted, err = archives.Extract(fsys, archivePath, dest, archives.ExtractOptions{
+		Format:    format,
+		Password:  s.Password,
+		Overwrite: s.Overwrite,
+		Filter:    s.filter(),
+	})
+	if err != nil {
+		// failed steps are not cleaned up, so remove
+		// whatever a partial extraction wrote now
+		if rbErr := removeWritten(fsys, s.extracted); rbErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to remove partially extracted files: %w", rbErr))
+		}
+		s.extracted = nil
+		return nil, err
+	}
+	logging.L().Infof("Extracted %d file(s) to %v", len(s.extracted.Files), dest)
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"destination": dest,
+			"files":       strings.Join(s.extracted.Files, "\n"),
+		},
+		TypedOutputs: map[string]any{
+			"files": stringList(s.extracted.Files),
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetDefaultCleanupAction will instruct the calling code
+// to remove the files and directories created by this action
+func (s *ExtractStep) GetDefaultCleanupAction() Action {
+	return &extractCleanupAction{step: s}
+}
+
+// extractCleanupAction removes exactly what an ExtractStep
+// created, leaving any pre-existing files in the destination alone
+type extractCleanupAction struct {
+	actionDefaults
+	step *ExtractStep
+}
+
+// IsNil is not needed here, as this is not a user-accessible step type
+func (a *extractCleanupAction) IsNil() bool {
+	return false
+}
+
+// Validate is not needed here, as this is not a user-accessible step type
+func (a *extractCleanupAction) Validate(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Template is not needed here, as this is not a user-accessible step type
+func (a *extractCleanupAction) Template(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Execute removes the extracted files, followed by any directories
+// that the extraction created, as long as they are now empty
+func (a *extractCleanupAction) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	extracted := a.step.extracted
+	if extracted == nil {
+		logging.L().Info("Nothing was extracted - skipping cleanup")
+		return &ActResult{}, nil
+	}
+	fsys, err := execCtx.getFs(a.step.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+	if err := removeWritten(fsys, extracted); err != nil {
+		return nil, err
+	}
+	return &ActResult{}, nil
+}
+
+// removeWritten removes the files that an extract or archive step
+// created, followed by any directories it created that are now empty.
+// Files that were overwritten rather than created are left in place.
+func removeWritten(fsys afero.Fs, extracted *archives.Extracted) error {
+	if extracted == nil {
+		return nil
+	}
+	replaced := make(map[string]bool, len(extracted.Replaced))
+	for _, file := range extracted.Replaced {
+		replaced[file] = true
+	}
+	logging.L().Infof("Removing %d created file(s)", len(extracted.Files)-len(extracted.Replaced))
+	for i := len(extracted.Files) - 1; i >= 0; i-- {
+		if replaced[extracted.Files[i]] {
+			logging.L().Warnf("%v existed before this step - leaving it in place", extracted.Files[i])
+			continue
+		}
+		if err := fsys.Remove(extracted.Files[i]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %v: %w", extracted.Files[i], err)
+		}
+	}
+	for i := len(extracted.Dirs) - 1; i >= 0; i-- {
+		dir := extracted.Dirs[i]
+		empty, err := afero.IsEmpty(fsys, dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !empty {
+			logging.L().Warnf("Directory %v is not empty - leaving it in place", dir)
+			continue
+		}
+		if err := fsys.Remove(dir); err != nil {
+			return fmt.Errorf("failed to remove %v: %w", dir, err)
+		}
+	}
+	return nil
+}
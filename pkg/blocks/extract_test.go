@@ -0,0 +1,245 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"testing"
+
+	"github.com/facebookincubator/ttpforge/pkg/archives"
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func makeTestArchive(t *testing.T, fsys afero.Fs, archivePath, format, password string) {
+	require.NoError(t, afero.WriteFile(fsys, "/payload/tools/implant.sh", []byte("#!/bin/sh\necho pwned"), 0755))
+	require.NoError(t, afero.WriteFile(fsys, "/payload/tools/README", []byte("read me"), 0644))
+	_, err := archives.Create(fsys, archivePath, []string{"/payload/tools"}, archives.CreateOptions{
+		Format:   format,
+		Password: password,
+	})
+	require.NoError(t, err)
+}
+
+func TestExtractStepValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Valid",
+			content: `extract: /tmp/payload.zip
+to: /tmp/payload`,
+		},
+		{
+			name:      "Missing Destination",
+			content:   `extract: /tmp/payload.zip`,
+			wantError: true,
+		},
+		{
+			name: "Password With Tar Gz",
+			content: `extract: /tmp/payload.tar.gz
+to: /tmp/payload
+format: tar.gz
+password: infected`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step ExtractStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestExtractStepExecuteAndCleanup(t *testing.T) {
+	testCases := []struct {
+		name          string
+		archivePath   string
+		format        string
+		password      string
+		step          *ExtractStep
+		expectedFiles []any
+		wantError     bool
+	}{
+		{
+			name:        "Zip Into New Directory",
+			archivePath: "/tmp/payload.zip",
+			format:      archives.FormatZip,
+			step: &ExtractStep{
+				Path:        "/tmp/payload.zip",
+				Destination: "/opt/staging/new",
+			},
+			expectedFiles: []any{"/opt/staging/new/tools/README", "/opt/staging/new/tools/implant.sh"},
+		},
+		{
+			name:        "Password Protected Zip",
+			archivePath: "/tmp/payload.zip",
+			format:      archives.FormatZip,
+			password:    "infected",
+			step: &ExtractStep{
+				Path:        "/tmp/payload.zip",
+				Destination: "/opt/staging",
+				Password:    "infected",
+				Include:     []string{"*.sh"},
+			},
+			expectedFiles: []any{"/opt/staging/tools/implant.sh"},
+		},
+		{
+			name:        "Tar Gz",
+			archivePath: "/tmp/payload.tar.gz",
+			format:      archives.FormatTarGz,
+			step: &ExtractStep{
+				Path:        "/tmp/payload.tar.gz",
+				Destination: "/opt/staging",
+			},
+			expectedFiles: []any{"/opt/staging/tools/README", "/opt/staging/tools/implant.sh"},
+		},
+		{
+			name:        "Wrong Password",
+			archivePath: "/tmp/payload.zip",
+			format:      archives.FormatZip,
+			password:    "infected",
+			step: &ExtractStep{
+				Path:        "/tmp/payload.zip",
+				Destination: "/opt/staging",
+				Password:    "wrong",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys, err := testutils.MakeAferoTestFs(map[string][]byte{
+				"/opt/staging/existing.txt": []byte("do not remove"),
+			})
+			require.NoError(t, err)
+			makeTestArchive(t, fsys, tc.archivePath, tc.format, tc.password)
+			tc.step.FileSystem = fsys
+
+			execCtx := NewTTPExecutionContext()
+			require.NoError(t, tc.step.Template(execCtx))
+			result, err := tc.step.Execute(execCtx)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedFiles, result.TypedOutputs["files"])
+			for _, file := range tc.expectedFiles {
+				exists, err := afero.Exists(fsys, file.(string))
+				require.NoError(t, err)
+				assert.True(t, exists)
+			}
+
+			// cleanup removes only what was extracted
+			_, err = tc.step.GetDefaultCleanupAction().Execute(execCtx)
+			require.NoError(t, err)
+			for _, file := range tc.expectedFiles {
+				exists, err := afero.Exists(fsys, file.(string))
+				require.NoError(t, err)
+				assert.False(t, exists)
+			}
+			exists, err := afero.Exists(fsys, tc.step.Destination+"/tools")
+			require.NoError(t, err)
+			assert.False(t, exists)
+			exists, err = afero.Exists(fsys, "/opt/staging/existing.txt")
+			require.NoError(t, err)
+			assert.True(t, exists)
+		})
+	}
+}
+
+func TestExtractStepCleanupBeforeExecute(t *testing.T) {
+	step := &ExtractStep{
+		Path:        "/tmp/payload.zip",
+		Destination: "/opt/staging",
+		FileSystem:  afero.NewMemMapFs(),
+	}
+	_, err := step.GetDefaultCleanupAction().Execute(NewTTPExecutionContext())
+	assert.NoError(t, err)
+}
+
+func TestExtractStepFailureRollsBack(t *testing.T) {
+	fsys, err := testutils.MakeAferoTestFs(map[string][]byte{
+		// blocks the extraction of implant.sh, after README was extracted
+		"/opt/staging/tools/implant.sh": []byte("in the way"),
+	})
+	require.NoError(t, err)
+	makeTestArchive(t, fsys, "/tmp/payload.zip", archives.FormatZip, "")
+	step := &ExtractStep{
+		Path:        "/tmp/payload.zip",
+		Destination: "/opt/staging",
+		FileSystem:  fsys,
+	}
+
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Template(execCtx))
+	_, err = step.Execute(execCtx)
+	require.Error(t, err)
+
+	exists, err := afero.Exists(fsys, "/opt/staging/tools/README")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	contents, err := afero.ReadFile(fsys, "/opt/staging/tools/implant.sh")
+	require.NoError(t, err)
+	assert.Equal(t, "in the way", string(contents))
+}
+
+func TestExtractStepOverwriteKeepsExisting(t *testing.T) {
+	fsys, err := testutils.MakeAferoTestFs(map[string][]byte{
+		"/opt/staging/tools/implant.sh": []byte("the user's own file"),
+	})
+	require.NoError(t, err)
+	makeTestArchive(t, fsys, "/tmp/payload.zip", archives.FormatZip, "")
+	step := &ExtractStep{
+		Path:        "/tmp/payload.zip",
+		Destination: "/opt/staging",
+		Overwrite:   true,
+		FileSystem:  fsys,
+	}
+
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Template(execCtx))
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"/opt/staging/tools/README", "/opt/staging/tools/implant.sh"}, result.TypedOutputs["files"])
+
+	_, err = step.GetDefaultCleanupAction().Execute(execCtx)
+	require.NoError(t, err)
+	exists, err := afero.Exists(fsys, "/opt/staging/tools/README")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = afero.Exists(fsys, "/opt/staging/tools/implant.sh")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
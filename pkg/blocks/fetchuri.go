@@ -20,26 +20,85 @@ THE SOFTWARE.
 package blocks
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/spf13/afero"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultFetchRetryDelay is the default delay before the first retry of a failed download
+const DefaultFetchRetryDelay = time.Second
+
+// DefaultFetchTimeout is the default time limit for each attempt to download over HTTP(S)
+const DefaultFetchTimeout = 10 * time.Minute
+
+// sha256Regex matches a hex-encoded SHA256 digest
+var sha256Regex = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
 // FetchURIStep represents a step in a process that consists of a main action,
 // a cleanup action, and additional metadata.
 type FetchURIStep struct {
 	actionDefaults `yaml:",inline"`
-	FetchURI       string   `yaml:"fetch_uri,omitempty"`
-	Retries        string   `yaml:"retries,omitempty"`
-	Location       string   `yaml:"location,omitempty"`
-	Proxy          string   `yaml:"proxy,omitempty"`
-	Overwrite      bool     `yaml:"overwrite,omitempty"`
-	FileSystem     afero.Fs `yaml:"-,omitempty"`
+	FetchURI       string        `yaml:"fetch_uri,omitempty"`
+	Retries        int           `yaml:"-"`
+	RetryDelay     string        `yaml:"retry_delay,omitempty"`
+	Timeout        string        `yaml:"timeout,omitempty"`
+	Location       string        `yaml:"location,omitempty"`
+	Proxy          string        `yaml:"proxy,omitempty"`
+	Overwrite      bool          `yaml:"overwrite,omitempty"`
+	Resume         bool          `yaml:"resume,omitempty"`
+	SHA256         string        `yaml:"sha256,omitempty"`
+	Headers        []*HTTPHeader `yaml:"headers,omitempty"`
+	Auth           *HTTPAuth     `yaml:"auth,omitempty"`
+	FileSystem     afero.Fs      `yaml:"-,omitempty"`
+
+	retriesTemplate string
+	retryDelay      time.Duration
+	timeout         time.Duration
+}
+
+// UnmarshalYAML decodes a FetchURIStep. Retries is an integer, but it
+// may also be given as a string, either quoted (`"3"`) or templated
+// (`"{[{.StepVars.retries}]}"`), so it is decoded separately. Templated
+// values are resolved into Retries when the step is templated.
+func (f *FetchURIStep) UnmarshalYAML(node *yaml.Node) error {
+	type fetchURIFields FetchURIStep
+	if err := node.Decode((*fetchURIFields)(f)); err != nil {
+		return err
+	}
+
+	var fields struct {
+		Retries string `yaml:"retries,omitempty"`
+	}
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	f.retriesTemplate = ""
+	if fields.Retries == "" {
+		return nil
+	}
+	retries, err := strconv.Atoi(fields.Retries)
+	if err != nil {
+		// left for Validate and Template to check
+		f.retriesTemplate = fields.Retries
+		return nil
+	}
+	f.Retries = retries
+	return nil
 }
 
 // NewFetchURIStep creates a new FetchURIStep instance and returns a pointer to it.
@@ -76,6 +135,40 @@ func (f *FetchURIStep) Validate(execCtx TTPExecutionContext) error {
 		return fmt.Errorf("require Location to be set with fetchURI")
 	}
 
+	if f.Overwrite && f.Resume {
+		return fmt.Errorf("overwrite and resume cannot both be set")
+	}
+
+	if f.SHA256 != "" && !sha256Regex.MatchString(f.SHA256) {
+		return fmt.Errorf("sha256 must be a hex-encoded SHA256 digest, got %q", f.SHA256)
+	}
+
+	for _, header := range f.Headers {
+		if header.Field == "" || header.Value == "" {
+			return fmt.Errorf("broken HTTP header %s: %s", header.Value, header.Field)
+		}
+	}
+
+	if f.Auth != nil {
+		if err := f.Auth.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate URI scheme and the settings that depend on it
+	if !execCtx.containsStepTemplating(f.FetchURI) {
+		if err := f.validateURI(); err != nil {
+			return err
+		}
+	}
+
+	// Validate retries, the delay between them and the timeout of each attempt
+	if !execCtx.containsStepTemplating(f.retriesTemplate) {
+		if err := f.validateRetries(); err != nil {
+			return err
+		}
+	}
+
 	// Validate Proxy is valid URI
 	if f.Proxy != "" && !execCtx.containsStepTemplating(f.Proxy) {
 		err := f.validateProxy()
@@ -102,16 +195,41 @@ func (f *FetchURIStep) Validate(execCtx TTPExecutionContext) error {
 func (f *FetchURIStep) Template(execCtx TTPExecutionContext) error {
 	var err error
 
-	// Template URI
-	f.FetchURI, err = execCtx.templateStep(f.FetchURI)
-	if err != nil {
-		return err
+	// Template and revalidate URI
+	if execCtx.containsStepTemplating(f.FetchURI) {
+		f.FetchURI, err = execCtx.templateStep(f.FetchURI)
+		if err != nil {
+			return err
+		}
+		err = f.validateURI()
+		if err != nil {
+			return err
+		}
 	}
 
-	// Template retries
-	f.Retries, err = execCtx.templateStep(f.Retries)
-	if err != nil {
-		return err
+	// Template and revalidate retries
+	if execCtx.containsStepTemplating(f.retriesTemplate) {
+		f.retriesTemplate, err = execCtx.templateStep(f.retriesTemplate)
+		if err != nil {
+			return err
+		}
+		err = f.validateRetries()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Template headers and credentials
+	for _, header := range f.Headers {
+		header.Value, err = execCtx.templateStep(header.Value)
+		if err != nil {
+			return err
+		}
+	}
+	if f.Auth != nil {
+		if err := f.Auth.Template(execCtx); err != nil {
+			return err
+		}
 	}
 
 	// Template and revalidate location
@@ -145,7 +263,8 @@ func (f *FetchURIStep) Template(execCtx TTPExecutionContext) error {
 func (f *FetchURIStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 	logging.L().Info("========= Executing ==========")
 	logging.L().Infof("FetchURI: %s", f.FetchURI)
-	if err := f.fetchURI(execCtx); err != nil {
+	absLocal, size, digest, err := f.fetchURI(execCtx)
+	if err != nil {
 		logging.L().Error(zap.Error(err))
 		return nil, err
 	}
@@ -163,12 +282,28 @@ func (f *FetchURIStep) Execute(execCtx TTPExecutionContext) (*ActResult, error)
 		execCtx.Vars.StepVars[f.OutputVar] = string(content)
 	}
 
-	return &ActResult{}, nil
+	result := &ActResult{
+		Outputs: map[string]string{
+			"path":   absLocal,
+			"size":   strconv.FormatInt(size, 10),
+			"sha256": digest,
+		},
+		TypedOutputs: map[string]any{
+			"path":   absLocal,
+			"size":   int(size),
+			"sha256": digest,
+		},
+	}
+	if err := f.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// fetchURI executes the FetchURIStep with the specified Location, Uri, and additional arguments,
-// and an error if any errors occur.
-func (f *FetchURIStep) fetchURI(execCtx TTPExecutionContext) error {
+// fetchURI executes the FetchURIStep with the specified Location, Uri, and additional arguments.
+// It returns the path that the content was written to, its size and the hex-encoded
+// SHA256 digest of the content, or an error if any errors occur.
+func (f *FetchURIStep) fetchURI(execCtx TTPExecutionContext) (string, int64, string, error) {
 	appFs := f.FileSystem
 	absLocal := f.Location
 
@@ -177,7 +312,7 @@ func (f *FetchURIStep) fetchURI(execCtx TTPExecutionContext) error {
 			var err error
 			appFs, err = execCtx.Backend.GetFs()
 			if err != nil {
-				return fmt.Errorf("failed to get filesystem: %w", err)
+				return "", 0, "", fmt.Errorf("failed to get filesystem: %w", err)
 			}
 			// For remote execution, use paths as-is
 		} else {
@@ -185,50 +320,223 @@ func (f *FetchURIStep) fetchURI(execCtx TTPExecutionContext) error {
 			appFs = afero.NewOsFs()
 			absLocal, err = FetchAbs(f.Location, execCtx.Vars.WorkDir)
 			if err != nil {
-				return err
+				return "", 0, "", err
 			}
 		}
 	}
 
-	if ok, _ := afero.Exists(appFs, absLocal); ok && !f.Overwrite {
+	if ok, _ := afero.Exists(appFs, absLocal); ok && !f.Overwrite && !f.Resume {
 		logging.L().Errorw("location exists, remove and retry", "location", absLocal)
-		return fmt.Errorf("location [%s] exists and overwrite is set to false. remove and retry", f.Location)
+		return "", 0, "", fmt.Errorf("location [%s] exists and overwrite is set to false. remove and retry", f.Location)
 	}
 
-	client := http.DefaultClient
-	if f.Proxy != "" && !execCtx.Cfg.NoProxy {
-		proxyURI, err := url.Parse(f.Proxy)
-		if err != nil {
-			return err
-		} else if proxyURI.Host == "" || proxyURI.Scheme == "" {
-			return fmt.Errorf("invalid URI given for Proxy: %s", f.Proxy)
+	open, err := f.source(execCtx)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	// retry failed downloads with exponential backoff,
+	// resuming from the partial file if requested
+	var size int64
+	var digest string
+	var written bool
+	delay := f.retryDelay
+	for attempt := 0; ; attempt++ {
+		var wrote bool
+		size, digest, wrote, err = f.download(appFs, absLocal, open)
+		written = written || wrote
+		if err == nil {
+			break
 		}
-		tr := &http.Transport{
-			Proxy: http.ProxyURL(proxyURI),
+		var permanent *permanentFetchError
+		if attempt >= f.Retries || errors.As(err, &permanent) {
+			// the partial file is only kept if a later run may resume it
+			if written && !f.Resume {
+				if err := appFs.Remove(absLocal); err != nil {
+					logging.L().Warnw("failed to remove partial download", "location", absLocal, zap.Error(err))
+				}
+			}
+			return "", 0, "", err
 		}
-		client = &http.Client{Transport: tr}
+		logging.L().Warnf("Download attempt %d of %d failed, retrying in %v: %v", attempt+1, f.Retries+1, delay, err)
+		time.Sleep(delay)
+		delay *= 2
 	}
 
-	resp, err := client.Get(f.FetchURI)
-	if err != nil {
-		return err
+	if f.SHA256 != "" && !strings.EqualFold(digest, f.SHA256) {
+		if err := appFs.Remove(absLocal); err != nil {
+			logging.L().Warnw("failed to remove file with mismatched digest", "location", absLocal, zap.Error(err))
+		}
+		return "", 0, "", fmt.Errorf("SHA256 digest of %v is %v, expected %v", f.FetchURI, digest, strings.ToLower(f.SHA256))
 	}
-	defer resp.Body.Close()
 
-	fHandle, err := appFs.Create(absLocal)
+	logging.L().Debugw("wrote contents of URI to specified location", "location", absLocal, "uri", f.FetchURI, "size", size)
+
+	return absLocal, size, digest, nil
+}
+
+// fetchOpener opens the source of the download, starting from
+// the requested offset if possible. It returns the offset that
+// the returned reader actually starts from.
+type fetchOpener func(offset int64) (io.ReadCloser, int64, error)
+
+// permanentFetchError marks failures that retrying will not fix
+type permanentFetchError struct {
+	err error
+}
+
+func (e *permanentFetchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentFetchError) Unwrap() error {
+	return e.err
+}
+
+// download writes the source to path, appending to any existing
+// partial file if resume is set, and returns the size and the
+// hex-encoded SHA256 digest of the complete file. It also reports
+// whether the file at path was created or modified, even on failure.
+func (f *FetchURIStep) download(appFs afero.Fs, path string, open fetchOpener) (int64, string, bool, error) {
+	var offset int64
+	if f.Resume {
+		if info, err := appFs.Stat(path); err == nil && info.Mode().IsRegular() {
+			offset = info.Size()
+		}
+	}
+
+	body, start, err := open(offset)
 	if err != nil {
-		return err
+		return 0, "", false, err
+	}
+	defer body.Close()
+
+	hasher := sha256.New()
+	var fHandle afero.File
+	if start > 0 {
+		logging.L().Infof("Resuming download of %v from byte %d", f.FetchURI, start)
+		partial, err := appFs.Open(path)
+		if err != nil {
+			return 0, "", false, err
+		}
+		_, err = io.CopyN(hasher, partial, start)
+		partial.Close()
+		if err != nil {
+			return 0, "", false, err
+		}
+		fHandle, err = appFs.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return 0, "", false, err
+		}
+	} else {
+		fHandle, err = appFs.Create(path)
+		if err != nil {
+			return 0, "", false, err
+		}
 	}
 	defer fHandle.Close()
 
-	_, err = io.Copy(fHandle, resp.Body)
+	n, err := io.Copy(io.MultiWriter(fHandle, hasher), body)
 	if err != nil {
-		return err
+		return 0, "", true, err
 	}
+	return start + n, hex.EncodeToString(hasher.Sum(nil)), true, nil
+}
 
-	logging.L().Debugw("wrote contents of URI to specified location", "location", absLocal, "uri", f.FetchURI)
+// source returns the opener for the scheme of the URI
+func (f *FetchURIStep) source(execCtx TTPExecutionContext) (fetchOpener, error) {
+	uri, err := url.Parse(f.FetchURI)
+	if err != nil {
+		return nil, err
+	}
+	switch uri.Scheme {
+	case "file":
+		// file:// URIs are read from the machine running TTPForge
+		return openFileSource(afero.NewOsFs(), uri.Path), nil
+	case "sftp":
+		if execCtx.ConnPool == nil {
+			return nil, fmt.Errorf("cannot fetch %v: connection pool is not initialized", f.FetchURI)
+		}
+		backend, err := execCtx.ConnPool.GetByName(uri.Host)
+		if err != nil {
+			return nil, err
+		}
+		remoteFs, err := backend.GetFs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get filesystem for connection %q: %w", uri.Host, err)
+		}
+		return openFileSource(remoteFs, uri.Path), nil
+	}
 
-	return nil
+	// the timeout covers reading the body as well, so that
+	// a stalled download fails and can be retried
+	client := &http.Client{Timeout: f.timeout}
+	if f.Proxy != "" && !execCtx.Cfg.NoProxy {
+		tr, err := newHTTPTransport(HTTPTLSConfig{}, f.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = tr
+	}
+	return func(offset int64) (io.ReadCloser, int64, error) {
+		req, err := http.NewRequest(http.MethodGet, f.FetchURI, nil)
+		if err != nil {
+			return nil, 0, &permanentFetchError{err}
+		}
+		for _, header := range f.Headers {
+			req.Header.Set(header.Field, header.Value)
+		}
+		if f.Auth != nil {
+			f.Auth.apply(req)
+		}
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && offset > 0:
+			return resp.Body, offset, nil
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+			// the partial file is already complete
+			resp.Body.Close()
+			return io.NopCloser(strings.NewReader("")), offset, nil
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			// the server ignored the range, so start over
+			return resp.Body, 0, nil
+		}
+		resp.Body.Close()
+		err = fmt.Errorf("server responded to %v with status %v", f.FetchURI, resp.Status)
+		switch {
+		case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+			return nil, 0, err
+		default:
+			return nil, 0, &permanentFetchError{err}
+		}
+	}, nil
+}
+
+// openFileSource returns an opener that reads path from fsys
+func openFileSource(fsys afero.Fs, path string) fetchOpener {
+	return func(offset int64) (io.ReadCloser, int64, error) {
+		file, err := fsys.Open(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, 0, &permanentFetchError{err}
+			}
+			return nil, 0, err
+		}
+		if offset > 0 {
+			if _, err := file.Seek(offset, io.SeekStart); err != nil {
+				file.Close()
+				return nil, 0, err
+			}
+		}
+		return file, offset, nil
+	}
 }
 
 // GetDefaultCleanupAction will instruct the calling code
@@ -239,6 +547,71 @@ func (f *FetchURIStep) GetDefaultCleanupAction() Action {
 	}
 }
 
+// validateURI validates that the URI has a supported scheme, and that
+// HTTP-only settings aren't used with other schemes. Returns an error if
+// validation fails, otherwise returns nil.
+func (f *FetchURIStep) validateURI() error {
+	uri, err := url.Parse(f.FetchURI)
+	if err != nil {
+		return err
+	}
+	switch uri.Scheme {
+	case "http", "https":
+		return nil
+	case "file":
+		if uri.Host != "" && uri.Host != "localhost" {
+			return fmt.Errorf("file URI %v must refer to a local path, such as file:///tmp/file", f.FetchURI)
+		}
+	case "sftp":
+		if uri.Host == "" {
+			return fmt.Errorf("sftp URI %v must specify a connection name, such as sftp://<connection_name>/tmp/file", f.FetchURI)
+		}
+	default:
+		return fmt.Errorf("unsupported URI scheme %q in %v, must be one of http, https, file or sftp", uri.Scheme, f.FetchURI)
+	}
+	if uri.Path == "" {
+		return fmt.Errorf("URI %v must specify a path", f.FetchURI)
+	}
+	if len(f.Headers) > 0 || f.Auth != nil || f.Proxy != "" {
+		return fmt.Errorf("headers, auth and proxy can only be used with http and https URIs")
+	}
+	return nil
+}
+
+// validateRetries validates the number of retries, the delay between
+// them and the timeout of each attempt. Returns an error if validation
+// fails, otherwise returns nil.
+func (f *FetchURIStep) validateRetries() error {
+	if f.retriesTemplate != "" {
+		retries, err := strconv.Atoi(f.retriesTemplate)
+		if err != nil {
+			return fmt.Errorf("retries must be a non-negative integer, got %q", f.retriesTemplate)
+		}
+		f.Retries = retries
+		f.retriesTemplate = ""
+	}
+	if f.Retries < 0 {
+		return fmt.Errorf("retries must be a non-negative integer, got %d", f.Retries)
+	}
+	f.retryDelay = DefaultFetchRetryDelay
+	if f.RetryDelay != "" {
+		delay, err := time.ParseDuration(f.RetryDelay)
+		if err != nil || delay < 0 {
+			return fmt.Errorf("invalid retry_delay %q", f.RetryDelay)
+		}
+		f.retryDelay = delay
+	}
+	f.timeout = DefaultFetchTimeout
+	if f.Timeout != "" {
+		timeout, err := time.ParseDuration(f.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q", f.Timeout)
+		}
+		f.timeout = timeout
+	}
+	return nil
+}
+
 // validateProxy validates if the proxy is a valid uri and returns an error if validate fails, otherwise returns nil.
 func (f *FetchURIStep) validateProxy() error {
 	uri, err := url.Parse(f.Proxy)
@@ -251,7 +624,7 @@ func (f *FetchURIStep) validateProxy() error {
 }
 
 // validateLocation validates that the location is a valid path, and isn't overriding an existing
-// file unless explicitly stated.  Returns an error if validation fails, otherwise returns nil.
+// file unless explicitly stated, either with overwrite or with resume.  Returns an error if validation fails, otherwise returns nil.
 func (f *FetchURIStep) validateLocation(execCtx TTPExecutionContext) error {
 	fsys := f.FileSystem
 	if fsys == nil {
@@ -266,8 +639,8 @@ func (f *FetchURIStep) validateLocation(execCtx TTPExecutionContext) error {
 	if err != nil {
 		return fmt.Errorf("error checking if location exists (location: %q): %w", absLocal, err)
 	}
-	if exists && !f.Overwrite {
-		return fmt.Errorf("file exists at location %q and overwrite is not enabled", absLocal)
+	if exists && !f.Overwrite && !f.Resume {
+		return fmt.Errorf("file exists at location %q and neither overwrite nor resume is enabled", absLocal)
 	}
 	return nil
 }
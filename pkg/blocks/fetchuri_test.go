@@ -20,14 +20,22 @@ THE SOFTWARE.
 package blocks
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"github.com/spf13/afero"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/facebookincubator/ttpforge/pkg/backends"
 	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
@@ -92,6 +100,68 @@ steps:
 	}
 }
 
+func TestUnmarshalFetchURIRetries(t *testing.T) {
+	testCases := []struct {
+		name            string
+		content         string
+		expectedRetries int
+		expectTemplate  bool
+		wantError       bool
+	}{
+		{
+			name: "Integer",
+			content: `
+fetch_uri: http://someuri.com
+location: ./location
+retries: 3
+`,
+			expectedRetries: 3,
+		},
+		{
+			name: "Quoted Integer",
+			content: `
+fetch_uri: http://someuri.com
+location: ./location
+retries: "3"
+`,
+			expectedRetries: 3,
+		},
+		{
+			name: "Templated",
+			content: `
+fetch_uri: http://someuri.com
+location: ./location
+retries: "{[{.StepVars.retries}]}"
+`,
+			expectTemplate: true,
+		},
+		{
+			name: "Not A Scalar",
+			content: `
+fetch_uri: http://someuri.com
+location: ./location
+retries: [3]
+`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step := NewFetchURIStep()
+			err := yaml.Unmarshal([]byte(tc.content), step)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRetries, step.Retries)
+			assert.Equal(t, tc.expectTemplate, step.retriesTemplate != "")
+			assert.Equal(t, "http://someuri.com", step.FetchURI)
+		})
+	}
+}
+
 func TestFetchURI(t *testing.T) {
 	testCases := []struct {
 		name                string
@@ -250,6 +320,124 @@ location: /tmp/{[{.StepVars.location}]}.txt
 			},
 			expectTemplateError: true,
 		},
+		{
+			name: "bad sha256",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+sha256: abc123
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "overwrite and resume",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+overwrite: true
+resume: true
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "resume existing file",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/test.txt
+resume: true
+`,
+			fsysContents: map[string][]byte{
+				"/tmp/test.txt": []byte("Test file"),
+			},
+		},
+		{
+			name: "negative retries",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+retries: -1
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "non-numeric retries after templating",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+retries: "{[{.StepVars.retries}]}"
+`,
+			stepVars: map[string]string{
+				"retries": "three",
+			},
+			expectTemplateError: true,
+		},
+		{
+			name: "bad retry delay",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+retries: 3
+retry_delay: soon
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "bad timeout",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+timeout: 0s
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "unsupported scheme",
+			content: `
+name: fetch
+fetch_uri: ftp://someuri.com/file
+location: /tmp/output.txt
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "headers with file uri",
+			content: `
+name: fetch
+fetch_uri: file:///etc/hosts
+location: /tmp/output.txt
+headers:
+  - field: X-Test
+    value: test
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "sftp uri without connection",
+			content: `
+name: fetch
+fetch_uri: sftp:///etc/hosts
+location: /tmp/output.txt
+`,
+			expectValidateError: true,
+		},
+		{
+			name: "broken header",
+			content: `
+name: fetch
+fetch_uri: http://someuri.com
+location: /tmp/output.txt
+headers:
+  - field: X-Test
+`,
+			expectValidateError: true,
+		},
 	}
 
 	// prepare test server
@@ -347,7 +535,7 @@ overwrite: true
 	require.NoError(t, err)
 
 	// execute and check result
-	_, err = s.Execute(execCtx)
+	result, err := s.Execute(execCtx)
 	require.NoError(t, err)
 
 	f, err := os.Stat(s.Location)
@@ -359,4 +547,345 @@ overwrite: true
 
 	assert.Equal(t, string(dat), "Hello, client\n")
 
+	// check built-in outputs
+	digest := sha256.Sum256(dat)
+	assert.Equal(t, s.Location, result.Outputs["path"])
+	assert.Equal(t, hex.EncodeToString(digest[:]), result.Outputs["sha256"])
+
+}
+
+// runFetchURI runs a fetch_uri step, with the location on fsys
+func runFetchURI(t *testing.T, execCtx TTPExecutionContext, content string, fsys afero.Fs) (*ActResult, error) {
+	var s FetchURIStep
+	require.NoError(t, yaml.Unmarshal([]byte(content), &s))
+	s.FileSystem = fsys
+	require.NoError(t, s.Validate(execCtx))
+	require.NoError(t, s.Template(execCtx))
+	return s.Execute(execCtx)
+}
+
+func sha256Hex(data string) string {
+	digest := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestFetchURISHA256(t *testing.T) {
+	const data = "Here's some data!"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, data)
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		name        string
+		sha256      string
+		expectError bool
+	}{
+		{
+			name:   "matching digest",
+			sha256: sha256Hex(data),
+		},
+		{
+			name:   "matching uppercase digest",
+			sha256: strings.ToUpper(sha256Hex(data)),
+		},
+		{
+			name:        "mismatched digest",
+			sha256:      sha256Hex("other data"),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+			content := fmt.Sprintf("fetch_uri: %v\nlocation: /tmp/output.txt\nsha256: %v\n", ts.URL, tc.sha256)
+			result, err := runFetchURI(t, NewTTPExecutionContext(), content, fsys)
+			if tc.expectError {
+				require.Error(t, err)
+				exists, err := afero.Exists(fsys, "/tmp/output.txt")
+				require.NoError(t, err)
+				assert.False(t, exists, "file with mismatched digest should be removed")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, sha256Hex(data), result.Outputs["sha256"])
+			assert.Equal(t, len(data), result.TypedOutputs["size"])
+			assert.Equal(t, "/tmp/output.txt", result.Outputs["path"])
+		})
+	}
+}
+
+func TestFetchURIHeadersAndAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "authorized")
+	}))
+	defer ts.Close()
+
+	content := fmt.Sprintf(`
+fetch_uri: %v
+location: /tmp/output.txt
+headers:
+  - field: X-Api-Key
+    value: secret
+auth:
+  bearer: token
+`, ts.URL)
+	fsys := afero.NewMemMapFs()
+	_, err := runFetchURI(t, NewTTPExecutionContext(), content, fsys)
+	require.NoError(t, err)
+	data, err := afero.ReadFile(fsys, "/tmp/output.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "authorized", string(data))
+}
+
+func TestFetchURIRetries(t *testing.T) {
+	testCases := []struct {
+		name           string
+		failures       int
+		failStatus     int
+		retries        int
+		expectError    bool
+		expectAttempts int32
+	}{
+		{
+			name:           "succeeds after retries",
+			failures:       2,
+			failStatus:     http.StatusServiceUnavailable,
+			retries:        3,
+			expectAttempts: 3,
+		},
+		{
+			name:           "runs out of retries",
+			failures:       5,
+			failStatus:     http.StatusServiceUnavailable,
+			retries:        2,
+			expectError:    true,
+			expectAttempts: 3,
+		},
+		{
+			name:           "does not retry client errors",
+			failures:       5,
+			failStatus:     http.StatusNotFound,
+			retries:        3,
+			expectError:    true,
+			expectAttempts: 1,
+		},
+		{
+			name:           "fails without retries",
+			failures:       1,
+			failStatus:     http.StatusInternalServerError,
+			expectError:    true,
+			expectAttempts: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) <= tc.failures {
+					w.WriteHeader(tc.failStatus)
+					return
+				}
+				fmt.Fprint(w, "finally")
+			}))
+			defer ts.Close()
+
+			content := fmt.Sprintf("fetch_uri: %v\nlocation: /tmp/output.txt\nretries: %d\nretry_delay: 1ms\n", ts.URL, tc.retries)
+			_, err := runFetchURI(t, NewTTPExecutionContext(), content, afero.NewMemMapFs())
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestFetchURIStall(t *testing.T) {
+	const data = "0123456789abcdefghijklmnopqrstuvwxyz"
+	testCases := []struct {
+		name          string
+		stalls        int
+		retries       int
+		resume        bool
+		expectError   bool
+		expectPartial bool
+	}{
+		{
+			name:    "retries after a stall",
+			stalls:  1,
+			retries: 1,
+		},
+		{
+			name:        "removes the partial file",
+			stalls:      2,
+			retries:     1,
+			expectError: true,
+		},
+		{
+			name:          "keeps the partial file to resume",
+			stalls:        1,
+			resume:        true,
+			expectError:   true,
+			expectPartial: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			done := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(attempts.Add(1)) > tc.stalls {
+					fmt.Fprint(w, data)
+					return
+				}
+				// send part of the body, then stall until the client gives up
+				w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+				fmt.Fprint(w, data[:10])
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+				case <-done:
+				}
+			}))
+			defer ts.Close()
+			defer close(done)
+
+			fsys := afero.NewMemMapFs()
+			content := fmt.Sprintf("fetch_uri: %v\nlocation: /tmp/output.txt\nretries: %d\nretry_delay: 1ms\ntimeout: 200ms\nresume: %v\n", ts.URL, tc.retries, tc.resume)
+			_, err := runFetchURI(t, NewTTPExecutionContext(), content, fsys)
+			exists, existsErr := afero.Exists(fsys, "/tmp/output.txt")
+			require.NoError(t, existsErr)
+			if tc.expectError {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectPartial, exists)
+				return
+			}
+			require.NoError(t, err)
+			got, err := afero.ReadFile(fsys, "/tmp/output.txt")
+			require.NoError(t, err)
+			assert.Equal(t, data, string(got))
+		})
+	}
+}
+
+func TestFetchURIResume(t *testing.T) {
+	const data = "0123456789abcdefghijklmnopqrstuvwxyz"
+	testCases := []struct {
+		name          string
+		partial       string
+		ignoreRange   bool
+		expectedRange string
+	}{
+		{
+			name:          "resumes partial file",
+			partial:       data[:10],
+			expectedRange: "bytes=10-",
+		},
+		{
+			name:          "restarts when range is ignored",
+			partial:       "stale",
+			ignoreRange:   true,
+			expectedRange: "bytes=5-",
+		},
+		{
+			name:          "complete file",
+			partial:       data,
+			expectedRange: fmt.Sprintf("bytes=%d-", len(data)),
+		},
+		{
+			name: "no partial file",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotRange string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRange = r.Header.Get("Range")
+				if tc.ignoreRange {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "data.txt", time.Time{}, strings.NewReader(data))
+			}))
+			defer ts.Close()
+
+			fsys := afero.NewMemMapFs()
+			if tc.partial != "" {
+				require.NoError(t, afero.WriteFile(fsys, "/tmp/output.txt", []byte(tc.partial), 0644))
+			}
+			content := fmt.Sprintf("fetch_uri: %v\nlocation: /tmp/output.txt\nresume: true\nsha256: %v\n", ts.URL, sha256Hex(data))
+			result, err := runFetchURI(t, NewTTPExecutionContext(), content, fsys)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRange, gotRange)
+
+			got, err := afero.ReadFile(fsys, "/tmp/output.txt")
+			require.NoError(t, err)
+			assert.Equal(t, data, string(got))
+			assert.Equal(t, len(data), result.TypedOutputs["size"])
+		})
+	}
+}
+
+func TestFetchURIFileSources(t *testing.T) {
+	const data = "local file contents"
+	srcPath := filepath.Join(t.TempDir(), "source.txt")
+	require.NoError(t, os.WriteFile(srcPath, []byte(data), 0644))
+
+	remote := newMockBackend("remote")
+	require.NoError(t, afero.WriteFile(remote.fs, "/srv/payload.bin", []byte(data), 0644))
+
+	testCases := []struct {
+		name        string
+		uri         string
+		expectError bool
+	}{
+		{
+			name: "file uri",
+			uri:  "file://" + filepath.ToSlash(srcPath),
+		},
+		{
+			name: "sftp uri",
+			uri:  "sftp://target_host/srv/payload.bin",
+		},
+		{
+			name:        "missing file",
+			uri:         "file://" + filepath.ToSlash(srcPath) + ".missing",
+			expectError: true,
+		},
+		{
+			name:        "unknown connection",
+			uri:         "sftp://unknown/srv/payload.bin",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCtx := NewTTPExecutionContext()
+			execCtx.ConnPool = backends.NewConnectionPool()
+			require.NoError(t, execCtx.ConnPool.RegisterWithBackend("target_host", &backends.RemoteConfig{Host: "target.example.com", Protocol: "ssh"}, remote))
+
+			fsys := afero.NewMemMapFs()
+			content := fmt.Sprintf("fetch_uri: %v\nlocation: /tmp/output.txt\nretries: 2\nretry_delay: 1ms\n", tc.uri)
+			result, err := runFetchURI(t, execCtx, content, fsys)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := afero.ReadFile(fsys, "/tmp/output.txt")
+			require.NoError(t, err)
+			assert.Equal(t, data, string(got))
+			assert.Equal(t, sha256Hex(data), result.Outputs["sha256"])
+		})
+	}
 }
@@ -24,7 +24,6 @@ import (
 	"fmt"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
-	"github.com/facebookincubator/ttpforge/pkg/outputs"
 )
 
 // FileStep represents a step in a process that consists of a main action,
@@ -32,11 +31,10 @@ import (
 type FileStep struct {
 	actionDefaults      `yaml:",inline"`
 	timedActionDefaults `yaml:",inline"`
-	FilePath            string                  `yaml:"file,omitempty"`
-	Executor            string                  `yaml:"executor,omitempty"`
-	Environment         map[string]string       `yaml:"env,omitempty"`
-	Outputs             map[string]outputs.Spec `yaml:"outputs,omitempty"`
-	Args                []string                `yaml:"args,omitempty,flow"`
+	FilePath            string            `yaml:"file,omitempty"`
+	Executor            string            `yaml:"executor,omitempty"`
+	Environment         map[string]string `yaml:"env,omitempty"`
+	Args                []string          `yaml:"args,omitempty,flow"`
 }
 
 // NewFileStep creates a new FileStep instance and returns a pointer to it.
@@ -133,12 +131,14 @@ func (f *FileStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	result.Outputs, err = outputs.Parse(f.Outputs, result.Stdout)
+	if err := f.extractOutputs(result); err != nil {
+		return nil, err
+	}
 	// Send stdout to the output variable
 	if f.OutputVar != "" {
 		execCtx.Vars.StepVars[f.OutputVar] = result.Stdout
 	}
-	return result, err
+	return result, nil
 }
 
 // Cleanup is a method to establish a link with the Cleanup interface.
@@ -20,18 +20,25 @@ THE SOFTWARE.
 package blocks
 
 import (
-	"crypto/tls"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"maps"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
 	"go.uber.org/zap"
 )
 
@@ -53,21 +60,43 @@ type ResponseData struct {
 	Body string              `json:"body"`
 }
 
+// HTTPMultipartField is a single part of a multipart/form-data body.
+// Exactly one of Value and File must be set.
+type HTTPMultipartField struct {
+	Name        string `yaml:"name,omitempty"`
+	Value       string `yaml:"value,omitempty"`
+	File        string `yaml:"file,omitempty"`
+	Filename    string `yaml:"filename,omitempty"`
+	ContentType string `yaml:"content_type,omitempty"`
+}
+
 // HTTPRequestStep represents a step in a process that consists of a main action,
 // a cleanup action, and additional metadata.
 type HTTPRequestStep struct {
-	actionDefaults     `yaml:",inline"`
-	HTTPRequest        string           `yaml:"http_request,omitempty"`
-	Type               string           `yaml:"type,omitempty"`
-	Headers            []*HTTPHeader    `yaml:"headers,omitempty"`
-	Parameters         []*HTTPParameter `yaml:"parameters,omitempty"`
-	Body               string           `yaml:"body,omitempty"`
-	Regex              string           `yaml:"regex,omitempty"`
-	Proxy              string           `yaml:"proxy,omitempty"`
-	DisableRedirects   bool             `yaml:"disable_redirects,omitempty"`
-	InsecureSkipVerify bool             `yaml:"insecure_skip_verify,omitempty"`
-	ResponseHeaders    bool             `yaml:"response_headers,omitempty"`
-	Response           string           `yaml:"response,omitempty"`
+	actionDefaults   `yaml:",inline"`
+	HTTPTLSConfig    `yaml:",inline"`
+	HTTPRequest      string                `yaml:"http_request,omitempty"`
+	Type             string                `yaml:"type,omitempty"`
+	Headers          []*HTTPHeader         `yaml:"headers,omitempty"`
+	Parameters       []*HTTPParameter      `yaml:"parameters,omitempty"`
+	Body             string                `yaml:"body,omitempty"`
+	BodyFile         string                `yaml:"body_file,omitempty"`
+	Multipart        []*HTTPMultipartField `yaml:"multipart,omitempty"`
+	Regex            string                `yaml:"regex,omitempty"`
+	Proxy            string                `yaml:"proxy,omitempty"`
+	DisableRedirects bool                  `yaml:"disable_redirects,omitempty"`
+	ResponseHeaders  bool                  `yaml:"response_headers,omitempty"`
+	Response         string                `yaml:"response,omitempty"`
+	Auth             *HTTPAuth             `yaml:"auth,omitempty"`
+
+	// Session names an HTTP session that is shared with
+	// every other step that specifies the same name. The
+	// first of these steps to run creates the session from
+	// its BaseURL, DefaultHeaders, Auth, TLS and Proxy
+	// settings.
+	Session        string        `yaml:"session,omitempty"`
+	BaseURL        string        `yaml:"base_url,omitempty"`
+	DefaultHeaders []*HTTPHeader `yaml:"default_headers,omitempty"`
 }
 
 // NewHTTPRequestStep creates a new HTTPRequestStep instance and returns a pointer to it.
@@ -90,6 +119,17 @@ func (r *HTTPRequestStep) Validate(execCtx TTPExecutionContext) error {
 		}
 	}
 
+	// Validate the base URL, skip if contains template
+	if r.BaseURL != "" && !execCtx.containsStepTemplating(r.BaseURL) {
+		err := r.validateBaseURL()
+		if err != nil {
+			return err
+		}
+	}
+	if r.Session == "" && len(r.DefaultHeaders) > 0 {
+		return fmt.Errorf("default_headers can only be used with a session")
+	}
+
 	// Validate the proxy URL, skip if contains template
 	if r.Proxy != "" && !execCtx.containsStepTemplating(r.Proxy) {
 		err := r.validateProxy()
@@ -107,12 +147,41 @@ func (r *HTTPRequestStep) Validate(execCtx TTPExecutionContext) error {
 	}
 
 	// Validate headers
-	for _, header := range r.Headers {
+	for _, header := range slices.Concat(r.Headers, r.DefaultHeaders) {
 		if header.Field == "" || header.Value == "" {
 			return fmt.Errorf("broken HTTP header %s: %s", header.Value, header.Field)
 		}
 	}
 
+	// Validate body sources
+	bodySources := 0
+	for _, set := range []bool{r.Body != "", r.BodyFile != "", len(r.Multipart) > 0} {
+		if set {
+			bodySources++
+		}
+	}
+	if bodySources > 1 {
+		return fmt.Errorf("only one of body, body_file and multipart may be specified")
+	}
+	for _, field := range r.Multipart {
+		if field.Name == "" {
+			return fmt.Errorf("multipart field name cannot be empty")
+		}
+		if (field.Value == "") == (field.File == "") {
+			return fmt.Errorf("multipart field %v must specify exactly one of value or file", field.Name)
+		}
+	}
+
+	// Validate credentials and TLS settings
+	if r.Auth != nil {
+		if err := r.Auth.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := r.HTTPTLSConfig.Validate(); err != nil {
+		return err
+	}
+
 	// Validate parameters
 	for _, parameter := range r.Parameters {
 		if parameter.Name == "" || parameter.Value == "" {
@@ -204,6 +273,49 @@ func (r *HTTPRequestStep) Template(execCtx TTPExecutionContext) error {
 	if err != nil {
 		return err
 	}
+	r.BodyFile, err = execCtx.templateStep(r.BodyFile)
+	if err != nil {
+		return err
+	}
+	for _, field := range r.Multipart {
+		field.Value, err = execCtx.templateStep(field.Value)
+		if err != nil {
+			return err
+		}
+		field.File, err = execCtx.templateStep(field.File)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Template session settings and credentials
+	for _, field := range []*string{&r.Session, &r.CACert, &r.ClientCert, &r.ClientKey} {
+		*field, err = execCtx.templateStep(*field)
+		if err != nil {
+			return err
+		}
+	}
+	if execCtx.containsStepTemplating(r.BaseURL) {
+		r.BaseURL, err = execCtx.templateStep(r.BaseURL)
+		if err != nil {
+			return err
+		}
+		err = r.validateBaseURL()
+		if err != nil {
+			return err
+		}
+	}
+	for _, header := range r.DefaultHeaders {
+		header.Value, err = execCtx.templateStep(header.Value)
+		if err != nil {
+			return err
+		}
+	}
+	if r.Auth != nil {
+		if err := r.Auth.Template(execCtx); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -215,69 +327,83 @@ func (r *HTTPRequestStep) Execute(execCtx TTPExecutionContext) (*ActResult, erro
 	}
 	logging.L().Info("========= Executing ==========")
 	logging.L().Infof("HTTPRequest to: %s", r.HTTPRequest)
-	if err := r.SendRequest(execCtx); err != nil {
+	result, err := r.SendRequest(execCtx)
+	if err != nil {
 		logging.L().Error(zap.Error(err))
 		return nil, err
 	}
+	if err := r.extractOutputs(result); err != nil {
+		return nil, err
+	}
 	logging.L().Info("========= Complete ==========")
-	return &ActResult{}, nil
+	return result, nil
 }
 
-// HTTPRequest executes the HTTPRequestStep.
-func (r *HTTPRequestStep) SendRequest(execCtx TTPExecutionContext) error {
+// SendRequest executes the HTTPRequestStep. The returned result contains
+// the (possibly filtered) response as its Stdout, along with the
+// status code and response headers as outputs.
+func (r *HTTPRequestStep) SendRequest(execCtx TTPExecutionContext) (*ActResult, error) {
 
 	// Gather the parameters
 	params := url.Values{}
 	for _, parameter := range r.Parameters {
 		params.Add(parameter.Name, parameter.Value)
 	}
+	// Look up the session, or set up a standalone one for this request
+	session, err := r.getSession(execCtx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Construct the full URL with parameters
 	fullURL := r.HTTPRequest
+	if session.BaseURL != nil {
+		target, err := url.Parse(r.HTTPRequest)
+		if err != nil {
+			return nil, err
+		}
+		fullURL = session.BaseURL.ResolveReference(target).String()
+	}
 	if len(params) > 0 {
 		fullURL = fmt.Sprintf("%s?%s", fullURL, params.Encode())
 	}
 
-	// Trim the body of any trailing new lines
-	trimBody := strings.TrimSuffix(r.Body, "\n")
+	reqBody, contentType, err := r.buildBody()
+	if err != nil {
+		return nil, err
+	}
 
 	// Create a new request with the specified method, URL, and body.
-	req, err := http.NewRequest(r.Type, fullURL, strings.NewReader(trimBody))
+	req, err := http.NewRequest(r.Type, fullURL, reqBody)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if req.URL.Host == "" {
+		return nil, fmt.Errorf("invalid URL given for request URL: %s", fullURL)
 	}
 
-	// Loop through and set each header
-	for _, header := range r.Headers {
+	// Loop through and set each header, starting with the session defaults
+	for _, header := range slices.Concat(session.DefaultHeaders, r.Headers) {
 		if header.Field != "" && header.Value != "" {
 			req.Header.Set(header.Field, header.Value)
 		}
 
 	}
-
-	// Create base transport
-	tr := &http.Transport{}
-
-	// Configure TLS to skip certificate verification if specified
-	if r.InsecureSkipVerify {
-		// #nosec G402
-		tr.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true,
-			MinVersion:         tls.VersionTLS13,
-		}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
 	}
 
-	// Configure proxy if specified and not disabled
-	if r.Proxy != "" && !execCtx.Cfg.NoProxy {
-		proxyURI, err := url.Parse(r.Proxy)
-		if err != nil {
-			return err
-		}
-		tr.Proxy = http.ProxyURL(proxyURI)
+	// Add credentials, preferring those of the step over the session's
+	if r.Auth != nil {
+		r.Auth.apply(req)
+	} else if session.Auth != nil {
+		session.Auth.apply(req)
 	}
 
-	// Create HTTP client with configured transport
+	// Create HTTP client with the session's transport and cookies
 	client := &http.Client{
-		Transport: tr,
+		Transport: session.Transport,
+		Jar:       session.Jar,
 	}
 
 	// Configure redirect behavior if specified
@@ -295,14 +421,14 @@ func (r *HTTPRequestStep) SendRequest(execCtx TTPExecutionContext) error {
 	// Send the request
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 
 	// Default response to just include the body
@@ -322,7 +448,7 @@ func (r *HTTPRequestStep) SendRequest(execCtx TTPExecutionContext) error {
 
 		fullResponse, err := json.Marshal(data)
 		if err != nil {
-			return fmt.Errorf("error marshalling response: %w", err)
+			return nil, fmt.Errorf("error marshalling response: %w", err)
 		}
 		// Convert the byte slice to a string and send back as the response.
 		finalResponse = string(fullResponse)
@@ -349,7 +475,7 @@ func (r *HTTPRequestStep) SendRequest(execCtx TTPExecutionContext) error {
 	if r.Response != "" {
 		err = os.Setenv(r.Response, finalResponse)
 		if err != nil {
-			return fmt.Errorf("error setting environment variable: %w", err)
+			return nil, fmt.Errorf("error setting environment variable: %w", err)
 		}
 	}
 	logging.L().Infof("Response: %s", finalResponse)
@@ -358,7 +484,30 @@ func (r *HTTPRequestStep) SendRequest(execCtx TTPExecutionContext) error {
 		execCtx.Vars.StepVars[r.OutputVar] = finalResponse
 	}
 
-	return nil
+	headers := make(map[string]any, len(resp.Header))
+	result := &ActResult{
+		Stdout: finalResponse,
+		Outputs: map[string]string{
+			"status_code": strconv.Itoa(resp.StatusCode),
+		},
+		TypedOutputs: map[string]any{
+			"status_code": resp.StatusCode,
+			"headers":     headers,
+		},
+	}
+	for name, values := range resp.Header {
+		headers[name] = strings.Join(values, ", ")
+		result.Outputs[headerOutputName(name)] = strings.Join(values, ", ")
+	}
+	result.Outputs["headers"] = outputs.Stringify(headers)
+	return result, nil
+}
+
+// headerOutputName converts an HTTP header name into the name of
+// the output that holds its value - for example, the value of the
+// Content-Type header is stored in the header_content_type output
+func headerOutputName(header string) string {
+	return "header_" + strings.ReplaceAll(strings.ToLower(header), "-", "_")
 }
 
 // validateURL validates that the URL is valid URI.  Returns an error if validation fails, otherwise returns nil
@@ -366,6 +515,9 @@ func (r *HTTPRequestStep) validateURL() error {
 	uri, err := url.Parse(r.HTTPRequest)
 	if err != nil {
 		return err
+	} else if r.BaseURL != "" || r.Session != "" {
+		// relative URLs are resolved against the base URL
+		return nil
 	} else if uri.Host == "" || uri.Scheme == "" {
 		return fmt.Errorf("invalid URL given for request URL: %s", r.HTTPRequest)
 	}
@@ -373,6 +525,123 @@ func (r *HTTPRequestStep) validateURL() error {
 	return nil
 }
 
+// validateBaseURL validates that the base URL is an absolute HTTP(S) URL.  Returns an error if validation fails, otherwise returns nil
+func (r *HTTPRequestStep) validateBaseURL() error {
+	uri, err := url.Parse(r.BaseURL)
+	if err != nil {
+		return err
+	} else if uri.Host == "" || (uri.Scheme != "http" && uri.Scheme != "https") {
+		return fmt.Errorf("invalid URL given for base_url: %s", r.BaseURL)
+	}
+
+	return nil
+}
+
+// getSession returns the session that the request is sent in. Steps
+// without a session get a new session that is not stored anywhere, so
+// cookies are not shared between them.
+func (r *HTTPRequestStep) getSession(execCtx TTPExecutionContext) (*HTTPSession, error) {
+	create := func() (*HTTPSession, error) {
+		proxy := r.Proxy
+		if execCtx.Cfg.NoProxy {
+			proxy = ""
+		}
+		tr, err := newHTTPTransport(r.HTTPTLSConfig, proxy)
+		if err != nil {
+			return nil, err
+		}
+		session := &HTTPSession{
+			Name:           r.Session,
+			DefaultHeaders: r.DefaultHeaders,
+			Auth:           r.Auth,
+			Transport:      tr,
+		}
+		if r.BaseURL != "" {
+			if session.BaseURL, err = url.Parse(r.BaseURL); err != nil {
+				return nil, err
+			}
+		}
+		return session, nil
+	}
+	if r.Session == "" {
+		return create()
+	}
+
+	if execCtx.HTTPSessions == nil {
+		return nil, fmt.Errorf("HTTP session %q: session store is not initialized", r.Session)
+	}
+	session, created, err := execCtx.HTTPSessions.GetOrCreate(r.Session, create)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP session %q: %w", r.Session, err)
+	}
+	if created {
+		logging.L().Infof("Created HTTP session %q", r.Session)
+		return session, nil
+	}
+	if r.BaseURL != "" || len(r.DefaultHeaders) > 0 || r.HTTPTLSConfig.isSet() || r.Proxy != "" {
+		return nil, fmt.Errorf("HTTP session %q already exists - base_url, default_headers, proxy and TLS settings can only be specified by the step that creates it", r.Session)
+	}
+	return session, nil
+}
+
+// buildBody returns the body of the request, along with
+// its content type if that is determined by the body
+func (r *HTTPRequestStep) buildBody() (io.Reader, string, error) {
+	switch {
+	case r.BodyFile != "":
+		data, err := os.ReadFile(r.BodyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading body_file: %w", err)
+		}
+		return bytes.NewReader(data), "", nil
+	case len(r.Multipart) > 0:
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, field := range r.Multipart {
+			if err := writeMultipartField(mw, field); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, mw.FormDataContentType(), nil
+	default:
+		// Trim the body of any trailing new lines
+		return strings.NewReader(strings.TrimSuffix(r.Body, "\n")), "", nil
+	}
+}
+
+func writeMultipartField(mw *multipart.Writer, field *HTTPMultipartField) error {
+	if field.File == "" {
+		return mw.WriteField(field.Name, field.Value)
+	}
+	data, err := os.ReadFile(field.File)
+	if err != nil {
+		return fmt.Errorf("error reading multipart file: %w", err)
+	}
+	filename := field.Filename
+	if filename == "" {
+		filename = filepath.Base(field.File)
+	}
+	contentType := field.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     field.Name,
+		"filename": filename,
+	}))
+	header.Set("Content-Type", contentType)
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(data)
+	return err
+}
+
 // validateProxy validates that the proxy is a valid URI.  Returns an error if validation fails, otherwise returns nil
 func (r *HTTPRequestStep) validateProxy() error {
 	uri, err := url.Parse(r.Proxy)
@@ -20,11 +20,16 @@ THE SOFTWARE.
 package blocks
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"gopkg.in/yaml.v3"
 )
@@ -503,3 +508,118 @@ outputvar: testvar
 		})
 	}
 }
+
+func TestHTTPRequestOutputs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("X-Request-Id", "abc123")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"session":{"token":"s3cr3t"}}`))
+	}))
+	defer ts.Close()
+
+	content := `name: request with outputs
+http_request: ` + ts.URL + `
+type: POST
+outputs:
+  token:
+    filters:
+      - json_path: session.token
+  status_code:
+    filters:
+      - json_path: session.token`
+	var step HTTPRequestStep
+	err := yaml.Unmarshal([]byte(content), &step)
+	require.NoError(t, err)
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, `{"session":{"token":"s3cr3t"}}`, result.Stdout)
+	assert.Equal(t, "s3cr3t", result.Outputs["token"])
+	assert.Equal(t, "application/json", result.Outputs["header_content_type"])
+	assert.Equal(t, "abc123", result.Outputs["header_x_request_id"])
+	assert.Contains(t, result.Outputs["headers"], `"X-Request-Id":"abc123"`)
+	assert.Equal(t, "abc123", result.TypedOutputs["headers"].(map[string]any)["X-Request-Id"])
+	// a specified output takes precedence over a built-in output with the same name
+	assert.Equal(t, "s3cr3t", result.Outputs["status_code"])
+
+	step.Outputs = nil
+	result, err = step.Execute(execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "201", result.Outputs["status_code"])
+	assert.Equal(t, http.StatusCreated, result.TypedOutputs["status_code"])
+}
+
+func TestHTTPRequestBody(t *testing.T) {
+	dir := t.TempDir()
+	payload := filepath.Join(dir, "payload.bin")
+	require.NoError(t, os.WriteFile(payload, []byte("line one\nline two\n"), 0600))
+
+	testCases := []struct {
+		name           string
+		content        string
+		expectedBody   string
+		expectedFields map[string]string
+		expectedFiles  map[string]string
+	}{
+		{
+			name: "Body From File",
+			content: `type: POST
+body_file: ` + payload,
+			// the file is sent as is, without trimming the final newline
+			expectedBody: "line one\nline two\n",
+		},
+		{
+			name: "Multipart",
+			content: `type: POST
+multipart:
+  - name: comment
+    value: quarterly report
+  - name: upload
+    file: ` + payload + `
+    filename: report.pdf
+    content_type: application/pdf`,
+			expectedFields: map[string]string{"comment": "quarterly report"},
+			expectedFiles:  map[string]string{"upload": "report.pdf:application/pdf:line one\nline two\n"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body []byte
+			var fields, files map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+					require.NoError(t, r.ParseMultipartForm(1<<20))
+					fields = make(map[string]string)
+					for name, values := range r.MultipartForm.Value {
+						fields[name] = values[0]
+					}
+					files = make(map[string]string)
+					for name, headers := range r.MultipartForm.File {
+						f, err := headers[0].Open()
+						require.NoError(t, err)
+						data, err := io.ReadAll(f)
+						require.NoError(t, err)
+						files[name] = headers[0].Filename + ":" + headers[0].Header.Get("Content-Type") + ":" + string(data)
+					}
+					return
+				}
+				body, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			_, err := runHTTPRequest(t, NewTTPExecutionContext(), "http_request: "+server.URL+"\n"+tc.content)
+			require.NoError(t, err)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, string(body))
+			}
+			if tc.expectedFields != nil {
+				assert.Equal(t, tc.expectedFields, fields)
+				assert.Equal(t, tc.expectedFiles, files)
+			}
+		})
+	}
+}
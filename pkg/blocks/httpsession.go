@@ -0,0 +1,196 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// HTTPAuth configures the credentials sent with an HTTP request.
+// Exactly one of Basic and Bearer may be set.
+type HTTPAuth struct {
+	Basic  *HTTPBasicAuth `yaml:"basic,omitempty"`
+	Bearer string         `yaml:"bearer,omitempty"`
+}
+
+// HTTPBasicAuth holds the credentials for HTTP basic authentication.
+type HTTPBasicAuth struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// Validate checks that exactly one authentication scheme is configured.
+func (a *HTTPAuth) Validate() error {
+	if (a.Basic == nil) == (a.Bearer == "") {
+		return fmt.Errorf("auth must specify exactly one of basic or bearer")
+	}
+	if a.Basic != nil && a.Basic.Username == "" {
+		return fmt.Errorf("basic auth requires a username")
+	}
+	return nil
+}
+
+// Template resolves any template strings in the credentials.
+func (a *HTTPAuth) Template(execCtx TTPExecutionContext) error {
+	var err error
+	if a.Basic != nil {
+		a.Basic.Username, err = execCtx.templateStep(a.Basic.Username)
+		if err != nil {
+			return err
+		}
+		a.Basic.Password, err = execCtx.templateStep(a.Basic.Password)
+		if err != nil {
+			return err
+		}
+	}
+	a.Bearer, err = execCtx.templateStep(a.Bearer)
+	return err
+}
+
+// apply adds the credentials to the request.
+func (a *HTTPAuth) apply(req *http.Request) {
+	if a.Basic != nil {
+		req.SetBasicAuth(a.Basic.Username, a.Basic.Password)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Bearer)
+}
+
+// HTTPTLSConfig holds the TLS settings of an HTTP client. The
+// certificate and key files must be PEM encoded.
+type HTTPTLSConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	CACert             string `yaml:"ca_cert,omitempty"`
+	ClientCert         string `yaml:"client_cert,omitempty"`
+	ClientKey          string `yaml:"client_key,omitempty"`
+}
+
+// isSet reports whether any TLS setting has been specified.
+func (c HTTPTLSConfig) isSet() bool {
+	return c != HTTPTLSConfig{}
+}
+
+// Validate checks that a client certificate is accompanied by its key.
+func (c HTTPTLSConfig) Validate() error {
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return fmt.Errorf("client_cert and client_key must be specified together")
+	}
+	return nil
+}
+
+// build returns the TLS configuration, or nil if the defaults should be used.
+func (c HTTPTLSConfig) build() (*tls.Config, error) {
+	if !c.isSet() {
+		return nil, nil
+	}
+	cfg := &tls.Config{}
+	if c.InsecureSkipVerify {
+		// #nosec G402
+		cfg.InsecureSkipVerify = true
+		cfg.MinVersion = tls.VersionTLS13
+	}
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", c.CACert)
+		}
+	}
+	if c.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// HTTPSession holds the state shared by every http_request
+// step that specifies the same `session:` name, so that
+// cookies set by one request are sent with later ones.
+type HTTPSession struct {
+	Name           string
+	BaseURL        *url.URL
+	DefaultHeaders []*HTTPHeader
+	Auth           *HTTPAuth
+	Jar            http.CookieJar
+	Transport      *http.Transport
+}
+
+// newHTTPTransport creates a transport with the given TLS
+// settings that sends requests through proxy, if it is set
+func newHTTPTransport(tlsConfig HTTPTLSConfig, proxy string) (*http.Transport, error) {
+	tr := &http.Transport{}
+	var err error
+	if tr.TLSClientConfig, err = tlsConfig.build(); err != nil {
+		return nil, err
+	}
+	if proxy != "" {
+		proxyURI, err := url.Parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = http.ProxyURL(proxyURI)
+	}
+	return tr, nil
+}
+
+// HTTPSessions stores the named HTTP sessions of a TTP execution.
+// It is safe for concurrent use.
+type HTTPSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*HTTPSession
+}
+
+// NewHTTPSessions creates an empty session store.
+func NewHTTPSessions() *HTTPSessions {
+	return &HTTPSessions{sessions: make(map[string]*HTTPSession)}
+}
+
+// GetOrCreate returns the session with the given name, calling
+// create to make it if it does not exist yet. The boolean result
+// reports whether the session was created by this call.
+func (s *HTTPSessions) GetOrCreate(name string, create func() (*HTTPSession, error)) (*HTTPSession, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[name]; ok {
+		return session, false, nil
+	}
+	session, err := create()
+	if err != nil {
+		return nil, false, err
+	}
+	if session.Jar == nil {
+		// cookiejar.New never returns an error when options are nil
+		session.Jar, _ = cookiejar.New(nil)
+	}
+	s.sessions[name] = session
+	return session, true, nil
+}
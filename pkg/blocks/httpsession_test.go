@@ -0,0 +1,289 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// runHTTPRequest parses, validates, templates and executes an http_request step
+func runHTTPRequest(t *testing.T, execCtx TTPExecutionContext, content string) (*ActResult, error) {
+	var step HTTPRequestStep
+	require.NoError(t, yaml.Unmarshal([]byte(content), &step))
+	if err := step.Validate(execCtx); err != nil {
+		return nil, err
+	}
+	if err := step.Template(execCtx); err != nil {
+		return nil, err
+	}
+	return step.Execute(execCtx)
+}
+
+func TestHTTPSessionValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Relative URL With Session",
+			content: `http_request: /login
+session: admin`,
+		},
+		{
+			name: "Relative URL With Base URL",
+			content: `http_request: /login
+base_url: https://target.example.com/app/`,
+		},
+		{
+			name:      "Relative URL Without Base URL",
+			content:   `http_request: /login`,
+			wantError: true,
+		},
+		{
+			name: "Base URL Is Not HTTP",
+			content: `http_request: /login
+base_url: ftp://target.example.com`,
+			wantError: true,
+		},
+		{
+			name: "Default Headers Without Session",
+			content: `http_request: https://target.example.com
+default_headers:
+  - field: User-Agent
+    value: curl/8.0`,
+			wantError: true,
+		},
+		{
+			name: "Both Auth Schemes",
+			content: `http_request: https://target.example.com
+auth:
+  bearer: token
+  basic:
+    username: admin`,
+			wantError: true,
+		},
+		{
+			name: "Basic Auth Without Username",
+			content: `http_request: https://target.example.com
+auth:
+  basic:
+    password: hunter2`,
+			wantError: true,
+		},
+		{
+			name: "Client Certificate Without Key",
+			content: `http_request: https://target.example.com
+client_cert: /tmp/client.pem`,
+			wantError: true,
+		},
+		{
+			name: "Body And Multipart",
+			content: `http_request: https://target.example.com
+body: data
+multipart:
+  - name: field
+    value: value`,
+			wantError: true,
+		},
+		{
+			name: "Multipart Field With Value And File",
+			content: `http_request: https://target.example.com
+multipart:
+  - name: field
+    value: value
+    file: /etc/passwd`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step HTTPRequestStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPSessionSharesCookiesAndDefaults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/login":
+			http.SetCookie(w, &http.Cookie{Name: "session_id", Value: "s3cr3t", Path: "/"})
+			_, _ = w.Write([]byte("logged in"))
+		case "/app/admin":
+			cookie, err := r.Cookie("session_id")
+			if err != nil || cookie.Value != "s3cr3t" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(r.Header.Get("User-Agent") + " " + r.Header.Get("X-Step")))
+		}
+	}))
+	defer server.Close()
+
+	execCtx := NewTTPExecutionContext()
+	_, err := runHTTPRequest(t, execCtx, `http_request: login
+type: POST
+session: admin
+base_url: `+server.URL+`/app/
+default_headers:
+  - field: User-Agent
+    value: evil-agent/1.0`)
+	require.NoError(t, err)
+
+	result, err := runHTTPRequest(t, execCtx, `http_request: /app/admin
+session: admin
+headers:
+  - field: X-Step
+    value: second`)
+	require.NoError(t, err)
+	assert.Equal(t, "200", result.Outputs["status_code"])
+	assert.Equal(t, "evil-agent/1.0 second", result.Stdout)
+
+	// a different session does not have the cookie
+	result, err = runHTTPRequest(t, execCtx, `http_request: `+server.URL+`/app/admin
+session: other`)
+	require.NoError(t, err)
+	assert.Equal(t, "401", result.Outputs["status_code"])
+
+	// a step without a session does not have it either
+	result, err = runHTTPRequest(t, execCtx, `http_request: `+server.URL+`/app/admin`)
+	require.NoError(t, err)
+	assert.Equal(t, "401", result.Outputs["status_code"])
+
+	// the session settings cannot be changed once it exists
+	_, err = runHTTPRequest(t, execCtx, `http_request: /admin
+session: admin
+base_url: `+server.URL)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestHTTPSessionAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); ok {
+			_, _ = w.Write([]byte("basic " + username + ":" + password))
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	execCtx := NewTTPExecutionContext()
+	result, err := runHTTPRequest(t, execCtx, `http_request: `+server.URL+`
+session: api
+auth:
+  bearer: t0k3n`)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t0k3n", result.Stdout)
+
+	// the session's credentials are used by default
+	result, err = runHTTPRequest(t, execCtx, `http_request: `+server.URL+`
+session: api`)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer t0k3n", result.Stdout)
+
+	// and can be overridden by a step
+	result, err = runHTTPRequest(t, execCtx, `http_request: `+server.URL+`
+session: api
+auth:
+  basic:
+    username: admin
+    password: hunter2`)
+	require.NoError(t, err)
+	assert.Equal(t, "basic admin:hunter2", result.Stdout)
+}
+
+// writePEM writes a PEM block to a new file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return p
+}
+
+func TestHTTPRequestClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	// create a self-signed client certificate
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ttpforge-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	clientCert := writePEM(t, dir, "client.pem", "CERTIFICATE", certDER)
+	clientKey := writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER)
+	clientCA, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello " + r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  x509.NewCertPool(),
+	}
+	server.TLS.ClientCAs.AddCert(clientCA)
+	server.StartTLS()
+	defer server.Close()
+	caCert := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	execCtx := NewTTPExecutionContext()
+	result, err := runHTTPRequest(t, execCtx, `http_request: `+server.URL+`
+ca_cert: `+caCert+`
+client_cert: `+clientCert+`
+client_key: `+clientKey)
+	require.NoError(t, err)
+	assert.Equal(t, "hello ttpforge-client", result.Stdout)
+
+	// the server rejects requests without the certificate
+	_, err = runHTTPRequest(t, execCtx, `http_request: `+server.URL+`
+ca_cert: `+caCert)
+	assert.Error(t, err)
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/facebookincubator/ttpforge/pkg/processutils"
@@ -137,16 +138,18 @@ func (s *KillProcessStep) extractPIDs() ([]int, error) {
 
 // killProcesses - kills all processes with the given process IDs.
 // Logs successful and unsuccessful kill actions.
-// Returns an error if there's only one process to kill and it fails.
-func (s *KillProcessStep) killProcesses(pids []int) error {
+// Returns the IDs of the processes that were killed, or an error
+// if a failure occurs and the step is configured to error on it.
+func (s *KillProcessStep) killProcesses(pids []int) ([]int, error) {
 	logging.L().Infof("Killing the following processes: %v", pids)
 
+	var killed []int
 	for _, pid := range pids {
 		proc, err := os.FindProcess(pid)
 		if err != nil {
 			logging.L().Errorf("Error while trying to find process with ID: %v; %+v", pid, err)
 			if s.ErrorOnFindProcessFailure {
-				return err
+				return nil, err
 			}
 			continue
 		}
@@ -155,15 +158,16 @@ func (s *KillProcessStep) killProcesses(pids []int) error {
 		if err := proc.Kill(); err != nil {
 			logging.L().Errorf("Failed to kill process with ID: %v; %+v", pid, err)
 			if s.ErrorOnKillFailure {
-				return err
+				return nil, err
 			}
 			continue
 		}
 
 		logging.L().Infof("Killed process with ID: %d", pid)
+		killed = append(killed, pid)
 	}
 
-	return nil
+	return killed, nil
 }
 
 // Execute runs the step and returns an error if one occurs while extracting PIDs or killing processes.
@@ -179,13 +183,38 @@ func (s *KillProcessStep) Execute(execCtx TTPExecutionContext) (*ActResult, erro
 
 	if len(pids) == 0 {
 		logging.L().Infof("No processes found to kill")
-		return &ActResult{}, nil
+		return s.buildResult(nil)
 	}
-	if err := s.killProcesses(pids); err != nil {
+	killed, err := s.killProcesses(pids)
+	if err != nil {
 		return nil, err
 	}
 
-	return &ActResult{}, nil
+	return s.buildResult(killed)
+}
+
+// buildResult creates the result of this step, exposing
+// the IDs of the killed processes as the `pids` output
+// (a list, or one per line when used as a string)
+func (s *KillProcessStep) buildResult(killed []int) (*ActResult, error) {
+	pidStrs := make([]string, len(killed))
+	pidVals := make([]any, len(killed))
+	for i, pid := range killed {
+		pidStrs[i] = strconv.Itoa(pid)
+		pidVals[i] = pid
+	}
+	result := &ActResult{
+		Outputs: map[string]string{
+			"pids": strings.Join(pidStrs, "\n"),
+		},
+		TypedOutputs: map[string]any{
+			"pids": pidVals,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // executeRemote handles process killing via the execution backend.
@@ -202,7 +231,7 @@ func (s *KillProcessStep) executeRemote(execCtx TTPExecutionContext) (*ActResult
 				return nil, fmt.Errorf("process %d not found on remote host", processID)
 			}
 			logging.L().Infof("No processes found to kill")
-			return &ActResult{}, nil
+			return s.buildResult(nil)
 		}
 		pids = []int{processID}
 	} else {
@@ -214,10 +243,11 @@ func (s *KillProcessStep) executeRemote(execCtx TTPExecutionContext) (*ActResult
 				return nil, err
 			}
 			logging.L().Infof("No processes found to kill")
-			return &ActResult{}, nil
+			return s.buildResult(nil)
 		}
 	}
 
+	var killed []int
 	for _, pid := range pids {
 		if err := backend.KillProcess(pid); err != nil {
 			logging.L().Errorf("Failed to kill remote process %d: %v", pid, err)
@@ -227,7 +257,8 @@ func (s *KillProcessStep) executeRemote(execCtx TTPExecutionContext) (*ActResult
 			continue
 		}
 		logging.L().Infof("Killed remote process with ID: %d", pid)
+		killed = append(killed, pid)
 	}
 
-	return &ActResult{}, nil
+	return s.buildResult(killed)
 }
@@ -28,7 +28,6 @@ import (
 	"path/filepath"
 	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
 	"github.com/facebookincubator/ttpforge/pkg/args"
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/facebookincubator/ttpforge/pkg/platforms"
@@ -53,15 +52,22 @@ type RenderParameters struct {
 // **Parameters:**
 //
 // ttpStr: A string containing the TTP template to be rendered.
+// rp: The RenderParameters (such as argument values) made available to the template.
 // execCfg: A pointer to a TTPExecutionConfig that represents the execution configuration for the TTP.
+// Its Repo and RepoCollection are used to resolve partials loaded with `include`; it may be nil.
 //
 // **Returns:**
 //
 // *TTP: A pointer to the TTP object created from the template.
 // error: An error if the rendering or unmarshaling process fails.
-func RenderTemplatedTTP(ttpStr string, rp RenderParameters) (*TTP, error) {
-	tmpl, err := template.New("ttp").Funcs(sprig.TxtFuncMap()).Parse(ttpStr)
-
+func RenderTemplatedTTP(ttpStr string, rp RenderParameters, execCfg *TTPExecutionConfig) (*TTP, error) {
+	var tmpl *template.Template
+	if execCfg != nil {
+		tmpl = NewTTPTemplate(execCfg.Repo, execCfg.RepoCollection)
+	} else {
+		tmpl = NewTTPTemplate(nil, nil)
+	}
+	tmpl, err := tmpl.Parse(ttpStr)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +150,7 @@ func LoadTTP(ttpFilePath string, fsys afero.Fs, execCfg *TTPExecutionConfig, ste
 		Args:     argValues,
 		Platform: platforms.GetCurrentPlatformSpec(),
 	}
-	ttp, err := RenderTemplatedTTP(string(ttpBytes), rp)
+	ttp, err := RenderTemplatedTTP(string(ttpBytes), rp, execCfg)
 	if err != nil {
 		return nil, nil, err
 	}
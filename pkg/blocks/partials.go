@@ -0,0 +1,130 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/facebookincubator/ttpforge/pkg/repos"
+	"github.com/spf13/afero"
+)
+
+// maxIncludeDepth bounds how deeply partials may include
+// other partials, which protects us from include cycles
+const maxIncludeDepth = 32
+
+// partialResolver locates the template files referenced
+// by the `include` template function. References of the
+// form repo//path/to/partial are resolved through the
+// RepoCollection; references without a repository name
+// are resolved against the repository of the current TTP.
+type partialResolver struct {
+	repo           repos.Repo
+	repoCollection repos.RepoCollection
+}
+
+// resolve returns the filesystem and absolute path
+// of the partial identified by ref
+func (pr partialResolver) resolve(ref string) (afero.Fs, string, error) {
+	repoName, _, hasRepoName := strings.Cut(ref, repos.RepoPrefixSep)
+	if hasRepoName && repoName != "" && (pr.repo == nil || repoName != pr.repo.GetName()) {
+		if pr.repoCollection == nil {
+			return nil, "", fmt.Errorf("cannot resolve partial %q: no repositories are configured", ref)
+		}
+		r, absPath, err := pr.repoCollection.ResolveTemplateRef(ref)
+		if err != nil {
+			return nil, "", err
+		}
+		return r.GetFs(), absPath, nil
+	}
+
+	if pr.repo == nil {
+		return nil, "", fmt.Errorf("cannot resolve partial %q: TTP does not belong to a repository", ref)
+	}
+	absPath, err := pr.repo.FindTemplate(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	return pr.repo.GetFs(), absPath, nil
+}
+
+// NewTTPTemplate creates the root template that is used to
+// render TTP files. In addition to the Sprig functions, it
+// provides the `include` function, which renders either a
+// named template (created with `define`) or a partial file
+// found in the `template_search_paths` of a repository:
+//
+//	{{ include "examples//partials/setup.yaml" . | nindent 2 }}
+//
+// Any templates defined by an included partial become part
+// of the TTP template set, so they can subsequently be used
+// with `include` or `template`.
+//
+// **Parameters:**
+//
+// repo: the repository containing the TTP (may be nil)
+// repoCollection: used to resolve partials from other repositories (may be nil)
+//
+// **Returns:**
+//
+// *template.Template: the root template, ready to Parse the TTP
+func NewTTPTemplate(repo repos.Repo, repoCollection repos.RepoCollection) *template.Template {
+	resolver := partialResolver{
+		repo:           repo,
+		repoCollection: repoCollection,
+	}
+	tmpl := template.New("ttp").Funcs(sprig.TxtFuncMap())
+
+	depth := 0
+	include := func(name string, data any) (string, error) {
+		if depth >= maxIncludeDepth {
+			return "", fmt.Errorf("include %q exceeds the maximum include depth of %d - is there an include cycle?", name, maxIncludeDepth)
+		}
+		depth++
+		defer func() { depth-- }()
+
+		target := tmpl.Lookup(name)
+		if target == nil {
+			fsys, absPath, err := resolver.resolve(name)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve partial %q: %w", name, err)
+			}
+			contents, err := afero.ReadFile(fsys, absPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to read partial %q: %w", name, err)
+			}
+			target, err = tmpl.New(name).Parse(string(contents))
+			if err != nil {
+				return "", fmt.Errorf("failed to parse partial %q: %w", name, err)
+			}
+		}
+
+		var result bytes.Buffer
+		if err := target.Execute(&result, data); err != nil {
+			return "", err
+		}
+		return result.String(), nil
+	}
+	return tmpl.Funcs(template.FuncMap{"include": include})
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"testing"
+
+	"github.com/facebookincubator/ttpforge/pkg/repos"
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplatedTTPWithPartials(t *testing.T) {
+	fsys, err := testutils.MakeAferoTestFs(map[string][]byte{
+		"repos/main/" + repos.RepoConfigFileName: []byte(`template_search_paths: ["partials"]`),
+		"repos/main/partials/print.yaml": []byte(`- name: print_{{ .Args.who }}
+  print_str: hello {{ .Args.who }}`),
+		"repos/main/partials/loop.yaml":            []byte(`{{ include "loop.yaml" . }}`),
+		"repos/main/partials/invalid.yaml":         []byte(`{{ if }}`),
+		"repos/shared/" + repos.RepoConfigFileName: []byte(`template_search_paths: ["lib"]`),
+		"repos/shared/lib/defines.yaml": []byte(`{{ define "cleanup_step" -}}
+- name: cleanup_{{ .Args.who }}
+  print_str: cleaning up
+{{- end }}`),
+	})
+	require.NoError(t, err)
+
+	rc, err := repos.NewRepoCollection(fsys, []repos.Spec{
+		{Name: "main", Path: "repos/main"},
+		{Name: "shared", Path: "repos/shared"},
+	}, "")
+	require.NoError(t, err)
+	mainRepo, err := rc.GetRepo("main")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name              string
+		content           string
+		expectRenderError bool
+		expectedSteps     []string
+	}{
+		{
+			name: "Partial From Current Repo",
+			content: `name: partial_test
+steps:
+  {{- include "print.yaml" . | nindent 2 }}`,
+			expectedSteps: []string{"print_bob"},
+		},
+		{
+			name: "Partial With Current Repo Prefix",
+			content: `name: partial_test
+steps:
+  {{- include "main//print.yaml" . | nindent 2 }}`,
+			expectedSteps: []string{"print_bob"},
+		},
+		{
+			name: "Named Template From Other Repo",
+			content: `name: partial_test
+{{- include "shared//defines.yaml" . }}
+steps:
+  {{- include "print.yaml" . | nindent 2 }}
+  {{- include "cleanup_step" . | nindent 2 }}`,
+			expectedSteps: []string{"print_bob", "cleanup_bob"},
+		},
+		{
+			name: "Named Template Used With template Action",
+			content: `name: partial_test
+{{- include "shared//defines.yaml" . }}
+steps:
+{{ template "cleanup_step" . }}`,
+			expectedSteps: []string{"cleanup_bob"},
+		},
+		{
+			name: "Partial Not Found",
+			content: `name: partial_test
+steps:
+  {{- include "notreal.yaml" . | nindent 2 }}`,
+			expectRenderError: true,
+		},
+		{
+			name: "Repo Not Found",
+			content: `name: partial_test
+steps:
+  {{- include "notreal//print.yaml" . | nindent 2 }}`,
+			expectRenderError: true,
+		},
+		{
+			name: "Invalid Partial",
+			content: `name: partial_test
+steps:
+  {{- include "invalid.yaml" . | nindent 2 }}`,
+			expectRenderError: true,
+		},
+		{
+			name: "Include Cycle",
+			content: `name: partial_test
+steps:
+  {{- include "loop.yaml" . | nindent 2 }}`,
+			expectRenderError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCfg := TTPExecutionConfig{
+				Repo:           mainRepo,
+				RepoCollection: rc,
+			}
+			ttp, err := RenderTemplatedTTP(tc.content, RenderParameters{
+				Args: map[string]any{"who": "bob"},
+			}, &execCfg)
+			if tc.expectRenderError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var stepNames []string
+			for _, step := range ttp.Steps {
+				stepNames = append(stepNames, step.Name)
+			}
+			assert.Equal(t, tc.expectedSteps, stepNames)
+		})
+	}
+}
+
+func TestRenderTemplatedTTPPartialWithoutRepo(t *testing.T) {
+	content := `name: partial_test
+steps:
+  {{- include "print.yaml" . | nindent 2 }}`
+	_, err := RenderTemplatedTTP(content, RenderParameters{}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,612 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// Supported persistence mechanisms
+const (
+	PersistenceCron         = "cron"
+	PersistenceSystemdUser  = "systemd_user"
+	PersistenceShellRC      = "shell_rc"
+	PersistenceXDGAutostart = "xdg_autostart"
+)
+
+const (
+	defaultPersistenceLabel    = "ttpforge"
+	defaultPersistenceSchedule = "@reboot"
+	defaultPersistenceRCFile   = "~/.bashrc"
+)
+
+var persistenceLabelRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// PersistenceStep installs a command that runs automatically on a
+// Linux host, using one of the mechanisms commonly abused for
+// persistence (T1053.003, T1543.002, T1546.004 and T1547.013).
+// Everything is installed through the execution backend, so it
+// works the same way on remote hosts.
+type PersistenceStep struct {
+	actionDefaults `yaml:",inline"`
+	Mechanism      string   `yaml:"persistence,omitempty"`
+	Command        string   `yaml:"command,omitempty"`
+	Label          string   `yaml:"label,omitempty"`
+	Schedule       string   `yaml:"schedule,omitempty"`
+	RCFile         string   `yaml:"rc_file,omitempty"`
+	FileSystem     afero.Fs `yaml:"-,omitempty"`
+
+	// installed records exactly what was installed
+	// so that the default cleanup can remove it
+	installed *persistenceRecord
+}
+
+// persistenceRecord describes what a PersistenceStep installed
+type persistenceRecord struct {
+	// path is the crontab, file or unit where the persistence lives
+	path string
+	// block is the text that was added to the crontab
+	// or shell rc file, and createdFile is set if that
+	// file did not exist beforehand
+	block       string
+	createdFile bool
+	// files and dirs were created by the step, and
+	// are removed in reverse order during cleanup
+	files []string
+	dirs  []string
+}
+
+// NewPersistenceStep creates a new PersistenceStep instance and returns a pointer to it.
+func NewPersistenceStep() *PersistenceStep {
+	return &PersistenceStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *PersistenceStep) IsNil() bool {
+	switch s.Mechanism {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *PersistenceStep) Validate(_ TTPExecutionContext) error {
+	switch s.Mechanism {
+	case PersistenceCron, PersistenceSystemdUser, PersistenceShellRC, PersistenceXDGAutostart:
+	default:
+		return fmt.Errorf("persistence mechanism must be one of %v, %v, %v or %v, got %q",
+			PersistenceCron, PersistenceSystemdUser, PersistenceShellRC, PersistenceXDGAutostart, s.Mechanism)
+	}
+	if s.Command == "" {
+		return fmt.Errorf("command field cannot be empty")
+	}
+	if s.Mechanism != PersistenceShellRC && strings.ContainsAny(s.Command, "\r\n") {
+		return fmt.Errorf("command cannot span multiple lines for the %v mechanism", s.Mechanism)
+	}
+	if s.Label != "" && !persistenceLabelRegex.MatchString(s.Label) {
+		return fmt.Errorf("label %q may only contain letters, digits, '_', '.' and '-'", s.Label)
+	}
+	if s.Schedule != "" && s.Mechanism != PersistenceCron {
+		return fmt.Errorf("schedule can only be used with the %v mechanism", PersistenceCron)
+	}
+	if s.RCFile != "" && s.Mechanism != PersistenceShellRC {
+		return fmt.Errorf("rc_file can only be used with the %v mechanism", PersistenceShellRC)
+	}
+	return nil
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *PersistenceStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Command, err = execCtx.templateStep(s.Command)
+	if err != nil {
+		return err
+	}
+	s.RCFile, err = execCtx.templateStep(s.RCFile)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *PersistenceStep) label() string {
+	if s.Label == "" {
+		return defaultPersistenceLabel
+	}
+	return s.Label
+}
+
+// homeDir returns the home directory of the user
+// that the step runs as on the target host
+func homeDir(execCtx TTPExecutionContext) (string, error) {
+	if execCtx.Backend == nil {
+		return os.UserHomeDir()
+	}
+	stdout, _, err := execCtx.Backend.RunCommand(context.Background(), "printenv", "", []string{"HOME"}, nil, "", nil, nil)
+	home := strings.TrimSpace(stdout)
+	if err != nil || home == "" {
+		return "", fmt.Errorf("failed to determine the home directory on the target host: %w", err)
+	}
+	return home, nil
+}
+
+// expandHome resolves a leading ~/ against the given home directory
+func expandHome(home, p string) string {
+	if p == "~" {
+		return home
+	}
+	if strings.HasPrefix(p, "~/") {
+		return path.Join(home, p[2:])
+	}
+	return p
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *PersistenceStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	logging.L().Infof("Installing %v persistence %q: %v", s.Mechanism, s.label(), s.Command)
+	var record *persistenceRecord
+	var location string
+	var err error
+	switch s.Mechanism {
+	case PersistenceCron:
+		record, err = s.installCron(execCtx)
+		location = "crontab"
+	default:
+		var fsys afero.Fs
+		if fsys, err = execCtx.getFs(s.FileSystem); err != nil {
+			return nil, err
+		}
+		var home string
+		if home, err = homeDir(execCtx); err != nil {
+			return nil, err
+		}
+		switch s.Mechanism {
+		case PersistenceShellRC:
+			location = expandHome(home, s.rcFile())
+			record, err = installBlock(fsys, location, s.block())
+		case PersistenceSystemdUser:
+			location = path.Join(home, ".config", "systemd", "user", s.label()+".service")
+			record, err = s.installSystemdUnit(fsys, location)
+		case PersistenceXDGAutostart:
+			location = path.Join(home, ".config", "autostart", s.label()+".desktop")
+			record, err = s.installFiles(fsys, map[string]string{location: s.desktopEntry()}, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.path = location
+	s.installed = record
+	logging.L().Infof("Installed persistence in %v", location)
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"path": location,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, s.uninstallAfterFailure(execCtx, err)
+	}
+	return result, nil
+}
+
+// uninstallAfterFailure removes the installed persistence when the
+// step fails after installing it, since failed steps are not cleaned up
+func (s *PersistenceStep) uninstallAfterFailure(execCtx TTPExecutionContext, cause error) error {
+	if _, err := s.GetDefaultCleanupAction().Execute(execCtx); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to remove persistence: %w", err))
+	}
+	return cause
+}
+
+func (s *PersistenceStep) rcFile() string {
+	if s.RCFile == "" {
+		return defaultPersistenceRCFile
+	}
+	return s.RCFile
+}
+
+// block wraps the persisted command in marker comments
+// so that it can be identified in crontabs and rc files
+func (s *PersistenceStep) block() string {
+	command := s.Command
+	if s.Mechanism == PersistenceCron {
+		schedule := s.Schedule
+		if schedule == "" {
+			schedule = defaultPersistenceSchedule
+		}
+		command = schedule + " " + command
+	}
+	return fmt.Sprintf("# BEGIN ttpforge persistence: %v\n%v\n# END ttpforge persistence: %v\n",
+		s.label(), strings.TrimRight(command, "\n"), s.label())
+}
+
+// appendBlock appends block to contents, starting a new line first if needed
+func appendBlock(contents, block string) string {
+	if contents != "" && !strings.HasSuffix(contents, "\n") {
+		block = "\n" + block
+	}
+	return block
+}
+
+// removeBlock removes the last occurrence of block from contents
+func removeBlock(contents, block string) (string, error) {
+	idx := strings.LastIndex(contents, block)
+	if idx < 0 {
+		return "", errors.New("the installed entry was not found - it may have been modified or removed")
+	}
+	return contents[:idx] + contents[idx+len(block):], nil
+}
+
+// installCron adds the command to the user's crontab using the crontab
+// program, since the crontab files themselves are only writable by root
+func (s *PersistenceStep) installCron(execCtx TTPExecutionContext) (*persistenceRecord, error) {
+	existing, hadCrontab, err := readCrontab(execCtx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(existing, s.block()) {
+		return nil, fmt.Errorf("crontab already contains persistence labeled %q", s.label())
+	}
+	block := appendBlock(existing, s.block())
+	if err := writeCrontab(execCtx, existing+block); err != nil {
+		return nil, err
+	}
+	return &persistenceRecord{block: block, createdFile: !hadCrontab}, nil
+}
+
+func readCrontab(execCtx TTPExecutionContext) (string, bool, error) {
+	stdout, stderr, err := runPersistenceCommand(execCtx, "crontab", "", "-l")
+	if err != nil {
+		if strings.Contains(stderr, "no crontab") {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to read crontab: %w: %v", err, strings.TrimSpace(stderr))
+	}
+	return stdout, true, nil
+}
+
+func writeCrontab(execCtx TTPExecutionContext, contents string) error {
+	_, stderr, err := runPersistenceCommand(execCtx, "crontab", contents, "-")
+	if err != nil {
+		return fmt.Errorf("failed to write crontab: %w: %v", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+func runPersistenceCommand(execCtx TTPExecutionContext, name, stdin string, args ...string) (string, string, error) {
+	backend := execCtx.Backend
+	if backend == nil {
+		backend = backends.NewLocalBackend()
+	}
+	return backend.RunCommand(context.Background(), name, stdin, args, nil, "", nil, nil)
+}
+
+// installBlock appends block to the file at filePath, creating it if needed
+func installBlock(fsys afero.Fs, filePath, block string) (*persistenceRecord, error) {
+	record := &persistenceRecord{}
+	contents, err := afero.ReadFile(fsys, filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		record.createdFile = true
+		if record.dirs, err = mkdirAllTracked(fsys, path.Dir(filePath)); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(contents), block) {
+		return nil, fmt.Errorf("%v already contains this persistence entry", filePath)
+	}
+	record.block = appendBlock(string(contents), block)
+
+	f, err := fsys.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err == nil {
+		_, err = f.Write([]byte(record.block))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		if record.createdFile {
+			record.files = []string{filePath}
+		}
+		return nil, removeCreated(fsys, record, err)
+	}
+	return record, nil
+}
+
+// installSystemdUnit writes a user service that runs the command and enables
+// it in the same way as `systemctl --user enable`, so that it starts whenever
+// the user's service manager does
+func (s *PersistenceStep) installSystemdUnit(fsys afero.Fs, unitPath string) (*persistenceRecord, error) {
+	if _, ok := fsys.(afero.Linker); !ok {
+		return nil, errors.New("the filesystem does not support the symlinks needed to enable a systemd unit")
+	}
+	unitName := path.Base(unitPath)
+	link := path.Join(path.Dir(unitPath), "default.target.wants", unitName)
+	return s.installFiles(fsys, map[string]string{unitPath: s.systemdUnit()}, map[string]string{link: unitPath})
+}
+
+// installFiles creates new files and symlinks (keyed by
+// their path), refusing to overwrite any that already exist
+func (s *PersistenceStep) installFiles(fsys afero.Fs, files map[string]string, links map[string]string) (*persistenceRecord, error) {
+	for p := range files {
+		if err := checkNotExists(fsys, p); err != nil {
+			return nil, err
+		}
+	}
+	for p := range links {
+		if err := checkNotExists(fsys, p); err != nil {
+			return nil, err
+		}
+	}
+
+	record := &persistenceRecord{}
+	for p, contents := range files {
+		dirs, err := mkdirAllTracked(fsys, path.Dir(p))
+		record.dirs = append(record.dirs, dirs...)
+		if err != nil {
+			return nil, removeCreated(fsys, record, err)
+		}
+		if err := afero.WriteFile(fsys, p, []byte(contents), 0644); err != nil {
+			return nil, removeCreated(fsys, record, err)
+		}
+		record.files = append(record.files, p)
+	}
+	for p, target := range links {
+		dirs, err := mkdirAllTracked(fsys, path.Dir(p))
+		record.dirs = append(record.dirs, dirs...)
+		if err != nil {
+			return nil, removeCreated(fsys, record, err)
+		}
+		if err := fsys.(afero.Linker).SymlinkIfPossible(target, p); err != nil {
+			return nil, removeCreated(fsys, record, err)
+		}
+		record.files = append(record.files, p)
+	}
+	return record, nil
+}
+
+func checkNotExists(fsys afero.Fs, p string) error {
+	_, err := lstat(fsys, p)
+	if err == nil {
+		return fmt.Errorf("%v already exists - refusing to overwrite it", p)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func lstat(fsys afero.Fs, p string) (os.FileInfo, error) {
+	if lstater, ok := fsys.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(p)
+		return info, err
+	}
+	return fsys.Stat(p)
+}
+
+// mkdirAllTracked creates dir and any missing parents,
+// returning the directories that it created from the
+// outermost to the innermost
+func mkdirAllTracked(fsys afero.Fs, dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = path.Dir(d) {
+		exists, err := afero.DirExists(fsys, d)
+		if err != nil {
+			return nil, err
+		}
+		if exists || d == path.Dir(d) {
+			break
+		}
+		missing = append([]string{d}, missing...)
+	}
+	var created []string
+	for _, d := range missing {
+		if err := fsys.Mkdir(d, 0755); err != nil {
+			return created, err
+		}
+		created = append(created, d)
+	}
+	return created, nil
+}
+
+// removeCreated removes the files and directories in record,
+// and is used to roll back a failed installation
+func removeCreated(fsys afero.Fs, record *persistenceRecord, cause error) error {
+	if err := record.removeFiles(fsys); err != nil {
+		logging.L().Warnf("Failed to roll back persistence: %v", err)
+	}
+	return cause
+}
+
+// removeFiles removes the files that were created,
+// followed by any directories that are now empty
+func (r *persistenceRecord) removeFiles(fsys afero.Fs) error {
+	var errs []error
+	for i := len(r.files) - 1; i >= 0; i-- {
+		if err := fsys.Remove(r.files[i]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	for i := len(r.dirs) - 1; i >= 0; i-- {
+		entries, err := afero.ReadDir(fsys, r.dirs[i])
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := fsys.Remove(r.dirs[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// systemdUnit returns a user service that runs the command through sh
+func (s *PersistenceStep) systemdUnit() string {
+	// systemd expands specifiers (%) and variables ($) on
+	// the ExecStart line, and supports C-style escapes in
+	// quoted arguments
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "%", "%%", "$", "$$").Replace(s.Command)
+	description := s.Description
+	if description == "" {
+		description = s.label()
+	}
+	return fmt.Sprintf(`[Unit]
+Description=%v
+
+[Service]
+Type=simple
+ExecStart=/bin/sh -c '%v'
+
+[Install]
+WantedBy=default.target
+`, strings.ReplaceAll(description, "\n", " "), escaped)
+}
+
+// desktopEntry returns an XDG autostart entry that runs the command through sh
+func (s *PersistenceStep) desktopEntry() string {
+	// quote the command as an Exec argument, then escape
+	// the result as a desktop entry string value, see
+	// https://specifications.freedesktop.org/desktop-entry-spec/latest/exec-variables.html
+	quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace(s.Command) + `"`
+	exec := strings.NewReplacer(`\`, `\\`, "%", "%%").Replace(quoted)
+	return fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=%v
+Exec=sh -c %v
+NoDisplay=true
+X-GNOME-Autostart-enabled=true
+`, s.label(), exec)
+}
+
+// GetDefaultCleanupAction will instruct the calling code
+// to remove exactly what the step installed
+func (s *PersistenceStep) GetDefaultCleanupAction() Action {
+	return &removePersistenceAction{step: s}
+}
+
+// removePersistenceAction removes the persistence installed by a PersistenceStep
+type removePersistenceAction struct {
+	actionDefaults
+	step *PersistenceStep
+}
+
+// IsNil is not needed here, as this is not a user-accessible step type
+func (a *removePersistenceAction) IsNil() bool {
+	return false
+}
+
+// Validate is not needed here, as this is not a user-accessible step type
+func (a *removePersistenceAction) Validate(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Template is not needed here, as this is not a user-accessible step type
+func (a *removePersistenceAction) Template(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Execute removes the installed persistence
+func (a *removePersistenceAction) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	record := a.step.installed
+	if record == nil {
+		logging.L().Info("No persistence was installed - skipping cleanup")
+		return &ActResult{}, nil
+	}
+	logging.L().Infof("Removing %v persistence %q", a.step.Mechanism, a.step.label())
+
+	switch a.step.Mechanism {
+	case PersistenceCron:
+		if err := removeCronBlock(execCtx, record); err != nil {
+			return nil, err
+		}
+	default:
+		fsys, err := execCtx.getFs(a.step.FileSystem)
+		if err != nil {
+			return nil, err
+		}
+		if a.step.Mechanism == PersistenceShellRC {
+			if err := removeFileBlock(fsys, record); err != nil {
+				return nil, err
+			}
+		}
+		if err := record.removeFiles(fsys); err != nil {
+			return nil, err
+		}
+	}
+	a.step.installed = nil
+	return &ActResult{}, nil
+}
+
+func removeCronBlock(execCtx TTPExecutionContext, record *persistenceRecord) error {
+	existing, _, err := readCrontab(execCtx)
+	if err != nil {
+		return err
+	}
+	remaining, err := removeBlock(existing, record.block)
+	if err != nil {
+		return fmt.Errorf("failed to remove persistence from crontab: %w", err)
+	}
+	if remaining == "" && record.createdFile {
+		if _, stderr, err := runPersistenceCommand(execCtx, "crontab", "", "-r"); err != nil {
+			return fmt.Errorf("failed to remove crontab: %w: %v", err, strings.TrimSpace(stderr))
+		}
+		return nil
+	}
+	return writeCrontab(execCtx, remaining)
+}
+
+// removeFileBlock removes the block that was appended to a
+// shell rc file, along with the file itself if it was created
+// by the step and nothing else has been added to it since
+func removeFileBlock(fsys afero.Fs, record *persistenceRecord) error {
+	filePath := record.path
+	contents, err := afero.ReadFile(fsys, filePath)
+	if err != nil {
+		return err
+	}
+	remaining, err := removeBlock(string(contents), record.block)
+	if err != nil {
+		return fmt.Errorf("failed to remove persistence from %v: %w", filePath, err)
+	}
+	if remaining == "" && record.createdFile {
+		return fsys.Remove(filePath)
+	}
+	info, err := fsys.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(fsys, filePath, []byte(remaining), info.Mode().Perm())
+}
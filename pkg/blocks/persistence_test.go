@@ -0,0 +1,391 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package blocks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPersistenceValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Valid Cron",
+			content: `persistence: cron
+command: /tmp/implant
+schedule: "*/5 * * * *"`,
+		},
+		{
+			name: "Valid Shell RC",
+			content: `persistence: shell_rc
+rc_file: ~/.zshrc
+command: |
+  export PATH=/tmp/evil:$PATH
+  /tmp/implant &`,
+		},
+		{
+			name: "Unknown Mechanism",
+			content: `persistence: launchd
+command: /tmp/implant`,
+			wantError: true,
+		},
+		{
+			name:      "Missing Command",
+			content:   `persistence: systemd_user`,
+			wantError: true,
+		},
+		{
+			name: "Multi-Line Cron Command",
+			content: `persistence: cron
+command: |
+  /tmp/implant
+  /tmp/other`,
+			wantError: true,
+		},
+		{
+			name: "Invalid Label",
+			content: `persistence: xdg_autostart
+command: /tmp/implant
+label: ../../evil`,
+			wantError: true,
+		},
+		{
+			name: "Schedule Without Cron",
+			content: `persistence: systemd_user
+command: /tmp/implant
+schedule: "@reboot"`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step PersistenceStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// snapshotDir records the contents of every file
+// and the target of every symlink under dir
+func snapshotDir(t *testing.T, dir string) map[string]string {
+	snapshot := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			snapshot[p] = "-> " + target
+			return err
+		case d.IsDir():
+			snapshot[p] = "dir"
+		default:
+			data, err := os.ReadFile(p)
+			snapshot[p] = string(data)
+			return err
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return snapshot
+}
+
+func TestPersistenceExecuteAndCleanup(t *testing.T) {
+	testCases := []struct {
+		name             string
+		step             *PersistenceStep
+		existingFiles    map[string]string
+		expectedPath     string
+		expectedContents []string
+		expectedLink     string
+		wantError        bool
+	}{
+		{
+			name: "Shell RC Appends To Existing File",
+			step: &PersistenceStep{
+				Mechanism: PersistenceShellRC,
+				Command:   "/tmp/implant &",
+			},
+			existingFiles: map[string]string{
+				".bashrc": "alias ll='ls -l'",
+			},
+			expectedPath: ".bashrc",
+			expectedContents: []string{
+				"alias ll='ls -l'\n# BEGIN ttpforge persistence: ttpforge\n/tmp/implant &\n# END ttpforge persistence: ttpforge\n",
+			},
+		},
+		{
+			name: "Shell RC Creates File",
+			step: &PersistenceStep{
+				Mechanism: PersistenceShellRC,
+				Command:   "/tmp/implant &",
+				RCFile:    "~/.config/fish/config.fish",
+				Label:     "fish",
+			},
+			expectedPath:     ".config/fish/config.fish",
+			expectedContents: []string{"# BEGIN ttpforge persistence: fish\n/tmp/implant &\n"},
+		},
+		{
+			name: "Systemd User Unit",
+			step: &PersistenceStep{
+				Mechanism: PersistenceSystemdUser,
+				Command:   `echo "100%" > $HOME/pwned`,
+				Label:     "updater",
+			},
+			existingFiles: map[string]string{
+				".config/systemd/user/other.service": "[Unit]",
+			},
+			expectedPath: ".config/systemd/user/updater.service",
+			expectedContents: []string{
+				`ExecStart=/bin/sh -c 'echo "100%%" > $$HOME/pwned'`,
+				"WantedBy=default.target",
+			},
+			expectedLink: ".config/systemd/user/default.target.wants/updater.service",
+		},
+		{
+			name: "XDG Autostart Entry",
+			step: &PersistenceStep{
+				Mechanism: PersistenceXDGAutostart,
+				Command:   `echo "$HOME" 100%`,
+				Label:     "updater",
+			},
+			expectedPath: ".config/autostart/updater.desktop",
+			expectedContents: []string{
+				`Exec=sh -c "echo \\"\\$HOME\\" 100%%"`,
+				"Name=updater",
+			},
+		},
+		{
+			name: "Shell RC Removed When Outputs Fail",
+			step: &PersistenceStep{
+				actionDefaults: failingOutputs(),
+				Mechanism:      PersistenceShellRC,
+				Command:        "/tmp/implant &",
+			},
+			existingFiles: map[string]string{
+				".bashrc": "alias ll='ls -l'",
+			},
+			wantError: true,
+		},
+		{
+			name: "Systemd User Unit Removed When Outputs Fail",
+			step: &PersistenceStep{
+				actionDefaults: failingOutputs(),
+				Mechanism:      PersistenceSystemdUser,
+				Command:        "/tmp/implant",
+			},
+			wantError: true,
+		},
+		{
+			name: "Refuses To Overwrite",
+			step: &PersistenceStep{
+				Mechanism: PersistenceXDGAutostart,
+				Command:   "/tmp/implant",
+			},
+			existingFiles: map[string]string{
+				".config/autostart/ttpforge.desktop": "[Desktop Entry]",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			for name, contents := range tc.existingFiles {
+				p := filepath.Join(home, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+				require.NoError(t, os.WriteFile(p, []byte(contents), 0644))
+			}
+			before := snapshotDir(t, home)
+
+			execCtx := NewTTPExecutionContext()
+			require.NoError(t, tc.step.Validate(execCtx))
+			require.NoError(t, tc.step.Template(execCtx))
+			result, err := tc.step.Execute(execCtx)
+			if tc.wantError {
+				require.Error(t, err)
+				assert.Equal(t, before, snapshotDir(t, home))
+				return
+			}
+			require.NoError(t, err)
+			expectedPath := filepath.Join(home, tc.expectedPath)
+			assert.Equal(t, expectedPath, result.Outputs["path"])
+
+			contents, err := os.ReadFile(expectedPath)
+			require.NoError(t, err)
+			for _, expected := range tc.expectedContents {
+				assert.Contains(t, string(contents), expected)
+			}
+			if tc.expectedLink != "" {
+				target, err := os.Readlink(filepath.Join(home, tc.expectedLink))
+				require.NoError(t, err)
+				assert.Equal(t, expectedPath, target)
+			}
+
+			_, err = tc.step.GetDefaultCleanupAction().Execute(execCtx)
+			require.NoError(t, err)
+			assert.Equal(t, before, snapshotDir(t, home))
+		})
+	}
+}
+
+func TestPersistenceShellRCKeepsLaterChanges(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	rcFile := filepath.Join(home, ".bashrc")
+
+	step := &PersistenceStep{
+		Mechanism: PersistenceShellRC,
+		Command:   "/tmp/implant &",
+	}
+	execCtx := NewTTPExecutionContext()
+	_, err := step.Execute(execCtx)
+	require.NoError(t, err)
+
+	f, err := os.OpenFile(rcFile, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("export EDITOR=vim\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = step.GetDefaultCleanupAction().Execute(execCtx)
+	require.NoError(t, err)
+	contents, err := os.ReadFile(rcFile)
+	require.NoError(t, err)
+	assert.Equal(t, "export EDITOR=vim\n", string(contents))
+}
+
+// crontabBackend simulates the crontab program and the
+// home directory of the user on a remote host
+type crontabBackend struct {
+	*mockBackend
+	crontab *string
+}
+
+func (b *crontabBackend) RunCommand(ctx context.Context, name string, stdin string, args []string, env []string, workDir string, stdoutW io.Writer, stderrW io.Writer) (string, string, error) {
+	_, _, _ = b.mockBackend.RunCommand(ctx, name, stdin, args, env, workDir, stdoutW, stderrW)
+	switch name + " " + joinArgs(args) {
+	case "printenv HOME":
+		return "/home/remote\n", "", nil
+	case "crontab -l":
+		if b.crontab == nil {
+			return "", "no crontab for remote\n", errors.New("exit status 1")
+		}
+		return *b.crontab, "", nil
+	case "crontab -":
+		b.crontab = &stdin
+		return "", "", nil
+	case "crontab -r":
+		b.crontab = nil
+		return "", "", nil
+	}
+	return "", "", errors.New("unexpected command")
+}
+
+func TestPersistenceCron(t *testing.T) {
+	existing := "0 * * * * /usr/bin/backup\n"
+	testCases := []struct {
+		name     string
+		crontab  *string
+		schedule string
+		expected string
+	}{
+		{
+			name:     "No Existing Crontab",
+			expected: "# BEGIN ttpforge persistence: ttpforge\n@reboot /tmp/implant\n# END ttpforge persistence: ttpforge\n",
+		},
+		{
+			name:     "Existing Crontab",
+			crontab:  &existing,
+			schedule: "*/5 * * * *",
+			expected: existing + "# BEGIN ttpforge persistence: ttpforge\n*/5 * * * * /tmp/implant\n# END ttpforge persistence: ttpforge\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := &crontabBackend{mockBackend: newMockBackend("remote"), crontab: tc.crontab}
+			execCtx := NewTTPExecutionContext()
+			execCtx.Backend = backend
+
+			step := &PersistenceStep{
+				Mechanism: PersistenceCron,
+				Command:   "/tmp/implant",
+				Schedule:  tc.schedule,
+			}
+			require.NoError(t, step.Validate(execCtx))
+			result, err := step.Execute(execCtx)
+			require.NoError(t, err)
+			assert.Equal(t, "crontab", result.Outputs["path"])
+			require.NotNil(t, backend.crontab)
+			assert.Equal(t, tc.expected, *backend.crontab)
+
+			_, err = step.GetDefaultCleanupAction().Execute(execCtx)
+			require.NoError(t, err)
+			assert.Equal(t, tc.crontab, backend.crontab)
+		})
+	}
+}
+
+func TestPersistenceRemoteHome(t *testing.T) {
+	backend := &crontabBackend{mockBackend: newMockBackend("remote")}
+	execCtx := NewTTPExecutionContext()
+	execCtx.Backend = backend
+
+	step := &PersistenceStep{
+		Mechanism: PersistenceShellRC,
+		Command:   "/tmp/implant &",
+	}
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "/home/remote/.bashrc", result.Outputs["path"])
+	exists, err := afero.Exists(backend.fs, "/home/remote/.bashrc")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = step.GetDefaultCleanupAction().Execute(execCtx)
+	require.NoError(t, err)
+	exists, err = afero.Exists(backend.fs, "/home/remote")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/facebookincubator/ttpforge/pkg/args"
+	"github.com/facebookincubator/ttpforge/pkg/detections"
 )
 
 // PreambleFields are TTP fields that can be parsed
@@ -36,15 +37,17 @@ import (
 // MitreAttackMapping: A MitreAttack object containing mappings to the MITRE ATT&CK framework.
 // Requirements: The Requirements to run the TTP
 // ArgSpecs: An slice of argument specifications for the TTP.
+// Detections: The detections that the TTP is expected to trigger.
 type PreambleFields struct {
-	APIVersion         string              `yaml:"api_version,omitempty"`
-	UUID               string              `yaml:"uuid,omitempty"`
-	Name               string              `yaml:"name,omitempty"`
-	Authors            []string            `yaml:"authors,omitempty"`
-	Description        string              `yaml:"description"`
-	MitreAttackMapping *MitreAttack        `yaml:"mitre,omitempty"`
-	Requirements       *RequirementsConfig `yaml:"requirements,omitempty"`
-	ArgSpecs           []args.Spec         `yaml:"args,omitempty,flow"`
+	APIVersion         string                   `yaml:"api_version,omitempty"`
+	UUID               string                   `yaml:"uuid,omitempty"`
+	Name               string                   `yaml:"name,omitempty"`
+	Authors            []string                 `yaml:"authors,omitempty"`
+	Description        string                   `yaml:"description"`
+	MitreAttackMapping *MitreAttack             `yaml:"mitre,omitempty"`
+	Requirements       *RequirementsConfig      `yaml:"requirements,omitempty"`
+	ArgSpecs           []args.Spec              `yaml:"args,omitempty,flow"`
+	Detections         []detections.Expectation `yaml:"detections,omitempty"`
 }
 
 // Validate validates the preamble fields.
@@ -64,5 +67,12 @@ func (pf *PreambleFields) Validate(strict bool) error {
 	if err := pf.Requirements.Validate(); err != nil {
 		return fmt.Errorf("TTP '%s' has an invalid requirements section: %w", pf.Name, err)
 	}
+
+	// validate expected detections
+	for _, detection := range pf.Detections {
+		if err := detection.Validate(); err != nil {
+			return fmt.Errorf("TTP '%s' has an invalid detections section: %w", pf.Name, err)
+		}
+	}
 	return nil
 }
@@ -86,5 +86,8 @@ func (s *PrintStrAction) Execute(execCtx TTPExecutionContext) (*ActResult, error
 	result := &ActResult{
 		Stdout: stdoutBuf.String(),
 	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
@@ -123,7 +123,12 @@ func (s *RemovePathAction) Execute(execCtx TTPExecutionContext) (*ActResult, err
 	if err != nil {
 		return nil, err
 	}
-	return &ActResult{}, nil
+
+	result := &ActResult{}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // CanBeUsedInCompositeAction enables this action to be used in a composite action
@@ -19,20 +19,28 @@ THE SOFTWARE.
 
 package blocks
 
+import "time"
+
 // ActResult contains common fields produced
 // from both the execution of steps and their
 // associated cleanup actions
 type ActResult struct {
-	Stdout  string
-	Stderr  string
+	Stdout string
+	Stderr string
+	// Outputs holds the value of each output coerced to a string
 	Outputs map[string]string
+	// TypedOutputs holds the value of each output with its type
+	// (list, object, number, bool or string) retained
+	TypedOutputs map[string]any
 }
 
 // ExecutionResult stores the results/outputs
 // generated by executing a Step
 type ExecutionResult struct {
 	ActResult
-	Cleanup *ActResult
+	// StartTime is when the step began executing
+	StartTime time.Time
+	Cleanup   *ActResult
 }
 
 // StepResultsRecord provides convenient accessors
@@ -0,0 +1,368 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// SetFileAttributesStep changes the timestamps, permissions and
+// ownership of an existing file. Its intended use is simulating
+// timestomping (T1070.006) and permission modification (T1222)
+// without the shell history left behind by touch or chmod.
+type SetFileAttributesStep struct {
+	actionDefaults `yaml:",inline"`
+	Path           string   `yaml:"set_file_attributes,omitempty"`
+	ModifiedTime   string   `yaml:"mtime,omitempty"`
+	AccessTime     string   `yaml:"atime,omitempty"`
+	Reference      string   `yaml:"reference,omitempty"`
+	Mode           int      `yaml:"mode,omitempty"`
+	Owner          string   `yaml:"owner,omitempty"`
+	Group          string   `yaml:"group,omitempty"`
+	FileSystem     afero.Fs `yaml:"-,omitempty"`
+
+	// original records the attributes of the file before they
+	// were changed so that the default cleanup can restore them
+	original *fileAttributes
+	// changed records which attributes were actually changed,
+	// so that only those are restored
+	changed attributeChanges
+}
+
+// attributeChanges records which attributes
+// a SetFileAttributesStep has changed
+type attributeChanges struct {
+	owner bool
+	mode  bool
+	times bool
+}
+
+// fileAttributes holds the attributes that
+// a SetFileAttributesStep may change
+type fileAttributes struct {
+	path         string
+	mode         os.FileMode
+	modifiedTime time.Time
+	accessTime   time.Time
+	hasOwner     bool
+	uid          int
+	gid          int
+}
+
+// NewSetFileAttributesStep creates a new SetFileAttributesStep instance and returns a pointer to it.
+func NewSetFileAttributesStep() *SetFileAttributesStep {
+	return &SetFileAttributesStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *SetFileAttributesStep) IsNil() bool {
+	switch s.Path {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *SetFileAttributesStep) Validate(_ TTPExecutionContext) error {
+	if s.Path == "" {
+		return fmt.Errorf("set_file_attributes field cannot be empty")
+	}
+	if s.Reference != "" && (s.ModifiedTime != "" || s.AccessTime != "") {
+		return fmt.Errorf("reference cannot be combined with mtime or atime")
+	}
+	if !s.changesTimes() && s.Mode == 0 && s.Owner == "" && s.Group == "" {
+		return fmt.Errorf("at least one of mtime, atime, reference, mode, owner or group must be specified")
+	}
+	if s.Mode < 0 || s.Mode > 0o7777 {
+		return fmt.Errorf("mode %o is not a valid permission mode", s.Mode)
+	}
+	return nil
+}
+
+func (s *SetFileAttributesStep) changesTimes() bool {
+	return s.ModifiedTime != "" || s.AccessTime != "" || s.Reference != ""
+}
+
+func (s *SetFileAttributesStep) changesOwner() bool {
+	return s.Owner != "" || s.Group != ""
+}
+
+// fileMode converts a chmod style mode to an os.FileMode.
+// The setuid, setgid and sticky bits are not stored in the
+// low bits of an os.FileMode, so they must be mapped across
+func fileMode(mode int) os.FileMode {
+	fm := os.FileMode(mode) & os.ModePerm
+	if mode&0o4000 != 0 {
+		fm |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		fm |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		fm |= os.ModeSticky
+	}
+	return fm
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *SetFileAttributesStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Path, err = execCtx.templateStep(s.Path)
+	if err != nil {
+		return err
+	}
+	s.ModifiedTime, err = execCtx.templateStep(s.ModifiedTime)
+	if err != nil {
+		return err
+	}
+	s.AccessTime, err = execCtx.templateStep(s.AccessTime)
+	if err != nil {
+		return err
+	}
+	s.Reference, err = execCtx.templateStep(s.Reference)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// readFileAttributes records the current attributes of a file
+func readFileAttributes(fsys afero.Fs, path string) (*fileAttributes, error) {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	attrs := &fileAttributes{
+		path:         path,
+		mode:         info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky),
+		modifiedTime: info.ModTime(),
+	}
+	var ok bool
+	// not every filesystem records access times, in which
+	// case the modification time is the best substitute
+	if attrs.accessTime, ok = fileutils.AccessTime(info); !ok {
+		attrs.accessTime = attrs.modifiedTime
+	}
+	attrs.uid, attrs.gid, attrs.hasOwner = fileutils.OwnerIDs(info)
+	return attrs, nil
+}
+
+// parseFileTime parses either an RFC3339 timestamp or a
+// duration, which is interpreted as that long before now
+func parseFileTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC3339 timestamp nor a duration", value)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *SetFileAttributesStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	logging.L().Infof("Setting attributes of file %v", s.Path)
+	fsys, err := execCtx.getFs(s.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+	path, err := fileutils.ExpandPath(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	original, err := readFileAttributes(fsys, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attributes of %v: %w", path, err)
+	}
+
+	// resolve everything before changing anything, so
+	// that invalid fields do not leave the file half-modified
+	atime, mtime := original.accessTime, original.modifiedTime
+	if s.Reference != "" {
+		reference, err := fileutils.ExpandPath(s.Reference)
+		if err != nil {
+			return nil, err
+		}
+		refAttrs, err := readFileAttributes(fsys, reference)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attributes of reference file %v: %w", reference, err)
+		}
+		atime, mtime = refAttrs.accessTime, refAttrs.modifiedTime
+	}
+	if s.ModifiedTime != "" {
+		if mtime, err = parseFileTime(s.ModifiedTime); err != nil {
+			return nil, fmt.Errorf("invalid mtime: %w", err)
+		}
+	}
+	if s.AccessTime != "" {
+		if atime, err = parseFileTime(s.AccessTime); err != nil {
+			return nil, fmt.Errorf("invalid atime: %w", err)
+		}
+	}
+	// -1 leaves the owner or group unchanged
+	uid, gid := -1, -1
+	if original.hasOwner {
+		uid, gid = original.uid, original.gid
+	}
+	if s.Owner != "" {
+		if uid, err = fileutils.LookupID(fsys, s.Owner, false); err != nil {
+			return nil, err
+		}
+	}
+	if s.Group != "" {
+		if gid, err = fileutils.LookupID(fsys, s.Group, true); err != nil {
+			return nil, err
+		}
+	}
+
+	s.original = original
+	if err := s.apply(fsys, path, uid, gid, atime, mtime); err != nil {
+		return nil, s.restoreAfterFailure(execCtx, err)
+	}
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"path": path,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, s.restoreAfterFailure(execCtx, err)
+	}
+	return result, nil
+}
+
+// apply makes the requested changes to the file, recording
+// each one that is made so that it can be undone
+func (s *SetFileAttributesStep) apply(fsys afero.Fs, path string, uid, gid int, atime, mtime time.Time) error {
+	s.changed = attributeChanges{}
+	// ownership changes can clear the setuid and setgid
+	// bits, so they are made before the mode is set, and
+	// times are set last since the other changes may touch them
+	if s.changesOwner() {
+		logging.L().Infof("Changing owner of %v to %d:%d", path, uid, gid)
+		if err := fsys.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("failed to change owner of %v: %w", path, err)
+		}
+		s.changed.owner = true
+	}
+	if s.Mode != 0 {
+		logging.L().Infof("Changing mode of %v to %04o", path, s.Mode)
+		if err := fsys.Chmod(path, fileMode(s.Mode)); err != nil {
+			return fmt.Errorf("failed to change mode of %v: %w", path, err)
+		}
+		s.changed.mode = true
+	}
+	if s.changesTimes() {
+		logging.L().Infof("Changing times of %v to atime %v, mtime %v", path, atime.Format(time.RFC3339), mtime.Format(time.RFC3339))
+		if err := fsys.Chtimes(path, atime, mtime); err != nil {
+			return fmt.Errorf("failed to change times of %v: %w", path, err)
+		}
+		s.changed.times = true
+	}
+	return nil
+}
+
+// restoreAfterFailure restores the original attributes when the
+// step fails after changing them, since failed steps are not cleaned up
+func (s *SetFileAttributesStep) restoreAfterFailure(execCtx TTPExecutionContext, cause error) error {
+	if _, err := s.GetDefaultCleanupAction().Execute(execCtx); err != nil {
+		return errors.Join(cause, err)
+	}
+	s.original = nil
+	return cause
+}
+
+// GetDefaultCleanupAction will instruct the calling code
+// to restore the original attributes of the file
+func (s *SetFileAttributesStep) GetDefaultCleanupAction() Action {
+	return &restoreFileAttributesAction{step: s}
+}
+
+// restoreFileAttributesAction restores the attributes
+// that a SetFileAttributesStep changed to their original values
+type restoreFileAttributesAction struct {
+	actionDefaults
+	step *SetFileAttributesStep
+}
+
+// IsNil is not needed here, as this is not a user-accessible step type
+func (a *restoreFileAttributesAction) IsNil() bool {
+	return false
+}
+
+// Validate is not needed here, as this is not a user-accessible step type
+func (a *restoreFileAttributesAction) Validate(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Template is not needed here, as this is not a user-accessible step type
+func (a *restoreFileAttributesAction) Template(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Execute restores the original attributes, in the
+// same order in which the step changed them
+func (a *restoreFileAttributesAction) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	s, original := a.step, a.step.original
+	if original == nil {
+		logging.L().Info("File attributes were not changed - skipping cleanup")
+		return &ActResult{}, nil
+	}
+	fsys, err := execCtx.getFs(s.FileSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.L().Infof("Restoring attributes of file %v", original.path)
+	if s.changed.owner {
+		if !original.hasOwner {
+			logging.L().Warnf("Original owner of %v is unknown - it cannot be restored", original.path)
+		} else if err := fsys.Chown(original.path, original.uid, original.gid); err != nil {
+			return nil, fmt.Errorf("failed to restore owner of %v: %w", original.path, err)
+		}
+	}
+	// the mode is restored even if only the owner changed,
+	// since chown may have cleared the setuid and setgid bits
+	if s.changed.mode || s.changed.owner {
+		if err := fsys.Chmod(original.path, original.mode); err != nil {
+			return nil, fmt.Errorf("failed to restore mode of %v: %w", original.path, err)
+		}
+	}
+	if s.changed.times {
+		if err := fsys.Chtimes(original.path, original.accessTime, original.modifiedTime); err != nil {
+			return nil, fmt.Errorf("failed to restore times of %v: %w", original.path, err)
+		}
+	}
+	return &ActResult{}, nil
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSetFileAttributesValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Timestomp",
+			content: `set_file_attributes: /usr/local/bin/implant
+reference: /bin/ls`,
+		},
+		{
+			name: "Permissions",
+			content: `set_file_attributes: /usr/local/bin/implant
+mode: 04755
+owner: root`,
+		},
+		{
+			name:      "Nothing To Change",
+			content:   `set_file_attributes: /usr/local/bin/implant`,
+			wantError: true,
+		},
+		{
+			name: "Mode Out Of Range",
+			content: `set_file_attributes: /usr/local/bin/implant
+mode: 017777`,
+			wantError: true,
+		},
+		{
+			name: "Reference And Mtime",
+			content: `set_file_attributes: /usr/local/bin/implant
+reference: /bin/ls
+mtime: 2020-01-01T00:00:00Z`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step SetFileAttributesStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSetFileAttributesExecuteAndCleanup(t *testing.T) {
+	referenceTime := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		step          *SetFileAttributesStep
+		expectedMtime time.Time
+		expectedMode  os.FileMode
+		failChtimes   bool
+		wantError     bool
+	}{
+		{
+			name: "Set Mtime",
+			step: &SetFileAttributesStep{
+				Path:         "/opt/implant",
+				ModifiedTime: "2020-01-02T03:04:05Z",
+			},
+			expectedMtime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			name: "Copy Times From Reference",
+			step: &SetFileAttributesStep{
+				Path:      "/opt/implant",
+				Reference: "/bin/ls",
+			},
+			expectedMtime: referenceTime,
+		},
+		{
+			name: "Set Mode",
+			step: &SetFileAttributesStep{
+				Path: "/opt/implant",
+				Mode: 0700,
+			},
+			expectedMode: 0700,
+		},
+		{
+			name: "Set Setuid Mode",
+			step: &SetFileAttributesStep{
+				Path: "/opt/implant",
+				Mode: 04755,
+			},
+			expectedMode: 0755 | os.ModeSetuid,
+		},
+		{
+			name: "Set Setgid And Sticky Mode",
+			step: &SetFileAttributesStep{
+				Path: "/opt/implant",
+				Mode: 03775,
+			},
+			expectedMode: 0775 | os.ModeSetgid | os.ModeSticky,
+		},
+		{
+			name: "Owner From Passwd",
+			step: &SetFileAttributesStep{
+				Path:  "/opt/implant",
+				Owner: "svc_backup",
+				Group: "10",
+			},
+		},
+		{
+			name: "Unknown Owner",
+			step: &SetFileAttributesStep{
+				Path:  "/opt/implant",
+				Owner: "nobody",
+			},
+			wantError: true,
+		},
+		{
+			name: "Invalid Mtime",
+			step: &SetFileAttributesStep{
+				Path:         "/opt/implant",
+				ModifiedTime: "last tuesday",
+			},
+			wantError: true,
+		},
+		{
+			name: "Mode Restored When Times Fail",
+			step: &SetFileAttributesStep{
+				Path:         "/opt/implant",
+				Mode:         04755,
+				ModifiedTime: "2020-01-02T03:04:05Z",
+			},
+			failChtimes: true,
+			wantError:   true,
+		},
+		{
+			name: "Restored When Outputs Fail",
+			step: &SetFileAttributesStep{
+				actionDefaults: failingOutputs(),
+				Path:           "/opt/implant",
+				Mode:           0700,
+				ModifiedTime:   "2020-01-02T03:04:05Z",
+			},
+			wantError: true,
+		},
+		{
+			name: "Missing File",
+			step: &SetFileAttributesStep{
+				Path: "/opt/missing",
+				Mode: 0700,
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys, err := testutils.MakeAferoTestFs(map[string][]byte{
+				"/opt/implant": []byte("#!/bin/sh"),
+				"/bin/ls":      []byte("ls"),
+				"/etc/passwd":  []byte("svc_backup:x:1005:1005::/home/svc_backup:/bin/sh\n"),
+			})
+			require.NoError(t, err)
+			originalTime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+			require.NoError(t, fsys.Chtimes("/opt/implant", originalTime, originalTime))
+			require.NoError(t, fsys.Chmod("/opt/implant", 0644))
+			require.NoError(t, fsys.Chtimes("/bin/ls", referenceTime, referenceTime))
+			tc.step.FileSystem = fsys
+			if tc.failChtimes {
+				tc.step.FileSystem = &failingChtimesFs{Fs: fsys}
+			}
+
+			execCtx := NewTTPExecutionContext()
+			require.NoError(t, tc.step.Validate(execCtx))
+			require.NoError(t, tc.step.Template(execCtx))
+			_, err = tc.step.Execute(execCtx)
+			cleanup := tc.step.GetDefaultCleanupAction()
+			if tc.wantError {
+				require.Error(t, err)
+				// anything that was changed has already been
+				// restored, so there is nothing left to restore
+				info, err := fsys.Stat("/opt/implant")
+				require.NoError(t, err)
+				assert.True(t, originalTime.Equal(info.ModTime()), "mtime was not restored: %v", info.ModTime())
+				assert.Equal(t, os.FileMode(0644), info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+				_, err = cleanup.Execute(execCtx)
+				require.NoError(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			info, err := fsys.Stat(tc.step.Path)
+			require.NoError(t, err)
+			if !tc.expectedMtime.IsZero() {
+				assert.True(t, tc.expectedMtime.Equal(info.ModTime()), "unexpected mtime %v", info.ModTime())
+			}
+			if tc.expectedMode != 0 {
+				assert.Equal(t, tc.expectedMode, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+			}
+
+			_, err = cleanup.Execute(execCtx)
+			require.NoError(t, err)
+			info, err = fsys.Stat(tc.step.Path)
+			require.NoError(t, err)
+			assert.True(t, originalTime.Equal(info.ModTime()), "mtime was not restored: %v", info.ModTime())
+			assert.Equal(t, os.FileMode(0644), info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+		})
+	}
+}
+
+// failingChtimesFs is a filesystem on which changing times fails
+type failingChtimesFs struct {
+	afero.Fs
+}
+
+func (f *failingChtimesFs) Chtimes(name string, _, _ time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrPermission}
+}
+
+func TestSetFileAttributesLocalAccessTime(t *testing.T) {
+	path := t.TempDir() + "/implant"
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh"), 0644))
+	originalTime := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, originalTime, originalTime))
+
+	step := &SetFileAttributesStep{
+		Path:       path,
+		AccessTime: "2020-01-02T03:04:05Z",
+		FileSystem: afero.NewOsFs(),
+	}
+	execCtx := NewTTPExecutionContext()
+	_, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	// only the access time was requested, so the mtime is kept
+	assert.True(t, originalTime.Equal(info.ModTime()))
+	atime, ok := fileutils.AccessTime(info)
+	require.True(t, ok)
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(atime), "unexpected atime %v", atime)
+
+	_, err = step.GetDefaultCleanupAction().Execute(execCtx)
+	require.NoError(t, err)
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	atime, ok = fileutils.AccessTime(info)
+	require.True(t, ok)
+	assert.True(t, originalTime.Equal(atime), "atime was not restored: %v", atime)
+}
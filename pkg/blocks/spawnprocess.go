@@ -0,0 +1,371 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/spf13/afero"
+)
+
+// DefaultSpawnReadyTimeout is how long a spawn_process step waits
+// for its wait_for conditions if no timeout is specified
+const DefaultSpawnReadyTimeout = 30 * time.Second
+
+// spawnReadyPollInterval is how often the wait_for conditions are checked
+const spawnReadyPollInterval = 250 * time.Millisecond
+
+// SpawnProcessStep starts a program in the background and leaves it
+// running for later steps, killing it along with any processes it
+// started in its default cleanup. Its intended use is building the
+// process trees and masqueraded processes that detections look for.
+type SpawnProcessStep struct {
+	actionDefaults `yaml:",inline"`
+	Path           string            `yaml:"spawn_process,omitempty"`
+	Args           []string          `yaml:"args,omitempty,flow"`
+	Argv0          string            `yaml:"argv0,omitempty"`
+	Environment    map[string]string `yaml:"env,omitempty"`
+	WorkDir        string            `yaml:"cwd,omitempty"`
+	Detach         bool              `yaml:"detach,omitempty"`
+	OutputFile     string            `yaml:"output_file,omitempty"`
+	WaitFor        *SpawnReadiness   `yaml:"wait_for,omitempty"`
+
+	pid int
+}
+
+// SpawnReadiness holds the conditions that a spawned
+// process must meet before the step completes
+type SpawnReadiness struct {
+	// Port is a TCP port (optionally host:port) that must accept connections
+	Port string `yaml:"port,omitempty"`
+	// Path is a file that must exist
+	Path string `yaml:"path,omitempty"`
+	// Output is a regular expression that must match the output file
+	Output string `yaml:"output,omitempty"`
+	// Delay is how long to wait before checking the other conditions
+	Delay   string `yaml:"delay,omitempty"`
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// NewSpawnProcessStep creates a new SpawnProcessStep instance and returns a pointer to it.
+func NewSpawnProcessStep() *SpawnProcessStep {
+	return &SpawnProcessStep{}
+}
+
+// IsNil checks if the step is nil or empty and returns a boolean value.
+func (s *SpawnProcessStep) IsNil() bool {
+	switch s.Path {
+	case "":
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate validates the step, checking for the necessary attributes and dependencies.
+func (s *SpawnProcessStep) Validate(_ TTPExecutionContext) error {
+	if s.Path == "" {
+		return fmt.Errorf("spawn_process field cannot be empty")
+	}
+	if s.WaitFor == nil {
+		return nil
+	}
+	w := s.WaitFor
+	if w.Port == "" && w.Path == "" && w.Output == "" && w.Delay == "" {
+		return fmt.Errorf("wait_for must specify at least one of port, path, output or delay")
+	}
+	if w.Output != "" {
+		if s.OutputFile == "" {
+			return fmt.Errorf("wait_for output requires output_file to be set")
+		}
+		if _, err := regexp.Compile(w.Output); err != nil {
+			return fmt.Errorf("invalid wait_for output regex: %w", err)
+		}
+	}
+	for name, value := range map[string]string{"delay": w.Delay, "timeout": w.Timeout} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid wait_for %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Template takes each applicable field in the step and replaces any template strings with their resolved values.
+//
+// **Returns:**
+//
+// error: error if template resolution fails, nil otherwise
+func (s *SpawnProcessStep) Template(execCtx TTPExecutionContext) error {
+	var err error
+	s.Path, err = execCtx.templateStep(s.Path)
+	if err != nil {
+		return err
+	}
+	for index, value := range s.Args {
+		s.Args[index], err = execCtx.templateStep(value)
+		if err != nil {
+			return err
+		}
+	}
+	s.Argv0, err = execCtx.templateStep(s.Argv0)
+	if err != nil {
+		return err
+	}
+	s.WorkDir, err = execCtx.templateStep(s.WorkDir)
+	if err != nil {
+		return err
+	}
+	s.OutputFile, err = execCtx.templateStep(s.OutputFile)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// getSpawner returns the backend that the process is spawned
+// on, which is the local machine if there is none
+func getSpawner(execCtx TTPExecutionContext) (backends.ExecutionBackend, backends.ProcessSpawner, error) {
+	backend := execCtx.Backend
+	if backend == nil {
+		backend = backends.NewLocalBackend()
+	}
+	spawner, ok := backend.(backends.ProcessSpawner)
+	if !ok {
+		return nil, nil, errors.New("spawning processes is not supported by this execution backend")
+	}
+	return backend, spawner, nil
+}
+
+// Execute runs the step and returns an error if one occurs.
+func (s *SpawnProcessStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	backend, spawner, err := getSpawner(execCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	// remote processes do not inherit the local environment
+	var env []string
+	if execCtx.Backend == nil {
+		env = os.Environ()
+	}
+	env = append(env, FetchEnv(execCtx.GlobalEnv)...)
+	env = append(env, FetchEnv(s.Environment)...)
+	workDir := s.WorkDir
+	if workDir == "" {
+		workDir = execCtx.Vars.WorkDir
+	}
+
+	logging.L().Infof("Spawning process %v %v", s.Path, s.Args)
+	s.pid, err = spawner.SpawnProcess(backends.SpawnSpec{
+		Path:       s.Path,
+		Args:       s.Args,
+		Argv0:      s.Argv0,
+		Env:        env,
+		WorkDir:    workDir,
+		Detach:     s.Detach,
+		OutputFile: s.OutputFile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to spawn process %v: %w", s.Path, err)
+	}
+	logging.L().Infof("Spawned process with ID: %d", s.pid)
+
+	if s.WaitFor != nil {
+		if err := s.waitUntilReady(backend); err != nil {
+			return nil, s.killAfterFailure(spawner, err)
+		}
+	}
+
+	result := &ActResult{
+		Outputs: map[string]string{
+			"pid": strconv.Itoa(s.pid),
+		},
+		TypedOutputs: map[string]any{
+			"pid": s.pid,
+		},
+	}
+	if err := s.extractOutputs(result); err != nil {
+		return nil, s.killAfterFailure(spawner, err)
+	}
+	return result, nil
+}
+
+// killAfterFailure kills the spawned process tree and returns cause
+// along with any error from doing so. Cleanup is not run for steps
+// that fail, so the process must not be left running.
+func (s *SpawnProcessStep) killAfterFailure(spawner backends.ProcessSpawner, cause error) error {
+	if err := spawner.KillProcessTree(s.pid); err != nil {
+		cause = errors.Join(cause, err)
+	}
+	s.pid = 0
+	return cause
+}
+
+// waitUntilReady polls the wait_for conditions until they are all
+// met, failing if the process exits or the timeout expires first
+func (s *SpawnProcessStep) waitUntilReady(backend backends.ExecutionBackend) error {
+	w := s.WaitFor
+	timeout := DefaultSpawnReadyTimeout
+	if w.Timeout != "" {
+		timeout, _ = time.ParseDuration(w.Timeout)
+	}
+	deadline := time.Now().Add(timeout)
+	if w.Delay != "" {
+		delay, _ := time.ParseDuration(w.Delay)
+		time.Sleep(delay)
+	}
+
+	logging.L().Infof("Waiting up to %v for process %d to become ready", timeout, s.pid)
+	for {
+		exists, err := backend.ProcessExists(s.pid)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("process %d exited before it became ready", s.pid)
+		}
+		notReady, err := s.checkReadiness(backend)
+		if err != nil {
+			return err
+		}
+		if notReady == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("process %d did not become ready within %v: %v", s.pid, timeout, notReady)
+		}
+		time.Sleep(spawnReadyPollInterval)
+	}
+}
+
+// checkReadiness describes the first wait_for condition
+// that is not yet met, or returns "" if they all are
+func (s *SpawnProcessStep) checkReadiness(backend backends.ExecutionBackend) (string, error) {
+	w := s.WaitFor
+	if w.Port != "" {
+		addr := w.Port
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort("127.0.0.1", addr)
+		}
+		dialer, ok := backend.(backends.Dialer)
+		if !ok {
+			return "", errors.New("waiting for a port is not supported by this execution backend")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		cancel()
+		if err != nil {
+			return fmt.Sprintf("port %v is not accepting connections", addr), nil
+		}
+		conn.Close()
+	}
+
+	if w.Path == "" && w.Output == "" {
+		return "", nil
+	}
+	fsys, err := backend.GetFs()
+	if err != nil {
+		return "", fmt.Errorf("failed to get filesystem: %w", err)
+	}
+	if w.Path != "" {
+		exists, err := afero.Exists(fsys, w.Path)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return fmt.Sprintf("path %v does not exist", w.Path), nil
+		}
+	}
+	if w.Output != "" {
+		output, err := afero.ReadFile(fsys, s.OutputFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if !regexp.MustCompile(w.Output).Match(output) {
+			return fmt.Sprintf("output does not match %q", w.Output), nil
+		}
+	}
+	return "", nil
+}
+
+// GetDefaultCleanupAction will instruct the calling code
+// to kill the spawned process and all of its descendants
+func (s *SpawnProcessStep) GetDefaultCleanupAction() Action {
+	return &killSpawnedProcessAction{step: s}
+}
+
+// killSpawnedProcessAction kills the process tree started by a SpawnProcessStep
+type killSpawnedProcessAction struct {
+	actionDefaults
+	step *SpawnProcessStep
+}
+
+// IsNil is not needed here, as this is not a user-accessible step type
+func (a *killSpawnedProcessAction) IsNil() bool {
+	return false
+}
+
+// Validate is not needed here, as this is not a user-accessible step type
+func (a *killSpawnedProcessAction) Validate(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Template is not needed here, as this is not a user-accessible step type
+func (a *killSpawnedProcessAction) Template(_ TTPExecutionContext) error {
+	return nil
+}
+
+// Execute kills the spawned process along with its descendants
+func (a *killSpawnedProcessAction) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
+	pid := a.step.pid
+	if pid == 0 {
+		logging.L().Info("No process was spawned - skipping cleanup")
+		return &ActResult{}, nil
+	}
+	backend, spawner, err := getSpawner(execCtx)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := backend.ProcessExists(pid)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		logging.L().Infof("Process %d has already exited", pid)
+		return &ActResult{}, nil
+	}
+	logging.L().Infof("Killing process %d and its descendants", pid)
+	if err := spawner.KillProcessTree(pid); err != nil {
+		return nil, err
+	}
+	return &ActResult{}, nil
+}
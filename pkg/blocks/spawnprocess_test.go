@@ -0,0 +1,253 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/processutils"
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// processGone reports whether a process has exited - zombies count
+// as exited, since orphans may not be reaped inside containers
+func processGone(pid int) bool {
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return true
+	}
+	status, err := proc.Status()
+	return err != nil || slices.Contains(status, process.Zombie)
+}
+
+func TestSpawnProcessValidate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		content   string
+		wantError bool
+	}{
+		{
+			name: "Valid",
+			content: `spawn_process: /usr/bin/python3
+args: ["-m", "http.server", "8000"]
+argv0: "[kworker/0:1]"
+detach: true
+wait_for:
+  port: "8000"
+  timeout: 10s`,
+		},
+		{
+			name: "Empty Wait For",
+			content: `spawn_process: /bin/sleep
+wait_for:
+  timeout: 10s`,
+			wantError: true,
+		},
+		{
+			name: "Output Without Output File",
+			content: `spawn_process: /bin/sleep
+wait_for:
+  output: ready`,
+			wantError: true,
+		},
+		{
+			name: "Invalid Delay",
+			content: `spawn_process: /bin/sleep
+wait_for:
+  delay: soon`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var step SpawnProcessStep
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &step))
+			err := step.Validate(NewTTPExecutionContext())
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSpawnProcessExecuteAndCleanup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses POSIX shell commands")
+	}
+	outputFile := filepath.Join(t.TempDir(), "output.log")
+	readyFile := filepath.Join(t.TempDir(), "ready")
+
+	testCases := []struct {
+		name             string
+		step             *SpawnProcessStep
+		expectedChildren int
+		wantError        bool
+	}{
+		{
+			name: "Process Tree",
+			step: &SpawnProcessStep{
+				Path: "sh",
+				Args: []string{"-c", "sleep 300 & sleep 300"},
+			},
+			expectedChildren: 2,
+		},
+		{
+			name: "Masqueraded Process",
+			step: &SpawnProcessStep{
+				Path:  "sleep",
+				Args:  []string{"300"},
+				Argv0: "ttpforge-masquerade",
+			},
+		},
+		{
+			name: "Wait For Output",
+			step: &SpawnProcessStep{
+				Path:       "sh",
+				Args:       []string{"-c", "sleep 0.3; echo listening on $PORT; exec sleep 300"},
+				OutputFile: outputFile,
+				Environment: map[string]string{
+					"PORT": "4444",
+				},
+				WaitFor: &SpawnReadiness{Output: "listening on 4444"},
+			},
+		},
+		{
+			name: "Wait For Path",
+			step: &SpawnProcessStep{
+				Path:    "sh",
+				Args:    []string{"-c", "sleep 0.3; touch " + readyFile + "; exec sleep 300"},
+				WaitFor: &SpawnReadiness{Path: readyFile},
+			},
+		},
+		{
+			name: "Never Ready",
+			step: &SpawnProcessStep{
+				Path:    "sleep",
+				Args:    []string{"300"},
+				WaitFor: &SpawnReadiness{Port: "1", Timeout: "500ms"},
+			},
+			wantError: true,
+		},
+		{
+			name: "Exits Before Ready",
+			step: &SpawnProcessStep{
+				Path:    "true",
+				WaitFor: &SpawnReadiness{Path: readyFile + ".never", Timeout: "5s"},
+			},
+			wantError: true,
+		},
+		{
+			name: "Missing Program",
+			step: &SpawnProcessStep{
+				Path: "/does/not/exist",
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execCtx := NewTTPExecutionContext()
+			require.NoError(t, tc.step.Validate(execCtx))
+			require.NoError(t, tc.step.Template(execCtx))
+			result, err := tc.step.Execute(execCtx)
+			if tc.wantError {
+				require.Error(t, err)
+				assert.Zero(t, tc.step.pid, "failed step should not leave a process to clean up")
+				return
+			}
+			require.NoError(t, err)
+			pid := result.TypedOutputs["pid"].(int)
+			assert.False(t, processGone(pid))
+
+			proc, err := process.NewProcess(int32(pid))
+			require.NoError(t, err)
+			if tc.step.Argv0 != "" {
+				cmdline, err := proc.CmdlineSlice()
+				require.NoError(t, err)
+				assert.Equal(t, tc.step.Argv0, cmdline[0])
+			}
+
+			var tree []int32
+			require.Eventually(t, func() bool {
+				tree, err = processutils.GetProcessTree(int32(pid))
+				return err == nil && len(tree) == tc.expectedChildren+1
+			}, 5*time.Second, 50*time.Millisecond)
+
+			_, err = tc.step.GetDefaultCleanupAction().Execute(execCtx)
+			require.NoError(t, err)
+			for _, p := range tree {
+				assert.Eventually(t, func() bool {
+					return processGone(int(p))
+				}, 5*time.Second, 50*time.Millisecond, "process %d was not killed", p)
+			}
+		})
+	}
+}
+
+func TestSpawnProcessOutputsFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses POSIX shell commands")
+	}
+	var step SpawnProcessStep
+	require.NoError(t, yaml.Unmarshal([]byte(`spawn_process: sh
+args:
+  - -c
+  - "sleep 300; : spawn-outputs-failure"
+outputs:
+  listener:
+    filters:
+      - json_path: port`), &step))
+	execCtx := NewTTPExecutionContext()
+	require.NoError(t, step.Validate(execCtx))
+	require.NoError(t, step.Template(execCtx))
+	_, err := step.Execute(execCtx)
+	require.Error(t, err)
+	assert.Zero(t, step.pid, "failed step should not leave a process to clean up")
+
+	assert.Eventually(t, func() bool {
+		pids, err := processutils.GetPIDsByCommandLine(regexp.MustCompile(`spawn-outputs-failure`))
+		if err != nil {
+			return errors.Is(err, processutils.ErrNoProcessFound)
+		}
+		return !slices.ContainsFunc(pids, func(pid int32) bool { return !processGone(int(pid)) })
+	}, 5*time.Second, 50*time.Millisecond, "spawned process was not killed")
+}
+
+func TestSpawnProcessUnsupportedBackend(t *testing.T) {
+	execCtx := NewTTPExecutionContext()
+	execCtx.Backend = newMockBackend("no-spawn")
+	step := &SpawnProcessStep{Path: "sleep", Args: []string{"300"}}
+	_, err := step.Execute(execCtx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+}
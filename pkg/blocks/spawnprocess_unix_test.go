@@ -0,0 +1,57 @@
+//go:build unix
+
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package blocks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestSpawnProcessDetach(t *testing.T) {
+	execCtx := NewTTPExecutionContext()
+	step := &SpawnProcessStep{
+		Path:   "sleep",
+		Args:   []string{"300"},
+		Detach: true,
+	}
+	result, err := step.Execute(execCtx)
+	require.NoError(t, err)
+	pid := result.TypedOutputs["pid"].(int)
+
+	// a detached process leads its own session
+	sid, err := unix.Getsid(pid)
+	require.NoError(t, err)
+	assert.Equal(t, pid, sid)
+	ownSid, err := unix.Getsid(0)
+	require.NoError(t, err)
+	assert.NotEqual(t, ownSid, sid)
+
+	_, err = step.GetDefaultCleanupAction().Execute(execCtx)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return processGone(pid)
+	}, 5*time.Second, 50*time.Millisecond)
+}
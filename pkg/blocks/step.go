@@ -23,6 +23,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/facebookincubator/ttpforge/pkg/backends"
 	"github.com/facebookincubator/ttpforge/pkg/checks"
@@ -38,6 +40,9 @@ type CommonStepFields struct {
 	Name   string         `yaml:"name,omitempty"`
 	Remote string         `yaml:"remote,omitempty"`
 	Checks []checks.Check `yaml:"checks,omitempty"`
+	// CleanupChecks are verified after the cleanup action
+	// runs, to confirm that no artifacts were left behind
+	CleanupChecks []checks.Check `yaml:"cleanup_checks,omitempty"`
 
 	// CleanupSpec is exported so that UnmarshalYAML
 	// can see it - however, it should be considered
@@ -101,10 +106,12 @@ func (s *Step) ShouldCleanupOnFailure() bool {
 // to make subTTPs always run their default
 // cleanup process even when `cleanup: default` is
 // not explicitly specified - this is purely for backward
-// compatibility
+// compatibility. encrypt_files steps also always run
+// their default cleanup, since it is the only way
+// to decrypt the files.
 func ShouldUseImplicitDefaultCleanup(action Action) bool {
 	switch action.(type) {
-	case *SubTTPStep:
+	case *SubTTPStep, *EncryptFilesStep:
 		return true
 	default:
 		return false
@@ -135,6 +142,7 @@ func (s *Step) UnmarshalYAML(node *yaml.Node) error {
 	if err != nil {
 		return fmt.Errorf("could not parse action for step %q: %w", s.Name, err)
 	}
+	markRemote(s.action, s.Remote)
 
 	// figure out what kind of action is
 	// associated with cleaning up this step
@@ -158,6 +166,10 @@ func (s *Step) UnmarshalYAML(node *yaml.Node) error {
 			return fmt.Errorf("`cleanup: default` was specified but step %v is not an action type that has a default cleanup action", s.Name)
 		}
 
+		if _, ok := s.action.(*EncryptFilesStep); ok {
+			return fmt.Errorf("step %v cannot have a custom cleanup action, as the default cleanup is the only way to decrypt its files", s.Name)
+		}
+
 		// Extract remote: from custom cleanup mapping before parsing the action
 		var cleanupMeta struct {
 			Remote string `yaml:"remote,omitempty"`
@@ -170,6 +182,7 @@ func (s *Step) UnmarshalYAML(node *yaml.Node) error {
 		if err != nil {
 			return fmt.Errorf("could not parse cleanup action for step %q: %w", s.Name, err)
 		}
+		markRemote(s.cleanup, s.cleanupRemote)
 	}
 	return nil
 }
@@ -185,9 +198,37 @@ func (s *Step) Validate(execCtx TTPExecutionContext) error {
 			return err
 		}
 	}
+	for checkIdx, check := range s.Checks {
+		if err := check.ValidateRemote(s.Remote); err != nil {
+			return fmt.Errorf("success check %d of step %q is invalid: %w", checkIdx+1, s.Name, err)
+		}
+	}
+	for checkIdx, check := range s.CleanupChecks {
+		if err := check.ValidateRemote(s.cleanupTarget()); err != nil {
+			return fmt.Errorf("cleanup check %d of step %q is invalid: %w", checkIdx+1, s.Name, err)
+		}
+	}
 	return nil
 }
 
+// markRemote tells an action that it runs on the given remote
+// connection, for actions whose validation checks the local host
+func markRemote(action Action, remote string) {
+	if expectStep, ok := action.(*ExpectStep); ok {
+		expectStep.remote = remote != ""
+	}
+}
+
+// cleanupTarget returns the remote on which the cleanup action runs:
+// the step's own remote for default cleanups, and the cleanup's
+// remote: (or the runner if it has none) for custom cleanups
+func (s *Step) cleanupTarget() string {
+	if s.isDefaultCleanup {
+		return s.Remote
+	}
+	return s.cleanupRemote
+}
+
 // Template replaces variables in the step action
 func (s *Step) Template(execCtx TTPExecutionContext) error {
 	if err := s.action.Template(execCtx); err != nil {
@@ -263,17 +304,7 @@ func (s *Step) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 // Cleanup runs the cleanup action associated with this step
 func (s *Step) Cleanup(execCtx TTPExecutionContext) (*ActResult, error) {
 	if s.cleanup != nil {
-		// Determine remote target for cleanup:
-		// - cleanup: default → inherit step's remote (same host)
-		// - custom cleanup → use cleanup's own remote: (local if not specified)
-		remoteForCleanup := ""
-		if s.isDefaultCleanup {
-			remoteForCleanup = s.Remote
-		} else {
-			remoteForCleanup = s.cleanupRemote
-		}
-
-		restore, err := s.swapToRemote(&execCtx, remoteForCleanup)
+		restore, err := s.swapToRemote(&execCtx, s.cleanupTarget())
 		if err != nil {
 			return nil, err
 		}
@@ -366,6 +397,14 @@ func (s *Step) ParseAction(node *yaml.Node) (Action, error) {
 		NewExpectStep(),
 		NewHTTPRequestStep(),
 		NewKillProcessStep(),
+		NewArchiveStep(),
+		NewExtractStep(),
+		NewSetFileAttributesStep(),
+		NewEncryptFilesStep(),
+		NewSpawnProcessStep(),
+		NewPersistenceStep(),
+		NewExfiltrateStep(),
+		NewBeaconStep(),
 	}
 
 	var action Action
@@ -395,15 +434,27 @@ func (s *Step) ParseAction(node *yaml.Node) (Action, error) {
 }
 
 // buildVerificationContext creates a VerificationContext for the given remote
-// connection name. If remoteName is empty, the context targets the local machine.
-// stepOutput is the combined stdout+stderr from the step that just ran.
-func (s *Step) buildVerificationContext(execCtx TTPExecutionContext, remoteName string, stepOutput string) (checks.VerificationContext, error) {
+// connection name. If remoteName is empty or "local", the context targets the
+// local machine. result holds the output of the step that just ran (it may
+// be nil), and startTime is when that step began executing.
+func buildVerificationContext(execCtx TTPExecutionContext, remoteName string, result *ActResult, startTime time.Time) (checks.VerificationContext, error) {
+	// "local" is a reserved alias for the runner
+	if remoteName == "local" {
+		remoteName = ""
+	}
+	var stepOutput string
+	var outputs map[string]string
+	if result != nil {
+		stepOutput = result.Stdout + result.Stderr
+		outputs = result.Outputs
+	}
+
 	var activeBackend backends.ExecutionBackend
 	if remoteName != "" && execCtx.ConnPool != nil {
 		var err error
 		activeBackend, err = execCtx.ConnPool.GetByName(remoteName)
 		if err != nil {
-			return checks.VerificationContext{}, fmt.Errorf("failed to get backend for check on step %q: %w", s.Name, err)
+			return checks.VerificationContext{}, fmt.Errorf("failed to get backend for remote %q: %w", remoteName, err)
 		}
 	} else if remoteName == "" {
 		activeBackend = execCtx.Backend
@@ -421,8 +472,21 @@ func (s *Step) buildVerificationContext(execCtx TTPExecutionContext, remoteName
 	}
 
 	verificationCtx := checks.VerificationContext{
-		FileSystem: fsys,
-		StepOutput: stepOutput,
+		Backend:       activeBackend,
+		FileSystem:    fsys,
+		StepOutput:    stepOutput,
+		Outputs:       outputs,
+		StepStartTime: startTime,
+		ExpandVariables: func(input string) (string, error) {
+			expanded, err := execCtx.ExpandVariables([]string{input})
+			if err != nil {
+				return "", err
+			}
+			return expanded[0], nil
+		},
+		ForRemote: func(nestedRemote string) (checks.VerificationContext, error) {
+			return buildVerificationContext(execCtx, nestedRemote, result, startTime)
+		},
 	}
 
 	if activeBackend != nil {
@@ -446,7 +510,12 @@ func (s *Step) buildVerificationContext(execCtx TTPExecutionContext, remoteName
 			stdout, stderr, err := activeBackend.RunCommand(ctx, shellName, "", args, nil, "", nil, nil)
 			output := stdout + stderr
 			if err != nil {
-				return output, 1, nil //nolint:nilerr // non-zero exit is not a check error
+				// a non-zero exit is not a check error, but
+				// a command that could not be run at all is
+				if exitCode, ok := backends.ExitCode(err); ok {
+					return output, exitCode, nil
+				}
+				return output, 0, err
 			}
 			return output, 0, nil
 		}
@@ -455,6 +524,18 @@ func (s *Step) buildVerificationContext(execCtx TTPExecutionContext, remoteName
 	return verificationCtx, nil
 }
 
+// startTime returns the time at which this step began
+// executing, or the zero time if it has not been recorded
+func (s *Step) startTime(execCtx TTPExecutionContext) time.Time {
+	if execCtx.StepResults == nil {
+		return time.Time{}
+	}
+	if result, ok := execCtx.StepResults.ByName[s.Name]; ok {
+		return result.StartTime
+	}
+	return time.Time{}
+}
+
 // VerifyChecks runs all checks and returns an error if any of them fail.
 // result is the ActResult from the step execution; it may be nil.
 func (s *Step) VerifyChecks(execCtx TTPExecutionContext, result *ActResult) error {
@@ -462,33 +543,48 @@ func (s *Step) VerifyChecks(execCtx TTPExecutionContext, result *ActResult) erro
 		logging.L().Debugf("No checks defined for step %v", s.Name)
 		return nil
 	}
+	return s.verifyCheckList(execCtx, s.Checks, "success", result)
+}
 
-	var stepOutput string
-	if result != nil {
-		stepOutput = result.Stdout + result.Stderr
+// VerifyCleanupChecks runs all cleanup checks and returns an error if
+// any of them fail. It should be called after the step's cleanup action
+// has run - result is the ActResult from the cleanup; it may be nil.
+func (s *Step) VerifyCleanupChecks(execCtx TTPExecutionContext, result *ActResult) error {
+	if len(s.CleanupChecks) == 0 {
+		logging.L().Debugf("No cleanup checks defined for step %v", s.Name)
+		return nil
 	}
+	return s.verifyCheckList(execCtx, s.CleanupChecks, "cleanup", result)
+}
 
-	for checkIdx, check := range s.Checks {
+// verifyCheckList runs the given checks in order, stopping at the
+// first failure. kind names the type of check in log and error messages.
+func (s *Step) verifyCheckList(execCtx TTPExecutionContext, checkList []checks.Check, kind string, result *ActResult) error {
+	for checkIdx, check := range checkList {
 		// Resolve the effective remote for this check.
 		// Default (empty) → run on localhost (the runner).
 		// "local" → reserved alias, same as default (runner).
 		// "<connection_name>" → run on that named remote connection.
-		checkRemote := check.GetRemote()
-		switch checkRemote {
-		case "", "local":
-			// No override or explicit "local" → run on the runner
-			checkRemote = ""
+		verificationCtx, err := buildVerificationContext(execCtx, check.GetRemote(), result, s.startTime(execCtx))
+		if err != nil {
+			return fmt.Errorf("%s check %d of step %q setup failed: %w", kind, checkIdx+1, s.Name, err)
 		}
 
-		verificationCtx, err := s.buildVerificationContext(execCtx, checkRemote, stepOutput)
+		attempts, elapsed, err := check.VerifyEventually(verificationCtx)
 		if err != nil {
-			return fmt.Errorf("success check %d of step %q setup failed: %w", checkIdx+1, s.Name, err)
+			if check.Eventually != "" {
+				return fmt.Errorf("%s check %d of step %q failed after %d attempts in %v: %w",
+					kind, checkIdx+1, s.Name, attempts, elapsed.Round(time.Millisecond), err)
+			}
+			return fmt.Errorf("%s check %d of step %q failed: %w", kind, checkIdx+1, s.Name, err)
 		}
-
-		if err := check.Verify(verificationCtx); err != nil {
-			return fmt.Errorf("success check %d of step %q failed: %w", checkIdx+1, s.Name, err)
+		logLabel := strings.ToUpper(kind[:1]) + kind[1:]
+		if attempts > 1 {
+			logging.L().Infof("%s check %d (%q) of step %q PASSED after %d attempts in %v",
+				logLabel, checkIdx+1, check.Msg, s.Name, attempts, elapsed.Round(time.Millisecond))
+		} else {
+			logging.L().Debugf("%s check %d (%q) of step %q PASSED", logLabel, checkIdx+1, check.Msg, s.Name)
 		}
-		logging.L().Debugf("Success check %d (%q) of step %q PASSED", checkIdx+1, check.Msg, s.Name)
 	}
 	return nil
 }
@@ -21,9 +21,11 @@ package blocks
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/facebookincubator/ttpforge/pkg/logging"
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
 )
 
 // SubTTPStep represents a step within a parent TTP that references a separate TTP file.
@@ -97,6 +99,9 @@ func (s *SubTTPStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 	logging.L().Infof("[*] Executing Sub TTP: %s", s.TtpRef)
 	logging.IncreaseIndentLevel()
 	runErr := s.ttp.RunSteps(*s.subExecCtx)
+	if runErr == nil {
+		runErr = s.ttp.VerifyAssertions(*s.subExecCtx)
+	}
 	if runErr != nil {
 		return &ActResult{}, runErr
 	}
@@ -115,6 +120,9 @@ func (s *SubTTPStep) Execute(execCtx TTPExecutionContext) (*ActResult, error) {
 		execCtx.Vars.StepVars[s.OutputVar] = strings.TrimSuffix(result.Stdout, "\n")
 	}
 
+	if err := s.extractOutputs(result); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
@@ -140,9 +148,35 @@ func aggregateResults(results []*ActResult) *ActResult {
 	}
 }
 
+// processSubTTPArgs converts the args of this step into the
+// "ARG_NAME=ARG_VALUE" form and expands any variables in them.
+//
+// An arg whose value is exactly one step output reference keeps the
+// type of that output: lists and objects are passed as JSON, so that
+// they can be used with `list` args. If the referenced step has not
+// run yet (as is the case during validation), the reference is passed
+// through unchanged and resolved when the step is templated.
 func (s *SubTTPStep) processSubTTPArgs(execCtx TTPExecutionContext) ([]string, error) {
+	re := contextVariableRegexp()
 	var argKvStrs []string
 	for k, v := range s.Args {
+		if re.FindString(v) == v && !strings.HasPrefix(v, "$$") {
+			path, err := parseVariableExpression(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid variable expression %v: %w", v, err)
+			}
+			stepName, _, _ := strings.Cut(path, ".")
+			if _, ok := execCtx.StepResults.ByName[stepName]; !ok {
+				argKvStrs = append(argKvStrs, k+"="+v)
+				continue
+			}
+			val, err := execCtx.resolveStepsVariable(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid variable expression %v: %w", v, err)
+			}
+			argKvStrs = append(argKvStrs, k+"="+outputs.Stringify(val))
+			continue
+		}
 		argKvStrs = append(argKvStrs, k+"="+v)
 	}
 
@@ -174,9 +208,10 @@ func (s *SubTTPStep) loadSubTTP(execCtx TTPExecutionContext) error {
 	s.ttp = ttps
 	s.subExecCtx = ctx
 
-	// Propagate backend and connection pool to child context
+	// Propagate backend, connection pool and HTTP sessions to child context
 	s.subExecCtx.Backend = execCtx.Backend
 	s.subExecCtx.ConnPool = execCtx.ConnPool
+	s.subExecCtx.HTTPSessions = execCtx.HTTPSessions
 
 	return nil
 }
@@ -21,13 +21,16 @@ package blocks
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"runtime"
+	"slices"
 	"time"
 
 	"github.com/facebookincubator/ttpforge/pkg/backends"
 	"github.com/facebookincubator/ttpforge/pkg/checks"
+	"github.com/facebookincubator/ttpforge/pkg/detections"
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/facebookincubator/ttpforge/pkg/platforms"
 	"gopkg.in/yaml.v3"
@@ -40,11 +43,13 @@ import (
 //
 // Environment: A map of environment variables to be set for the TTP.
 // Steps: An slice of steps to be executed for the TTP.
+// Assertions: Checks that are verified once all steps have completed.
 // WorkDir: The working directory for the TTP.
 type TTP struct {
 	PreambleFields `yaml:",inline"`
 	Environment    map[string]string `yaml:"env,flow,omitempty"`
 	Steps          []Step            `yaml:"steps,omitempty,flow"`
+	Assertions     []checks.Check    `yaml:"assertions,omitempty"`
 	// Omit WorkDir, but expose for testing.
 	WorkDir string `yaml:"-"`
 }
@@ -141,11 +146,73 @@ func (t *TTP) Execute(execCtx TTPExecutionContext) error {
 		return fmt.Errorf("TTP requirements not met: %w", err)
 	}
 
-	err := t.RunSteps(execCtx)
-	if err == nil {
-		logging.L().Info("All TTP steps completed successfully! ✅")
+	// create the connection pool here (rather than in RunSteps)
+	// so that assertions can use the steps' remote connections
+	if execCtx.ConnPool == nil {
+		execCtx.ConnPool = backends.NewConnectionPool()
+		defer execCtx.ConnPool.CloseAll()
 	}
-	return err
+
+	if err := t.RunSteps(execCtx); err != nil {
+		return err
+	}
+	logging.L().Info("All TTP steps completed successfully! ✅")
+	return t.VerifyAssertions(execCtx)
+}
+
+// VerifyAssertions verifies the TTP-level assertions, which check
+// outcomes of the TTP as a whole rather than of any single step.
+// Every assertion is verified (rather than stopping at the first
+// failure) so that the summary covers all of them. It returns an
+// error if any assertion failed.
+func (t *TTP) VerifyAssertions(execCtx TTPExecutionContext) error {
+	if len(t.Assertions) == 0 {
+		return nil
+	}
+	if execCtx.Cfg.NoChecks {
+		logging.L().Info("[*] Skipping Assertions as requested by Config")
+		return nil
+	}
+
+	// log checks in assertions search from the start of the TTP
+	var start time.Time
+	if len(execCtx.StepResults.ByIndex) > 0 {
+		start = execCtx.StepResults.ByIndex[0].StartTime
+	}
+	// assertions are not tied to a step, so they
+	// can check the named outputs of every step
+	allOutputs := &ActResult{Outputs: make(map[string]string)}
+	for stepName, stepResult := range execCtx.StepResults.ByName {
+		for outputName, value := range stepResult.Outputs {
+			allOutputs.Outputs[stepName+"."+outputName] = value
+		}
+	}
+
+	logging.DividerThin()
+	logging.L().Infof("VERIFYING %d assertion(s) of TTP: %q", len(t.Assertions), t.Name)
+	var failed int
+	for _, assertion := range t.Assertions {
+		verificationCtx, err := buildVerificationContext(execCtx, assertion.GetRemote(), allOutputs, start)
+		if err == nil {
+			var attempts int
+			var elapsed time.Duration
+			attempts, elapsed, err = assertion.VerifyEventually(verificationCtx)
+			if err != nil && assertion.Eventually != "" {
+				err = fmt.Errorf("failed after %d attempts in %v: %w", attempts, elapsed.Round(time.Millisecond), err)
+			}
+		}
+		if err != nil {
+			failed++
+			logging.L().Errorf("  [FAIL] %v: %v", assertion.Msg, err)
+			continue
+		}
+		logging.L().Infof("  [PASS] %v", assertion.Msg)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d assertion(s) failed", failed, len(t.Assertions))
+	}
+	logging.L().Info("All TTP assertions passed! ✅")
+	return nil
 }
 
 // RunSteps executes all of the steps in the given TTP.
@@ -176,6 +243,7 @@ func (t *TTP) RunSteps(execCtx TTPExecutionContext) error {
 	for stepIdx, step := range t.Steps {
 		logging.DividerThin()
 		logging.L().Infof("Executing Step #%d: %q", stepIdx+1, step.Name)
+		stepStart := time.Now()
 		// core execution - run the step action
 		go func(step Step) {
 			err := step.Template((execCtx))
@@ -199,9 +267,14 @@ func (t *TTP) RunSteps(execCtx TTPExecutionContext) error {
 			// step execution successful - record results
 			execResult := &ExecutionResult{
 				ActResult: *stepResult,
+				StartTime: stepStart,
 			}
 			execCtx.StepResults.ByName[step.Name] = execResult
 			execCtx.StepResults.ByIndex = append(execCtx.StepResults.ByIndex, execResult)
+			if execCtx.Vars.StepOutputs == nil {
+				execCtx.Vars.StepOutputs = make(map[string]map[string]any)
+			}
+			execCtx.Vars.StepOutputs[step.Name] = stepResult.TypedOutputs
 
 		case stepError = <-execCtx.errorsChan:
 			// this part is tricky - SubTTP steps
@@ -264,16 +337,57 @@ func (t *TTP) RunCleanup(execCtx TTPExecutionContext) error {
 
 	// TODO[nesusvet]: We also should catch signals in clean ups
 	cleanupResults, err := t.startCleanupForCompletedSteps(execCtx)
-	if err != nil {
-		return err
-	}
 	// since ByIndex and ByName both contain pointers to
 	// the same underlying struct, this will update both
 	for cleanupIdx, cleanupResult := range cleanupResults {
 		execCtx.StepResults.ByIndex[cleanupIdx].Cleanup = cleanupResult
 	}
+	return err
+}
 
-	return nil
+// VerifyDetections waits for the detections declared by the TTP
+// to be raised by the configured alert source, then logs whether
+// each one was observed. start should be the time at which the TTP
+// began executing. It returns an error if any detection was missed.
+func (t *TTP) VerifyDetections(execCtx TTPExecutionContext, start time.Time) ([]detections.Result, error) {
+	if len(t.Detections) == 0 {
+		return nil, nil
+	}
+	if execCtx.Cfg.NoDetections {
+		logging.L().Info("[*] Skipping Detection Verification as requested by Config")
+		return nil, nil
+	}
+	if execCtx.Cfg.AlertSource == nil {
+		logging.L().Warnf("TTP declares %d detection(s) but no alert source is configured - not verifying them", len(t.Detections))
+		return nil, nil
+	}
+
+	pollInterval := execCtx.Cfg.AlertPollInterval
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	logging.DividerThin()
+	logging.L().Infof("VERIFYING %d detection(s) of TTP: %q", len(t.Detections), t.Name)
+	results, err := detections.Verify(context.Background(), execCtx.Cfg.AlertSource, t.Detections, start, pollInterval)
+	if err != nil {
+		return results, err
+	}
+
+	logging.L().Info("DETECTION SUMMARY")
+	var hits int
+	for _, result := range results {
+		if result.Hit {
+			hits++
+			logging.L().Infof("  [HIT]  %v after %v", result.Expectation, result.Latency.Round(time.Millisecond))
+		} else {
+			logging.L().Warnf("  [MISS] %v: no alert within %v", result.Expectation, result.MaxLatency)
+		}
+	}
+	logging.L().Infof("%d of %d expected detection(s) observed", hits, len(results))
+	if hits < len(results) {
+		return results, fmt.Errorf("%d of %d expected detection(s) were not observed", len(results)-hits, len(results))
+	}
+	return results, nil
 }
 
 func (t *TTP) chdir() (func(), error) {
@@ -320,6 +434,7 @@ func (t *TTP) startCleanupForCompletedSteps(execCtx TTPExecutionContext) ([]*Act
 	n := len(execCtx.StepResults.ByIndex)
 	logging.L().Infof("CLEANING UP %v steps of TTP: %q", n, t.Name)
 	cleanupResults := make([]*ActResult, n)
+	var unverified []string
 	for cleanupIdx := n - 1; cleanupIdx >= 0; cleanupIdx-- {
 		stepToCleanup := t.Steps[cleanupIdx]
 		logging.DividerThin()
@@ -333,10 +448,32 @@ func (t *TTP) startCleanupForCompletedSteps(execCtx TTPExecutionContext) ([]*Act
 		if err != nil {
 			logging.L().Errorf("error cleaning up step: %v", err)
 			logging.L().Errorf("will continue to try to cleanup other steps")
+			unverified = append(unverified, fmt.Sprintf("%v: cleanup failed: %v", stepToCleanup.Name, err))
 			continue
 		}
+
+		// confirm that the cleanup did not leave artifacts behind
+		if !execCtx.Cfg.NoChecks {
+			if err := stepToCleanup.VerifyCleanupChecks(execCtx, cleanupResult); err != nil {
+				logging.L().Errorf("could not verify cleanup of step: %v", err)
+				unverified = append(unverified, fmt.Sprintf("%v: %v", stepToCleanup.Name, err))
+			}
+		}
 	}
 	logging.DividerThin()
+
+	if len(unverified) > 0 {
+		logging.L().Warnf("Cleanup could not be verified for %d step(s):", len(unverified))
+		// report in step order, not in execution (reverse) order
+		slices.Reverse(unverified)
+		for _, entry := range unverified {
+			logging.L().Warnf("  - %v", entry)
+		}
+		if execCtx.Cfg.StrictCleanup {
+			return cleanupResults, fmt.Errorf("cleanup could not be verified for %d step(s)", len(unverified))
+		}
+		return cleanupResults, nil
+	}
 	logging.L().Info("Finished Cleanup Successfully ✅")
 	return cleanupResults, nil
 }
@@ -20,8 +20,12 @@ THE SOFTWARE.
 package blocks
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/facebookincubator/ttpforge/pkg/detections"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -342,7 +346,7 @@ steps:
 			// Render the templated TTP first
 			ttp, err := RenderTemplatedTTP(tc.content, RenderParameters{
 				Args: tc.args,
-			})
+			}, nil)
 			require.NoError(t, err, "failed to render and unmarshal templated TTP")
 
 			execCtx := NewTTPExecutionContext()
@@ -369,6 +373,347 @@ steps:
 	}
 }
 
+func TestCleanupChecks(t *testing.T) {
+	testCases := []struct {
+		name               string
+		content            string
+		strictCleanup      bool
+		noChecks           bool
+		expectCleanupError bool
+	}{
+		{
+			name: "Cleanup Verified",
+			content: `name: test
+description: cleanup removes the artifact
+steps:
+  - name: drop_file
+    inline: touch "{{ .Args.artifact }}"
+    cleanup:
+      inline: rm "{{ .Args.artifact }}"
+    cleanup_checks:
+      - msg: artifact should be removed
+        path_not_exists: "{{ .Args.artifact }}"`,
+			strictCleanup: true,
+		},
+		{
+			name: "Cleanup Leaves Artifact",
+			content: `name: test
+description: cleanup claims success but leaves the artifact
+steps:
+  - name: drop_file
+    inline: touch "{{ .Args.artifact }}"
+    cleanup:
+      inline: echo "pretending to clean up"
+    cleanup_checks:
+      - msg: artifact should be removed
+        path_not_exists: "{{ .Args.artifact }}"`,
+		},
+		{
+			name: "Cleanup Leaves Artifact (Strict)",
+			content: `name: test
+description: cleanup claims success but leaves the artifact
+steps:
+  - name: drop_file
+    inline: touch "{{ .Args.artifact }}"
+    cleanup:
+      inline: echo "pretending to clean up"
+    cleanup_checks:
+      - msg: artifact should be removed
+        path_not_exists: "{{ .Args.artifact }}"
+  - name: other_step
+    inline: echo "other step"
+    cleanup:
+      inline: echo "cleaning up other step"`,
+			strictCleanup:      true,
+			expectCleanupError: true,
+		},
+		{
+			name: "Failed Cleanup (Strict)",
+			content: `name: test
+description: cleanup action fails
+steps:
+  - name: drop_file
+    inline: touch "{{ .Args.artifact }}"
+    cleanup:
+      inline: rm "{{ .Args.artifact }}" && exit 1`,
+			strictCleanup:      true,
+			expectCleanupError: true,
+		},
+		{
+			name: "Cleanup Checks Skipped",
+			content: `name: test
+description: cleanup checks are skipped with --no-checks
+steps:
+  - name: drop_file
+    inline: touch "{{ .Args.artifact }}"
+    cleanup:
+      inline: echo "pretending to clean up"
+    cleanup_checks:
+      - msg: artifact should be removed
+        path_not_exists: "{{ .Args.artifact }}"`,
+			strictCleanup: true,
+			noChecks:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			artifact := filepath.Join(t.TempDir(), "artifact.txt")
+			ttp, err := RenderTemplatedTTP(tc.content, RenderParameters{
+				Args: map[string]any{"artifact": artifact},
+			}, nil)
+			require.NoError(t, err)
+
+			execCtx := NewTTPExecutionContext()
+			execCtx.Cfg.StrictCleanup = tc.strictCleanup
+			execCtx.Cfg.NoChecks = tc.noChecks
+			require.NoError(t, ttp.Validate(execCtx))
+			require.NoError(t, ttp.Execute(execCtx))
+
+			err = ttp.RunCleanup(execCtx)
+			if tc.expectCleanupError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// cleanup results must be recorded even if
+			// the cleanup could not be verified
+			for _, result := range execCtx.StepResults.ByIndex {
+				assert.NotNil(t, result.Cleanup)
+			}
+		})
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		noChecks      bool
+		errorContains string
+	}{
+		{
+			name: "Assertions Use Step Outputs",
+			content: `name: test
+description: assertions verify the outcome of the whole chain
+steps:
+  - name: stage
+    inline: |
+      echo "{{ .Args.dir }}/staged.txt"
+    outputs:
+      staged_path:
+        filters:
+        - trim: true
+  - name: write
+    inline: echo payload > "$forge.steps.stage.outputs.staged_path"
+assertions:
+  - msg: payload should be staged
+    path_exists: $forge.steps.stage.outputs.staged_path
+    content_contains: payload
+  - msg: payload should be readable
+    command: cat "$forge.steps.stage.outputs.staged_path"
+    output_contains: payload`,
+		},
+		{
+			name: "Structured Output Checks",
+			content: `name: test
+description: step checks and assertions parse JSON output
+steps:
+  - name: enum
+    inline: |
+      echo '{"users": [{"name": "root"}, {"name": "svc_backup"}]}'
+    outputs:
+      users:
+        filters:
+        - json_path: users
+    checks:
+      - msg: two users should be found
+        output_path: users
+        length: 2
+assertions:
+  - msg: backup account should be enumerated
+    output: enum.users
+    output_path: 1.name
+    equals: svc_backup`,
+		},
+		{
+			name: "Failing Assertions",
+			content: `name: test
+description: every assertion is verified even after a failure
+steps:
+  - name: noop
+    print_str: hello
+assertions:
+  - msg: file should exist
+    path_exists: "{{ .Args.dir }}/missing.txt"
+  - msg: command should succeed
+    command: "false"
+  - msg: directory should exist
+    path_exists: "{{ .Args.dir }}"`,
+			errorContains: "2 of 3 assertion(s) failed",
+		},
+		{
+			name: "Assertions Skipped With No Checks",
+			content: `name: test
+description: assertions are checks, so --no-checks skips them
+steps:
+  - name: noop
+    print_str: hello
+assertions:
+  - msg: file should exist
+    path_exists: "{{ .Args.dir }}/missing.txt"`,
+			noChecks: true,
+		},
+		{
+			name: "Assertions Not Verified After Step Failure",
+			content: `name: test
+description: a failed step stops the TTP before assertions
+steps:
+  - name: fail
+    inline: exit 1
+assertions:
+  - msg: file should exist
+    path_exists: "{{ .Args.dir }}/missing.txt"`,
+			errorContains: "exit status 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ttp, err := RenderTemplatedTTP(tc.content, RenderParameters{
+				Args: map[string]any{"dir": t.TempDir()},
+			}, nil)
+			require.NoError(t, err)
+
+			execCtx := NewTTPExecutionContext()
+			execCtx.Cfg.NoChecks = tc.noChecks
+			require.NoError(t, ttp.Validate(execCtx))
+			err = ttp.Execute(execCtx)
+			if tc.errorContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errorContains)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyDetections(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		content              string
+		noSource             bool
+		noDetections         bool
+		expectValidateError  bool
+		expectDetectionError bool
+		expectedHits         []bool
+	}{
+		{
+			name: "Detection Hit",
+			content: `name: test
+description: simulated EDR raises the expected alert
+detections:
+  - rule_id: r1
+    max_latency: 1m
+steps:
+  - name: trigger
+    inline: |
+      echo '{"rule_id": "r1"}' >> "{{ .Args.alerts }}"`,
+			expectedHits: []bool{true},
+		},
+		{
+			name: "Detection Missed",
+			content: `name: test
+description: simulated EDR raises a different alert
+detections:
+  - rule_id: r1
+    max_latency: 1s
+  - rule_name: Never Raised
+    max_latency: 1ms
+steps:
+  - name: trigger
+    inline: |
+      echo '{"rule_id": "r1"}' >> "{{ .Args.alerts }}"`,
+			expectDetectionError: true,
+			expectedHits:         []bool{true, false},
+		},
+		{
+			name: "No Alert Source",
+			content: `name: test
+description: no alert source is configured
+detections:
+  - rule_id: r1
+steps:
+  - name: trigger
+    print_str: hello`,
+			noSource: true,
+		},
+		{
+			name: "Detections Disabled",
+			content: `name: test
+description: detection verification is disabled
+detections:
+  - rule_id: r1
+steps:
+  - name: trigger
+    print_str: hello`,
+			noDetections: true,
+		},
+		{
+			name: "Invalid Detection",
+			content: `name: test
+description: detection does not identify a rule
+detections:
+  - max_latency: 1m
+steps:
+  - name: trigger
+    print_str: hello`,
+			expectValidateError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alertsPath := filepath.Join(t.TempDir(), "alerts.json")
+			ttp, err := RenderTemplatedTTP(tc.content, RenderParameters{
+				Args: map[string]any{"alerts": alertsPath},
+			}, nil)
+			require.NoError(t, err)
+
+			execCtx := NewTTPExecutionContext()
+			execCtx.Cfg.NoDetections = tc.noDetections
+			execCtx.Cfg.AlertPollInterval = time.Millisecond
+			if !tc.noSource {
+				execCtx.Cfg.AlertSource = &detections.JSONLinesSource{
+					Path: alertsPath,
+					Fs:   afero.NewOsFs(),
+				}
+			}
+			err = ttp.Validate(execCtx)
+			if tc.expectValidateError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			start := time.Now()
+			require.NoError(t, ttp.Execute(execCtx))
+			results, err := ttp.VerifyDetections(execCtx, start)
+			if tc.expectDetectionError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, results, len(tc.expectedHits))
+			for i, result := range results {
+				assert.Equal(t, tc.expectedHits[i], result.Hit)
+			}
+		})
+	}
+}
+
 func TestMitreAttackMapping(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -22,15 +22,26 @@ package checks
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 )
 
+// defaultPollInterval is the time between attempts
+// for checks that set eventually: but not interval:
+const defaultPollInterval = time.Second
+
 // CommonCheckFields are common fields across all check types
 type CommonCheckFields struct {
 	Msg    string `yaml:"msg"`
 	Remote string `yaml:"remote,omitempty"`
+	// Eventually is how long to keep re-evaluating a failing
+	// check before giving up, for conditions (such as a file
+	// being quarantined) that become true asynchronously
+	Eventually string `yaml:"eventually,omitempty"`
+	// Interval is the time between attempts when Eventually is set
+	Interval string `yaml:"interval,omitempty"`
 }
 
 // Check is wrapper struct around a Condition.
@@ -41,12 +52,47 @@ type CommonCheckFields struct {
 // for decoding the actions associated with steps.
 type Check struct {
 	CommonCheckFields
-	condition Condition
+	condition    Condition
+	timeout      time.Duration
+	pollInterval time.Duration
 }
 
 // GetRemote returns the per-check remote override (empty means run locally on the runner)
 func (c *Check) GetRemote() string { return c.Remote }
 
+// ValidateRemote returns an error if the check, or any check nested
+// in it, cannot run against the remote on which it will be verified.
+// remote is the remote inherited from the enclosing step, which a
+// check's own remote: overrides.
+func (c *Check) ValidateRemote(remote string) error {
+	if c.Remote != "" {
+		remote = c.Remote
+	}
+	// "local" is a reserved alias for the runner
+	if remote == "local" {
+		remote = ""
+	}
+	var nested []nestedCheck
+	switch cond := c.condition.(type) {
+	case *PortOpen:
+		return cond.ValidateRemote(remote)
+	case *PortClosed:
+		return cond.ValidateRemote(remote)
+	case *AllOf:
+		nested = cond.Checks
+	case *AnyOf:
+		nested = cond.Checks
+	case *Not:
+		nested = []nestedCheck{*cond.Check}
+	}
+	for i := range nested {
+		if err := nested[i].ValidateRemote(remote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Verify wraps the Verify method from the underlying condition
 func (c *Check) Verify(ctx VerificationContext) error {
 	if ctx.FileSystem == nil {
@@ -55,10 +101,42 @@ func (c *Check) Verify(ctx VerificationContext) error {
 	return c.condition.Verify(ctx)
 }
 
+// VerifyEventually verifies the check, re-evaluating it until it
+// passes or the eventually: timeout expires. Checks that do not set
+// eventually: are verified exactly once. It returns the number of
+// attempts made and the time taken along with the result of the
+// last attempt.
+func (c *Check) VerifyEventually(ctx VerificationContext) (int, time.Duration, error) {
+	start := time.Now()
+	attempts := 0
+	for {
+		attempts++
+		err := c.Verify(ctx)
+		elapsed := time.Since(start)
+		if err == nil || c.timeout == 0 || elapsed+c.pollInterval > c.timeout {
+			return attempts, elapsed, err
+		}
+		time.Sleep(c.pollInterval)
+	}
+}
+
 // UnmarshalYAML implements custom deserialization
 // process to ensure that the check is decoded
 // into the correct struct type
 func (c *Check) UnmarshalYAML(node *yaml.Node) error {
+	if err := c.decode(node); err != nil {
+		return err
+	}
+	if c.Msg == "" {
+		return errors.New("no msg specified for check")
+	}
+	return nil
+}
+
+// decode populates the check from the given node without
+// requiring a msg, so that it can also be used for
+// checks nested inside composite conditions
+func (c *Check) decode(node *yaml.Node) error {
 
 	// Decode all of the shared fields.
 	// Use of this auxiliary type prevents infinite recursion
@@ -68,19 +146,39 @@ func (c *Check) UnmarshalYAML(node *yaml.Node) error {
 		return err
 	}
 	c.CommonCheckFields = ccf
-
-	if c.Msg == "" {
-		return errors.New("no msg specified for check")
+	c.condition = nil
+	if err := c.parsePolling(); err != nil {
+		return err
 	}
 
 	candidateTypeInstances := []Condition{
 		&PathExists{},
+		&PathNotExists{},
 		&CommandCheck{},
 		&OutputCheck{},
+		&OutputPath{},
+		&ProcessRunning{},
+		&ProcessNotRunning{},
+		&PortOpen{},
+		&PortClosed{},
+		&HTTPEndpoint{},
+		&LogContains{},
+		&AllOf{},
+		&AnyOf{},
+		&Not{},
 	}
 	for _, candidateTypeInstance := range candidateTypeInstances {
 		err := node.Decode(candidateTypeInstance)
-		if err == nil && !candidateTypeInstance.IsNil() {
+		if err != nil {
+			// composite conditions report errors in their
+			// nested checks - other candidate types simply
+			// do not match nodes that they cannot decode
+			if cc, ok := candidateTypeInstance.(compositeCondition); ok && hasKey(node, cc.keyword()) {
+				return err
+			}
+			continue
+		}
+		if !candidateTypeInstance.IsNil() {
 			if c.condition != nil {
 				// Must catch conditions with ambiguous types, such as:
 				// - path_exists: foo
@@ -98,3 +196,29 @@ func (c *Check) UnmarshalYAML(node *yaml.Node) error {
 	}
 	return nil
 }
+
+// parsePolling parses and validates the eventually: and interval: fields
+func (c *Check) parsePolling() error {
+	c.timeout = 0
+	c.pollInterval = 0
+	if c.Eventually == "" {
+		if c.Interval != "" {
+			return fmt.Errorf("check %q sets interval without eventually", c.Msg)
+		}
+		return nil
+	}
+
+	var err error
+	c.timeout, err = time.ParseDuration(c.Eventually)
+	if err != nil || c.timeout <= 0 {
+		return fmt.Errorf("check %q has invalid eventually duration %q", c.Msg, c.Eventually)
+	}
+	c.pollInterval = defaultPollInterval
+	if c.Interval != "" {
+		c.pollInterval, err = time.ParseDuration(c.Interval)
+		if err != nil || c.pollInterval <= 0 {
+			return fmt.Errorf("check %q has invalid interval duration %q", c.Msg, c.Interval)
+		}
+	}
+	return nil
+}
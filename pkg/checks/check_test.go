@@ -21,8 +21,11 @@ package checks
 
 import (
 	"testing"
+	"time"
 
 	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 )
@@ -77,6 +80,46 @@ checksum:
 			fsysContents:      map[string][]byte{"incorrect-hash.txt": []byte("foo")},
 			expectVerifyError: true,
 		},
+		{
+			name: "Multiple Checksum Algorithms (Success)",
+			contentStr: `msg: File does not have expected content
+path_exists: sample.bin
+checksum:
+  md5: acbd18db4cc2f85cedef654fccc4a4d8
+  sha1: 0BEEC7B5EA3F0FDBC95D0DD47F3C5BC275DA8A33
+  sha512: f7fbba6e0636f890e56fbbf3283e524c6fa3204ae298382d624741d0dc6638326e282c41be5e4254d8820772c5518a2c5a8c0c7f7eda19594a7eb539453e1ed7`,
+			fsysContents: map[string][]byte{"sample.bin": []byte("foo")},
+		},
+		{
+			name: "MD5 Checksum (Failure)",
+			contentStr: `msg: File does not have expected content
+path_exists: sample.bin
+checksum:
+  md5: d41d8cd98f00b204e9800998ecf8427e`,
+			fsysContents:      map[string][]byte{"sample.bin": []byte("foo")},
+			expectVerifyError: true,
+		},
+		{
+			name: "Empty Checksum",
+			contentStr: `msg: File does not have expected content
+path_exists: sample.bin
+checksum: {}`,
+			fsysContents:      map[string][]byte{"sample.bin": []byte("foo")},
+			expectVerifyError: true,
+		},
+		{
+			name: "Path Not Exists (Yes)",
+			contentStr: `msg: Payload should have been quarantined
+path_not_exists: payload.bin`,
+			fsysContents: map[string][]byte{"other.txt": []byte("foo")},
+		},
+		{
+			name: "Path Not Exists (No)",
+			contentStr: `msg: Payload should have been quarantined
+path_not_exists: payload.bin`,
+			fsysContents:      map[string][]byte{"payload.bin": []byte("foo")},
+			expectVerifyError: true,
+		},
 		{
 			name: "Content Contains (Success)",
 			contentStr: `msg: File should contain expected string
@@ -244,3 +287,103 @@ output_contains: "something"`,
 	}
 
 }
+
+func TestCheckVerifyEventually(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		contentStr           string
+		createFileAfter      time.Duration
+		expectUnmarshalError bool
+		expectVerifyError    bool
+		expectMultipleTries  bool
+	}{
+		{
+			name: "Passes Once File Appears",
+			contentStr: `msg: File should be quarantined
+path_exists: quarantine/payload.bin
+eventually: 5s
+interval: 20ms`,
+			createFileAfter:     100 * time.Millisecond,
+			expectMultipleTries: true,
+		},
+		{
+			name: "Times Out",
+			contentStr: `msg: File should be quarantined
+path_exists: quarantine/payload.bin
+eventually: 150ms
+interval: 20ms`,
+			expectVerifyError:   true,
+			expectMultipleTries: true,
+		},
+		{
+			name: "Single Attempt Without Eventually",
+			contentStr: `msg: File should be quarantined
+path_exists: quarantine/payload.bin`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Interval Without Eventually",
+			contentStr: `msg: File should be quarantined
+path_exists: quarantine/payload.bin
+interval: 1s`,
+			expectUnmarshalError: true,
+		},
+		{
+			name: "Invalid Eventually",
+			contentStr: `msg: File should be quarantined
+path_exists: quarantine/payload.bin
+eventually: soon`,
+			expectUnmarshalError: true,
+		},
+		{
+			name: "Invalid Interval",
+			contentStr: `msg: File should be quarantined
+path_exists: quarantine/payload.bin
+eventually: 10s
+interval: -1s`,
+			expectUnmarshalError: true,
+		},
+		{
+			name: "Eventually On Nested Check",
+			contentStr: `msg: File should be quarantined
+any_of:
+  - path_exists: quarantine/payload.bin
+    eventually: 10s`,
+			expectUnmarshalError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+
+			var check Check
+			err := yaml.Unmarshal([]byte(tc.contentStr), &check)
+			if tc.expectUnmarshalError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if tc.createFileAfter > 0 {
+				timer := time.AfterFunc(tc.createFileAfter, func() {
+					_ = afero.WriteFile(fsys, "quarantine/payload.bin", []byte("foo"), 0644)
+				})
+				defer timer.Stop()
+			}
+
+			attempts, elapsed, err := check.VerifyEventually(VerificationContext{FileSystem: fsys})
+			if tc.expectMultipleTries {
+				assert.Greater(t, attempts, 1)
+			} else {
+				assert.Equal(t, 1, attempts)
+			}
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				assert.Less(t, elapsed, check.timeout+time.Second)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
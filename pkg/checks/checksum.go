@@ -20,31 +20,54 @@ THE SOFTWARE.
 package checks
 
 import (
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
+	"strings"
 )
 
 // Checksum is a struct that contains different types
 // of checksums against which a file can be verified.
-// Supports SHA256 checksums.
+// Supports MD5, SHA1, SHA256 and SHA512 checksums.
 type Checksum struct {
+	MD5    string `yaml:"md5,omitempty"`
+	SHA1   string `yaml:"sha1,omitempty"`
 	SHA256 string `yaml:"sha256,omitempty"`
+	SHA512 string `yaml:"sha512,omitempty"`
 }
 
-// Verify computes the checksum of the contents
-// and compares it to the expected value.
-// Supports SHA256 checksums.
+// Verify computes the checksum of the contents and compares
+// it to the expected value. If several checksums are
+// provided, all of them must match.
 func (c *Checksum) Verify(contents []byte) error {
-	if c.SHA256 == "" {
-		return fmt.Errorf("checksum is empty - must provide sha256")
+	if c.MD5 == "" && c.SHA1 == "" && c.SHA256 == "" && c.SHA512 == "" {
+		return fmt.Errorf("checksum is empty - must provide md5, sha1, sha256 or sha512")
 	}
 
-	// Verify SHA256
-	rawResult := sha256.Sum256(contents)
-	actualSHA256 := fmt.Sprintf("%x", rawResult)
-	if actualSHA256 != c.SHA256 {
-		return fmt.Errorf("sha256 checksum mismatch: expected %s, got %s",
-			c.SHA256, actualSHA256)
+	checksums := []struct {
+		name     string
+		expected string
+		newHash  func() hash.Hash
+	}{
+		{"md5", c.MD5, md5.New},    // #nosec G401 -- matches known samples
+		{"sha1", c.SHA1, sha1.New}, // #nosec G401 -- matches known samples
+		{"sha256", c.SHA256, sha256.New},
+		{"sha512", c.SHA512, sha512.New},
+	}
+	for _, checksum := range checksums {
+		if checksum.expected == "" {
+			continue
+		}
+		h := checksum.newHash()
+		h.Write(contents)
+		actual := fmt.Sprintf("%x", h.Sum(nil))
+		if actual != strings.ToLower(checksum.expected) {
+			return failf("%s checksum mismatch: expected %s, got %s",
+				checksum.name, checksum.expected, actual)
+		}
 	}
 
 	return nil
@@ -48,26 +48,29 @@ func (c *CommandCheck) Verify(ctx VerificationContext) error {
 	if c.Command == "" {
 		return fmt.Errorf("command cannot be empty")
 	}
+	command, err := expandCheckField(ctx, c.Command)
+	if err != nil {
+		return err
+	}
 
 	var outputStr string
 	var exitCode int
 
 	if ctx.RunCommand != nil {
 		// Use the backend-aware command runner
-		var err error
-		outputStr, exitCode, err = ctx.RunCommand(c.Command)
+		outputStr, exitCode, err = ctx.RunCommand(command)
 		if err != nil {
-			return fmt.Errorf("failed to execute command %q: %w", c.Command, err)
+			return fmt.Errorf("failed to execute command %q: %w", command, err)
 		}
 	} else {
 		// Execute the command using platform-appropriate shell
 		var cmd *exec.Cmd
 		if runtime.GOOS == "windows" {
 			// @lint-ignore G204
-			cmd = exec.Command("cmd.exe", "/c", c.Command)
+			cmd = exec.Command("cmd.exe", "/c", command)
 		} else {
 			// @lint-ignore G204
-			cmd = exec.Command("sh", "-c", c.Command)
+			cmd = exec.Command("sh", "-c", command)
 		}
 
 		output, err := cmd.CombinedOutput()
@@ -80,7 +83,7 @@ func (c *CommandCheck) Verify(ctx VerificationContext) error {
 				exitCode = exitErr.ExitCode()
 			} else {
 				// Command failed to execute at all
-				return fmt.Errorf("failed to execute command %q: %w", c.Command, err)
+				return fmt.Errorf("failed to execute command %q: %w", command, err)
 			}
 		}
 	}
@@ -91,23 +94,23 @@ func (c *CommandCheck) Verify(ctx VerificationContext) error {
 		expectedExitCode = *c.ExpectExitCode
 	}
 	if exitCode != expectedExitCode {
-		return fmt.Errorf("command %q exited with code %d, expected %d. Output: %s",
-			c.Command, exitCode, expectedExitCode, outputStr)
+		return failf("command %q exited with code %d, expected %d. Output: %s",
+			command, exitCode, expectedExitCode, outputStr)
 	}
 
 	// Check if output contains expected string
 	if c.OutputContains != "" {
 		if !strings.Contains(outputStr, c.OutputContains) {
-			return fmt.Errorf("command %q output does not contain %q. Output: %s",
-				c.Command, c.OutputContains, outputStr)
+			return failf("command %q output does not contain %q. Output: %s",
+				command, c.OutputContains, outputStr)
 		}
 	}
 
 	// Check if output does not contain specified string
 	if c.OutputNotContains != "" {
 		if strings.Contains(outputStr, c.OutputNotContains) {
-			return fmt.Errorf("command %q output contains %q but should not. Output: %s",
-				c.Command, c.OutputNotContains, outputStr)
+			return failf("command %q output contains %q but should not. Output: %s",
+				command, c.OutputNotContains, outputStr)
 		}
 	}
 
@@ -118,8 +121,8 @@ func (c *CommandCheck) Verify(ctx VerificationContext) error {
 			return fmt.Errorf("invalid regex pattern %q: %w", c.OutputRegex, err)
 		}
 		if !matched {
-			return fmt.Errorf("command %q output does not match regex %q. Output: %s",
-				c.Command, c.OutputRegex, outputStr)
+			return failf("command %q output does not match regex %q. Output: %s",
+				command, c.OutputRegex, outputStr)
 		}
 	}
 
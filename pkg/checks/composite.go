@@ -0,0 +1,257 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// compositeCondition is implemented by conditions
+// that are composed of other (nested) checks
+type compositeCondition interface {
+	Condition
+	keyword() string
+}
+
+// nestedCheck is a check inside a composite condition.
+// Unlike a top-level check, it does not require a msg,
+// and it may use its own remote: to run somewhere other
+// than the enclosing check.
+type nestedCheck struct {
+	Check
+}
+
+// UnmarshalYAML decodes the nested check without requiring a msg
+func (n *nestedCheck) UnmarshalYAML(node *yaml.Node) error {
+	if err := n.Check.decode(node); err != nil {
+		return err
+	}
+	if n.Eventually != "" {
+		return errors.New("eventually can only be set on top-level checks, not on checks nested in all_of, any_of or not")
+	}
+	return nil
+}
+
+// verifyNested verifies the nested check, switching to the
+// context for its remote: if it specifies one
+func (n *nestedCheck) verifyNested(ctx VerificationContext) error {
+	if n.Remote != "" {
+		if ctx.ForRemote == nil {
+			return fmt.Errorf("remote %q cannot be used for checks in this context", n.Remote)
+		}
+		var err error
+		ctx, err = ctx.ForRemote(n.Remote)
+		if err != nil {
+			return err
+		}
+	}
+	return n.Verify(ctx)
+}
+
+// label returns the text used to identify the nested
+// check in the evaluation tree of a failed composite check
+func (n *nestedCheck) label() string {
+	if n.Msg != "" {
+		return n.Msg
+	}
+	switch cond := n.condition.(type) {
+	case compositeCondition:
+		return cond.keyword()
+	case *PathExists:
+		return fmt.Sprintf("path_exists %q", cond.Path)
+	case *PathNotExists:
+		return fmt.Sprintf("path_not_exists %q", cond.Path)
+	case *CommandCheck:
+		return fmt.Sprintf("command %q", cond.Command)
+	case *OutputCheck:
+		return "step output"
+	case *OutputPath:
+		return fmt.Sprintf("output_path %q", cond.Path)
+	case *ProcessRunning:
+		return fmt.Sprintf("process_running %v", cond.Process)
+	case *ProcessNotRunning:
+		return fmt.Sprintf("process_not_running %v", cond.Process)
+	case *PortOpen:
+		return fmt.Sprintf("port_open %q", cond.Address)
+	case *PortClosed:
+		return fmt.Sprintf("port_closed %q", cond.Address)
+	case *HTTPEndpoint:
+		return fmt.Sprintf("http_endpoint %q", cond.URL)
+	case *LogContains:
+		return fmt.Sprintf("log_contains %q", cond.Path)
+	default:
+		return fmt.Sprintf("%T", cond)
+	}
+}
+
+// AllOf is a condition that passes only if
+// every one of its nested checks passes
+type AllOf struct {
+	Checks []nestedCheck `yaml:"all_of"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (a *AllOf) IsNil() bool {
+	return len(a.Checks) == 0
+}
+
+func (a *AllOf) keyword() string {
+	return "all_of"
+}
+
+// Verify evaluates every nested check and returns an error
+// describing all of their results if any of them failed
+func (a *AllOf) Verify(ctx VerificationContext) error {
+	lines, numFailed, numErrored := verifyNestedChecks(ctx, a.Checks)
+	switch {
+	case numFailed > 0:
+		return newTreeFailure(fmt.Sprintf("all_of: %d of %d checks failed", numFailed, len(a.Checks)), lines)
+	case numErrored > 0:
+		return newTreeError(fmt.Sprintf("all_of: %d of %d checks could not be evaluated", numErrored, len(a.Checks)), lines)
+	default:
+		return nil
+	}
+}
+
+// AnyOf is a condition that passes if at
+// least one of its nested checks passes
+type AnyOf struct {
+	Checks []nestedCheck `yaml:"any_of"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (a *AnyOf) IsNil() bool {
+	return len(a.Checks) == 0
+}
+
+func (a *AnyOf) keyword() string {
+	return "any_of"
+}
+
+// Verify evaluates the nested checks and returns an
+// error describing their results if none of them passed
+func (a *AnyOf) Verify(ctx VerificationContext) error {
+	lines, numFailed, numErrored := verifyNestedChecks(ctx, a.Checks)
+	switch {
+	case numFailed+numErrored < len(a.Checks):
+		return nil
+	case numErrored > 0:
+		return newTreeError(fmt.Sprintf("any_of: none of %d checks passed and %d could not be evaluated", len(a.Checks), numErrored), lines)
+	default:
+		return newTreeFailure(fmt.Sprintf("any_of: none of %d checks passed", len(a.Checks)), lines)
+	}
+}
+
+// Not is a condition that passes only
+// if its nested check fails
+type Not struct {
+	Check *nestedCheck `yaml:"not"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (n *Not) IsNil() bool {
+	return n.Check == nil
+}
+
+func (n *Not) keyword() string {
+	return "not"
+}
+
+// Verify evaluates the nested check and returns an error if it
+// passed or if it could not be evaluated, since only a check that
+// was evaluated and failed may be inverted into a pass
+func (n *Not) Verify(ctx VerificationContext) error {
+	lines, numFailed, numErrored := verifyNestedChecks(ctx, []nestedCheck{*n.Check})
+	switch {
+	case numErrored > 0:
+		return newTreeError("not: nested check could not be evaluated", lines)
+	case numFailed > 0:
+		return nil
+	default:
+		return newTreeFailure("not: nested check passed but should have failed", lines)
+	}
+}
+
+// verifyNestedChecks verifies each of the nested checks, returning
+// one line describing the result of each, the number that failed,
+// and the number that could not be evaluated
+func verifyNestedChecks(ctx VerificationContext, nested []nestedCheck) ([]string, int, int) {
+	var lines []string
+	numFailed, numErrored := 0, 0
+	for i := range nested {
+		child := &nested[i]
+		err := child.verifyNested(ctx)
+		if err == nil {
+			lines = append(lines, "[PASS] "+child.label())
+			continue
+		}
+
+		line := "[FAIL] "
+		if isCheckFailure(err) {
+			numFailed++
+		} else {
+			numErrored++
+			line = "[ERROR] "
+		}
+		// composite checks without a msg are already
+		// identified by the first line of their error
+		if _, ok := child.condition.(compositeCondition); !ok || child.Msg != "" {
+			line += child.label() + ": "
+		}
+		lines = append(lines, line+err.Error())
+	}
+	return lines, numFailed, numErrored
+}
+
+// newTreeError builds an error whose message shows the summary
+// of a composite check followed by the (indented) result of each
+// of its nested checks, so that nested composites form a tree
+func newTreeError(summary string, lines []string) error {
+	var sb strings.Builder
+	sb.WriteString(summary)
+	for _, line := range lines {
+		sb.WriteString("\n  ")
+		sb.WriteString(strings.ReplaceAll(line, "\n", "\n  "))
+	}
+	return errors.New(sb.String())
+}
+
+// newTreeFailure is like newTreeError, but for
+// a composite check that was evaluated and failed
+func newTreeFailure(summary string, lines []string) error {
+	return &checkFailure{err: newTreeError(summary, lines)}
+}
+
+// hasKey checks whether a YAML mapping node contains the given key
+func hasKey(node *yaml.Node, key string) bool {
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,270 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCompositeChecks(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		contentStr           string
+		fsysContents         map[string][]byte
+		stepOutput           string
+		expectUnmarshalError bool
+		expectVerifyError    bool
+		expectedErrorMsg     string
+	}{
+		{
+			name: "all_of (Pass)",
+			contentStr: `msg: Payload should be dropped and announced
+all_of:
+  - path_exists: payload.bin
+  - output_contains: dropped`,
+			fsysContents: map[string][]byte{"payload.bin": []byte("foo")},
+			stepOutput:   "payload dropped",
+		},
+		{
+			name: "all_of (Fail)",
+			contentStr: `msg: Payload should be dropped and announced
+all_of:
+  - path_exists: payload.bin
+  - msg: Step should report success
+    output_contains: dropped`,
+			fsysContents:      map[string][]byte{"payload.bin": []byte("foo")},
+			stepOutput:        "access denied",
+			expectVerifyError: true,
+			expectedErrorMsg: `all_of: 1 of 2 checks failed
+  [PASS] path_exists "payload.bin"
+  [FAIL] Step should report success: step output does not contain "dropped"`,
+		},
+		{
+			name: "any_of (Pass)",
+			contentStr: `msg: File should be quarantined or removed
+any_of:
+  - path_exists: quarantine/payload.bin
+  - not:
+      path_exists: payload.bin`,
+			fsysContents: map[string][]byte{"quarantine/payload.bin": []byte("foo")},
+		},
+		{
+			name: "any_of (Fail)",
+			contentStr: `msg: File should be quarantined or removed
+any_of:
+  - path_exists: quarantine/payload.bin
+  - not:
+      path_exists: payload.bin`,
+			fsysContents:      map[string][]byte{"payload.bin": []byte("foo")},
+			expectVerifyError: true,
+			expectedErrorMsg: `any_of: none of 2 checks passed
+  [FAIL] path_exists "quarantine/payload.bin": file "quarantine/payload.bin" does not exist
+  [FAIL] not: nested check passed but should have failed
+    [PASS] path_exists "payload.bin"`,
+		},
+		{
+			name: "not (Pass)",
+			contentStr: `msg: Step should not report errors
+not:
+  output_contains: error`,
+			stepOutput: "all good",
+		},
+		{
+			name: "Nested Composites Show Evaluation Tree",
+			contentStr: `msg: Either the file or the marker should be present
+any_of:
+  - all_of:
+      - path_exists: a.txt
+      - path_exists: b.txt
+  - msg: Marker should be printed
+    output_contains: marker`,
+			fsysContents:      map[string][]byte{"a.txt": []byte("foo")},
+			expectVerifyError: true,
+			expectedErrorMsg: `any_of: none of 2 checks passed
+  [FAIL] all_of: 1 of 2 checks failed
+    [PASS] path_exists "a.txt"
+    [FAIL] path_exists "b.txt": file "b.txt" does not exist
+  [FAIL] Marker should be printed: step output does not contain "marker"`,
+		},
+		{
+			name: "Invalid Nested Check",
+			contentStr: `msg: Has an invalid nested check
+all_of:
+  - path_exists: a.txt
+  - not_a_real_check: foo`,
+			expectUnmarshalError: true,
+		},
+		{
+			name: "Ambiguous Nested Check",
+			contentStr: `msg: Has an ambiguous nested check
+any_of:
+  - path_exists: a.txt
+    command: "true"`,
+			expectUnmarshalError: true,
+		},
+		{
+			name: "Ambiguous Composite Check",
+			contentStr: `msg: Both all_of and any_of
+all_of:
+  - path_exists: a.txt
+any_of:
+  - path_exists: b.txt`,
+			expectUnmarshalError: true,
+		},
+		{
+			name: "Nested Remote Without Remote Support",
+			contentStr: `msg: Uses a remote
+all_of:
+  - path_exists: a.txt
+    remote: target`,
+			fsysContents:      map[string][]byte{"a.txt": []byte("foo")},
+			expectVerifyError: true,
+		},
+		{
+			name: "not Does Not Invert Evaluation Errors",
+			contentStr: `msg: Step should not report errors
+not:
+  output_regex: '[invalid'`,
+			stepOutput:        "all good",
+			expectVerifyError: true,
+			expectedErrorMsg: `not: nested check could not be evaluated
+  [ERROR] step output: invalid regex pattern "[invalid": error parsing regexp: missing closing ]: ` + "`[invalid`",
+		},
+		{
+			name: "not Does Not Invert Nested Remote Errors",
+			contentStr: `msg: Payload should be gone from the target
+not:
+  path_exists: a.txt
+  remote: target`,
+			expectVerifyError: true,
+			expectedErrorMsg: `not: nested check could not be evaluated
+  [ERROR] path_exists "a.txt": remote "target" cannot be used for checks in this context`,
+		},
+		{
+			name: "any_of Passes Despite Evaluation Error",
+			contentStr: `msg: File should be present somewhere
+any_of:
+  - path_exists: a.txt
+    remote: target
+  - path_exists: a.txt`,
+			fsysContents: map[string][]byte{"a.txt": []byte("foo")},
+		},
+		{
+			name: "any_of Reports Evaluation Error",
+			contentStr: `msg: File should be present somewhere
+any_of:
+  - path_exists: a.txt
+    remote: target
+  - path_exists: b.txt`,
+			fsysContents:      map[string][]byte{"a.txt": []byte("foo")},
+			expectVerifyError: true,
+			expectedErrorMsg: `any_of: none of 2 checks passed and 1 could not be evaluated
+  [ERROR] path_exists "a.txt": remote "target" cannot be used for checks in this context
+  [FAIL] path_exists "b.txt": file "b.txt" does not exist`,
+		},
+		{
+			name: "all_of Failure With Evaluation Error",
+			contentStr: `msg: Both files should be present
+all_of:
+  - path_exists: a.txt
+    remote: target
+  - path_exists: b.txt`,
+			expectVerifyError: true,
+			expectedErrorMsg: `all_of: 1 of 2 checks failed
+  [ERROR] path_exists "a.txt": remote "target" cannot be used for checks in this context
+  [FAIL] path_exists "b.txt": file "b.txt" does not exist`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys, err := testutils.MakeAferoTestFs(tc.fsysContents)
+			require.NoError(t, err)
+
+			var check Check
+			err = yaml.Unmarshal([]byte(tc.contentStr), &check)
+			if tc.expectUnmarshalError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			err = check.Verify(VerificationContext{FileSystem: fsys, StepOutput: tc.stepOutput})
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				if tc.expectedErrorMsg != "" {
+					assert.Equal(t, tc.expectedErrorMsg, err.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCompositeCheckNestedRemotes(t *testing.T) {
+	localFs, err := testutils.MakeAferoTestFs(map[string][]byte{"local.txt": []byte("foo")})
+	require.NoError(t, err)
+	remoteFs, err := testutils.MakeAferoTestFs(map[string][]byte{"remote.txt": []byte("foo")})
+	require.NoError(t, err)
+
+	var requestedRemotes []string
+	ctx := VerificationContext{
+		FileSystem: localFs,
+		ForRemote: func(remote string) (VerificationContext, error) {
+			requestedRemotes = append(requestedRemotes, remote)
+			switch remote {
+			case "target":
+				return VerificationContext{FileSystem: remoteFs}, nil
+			case "local":
+				return VerificationContext{FileSystem: localFs}, nil
+			}
+			return VerificationContext{}, fmt.Errorf("no connection named %q", remote)
+		},
+	}
+
+	var check Check
+	err = yaml.Unmarshal([]byte(`msg: Files should exist on both hosts
+all_of:
+  - path_exists: local.txt
+  - path_exists: remote.txt
+    remote: target
+  - not:
+      path_exists: remote.txt
+      remote: local`), &check)
+	require.NoError(t, err)
+	require.NoError(t, check.Verify(ctx))
+	assert.Equal(t, []string{"target", "local"}, requestedRemotes)
+
+	err = yaml.Unmarshal([]byte(`msg: Unknown remote
+any_of:
+  - path_exists: remote.txt
+    remote: missing`), &check)
+	require.NoError(t, err)
+	err = check.Verify(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no connection named "missing"`)
+}
@@ -19,9 +19,42 @@ THE SOFTWARE.
 
 package checks
 
+import (
+	"errors"
+	"fmt"
+)
+
 // Condition is the common interface
 // implemented by all condition types
 type Condition interface {
 	Verify(ctx VerificationContext) error
 	IsNil() bool
 }
+
+// checkFailure is returned by a condition that was evaluated
+// and found not to hold. Any other error means that the condition
+// could not be evaluated, which composite conditions such as not
+// must report rather than treat as a failed check.
+type checkFailure struct {
+	err error
+}
+
+func (f *checkFailure) Error() string {
+	return f.err.Error()
+}
+
+func (f *checkFailure) Unwrap() error {
+	return f.err
+}
+
+// failf formats a checkFailure in the same way as fmt.Errorf
+func failf(format string, args ...any) error {
+	return &checkFailure{err: fmt.Errorf(format, args...)}
+}
+
+// isCheckFailure reports whether err means that a condition did
+// not hold, as opposed to that it could not be evaluated
+func isCheckFailure(err error) bool {
+	var failure *checkFailure
+	return errors.As(err, &failure)
+}
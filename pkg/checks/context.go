@@ -20,6 +20,9 @@ THE SOFTWARE.
 package checks
 
 import (
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/backends"
 	"github.com/facebookincubator/ttpforge/pkg/platforms"
 
 	"github.com/spf13/afero"
@@ -31,6 +34,13 @@ import (
 type VerificationContext struct {
 	Platform   platforms.Spec
 	FileSystem afero.Fs
+	// Backend optionally provides the execution backend (local or
+	// remote) against which process checks are run. When nil, process
+	// checks inspect the local machine.
+	Backend backends.ExecutionBackend
+	// ExpandVariables optionally expands $forge.steps references
+	// (such as a PID extracted as a step output) in check fields.
+	ExpandVariables func(input string) (string, error)
 	// RunCommand optionally provides a backend-aware command runner.
 	// When set, command checks use this instead of local exec.
 	// Returns output, exit code, and error.
@@ -38,4 +48,16 @@ type VerificationContext struct {
 	// StepOutput holds the combined stdout+stderr from the step that just ran.
 	// Empty when no step output is available.
 	StepOutput string
+	// Outputs holds the named outputs extracted from the step that
+	// just ran. For TTP-level assertions, it holds the outputs of every
+	// step, keyed by "step_name.output_name".
+	Outputs map[string]string
+	// StepStartTime is when the step being verified started
+	// executing. Log checks only consider entries logged since
+	// then. It is zero when the start time is not known.
+	StepStartTime time.Time
+	// ForRemote optionally builds the context for a named remote
+	// connection ("local" targets the runner). It is used by checks
+	// nested in all_of/any_of/not that specify their own remote:.
+	ForRemote func(remote string) (VerificationContext, error)
 }
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultHTTPCheckTimeout = 10 * time.Second
+
+// maxHTTPCheckBodySize limits how much of a response
+// body is read when checking it against body_regex
+const maxHTTPCheckBodySize = 10 * 1024 * 1024
+
+// HTTPEndpoint is a condition that verifies that an HTTP endpoint
+// is reachable and responds as expected. Requests are made from
+// the host on which the check runs, so remote checks can reach
+// endpoints that only listen on the remote host's loopback interface.
+type HTTPEndpoint struct {
+	URL                string            `yaml:"http_endpoint"`
+	Method             string            `yaml:"method,omitempty"`
+	ExpectStatus       int               `yaml:"expect_status,omitempty"`
+	BodyRegex          string            `yaml:"body_regex,omitempty"`
+	HeaderRegex        map[string]string `yaml:"header_regex,omitempty"`
+	Timeout            string            `yaml:"timeout,omitempty"`
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *HTTPEndpoint) IsNil() bool {
+	return c.URL == ""
+}
+
+// Verify sends the request and validates the response
+func (c *HTTPEndpoint) Verify(ctx VerificationContext) error {
+	timeout, err := parseCheckTimeout(c.Timeout, defaultHTTPCheckTimeout)
+	if err != nil {
+		return err
+	}
+	url, err := expandCheckField(ctx, c.URL)
+	if err != nil {
+		return err
+	}
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	expectedStatus := c.ExpectStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+
+	// compile all patterns before sending the request
+	// so that invalid checks fail without side effects
+	var bodyRe *regexp.Regexp
+	if c.BodyRegex != "" {
+		bodyRe, err = regexp.Compile(c.BodyRegex)
+		if err != nil {
+			return fmt.Errorf("invalid regex pattern %q: %w", c.BodyRegex, err)
+		}
+	}
+	headerRes := make(map[string]*regexp.Regexp)
+	for header, pattern := range c.HeaderRegex {
+		headerRes[header], err = regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid regex pattern %q for header %q: %w", pattern, header, err)
+		}
+	}
+
+	dialer, err := getDialer(ctx)
+	if err != nil {
+		return err
+	}
+	// each verification (including every eventually: attempt) uses
+	// its own transport, so close its connections once it is done
+	tr := &http.Transport{DialContext: dialer.DialContext}
+	defer tr.CloseIdleConnections()
+	if c.InsecureSkipVerify {
+		// #nosec G402
+		tr.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
+		}
+	}
+	client := &http.Client{Timeout: timeout, Transport: tr}
+
+	reqCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, method, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid request for endpoint %q: %w", url, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return failf("endpoint %q is not reachable: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return failf("endpoint %q returned status %d, expected %d",
+			url, resp.StatusCode, expectedStatus)
+	}
+
+	headers := make([]string, 0, len(headerRes))
+	for header := range headerRes {
+		headers = append(headers, header)
+	}
+	slices.Sort(headers)
+	for _, header := range headers {
+		values := resp.Header.Values(header)
+		if !slices.ContainsFunc(values, headerRes[header].MatchString) {
+			return failf("endpoint %q header %q (%q) does not match regex %q",
+				url, header, strings.Join(values, ", "), c.HeaderRegex[header])
+		}
+	}
+
+	if bodyRe != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPCheckBodySize))
+		if err != nil {
+			return fmt.Errorf("failed to read response from endpoint %q: %w", url, err)
+		}
+		if !bodyRe.Match(body) {
+			return failf("endpoint %q response body does not match regex %q",
+				url, c.BodyRegex)
+		}
+	}
+	return nil
+}
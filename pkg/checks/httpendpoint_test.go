@@ -0,0 +1,134 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestHTTPEndpointCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Header().Set("Server", "beacon/1.2")
+			fmt.Fprint(w, `{"status": "ok", "agents": 3}`)
+		case "/admin":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name              string
+		contentStr        string
+		expectVerifyError bool
+	}{
+		{
+			name: "Endpoint Reachable",
+			contentStr: `msg: C2 should be up
+http_endpoint: ` + server.URL + `/health`,
+		},
+		{
+			name: "Body And Header Match",
+			contentStr: `msg: C2 should report healthy
+http_endpoint: ` + server.URL + `/health
+body_regex: '"status":\s*"ok"'
+header_regex:
+  Server: '^beacon/\d+\.\d+$'`,
+		},
+		{
+			name: "Body Does Not Match",
+			contentStr: `msg: C2 should report healthy
+http_endpoint: ` + server.URL + `/health
+body_regex: '"status":\s*"degraded"'`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Header Does Not Match",
+			contentStr: `msg: C2 should report its version
+http_endpoint: ` + server.URL + `/health
+header_regex:
+  Server: nginx`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Missing Header",
+			contentStr: `msg: C2 should set a cookie
+http_endpoint: ` + server.URL + `/health
+header_regex:
+  Set-Cookie: session`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Expected Status With Method",
+			contentStr: `msg: admin endpoint should be blocked
+http_endpoint: ` + server.URL + `/admin
+method: POST
+expect_status: 403`,
+		},
+		{
+			name: "Unexpected Status",
+			contentStr: `msg: page should exist
+http_endpoint: ` + server.URL + `/missing`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Unreachable Endpoint",
+			contentStr: `msg: endpoint should be up
+http_endpoint: http://127.0.0.1:` + closedPort(t, "tcp") + `/
+timeout: 1s`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Body Regex",
+			contentStr: `msg: bad regex
+http_endpoint: ` + server.URL + `/health
+body_regex: '[invalid'`,
+			expectVerifyError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			err := yaml.Unmarshal([]byte(tc.contentStr), &check)
+			require.NoError(t, err)
+
+			err = check.Verify(VerificationContext{})
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
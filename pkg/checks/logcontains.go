@@ -0,0 +1,477 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// maxLogLineSize is the longest log line (or JSON record)
+// that the log_contains check is able to read
+const maxLogLineSize = 16 * 1024 * 1024
+
+// defaultTimeFields are the JSON fields searched
+// for a timestamp when time_field is not set
+var defaultTimeFields = []string{"@timestamp", "timestamp", "time", "ts"}
+
+// LogContains is a condition that verifies that a log file contains
+// an entry matching a pattern, such as an auditd, syslog or EDR event
+// recording the activity of the step. The path may be a single file
+// or a directory of (possibly gzipped) rotated logs. By default only
+// entries logged since the step started are considered.
+//
+// Timestamps without a time zone are interpreted in the zone given
+// by timezone, which defaults to the time zone of the host whose log
+// is read: the runner for local checks, or the remote host otherwise.
+type LogContains struct {
+	Path      string            `yaml:"log_contains"`
+	Pattern   string            `yaml:"pattern,omitempty"`
+	FileGlob  string            `yaml:"file_glob,omitempty"`
+	Format    string            `yaml:"format,omitempty"`
+	Fields    map[string]string `yaml:"fields,omitempty"`
+	TimeField string            `yaml:"time_field,omitempty"`
+	Since     string            `yaml:"since,omitempty"`
+	Timezone  string            `yaml:"timezone,omitempty"`
+}
+
+// logMatcher holds the compiled criteria of a log_contains check
+type logMatcher struct {
+	json      bool
+	pattern   *regexp.Regexp
+	fields    map[string]*regexp.Regexp
+	timeField string
+	since     time.Time
+	// location is the time zone of timestamps without one
+	location *time.Location
+	// untimed counts matching entries that were
+	// ignored because their time could not be determined
+	untimed int
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *LogContains) IsNil() bool {
+	return c.Path == ""
+}
+
+// Verify searches the log files for a matching entry
+func (c *LogContains) Verify(ctx VerificationContext) error {
+	logPath, err := expandCheckField(ctx, c.Path)
+	if err != nil {
+		return err
+	}
+	m, err := c.newMatcher(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, err := c.listLogFiles(ctx.FileSystem, logPath, m.since)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		found, err := m.searchFile(ctx.FileSystem, file)
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("no entry in %q matches %v", logPath, c.describe())
+	if !m.since.IsZero() {
+		msg += fmt.Sprintf(" since %v", m.since.Format(time.RFC3339))
+	}
+	if m.untimed > 0 {
+		msg += fmt.Sprintf(" (ignored %d matching entries without a recognizable timestamp - set since: any to include them)", m.untimed)
+	}
+	return &checkFailure{err: errors.New(msg)}
+}
+
+// describe summarizes the match criteria for error messages
+func (c *LogContains) describe() string {
+	var criteria []string
+	if c.Pattern != "" {
+		criteria = append(criteria, fmt.Sprintf("pattern %q", c.Pattern))
+	}
+	fields := make([]string, 0, len(c.Fields))
+	for field := range c.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	for _, field := range fields {
+		criteria = append(criteria, fmt.Sprintf("field %q ~ %q", field, c.Fields[field]))
+	}
+	return strings.Join(criteria, " and ")
+}
+
+// newMatcher validates the check and compiles its criteria
+func (c *LogContains) newMatcher(ctx VerificationContext) (*logMatcher, error) {
+	m := &logMatcher{timeField: c.TimeField}
+	switch c.Format {
+	case "", "text":
+		if len(c.Fields) > 0 || c.TimeField != "" {
+			return nil, errors.New("fields and time_field require format: jsonl")
+		}
+	case "jsonl":
+		m.json = true
+	default:
+		return nil, fmt.Errorf("invalid log format %q - must be text or jsonl", c.Format)
+	}
+	if c.Pattern == "" && len(c.Fields) == 0 {
+		return nil, fmt.Errorf("log_contains check for %q must set pattern or fields", c.Path)
+	}
+
+	var err error
+	if c.Pattern != "" {
+		m.pattern, err = regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern %q: %w", c.Pattern, err)
+		}
+	}
+	m.fields = make(map[string]*regexp.Regexp)
+	for field, pattern := range c.Fields {
+		m.fields[field], err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern %q for field %q: %w", pattern, field, err)
+		}
+	}
+	if c.FileGlob != "" {
+		if _, err := path.Match(c.FileGlob, ""); err != nil {
+			return nil, fmt.Errorf("invalid file_glob %q: %w", c.FileGlob, err)
+		}
+	}
+
+	switch c.Since {
+	case "":
+		// log timestamps usually have a resolution of one second
+		m.since = ctx.StepStartTime.Truncate(time.Second)
+	case "any":
+	default:
+		m.since, err = parseTimeBound(c.Since, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+
+	// the time zone only matters if entries are filtered by time
+	if !m.since.IsZero() || c.Timezone != "" {
+		m.location, err = c.location(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// location returns the time zone in which timestamps without
+// one are interpreted - the configured timezone if set, and
+// otherwise the time zone of the host whose log is searched
+func (c *LogContains) location(ctx VerificationContext) (*time.Location, error) {
+	switch c.Timezone {
+	case "local":
+		return time.Local, nil
+	case "":
+		if ctx.Backend == nil {
+			return time.Local, nil
+		}
+		return remoteLocation(ctx)
+	default:
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+		}
+		return loc, nil
+	}
+}
+
+// remoteUTCOffsetRe matches the output of date +%z, such as -0500
+var remoteUTCOffsetRe = regexp.MustCompile(`^([+-])(\d{2})(\d{2})$`)
+
+// remoteLocation returns the current UTC offset of the remote host
+func remoteLocation(ctx VerificationContext) (*time.Location, error) {
+	if ctx.RunCommand == nil {
+		return nil, errors.New("cannot determine the time zone of the remote host - set timezone")
+	}
+	output, exitCode, err := ctx.RunCommand("date +%z")
+	offset := strings.TrimSpace(output)
+	match := remoteUTCOffsetRe.FindStringSubmatch(offset)
+	if err != nil || exitCode != 0 || match == nil {
+		return nil, fmt.Errorf("failed to determine the time zone of the remote host (date +%%z printed %q) - set timezone", offset)
+	}
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	seconds := (hours*60 + minutes) * 60
+	if match[1] == "-" {
+		seconds = -seconds
+	}
+	return time.FixedZone("UTC"+offset, seconds), nil
+}
+
+// listLogFiles returns the log files to search - either logPath
+// itself or the files in that directory matching file_glob. Files
+// that were last modified before since cannot contain new entries
+// and are skipped.
+func (c *LogContains) listLogFiles(fsys afero.Fs, logPath string, since time.Time) ([]string, error) {
+	info, err := fsys.Stat(logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access log %q: %w", logPath, err)
+	}
+	if !info.IsDir() {
+		return []string{logPath}, nil
+	}
+
+	entries, err := afero.ReadDir(fsys, logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log directory %q: %w", logPath, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || entry.ModTime().Before(since) {
+			continue
+		}
+		if c.FileGlob != "" {
+			if matched, _ := path.Match(c.FileGlob, entry.Name()); !matched {
+				continue
+			}
+		}
+		files = append(files, path.Join(logPath, entry.Name()))
+	}
+	return files, nil
+}
+
+// searchFile reports whether the given log file
+// (which may be gzipped) contains a matching entry
+func (m *logMatcher) searchFile(fsys afero.Fs, file string) (bool, error) {
+	f, err := fsys.Open(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to open log %q: %w", file, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return false, fmt.Errorf("failed to decompress log %q: %w", file, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		if m.matchLine(scanner.Text()) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read log %q: %w", file, err)
+	}
+	return false, nil
+}
+
+// matchLine reports whether a single log entry matches
+// the criteria and falls within the time window
+func (m *logMatcher) matchLine(line string) bool {
+	if m.pattern != nil && !m.pattern.MatchString(line) {
+		return false
+	}
+
+	var ts time.Time
+	var haveTime bool
+	if m.json {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			// skip blank lines and any non-JSON noise
+			return false
+		}
+		for field, re := range m.fields {
+			value, ok := lookupJSONField(record, field)
+			if !ok || !re.MatchString(stringifyJSONValue(value)) {
+				return false
+			}
+		}
+		if !m.since.IsZero() {
+			ts, haveTime = m.recordTime(record)
+		}
+	} else if !m.since.IsZero() {
+		ts, haveTime = parseLineTime(line, time.Now(), m.location)
+	}
+
+	if m.since.IsZero() {
+		return true
+	}
+	if !haveTime {
+		m.untimed++
+		return false
+	}
+	return !ts.Before(m.since)
+}
+
+// recordTime extracts the timestamp of a JSON log record
+func (m *logMatcher) recordTime(record map[string]any) (time.Time, bool) {
+	timeFields := defaultTimeFields
+	if m.timeField != "" {
+		timeFields = []string{m.timeField}
+	}
+	for _, field := range timeFields {
+		value, ok := lookupJSONField(record, field)
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case float64:
+			return epochTime(v), true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return epochTime(f), true
+			}
+			if ts, ok := parseLineTime(v, time.Now(), m.location); ok {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// lookupJSONField returns the value at a dotted field path such as
+// "process.name". Keys that themselves contain dots (as used by
+// some flattened log schemas) are also supported.
+func lookupJSONField(value any, field string) (any, bool) {
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	if v, ok := obj[field]; ok {
+		return v, true
+	}
+	for i := 0; i < len(field); i++ {
+		if field[i] != '.' {
+			continue
+		}
+		if v, ok := obj[field[:i]]; ok {
+			if found, ok := lookupJSONField(v, field[i+1:]); ok {
+				return found, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// stringifyJSONValue converts a JSON value to the
+// string against which field patterns are matched
+func stringifyJSONValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// epochTime converts a Unix timestamp in seconds,
+// milliseconds, microseconds or nanoseconds to a time
+func epochTime(v float64) time.Time {
+	switch {
+	case v > 1e17:
+		return time.Unix(0, int64(v))
+	case v > 1e14:
+		return time.UnixMicro(int64(v))
+	case v > 1e11:
+		return time.UnixMilli(int64(v))
+	default:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9))
+	}
+}
+
+var (
+	// auditd: type=EXECVE msg=audit(1700000000.123:456): ...
+	auditTimeRe = regexp.MustCompile(`audit\((\d+)(?:\.(\d+))?:\d+\)`)
+	// RFC3339/ISO 8601: 2024-01-02T15:04:05.000Z ...
+	isoTimeRe = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)`)
+	// BSD syslog: Jan  2 15:04:05 host ...
+	syslogTimeRe = regexp.MustCompile(`^(?:<\d+>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})`)
+)
+
+// isoTimeLayouts are the layouts tried for timestamps matching isoTimeRe
+var isoTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// parseLineTime extracts the timestamp from a log line in one
+// of the common auditd, ISO 8601 or BSD syslog formats. Times
+// without a zone are interpreted in the given location.
+func parseLineTime(line string, now time.Time, loc *time.Location) (time.Time, bool) {
+	if match := auditTimeRe.FindStringSubmatch(line); match != nil {
+		sec, err := strconv.ParseInt(match[1], 10, 64)
+		if err == nil {
+			var nsec int64
+			if match[2] != "" {
+				frac := (match[2] + "000000000")[:9]
+				nsec, _ = strconv.ParseInt(frac, 10, 64)
+			}
+			return time.Unix(sec, nsec), true
+		}
+	}
+	if match := isoTimeRe.FindStringSubmatch(line); match != nil {
+		value := strings.Replace(match[1], ",", ".", 1)
+		for _, layout := range isoTimeLayouts {
+			if ts, err := time.ParseInLocation(layout, value, loc); err == nil {
+				return ts, true
+			}
+		}
+	}
+	if match := syslogTimeRe.FindStringSubmatch(line); match != nil {
+		ts, err := time.ParseInLocation(time.Stamp, match[1], loc)
+		if err == nil {
+			// syslog timestamps have no year - assume the current
+			// one unless that puts the entry in the future
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
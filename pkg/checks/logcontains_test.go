@@ -0,0 +1,365 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLogContains(t *testing.T) {
+	now := time.Now()
+	stepStart := now.Add(-time.Minute)
+	recent := now.Format(time.RFC3339)
+	old := now.Add(-2 * time.Hour).Format(time.RFC3339)
+	oldFileTime := now.Add(-24 * time.Hour)
+
+	testCases := []struct {
+		name              string
+		files             map[string]string
+		oldFiles          []string
+		contentStr        string
+		noStepStart       bool
+		expectVerifyError bool
+		errorContains     string
+	}{
+		{
+			name: "Recent Text Entry",
+			files: map[string]string{
+				"/var/log/app.log": old + " started\n" + recent + " executed /usr/bin/curl\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: 'executed .*curl'`,
+		},
+		{
+			name: "Only Entry Predates Step",
+			files: map[string]string{
+				"/var/log/app.log": old + " executed /usr/bin/curl\n" + recent + " idle\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: curl`,
+			expectVerifyError: true,
+			errorContains:     "since",
+		},
+		{
+			name: "No Step Start Time Searches Everything",
+			files: map[string]string{
+				"/var/log/app.log": old + " executed /usr/bin/curl\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: curl`,
+			noStepStart: true,
+		},
+		{
+			name: "Auditd Entry",
+			files: map[string]string{
+				"/var/log/audit/audit.log": fmt.Sprintf(
+					"type=EXECVE msg=audit(%d.123:42): argc=2 a0=\"curl\" a1=\"evil.com\"\n", now.Unix()),
+			},
+			contentStr: `log_contains: /var/log/audit/audit.log
+pattern: 'a0="curl"'`,
+		},
+		{
+			name: "Syslog Entry",
+			files: map[string]string{
+				"/var/log/syslog": now.Format(time.Stamp) + " host sudo: user : COMMAND=/bin/id\n",
+			},
+			contentStr: `log_contains: /var/log/syslog
+pattern: COMMAND=/bin/id`,
+		},
+		{
+			name: "Entry Without Timestamp Ignored",
+			files: map[string]string{
+				"/var/log/app.log": "executed /usr/bin/curl\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: curl`,
+			expectVerifyError: true,
+			errorContains:     "since: any",
+		},
+		{
+			name: "Since Any",
+			files: map[string]string{
+				"/var/log/app.log": "executed /usr/bin/curl\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: curl
+since: any`,
+		},
+		{
+			name: "Since Duration",
+			files: map[string]string{
+				"/var/log/app.log": now.Add(-30*time.Minute).Format(time.RFC3339) + " executed /usr/bin/curl\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: curl
+since: 1h`,
+		},
+		{
+			name: "Rotated Gzipped Logs",
+			files: map[string]string{
+				"/var/log/app/app.log":      recent + " idle\n",
+				"/var/log/app/app.log.1.gz": recent + " executed /usr/bin/curl\n",
+				"/var/log/app/other.log":    recent + " unrelated\n",
+			},
+			contentStr: `log_contains: /var/log/app
+file_glob: 'app.log*'
+pattern: curl`,
+		},
+		{
+			name: "File Glob Excludes Match",
+			files: map[string]string{
+				"/var/log/app/app.log":   recent + " idle\n",
+				"/var/log/app/other.log": recent + " executed /usr/bin/curl\n",
+			},
+			contentStr: `log_contains: /var/log/app
+file_glob: 'app.log*'
+pattern: curl`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Stale Rotated Log Skipped",
+			files: map[string]string{
+				"/var/log/app/app.log":   recent + " idle\n",
+				"/var/log/app/app.log.1": recent + " executed /usr/bin/curl\n",
+			},
+			oldFiles: []string{"/var/log/app/app.log.1"},
+			contentStr: `log_contains: /var/log/app
+pattern: curl`,
+			expectVerifyError: true,
+		},
+		{
+			name: "JSON Lines Nested Field",
+			files: map[string]string{
+				"/var/log/edr.json": `{"@timestamp": "` + old + `", "process": {"name": "curl"}}
+not json
+{"@timestamp": "` + recent + `", "event": {"action": "exec"}, "process": {"name": "curl", "pid": 4242}}
+`,
+			},
+			contentStr: `log_contains: /var/log/edr.json
+format: jsonl
+fields:
+  event.action: ^exec$
+  process.name: ^curl$
+  process.pid: "4242"`,
+		},
+		{
+			name: "JSON Lines Flattened Field And Epoch Time",
+			files: map[string]string{
+				"/var/log/edr.json": fmt.Sprintf(`{"event_time": %d, "process.name": "curl"}`+"\n", now.UnixMilli()),
+			},
+			contentStr: `log_contains: /var/log/edr.json
+format: jsonl
+time_field: event_time
+fields:
+  process.name: curl`,
+		},
+		{
+			name: "JSON Lines Field Mismatch",
+			files: map[string]string{
+				"/var/log/edr.json": `{"@timestamp": "` + recent + `", "process": {"name": "wget"}}` + "\n",
+			},
+			contentStr: `log_contains: /var/log/edr.json
+format: jsonl
+fields:
+  process.name: ^curl$`,
+			expectVerifyError: true,
+			errorContains:     `field "process.name"`,
+		},
+		{
+			name: "Missing Pattern And Fields",
+			files: map[string]string{
+				"/var/log/app.log": recent + " idle\n",
+			},
+			contentStr:        `log_contains: /var/log/app.log`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Fields Require JSON Format",
+			files: map[string]string{
+				"/var/log/app.log": recent + " idle\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+fields:
+  process.name: curl`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Format",
+			files: map[string]string{
+				"/var/log/app.log": recent + " idle\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+format: xml
+pattern: idle`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Pattern",
+			files: map[string]string{
+				"/var/log/app.log": recent + " idle\n",
+			},
+			contentStr: `log_contains: /var/log/app.log
+pattern: '('`,
+			expectVerifyError: true,
+		},
+		{
+			name:  "Missing Log",
+			files: map[string]string{},
+			contentStr: `log_contains: /var/log/app.log
+pattern: idle`,
+			expectVerifyError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+			for name, contents := range tc.files {
+				data := []byte(contents)
+				if strings.HasSuffix(name, ".gz") {
+					var buf bytes.Buffer
+					gz := gzip.NewWriter(&buf)
+					_, err := gz.Write(data)
+					require.NoError(t, err)
+					require.NoError(t, gz.Close())
+					data = buf.Bytes()
+				}
+				require.NoError(t, afero.WriteFile(fsys, name, data, 0644))
+			}
+			for _, name := range tc.oldFiles {
+				require.NoError(t, fsys.Chtimes(name, oldFileTime, oldFileTime))
+			}
+
+			var check Check
+			err := yaml.Unmarshal([]byte("msg: log check\n"+tc.contentStr), &check)
+			require.NoError(t, err)
+
+			ctx := VerificationContext{FileSystem: fsys}
+			if !tc.noStepStart {
+				ctx.StepStartTime = stepStart
+			}
+			err = check.Verify(ctx)
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				if tc.errorContains != "" {
+					assert.Contains(t, err.Error(), tc.errorContains)
+				}
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLogContainsTimezone(t *testing.T) {
+	// the entry is logged now, in wall clock time nine hours behind UTC
+	// (Etc/GMT+9 is UTC-9, as the signs of the Etc zones are inverted)
+	entry := time.Now().In(time.FixedZone("UTC-9", -9*60*60)).Format("2006-01-02 15:04:05") + " executed /usr/bin/curl\n"
+
+	testCases := []struct {
+		name              string
+		timezone          string
+		remoteOffset      string
+		remoteExitCode    int
+		remote            bool
+		expectVerifyError bool
+		expectCheckFail   bool
+	}{
+		{
+			name:     "Configured Timezone",
+			timezone: "Etc/GMT+9",
+		},
+		{
+			name:              "Wrong Timezone",
+			timezone:          "UTC",
+			expectVerifyError: true,
+			expectCheckFail:   true,
+		},
+		{
+			name:              "Invalid Timezone",
+			timezone:          "Mars/Olympus_Mons",
+			expectVerifyError: true,
+		},
+		{
+			name:         "Remote Host Timezone",
+			remote:       true,
+			remoteOffset: "-0900\n",
+		},
+		{
+			name:         "Configured Timezone Overrides Remote Host",
+			timezone:     "UTC",
+			remote:       true,
+			remoteOffset: "-0900\n",
+			// the entry appears to be nine hours old
+			expectVerifyError: true,
+			expectCheckFail:   true,
+		},
+		{
+			name:              "Unknown Remote Host Timezone",
+			remote:            true,
+			remoteOffset:      "The system cannot accept the date entered.\n",
+			remoteExitCode:    1,
+			expectVerifyError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := afero.NewMemMapFs()
+			require.NoError(t, afero.WriteFile(fsys, "/var/log/app.log", []byte(entry), 0644))
+
+			content := "msg: log check\nlog_contains: /var/log/app.log\npattern: 'executed .*curl'\n"
+			if tc.timezone != "" {
+				content += fmt.Sprintf("timezone: %v\n", tc.timezone)
+			}
+			var check Check
+			require.NoError(t, yaml.Unmarshal([]byte(content), &check))
+
+			ctx := VerificationContext{
+				FileSystem:    fsys,
+				StepStartTime: time.Now().Add(-time.Minute),
+			}
+			if tc.remote {
+				ctx.Backend = &fakeProcessBackend{}
+				ctx.RunCommand = func(command string) (string, int, error) {
+					assert.Equal(t, "date +%z", command)
+					return tc.remoteOffset, tc.remoteExitCode, nil
+				}
+			}
+			err := check.Verify(ctx)
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				assert.Equal(t, tc.expectCheckFail, isCheckFailure(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
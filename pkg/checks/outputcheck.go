@@ -52,14 +52,14 @@ func (o *OutputCheck) Verify(ctx VerificationContext) error {
 
 	if o.OutputContains != "" {
 		if !strings.Contains(output, o.OutputContains) {
-			return fmt.Errorf("step output does not contain %q",
+			return failf("step output does not contain %q",
 				o.OutputContains)
 		}
 	}
 
 	if o.OutputNotContains != "" {
 		if strings.Contains(output, o.OutputNotContains) {
-			return fmt.Errorf("step output contains %q but should not",
+			return failf("step output contains %q but should not",
 				o.OutputNotContains)
 		}
 	}
@@ -70,7 +70,7 @@ func (o *OutputCheck) Verify(ctx VerificationContext) error {
 			return fmt.Errorf("invalid regex pattern %q: %w", o.OutputRegex, err)
 		}
 		if !matched {
-			return fmt.Errorf("step output does not match regex %q",
+			return failf("step output does not match regex %q",
 				o.OutputRegex)
 		}
 	}
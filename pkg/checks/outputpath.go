@@ -0,0 +1,220 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/facebookincubator/ttpforge/pkg/outputs"
+	"github.com/tidwall/gjson"
+)
+
+// OutputPath is a condition that parses the step output (or one of
+// its named outputs) as JSON or YAML and checks the value found at
+// a path. Paths use the same gjson syntax as the json_path output
+// filter. Every operator that is set must hold.
+type OutputPath struct {
+	Path        string   `yaml:"output_path"`
+	Output      string   `yaml:"output,omitempty"`
+	Format      string   `yaml:"format,omitempty"`
+	Exists      *bool    `yaml:"exists,omitempty"`
+	Equals      any      `yaml:"equals,omitempty"`
+	NotEquals   any      `yaml:"not_equals,omitempty"`
+	GreaterThan *float64 `yaml:"greater_than,omitempty"`
+	LessThan    *float64 `yaml:"less_than,omitempty"`
+	In          []any    `yaml:"in,omitempty"`
+	Length      *int     `yaml:"length,omitempty"`
+	Matches     string   `yaml:"matches,omitempty"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *OutputPath) IsNil() bool {
+	return c.Path == ""
+}
+
+// Verify parses the output and checks the value at the path
+func (c *OutputPath) Verify(ctx VerificationContext) error {
+	doc, err := c.document(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := gjson.GetBytes(doc, c.Path)
+	expectExists := c.Exists == nil || *c.Exists
+	if !result.Exists() {
+		if expectExists {
+			return failf("output path %q not found", c.Path)
+		}
+		return nil
+	}
+	if !expectExists {
+		return failf("output path %q exists (value %v) but should not", c.Path, result.Raw)
+	}
+
+	if c.Equals != nil && !valueEquals(result, c.Equals) {
+		return failf("output path %q is %v, expected %v", c.Path, result.Raw, formatExpected(c.Equals))
+	}
+	if c.NotEquals != nil && valueEquals(result, c.NotEquals) {
+		return failf("output path %q is %v but should not be", c.Path, result.Raw)
+	}
+	if c.GreaterThan != nil || c.LessThan != nil {
+		n, ok := numericValue(result)
+		if !ok {
+			return failf("output path %q is %v, which is not a number", c.Path, result.Raw)
+		}
+		if c.GreaterThan != nil && !(n > *c.GreaterThan) {
+			return failf("output path %q is %v, expected greater than %v", c.Path, result.Raw, *c.GreaterThan)
+		}
+		if c.LessThan != nil && !(n < *c.LessThan) {
+			return failf("output path %q is %v, expected less than %v", c.Path, result.Raw, *c.LessThan)
+		}
+	}
+	if len(c.In) > 0 {
+		found := false
+		for _, candidate := range c.In {
+			if valueEquals(result, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return failf("output path %q is %v, expected one of %v", c.Path, result.Raw, formatExpected(c.In))
+		}
+	}
+	if c.Length != nil {
+		length, ok := valueLength(result)
+		if !ok {
+			return failf("output path %q is %v, which has no length", c.Path, result.Raw)
+		}
+		if length != *c.Length {
+			return failf("output path %q has length %d, expected %d", c.Path, length, *c.Length)
+		}
+	}
+	if c.Matches != "" {
+		re, err := regexp.Compile(c.Matches)
+		if err != nil {
+			return fmt.Errorf("invalid regex pattern %q: %w", c.Matches, err)
+		}
+		if !re.MatchString(result.String()) {
+			return failf("output path %q is %v, which does not match regex %q", c.Path, result.Raw, c.Matches)
+		}
+	}
+	return nil
+}
+
+// document returns the output to be checked, converted to JSON
+func (c *OutputPath) document(ctx VerificationContext) ([]byte, error) {
+	output := ctx.StepOutput
+	source := "step output"
+	if c.Output != "" {
+		var ok bool
+		output, ok = ctx.Outputs[c.Output]
+		if !ok {
+			return nil, fmt.Errorf("output %q not found", c.Output)
+		}
+		source = fmt.Sprintf("output %q", c.Output)
+	}
+
+	switch c.Format {
+	case "", "json":
+		if !gjson.Valid(output) {
+			return nil, fmt.Errorf("%v is not valid JSON", source)
+		}
+		return []byte(output), nil
+	case "yaml":
+		doc, err := outputs.YAMLToJSON(output)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", source, err)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("invalid output format %q - must be json or yaml", c.Format)
+	}
+}
+
+// valueEquals compares a JSON value with an expected value from
+// the check. Scalars are also equal if their string forms match,
+// so that (for example) equals: "5" matches the number 5.
+func valueEquals(result gjson.Result, expected any) bool {
+	expectedJSON, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	expectedResult := gjson.ParseBytes(expectedJSON)
+	if canonicalJSON(result) == canonicalJSON(expectedResult) {
+		return true
+	}
+	if s, ok := expected.(string); ok && !result.IsObject() && !result.IsArray() {
+		return result.String() == s
+	}
+	return false
+}
+
+// canonicalJSON re-encodes a JSON value so that values that differ
+// only in formatting (such as key order or 1 vs 1.0) compare equal
+func canonicalJSON(result gjson.Result) string {
+	b, err := json.Marshal(result.Value())
+	if err != nil {
+		return result.Raw
+	}
+	return string(b)
+}
+
+// numericValue returns the value as a number, accepting
+// numeric strings since many tools quote numbers
+func numericValue(result gjson.Result) (float64, bool) {
+	switch result.Type {
+	case gjson.Number:
+		return result.Float(), true
+	case gjson.String:
+		n, err := strconv.ParseFloat(result.Str, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// valueLength returns the number of elements in a list,
+// keys in an object or characters in a string
+func valueLength(result gjson.Result) (int, bool) {
+	switch {
+	case result.IsArray():
+		return len(result.Array()), true
+	case result.IsObject():
+		return len(result.Map()), true
+	case result.Type == gjson.String:
+		return utf8.RuneCountInString(result.Str), true
+	default:
+		return 0, false
+	}
+}
+
+// formatExpected formats an expected value from the check for error messages
+func formatExpected(expected any) string {
+	b, err := json.Marshal(expected)
+	if err != nil {
+		return fmt.Sprint(expected)
+	}
+	return string(b)
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOutputPath(t *testing.T) {
+	jsonOutput := `{
+  "user": {"name": "svc_backup", "uid": 1005, "admin": true},
+  "groups": ["users", "wheel"],
+  "sessions": "3",
+  "tags": {"env": "prod", "team": "red"}
+}`
+	yamlOutput := `user:
+  name: svc_backup
+  uid: 1005
+groups:
+  - users
+  - wheel
+`
+
+	testCases := []struct {
+		name              string
+		contentStr        string
+		stepOutput        string
+		outputs           map[string]string
+		expectDecodeError bool
+		expectVerifyError bool
+	}{
+		{
+			name:       "Path Exists",
+			contentStr: `output_path: user.name`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name:              "Path Missing",
+			contentStr:        `output_path: user.shell`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Path Should Not Exist",
+			contentStr: `output_path: user.shell
+exists: false`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Path Exists But Should Not",
+			contentStr: `output_path: user.name
+exists: false`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Equals String",
+			contentStr: `output_path: user.name
+equals: svc_backup`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Equals Number",
+			contentStr: `output_path: user.uid
+equals: 1005`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Equals Quoted Number",
+			contentStr: `output_path: user.uid
+equals: "1005"`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Equals Bool",
+			contentStr: `output_path: user.admin
+equals: true`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Equals List",
+			contentStr: `output_path: groups
+equals: [users, wheel]`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Equals Object Regardless Of Key Order",
+			contentStr: `output_path: tags
+equals:
+  team: red
+  env: prod`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Equals Mismatch",
+			contentStr: `output_path: user.name
+equals: root`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Not Equals",
+			contentStr: `output_path: user.name
+not_equals: root`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Not Equals Mismatch",
+			contentStr: `output_path: user.uid
+not_equals: 1005`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Numeric Range",
+			contentStr: `output_path: user.uid
+greater_than: 1000
+less_than: 65534`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Numeric String Greater Than",
+			contentStr: `output_path: sessions
+greater_than: 2`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Not Greater Than",
+			contentStr: `output_path: user.uid
+greater_than: 1005`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Greater Than Non Number",
+			contentStr: `output_path: user.name
+greater_than: 1`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "In List",
+			contentStr: `output_path: groups.1
+in: [wheel, sudo, admin]`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Not In List",
+			contentStr: `output_path: groups.0
+in: [wheel, sudo, admin]`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Length Of List",
+			contentStr: `output_path: groups
+length: 2`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Length Of Object",
+			contentStr: `output_path: user
+length: 3`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Length Of String",
+			contentStr: `output_path: user.name
+length: 10`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Wrong Length",
+			contentStr: `output_path: groups
+length: 3`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Length Of Number",
+			contentStr: `output_path: user.uid
+length: 4`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Count With Gjson Modifier",
+			contentStr: `output_path: groups.#
+equals: 2`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Matches",
+			contentStr: `output_path: user.name
+matches: ^svc_`,
+			stepOutput: jsonOutput,
+		},
+		{
+			name: "Does Not Match",
+			contentStr: `output_path: user.name
+matches: ^adm_`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Regex",
+			contentStr: `output_path: user.name
+matches: '('`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "YAML Output",
+			contentStr: `output_path: groups.#(=="wheel")
+format: yaml
+exists: true`,
+			stepOutput: yamlOutput,
+		},
+		{
+			name: "Named Output",
+			contentStr: `output_path: uid
+output: user_info
+equals: 1005`,
+			stepOutput: "not json",
+			outputs:    map[string]string{"user_info": `{"uid": 1005}`},
+		},
+		{
+			name: "Missing Named Output",
+			contentStr: `output_path: uid
+output: user_info`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name:              "Invalid JSON",
+			contentStr:        `output_path: user.name`,
+			stepOutput:        "user: svc_backup",
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Format",
+			contentStr: `output_path: user.name
+format: toml`,
+			stepOutput:        jsonOutput,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Length Type",
+			contentStr: `output_path: groups
+length: two`,
+			expectDecodeError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			err := yaml.Unmarshal([]byte("msg: output path check\n"+tc.contentStr), &check)
+			if tc.expectDecodeError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			err = check.Verify(VerificationContext{
+				StepOutput: tc.stepOutput,
+				Outputs:    tc.outputs,
+			})
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
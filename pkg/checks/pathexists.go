@@ -33,6 +33,7 @@ import (
 // - File contents against a checksum
 // - File contains/doesn't contain specific text
 // - File permissions match expected values
+// - File metadata (see FileMetadata)
 type PathExists struct {
 	Path               string    `yaml:"path_exists"`
 	Checksum           *Checksum `yaml:"checksum,omitempty"`
@@ -40,6 +41,7 @@ type PathExists struct {
 	ContentNotContains string    `yaml:"content_not_contains,omitempty"`
 	ContentRegex       string    `yaml:"content_regex,omitempty"`
 	Permissions        string    `yaml:"permissions,omitempty"`
+	FileMetadata       `yaml:",inline"`
 }
 
 // IsNil checks if the condition is empty or uninitialized
@@ -49,15 +51,24 @@ func (c *PathExists) IsNil() bool {
 
 // Verify checks the condition and returns an error if it fails
 func (c *PathExists) Verify(ctx VerificationContext) error {
+	path, err := expandCheckField(ctx, c.Path)
+	if err != nil {
+		return err
+	}
 	fsys := ctx.FileSystem
 
 	// basic existence check
-	exists, err := afero.Exists(fsys, c.Path)
+	info, err := c.FileMetadata.stat(fsys, path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return failf("file %q does not exist", path)
+		}
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("file %q does not exist", c.Path)
+
+	// check the type, size, ownership and other metadata
+	if err := c.FileMetadata.verify(fsys, path, info); err != nil {
+		return err
 	}
 
 	// read file content once if needed for multiple checks
@@ -66,9 +77,9 @@ func (c *PathExists) Verify(ctx VerificationContext) error {
 		c.ContentNotContains != "" || c.ContentRegex != ""
 
 	if needsContent {
-		contentBytes, err := afero.ReadFile(fsys, c.Path)
+		contentBytes, err := afero.ReadFile(fsys, path)
 		if err != nil {
-			return fmt.Errorf("failed to read file %q: %w", c.Path, err)
+			return fmt.Errorf("failed to read file %q: %w", path, err)
 		}
 		contentStr = string(contentBytes)
 
@@ -82,16 +93,16 @@ func (c *PathExists) Verify(ctx VerificationContext) error {
 		// check if content contains expected string
 		if c.ContentContains != "" {
 			if !strings.Contains(contentStr, c.ContentContains) {
-				return fmt.Errorf("file %q does not contain %q",
-					c.Path, c.ContentContains)
+				return failf("file %q does not contain %q",
+					path, c.ContentContains)
 			}
 		}
 
 		// check if content does not contain specified string
 		if c.ContentNotContains != "" {
 			if strings.Contains(contentStr, c.ContentNotContains) {
-				return fmt.Errorf("file %q contains %q but should not",
-					c.Path, c.ContentNotContains)
+				return failf("file %q contains %q but should not",
+					path, c.ContentNotContains)
 			}
 		}
 
@@ -103,17 +114,17 @@ func (c *PathExists) Verify(ctx VerificationContext) error {
 					c.ContentRegex, err)
 			}
 			if !matched {
-				return fmt.Errorf("file %q content does not match regex %q",
-					c.Path, c.ContentRegex)
+				return failf("file %q content does not match regex %q",
+					path, c.ContentRegex)
 			}
 		}
 	}
 
 	// check file permissions if specified
 	if c.Permissions != "" {
-		info, err := fsys.Stat(c.Path)
+		info, err := fsys.Stat(path)
 		if err != nil {
-			return fmt.Errorf("failed to stat file %q: %w", c.Path, err)
+			return fmt.Errorf("failed to stat file %q: %w", path, err)
 		}
 
 		actualPerm := info.Mode().Perm()
@@ -127,8 +138,8 @@ func (c *PathExists) Verify(ctx VerificationContext) error {
 		}
 
 		if actualPerm != expectedPerm {
-			return fmt.Errorf("file %q has permissions %04o, expected %04o",
-				c.Path, actualPerm, expectedPerm)
+			return failf("file %q has permissions %04o, expected %04o",
+				path, actualPerm, expectedPerm)
 		}
 	}
 
@@ -0,0 +1,229 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/fileutils"
+	"github.com/spf13/afero"
+)
+
+// Supported values for the file_type field of a path_exists check
+const (
+	FileTypeRegular = "regular"
+	FileTypeDir     = "dir"
+	FileTypeSymlink = "symlink"
+)
+
+// FileMetadata contains the conditions on a file's metadata
+// that can be verified by a path_exists check. All of them are
+// read through afero.Fs, so they also work on remote hosts.
+type FileMetadata struct {
+	FileType       string `yaml:"file_type,omitempty"`
+	SymlinkTarget  string `yaml:"symlink_target,omitempty"`
+	MinSize        *int64 `yaml:"min_size,omitempty"`
+	MaxSize        *int64 `yaml:"max_size,omitempty"`
+	Owner          string `yaml:"owner,omitempty"`
+	Group          string `yaml:"group,omitempty"`
+	ModifiedAfter  string `yaml:"modified_after,omitempty"`
+	ModifiedBefore string `yaml:"modified_before,omitempty"`
+	MinEntries     *int   `yaml:"min_entries,omitempty"`
+	MaxEntries     *int   `yaml:"max_entries,omitempty"`
+}
+
+// checksLink returns true if the metadata conditions apply to a
+// symbolic link itself, rather than to the file that it points to
+func (m *FileMetadata) checksLink() bool {
+	return m.FileType == FileTypeSymlink || m.SymlinkTarget != ""
+}
+
+// stat returns the file info for the path, without
+// following symbolic links if checksLink is true
+func (m *FileMetadata) stat(fsys afero.Fs, path string) (os.FileInfo, error) {
+	if !m.checksLink() {
+		return fsys.Stat(path)
+	}
+	lstater, ok := fsys.(afero.Lstater)
+	if !ok {
+		return nil, fmt.Errorf("filesystem %v does not support symbolic links", fsys.Name())
+	}
+	info, lstatCalled, err := lstater.LstatIfPossible(path)
+	if err == nil && !lstatCalled {
+		return nil, fmt.Errorf("filesystem %v does not support symbolic links", fsys.Name())
+	}
+	return info, err
+}
+
+// verify checks the metadata conditions against the file at path
+func (m *FileMetadata) verify(fsys afero.Fs, path string, info os.FileInfo) error {
+	if err := m.verifyType(fsys, path, info); err != nil {
+		return err
+	}
+
+	if m.MinSize != nil && info.Size() < *m.MinSize {
+		return failf("file %q has size %d bytes, expected at least %d",
+			path, info.Size(), *m.MinSize)
+	}
+	if m.MaxSize != nil && info.Size() > *m.MaxSize {
+		return failf("file %q has size %d bytes, expected at most %d",
+			path, info.Size(), *m.MaxSize)
+	}
+
+	if err := m.verifyOwnership(fsys, path, info); err != nil {
+		return err
+	}
+
+	if err := m.verifyModTime(path, info, time.Now()); err != nil {
+		return err
+	}
+
+	if m.MinEntries != nil || m.MaxEntries != nil {
+		if !info.IsDir() {
+			return failf("cannot count entries of %q: not a directory", path)
+		}
+		entries, err := afero.ReadDir(fsys, path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory %q: %w", path, err)
+		}
+		if m.MinEntries != nil && len(entries) < *m.MinEntries {
+			return failf("directory %q has %d entries, expected at least %d",
+				path, len(entries), *m.MinEntries)
+		}
+		if m.MaxEntries != nil && len(entries) > *m.MaxEntries {
+			return failf("directory %q has %d entries, expected at most %d",
+				path, len(entries), *m.MaxEntries)
+		}
+	}
+	return nil
+}
+
+func (m *FileMetadata) verifyType(fsys afero.Fs, path string, info os.FileInfo) error {
+	isSymlink := info.Mode()&os.ModeSymlink != 0
+	switch m.FileType {
+	case "":
+	case FileTypeRegular:
+		if !info.Mode().IsRegular() {
+			return failf("%q is not a regular file", path)
+		}
+	case FileTypeDir:
+		if !info.IsDir() {
+			return failf("%q is not a directory", path)
+		}
+	case FileTypeSymlink:
+		if !isSymlink {
+			return failf("%q is not a symbolic link", path)
+		}
+	default:
+		return fmt.Errorf("invalid file_type %q - must be one of %v, %v, or %v",
+			m.FileType, FileTypeRegular, FileTypeDir, FileTypeSymlink)
+	}
+
+	if m.SymlinkTarget != "" {
+		if !isSymlink {
+			return failf("%q is not a symbolic link", path)
+		}
+		linkReader, ok := fsys.(afero.LinkReader)
+		if !ok {
+			return fmt.Errorf("filesystem %v does not support reading symbolic links", fsys.Name())
+		}
+		target, err := linkReader.ReadlinkIfPossible(path)
+		if err != nil {
+			return fmt.Errorf("failed to read symbolic link %q: %w", path, err)
+		}
+		if target != m.SymlinkTarget {
+			return failf("symbolic link %q points to %q, expected %q",
+				path, target, m.SymlinkTarget)
+		}
+	}
+	return nil
+}
+
+func (m *FileMetadata) verifyOwnership(fsys afero.Fs, path string, info os.FileInfo) error {
+	if m.Owner == "" && m.Group == "" {
+		return nil
+	}
+	uid, gid, ok := fileutils.OwnerIDs(info)
+	if !ok {
+		return fmt.Errorf("ownership of %q is not available on this platform", path)
+	}
+
+	if m.Owner != "" {
+		expectedUID, err := fileutils.LookupID(fsys, m.Owner, false)
+		if err != nil {
+			return err
+		}
+		if uid != expectedUID {
+			return failf("file %q is owned by user %d, expected %v (%d)",
+				path, uid, m.Owner, expectedUID)
+		}
+	}
+	if m.Group != "" {
+		expectedGID, err := fileutils.LookupID(fsys, m.Group, true)
+		if err != nil {
+			return err
+		}
+		if gid != expectedGID {
+			return failf("file %q is owned by group %d, expected %v (%d)",
+				path, gid, m.Group, expectedGID)
+		}
+	}
+	return nil
+}
+
+func (m *FileMetadata) verifyModTime(path string, info os.FileInfo, now time.Time) error {
+	mtime := info.ModTime()
+	if m.ModifiedAfter != "" {
+		bound, err := parseTimeBound(m.ModifiedAfter, now)
+		if err != nil {
+			return fmt.Errorf("invalid modified_after: %w", err)
+		}
+		if !mtime.After(bound) {
+			return failf("file %q was last modified at %v, expected after %v",
+				path, mtime.Format(time.RFC3339), bound.Format(time.RFC3339))
+		}
+	}
+	if m.ModifiedBefore != "" {
+		bound, err := parseTimeBound(m.ModifiedBefore, now)
+		if err != nil {
+			return fmt.Errorf("invalid modified_before: %w", err)
+		}
+		if !mtime.Before(bound) {
+			return failf("file %q was last modified at %v, expected before %v",
+				path, mtime.Format(time.RFC3339), bound.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// parseTimeBound parses either an RFC3339 timestamp or a
+// duration, which is interpreted as that long before now
+func parseTimeBound(bound string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, bound); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(bound)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC3339 timestamp nor a duration", bound)
+	}
+	return now.Add(-d), nil
+}
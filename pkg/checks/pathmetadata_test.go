@@ -0,0 +1,249 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/testutils"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPathMetadataChecks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links and file ownership are not tested on windows")
+	}
+
+	// build the test fixture used across all cases
+	tempDir := t.TempDir()
+	payloadPath := filepath.Join(tempDir, "payload.bin")
+	require.NoError(t, os.WriteFile(payloadPath, []byte("0123456789"), 0644))
+	stagingDir := filepath.Join(tempDir, "staging")
+	require.NoError(t, os.Mkdir(stagingDir, 0755))
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, os.WriteFile(filepath.Join(stagingDir, name), nil, 0644))
+	}
+	linkPath := filepath.Join(tempDir, "link")
+	require.NoError(t, os.Symlink(payloadPath, linkPath))
+	danglingLinkPath := filepath.Join(tempDir, "dangling")
+	require.NoError(t, os.Symlink(filepath.Join(tempDir, "missing"), danglingLinkPath))
+	oldPath := filepath.Join(tempDir, "old.txt")
+	require.NoError(t, os.WriteFile(oldPath, nil, 0644))
+	oldTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(oldPath, oldTime, oldTime))
+
+	currentUser, err := user.Current()
+	require.NoError(t, err)
+	currentGroup, err := user.LookupGroupId(currentUser.Gid)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name              string
+		contentStr        string
+		expectVerifyError bool
+	}{
+		{
+			name: "Regular File Within Size Bounds",
+			contentStr: `path_exists: ` + payloadPath + `
+file_type: regular
+min_size: 10
+max_size: 10`,
+		},
+		{
+			name: "File Too Small",
+			contentStr: `path_exists: ` + payloadPath + `
+min_size: 11`,
+			expectVerifyError: true,
+		},
+		{
+			name: "File Too Large",
+			contentStr: `path_exists: ` + payloadPath + `
+max_size: 0`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Directory Type",
+			contentStr: `path_exists: ` + stagingDir + `
+file_type: dir`,
+		},
+		{
+			name: "Wrong File Type",
+			contentStr: `path_exists: ` + payloadPath + `
+file_type: dir`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid File Type",
+			contentStr: `path_exists: ` + payloadPath + `
+file_type: socket`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Symlink With Target",
+			contentStr: `path_exists: ` + linkPath + `
+file_type: symlink
+symlink_target: ` + payloadPath,
+		},
+		{
+			name: "Dangling Symlink",
+			contentStr: `path_exists: ` + danglingLinkPath + `
+file_type: symlink`,
+		},
+		{
+			name: "Symlink With Wrong Target",
+			contentStr: `path_exists: ` + linkPath + `
+symlink_target: /etc/passwd`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Symlink Target Of Regular File",
+			contentStr: `path_exists: ` + payloadPath + `
+symlink_target: /etc/passwd`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Following Symlink",
+			contentStr: `path_exists: ` + linkPath + `
+file_type: regular`,
+		},
+		{
+			name: "Directory Entry Count",
+			contentStr: `path_exists: ` + stagingDir + `
+min_entries: 3
+max_entries: 3`,
+		},
+		{
+			name: "Too Many Directory Entries",
+			contentStr: `path_exists: ` + stagingDir + `
+max_entries: 2`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Entry Count Of Regular File",
+			contentStr: `path_exists: ` + payloadPath + `
+min_entries: 1`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Owner And Group By ID",
+			contentStr: `path_exists: ` + payloadPath + `
+owner: "` + currentUser.Uid + `"
+group: "` + currentUser.Gid + `"`,
+		},
+		{
+			name: "Owner And Group By Name",
+			contentStr: `path_exists: ` + payloadPath + `
+owner: ` + currentUser.Username + `
+group: ` + currentGroup.Name,
+		},
+		{
+			name: "Wrong Owner",
+			contentStr: `path_exists: ` + payloadPath + `
+owner: "` + wrongID(t, currentUser.Uid) + `"`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Unknown Owner",
+			contentStr: `path_exists: ` + payloadPath + `
+owner: no-such-user-ttpforge`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Modified Recently",
+			contentStr: `path_exists: ` + payloadPath + `
+modified_after: 1h`,
+		},
+		{
+			name: "Modified Within Window",
+			contentStr: `path_exists: ` + oldPath + `
+modified_after: "2019-12-31T00:00:00Z"
+modified_before: "2020-01-02T00:00:00Z"`,
+		},
+		{
+			name: "Not Modified Recently",
+			contentStr: `path_exists: ` + oldPath + `
+modified_after: 24h`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Modified Too Recently",
+			contentStr: `path_exists: ` + payloadPath + `
+modified_before: 1h`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Time Bound",
+			contentStr: `path_exists: ` + payloadPath + `
+modified_after: yesterday`,
+			expectVerifyError: true,
+		},
+		{
+			name:       "Path Not Exists With Dangling Symlink",
+			contentStr: `path_not_exists: ` + danglingLinkPath,
+			// the link itself still exists
+			expectVerifyError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			err := yaml.Unmarshal([]byte("msg: metadata check\n"+tc.contentStr), &check)
+			require.NoError(t, err)
+
+			err = check.Verify(VerificationContext{FileSystem: afero.NewOsFs()})
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// wrongID returns a numeric ID that differs from the given one
+func wrongID(t *testing.T, id string) string {
+	n, err := strconv.Atoi(id)
+	require.NoError(t, err)
+	return strconv.Itoa(n + 1)
+}
+
+func TestSymlinkChecksRequireSymlinkSupport(t *testing.T) {
+	fsys, err := testutils.MakeAferoTestFs(map[string][]byte{"link": []byte("foo")})
+	require.NoError(t, err)
+
+	var check Check
+	err = yaml.Unmarshal([]byte(`msg: symlink check
+path_exists: link
+file_type: symlink`), &check)
+	require.NoError(t, err)
+	err = check.Verify(VerificationContext{FileSystem: fsys})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not support symbolic links")
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/afero"
+)
+
+// PathNotExists is a condition that verifies that nothing exists at a
+// given path - for example, that a file was quarantined or cleaned up.
+// Symbolic links are not followed, so a dangling link counts as existing.
+type PathNotExists struct {
+	Path string `yaml:"path_not_exists"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *PathNotExists) IsNil() bool {
+	return c.Path == ""
+}
+
+// Verify checks the condition and returns an error if it fails
+func (c *PathNotExists) Verify(ctx VerificationContext) error {
+	path, err := expandCheckField(ctx, c.Path)
+	if err != nil {
+		return err
+	}
+	if lstater, ok := ctx.FileSystem.(afero.Lstater); ok {
+		_, _, err = lstater.LstatIfPossible(path)
+	} else {
+		_, err = ctx.FileSystem.Stat(path)
+	}
+	if err == nil {
+		return failf("%q exists but should not", path)
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check whether %q exists: %w", path, err)
+	}
+	return nil
+}
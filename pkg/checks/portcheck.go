@@ -0,0 +1,226 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultNetworkCheckTimeout = 3 * time.Second
+
+// PortSpec contains the fields shared by port_open and port_closed
+type PortSpec struct {
+	Protocol string `yaml:"protocol,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty"`
+}
+
+// PortOpen is a condition that verifies that a TCP or UDP
+// port accepts connections - for example, that a reverse
+// shell listener is up
+type PortOpen struct {
+	Address  string `yaml:"port_open"`
+	PortSpec `yaml:",inline"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *PortOpen) IsNil() bool {
+	return c.Address == ""
+}
+
+// Verify checks the condition and returns an error if it fails
+func (c *PortOpen) Verify(ctx VerificationContext) error {
+	addr, open, err := c.PortSpec.probe(ctx, c.Address)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return failf("%s port %s is not open", c.protocol(), addr)
+	}
+	return nil
+}
+
+// PortClosed is a condition that verifies that a TCP or UDP
+// port does not accept connections - for example, that
+// traffic to a port is really blocked
+type PortClosed struct {
+	Address  string `yaml:"port_closed"`
+	PortSpec `yaml:",inline"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *PortClosed) IsNil() bool {
+	return c.Address == ""
+}
+
+// Verify checks the condition and returns an error if it fails
+func (c *PortClosed) Verify(ctx VerificationContext) error {
+	addr, open, err := c.PortSpec.probe(ctx, c.Address)
+	if err != nil {
+		return err
+	}
+	if open {
+		return failf("%s port %s is open but should be closed", c.protocol(), addr)
+	}
+	return nil
+}
+
+func (p *PortSpec) protocol() string {
+	if p.Protocol == "" {
+		return "tcp"
+	}
+	return strings.ToLower(p.Protocol)
+}
+
+// probe determines whether the port at the given address is open.
+// The address may be a bare port number (which targets the loopback
+// interface) or host:port, and may reference step outputs.
+func (p *PortSpec) probe(ctx VerificationContext, address string) (string, bool, error) {
+	protocol := p.protocol()
+	if protocol != "tcp" && protocol != "udp" {
+		return "", false, fmt.Errorf("invalid protocol %q - must be tcp or udp", p.Protocol)
+	}
+	timeout, err := parseCheckTimeout(p.Timeout, defaultNetworkCheckTimeout)
+	if err != nil {
+		return "", false, err
+	}
+
+	addr, err := expandCheckField(ctx, address)
+	if err != nil {
+		return "", false, err
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort("127.0.0.1", addr)
+	}
+
+	dialer, err := getDialer(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	dialCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(dialCtx, protocol, addr)
+	if err != nil {
+		// refused, unreachable and timed out connections all
+		// mean the port is closed, but any other error means
+		// that the port could not be probed at all
+		if protocol == "tcp" && isClosedPortError(err) {
+			return addr, false, nil
+		}
+		return "", false, fmt.Errorf("failed to probe %s port %s: %w", protocol, addr, err)
+	}
+	defer conn.Close()
+
+	if protocol == "tcp" {
+		return addr, true, nil
+	}
+
+	// UDP is connectionless, so the only reliable signal is a closed
+	// port's ICMP "port unreachable" reply (seen as a refused read).
+	// A port that does not respond at all is treated as open.
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", false, err
+	}
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return addr, false, nil //nolint:nilerr // a failed write means nothing is listening
+	}
+	buf := make([]byte, 1)
+	_, err = conn.Read(buf)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return addr, false, nil
+	}
+	return addr, true, nil
+}
+
+// isClosedPortError reports whether a dial error
+// means that the port is closed or unreachable
+func isClosedPortError(err error) bool {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return true
+	}
+	// SSH servers report refused, unreachable and timed
+	// out forwarded connections alike as connect failures
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) && openErr.Reason == ssh.ConnectionFailed {
+		return true
+	}
+	for _, closedErr := range closedPortErrnos {
+		if errors.Is(err, closedErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRemote returns an error if the port check cannot run against
+// the given remote, as SSH connections can only forward TCP traffic
+func (p *PortSpec) ValidateRemote(remote string) error {
+	if remote != "" && p.protocol() == "udp" {
+		return fmt.Errorf("udp port checks cannot run on remote %q - SSH only supports tcp", remote)
+	}
+	return nil
+}
+
+// getDialer returns the dialer for the backend against which
+// checks run, which is the local machine if there is none
+func getDialer(ctx VerificationContext) (backends.Dialer, error) {
+	if ctx.Backend == nil {
+		return backends.NewLocalBackend(), nil
+	}
+	dialer, ok := ctx.Backend.(backends.Dialer)
+	if !ok {
+		return nil, errors.New("network checks are not supported by this execution backend")
+	}
+	return dialer, nil
+}
+
+// parseCheckTimeout parses a timeout: field, returning
+// the provided default value if it is not set
+func parseCheckTimeout(timeout string, defaultTimeout time.Duration) (time.Duration, error) {
+	if timeout == "" {
+		return defaultTimeout, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", timeout, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got %q", timeout)
+	}
+	return d, nil
+}
+
+// expandCheckField expands any step output
+// references in the value of a check field
+func expandCheckField(ctx VerificationContext, value string) (string, error) {
+	if ctx.ExpandVariables == nil {
+		return value, nil
+	}
+	return ctx.ExpandVariables(value)
+}
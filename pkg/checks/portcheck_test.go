@@ -0,0 +1,305 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// closedPort returns a port on the loopback interface
+// that was free a moment ago and so is very likely closed
+func closedPort(t *testing.T, network string) string {
+	var addr net.Addr
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr = conn.LocalAddr()
+		require.NoError(t, conn.Close())
+	} else {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr = listener.Addr()
+		require.NoError(t, listener.Close())
+	}
+	_, port, err := net.SplitHostPort(addr.String())
+	require.NoError(t, err)
+	return port
+}
+
+func TestPortChecks(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcpListener.Close()
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udpConn.Close()
+
+	openTCPAddr := tcpListener.Addr().String()
+	_, openTCPPort, err := net.SplitHostPort(openTCPAddr)
+	require.NoError(t, err)
+	openUDPAddr := udpConn.LocalAddr().String()
+	closedTCPPort := closedPort(t, "tcp")
+	closedUDPPort := closedPort(t, "udp")
+
+	expandVariables := func(input string) (string, error) {
+		return strings.ReplaceAll(input, "$forge.steps.listen.outputs.port", openTCPPort), nil
+	}
+
+	testCases := []struct {
+		name              string
+		contentStr        string
+		backend           bool
+		expectVerifyError bool
+	}{
+		{
+			name: "TCP Port Open (Yes)",
+			contentStr: `msg: listener should be up
+port_open: ` + openTCPAddr,
+		},
+		{
+			name: "TCP Port Open By Port Number (Yes)",
+			contentStr: `msg: listener should be up
+port_open: ` + openTCPPort,
+		},
+		{
+			name: "TCP Port Open From Step Output (Yes)",
+			contentStr: `msg: listener should be up
+port_open: $forge.steps.listen.outputs.port`,
+		},
+		{
+			name: "TCP Port Open (No)",
+			contentStr: `msg: listener should be up
+port_open: ` + closedTCPPort + `
+timeout: 500ms`,
+			expectVerifyError: true,
+		},
+		{
+			name: "TCP Port Closed (Yes)",
+			contentStr: `msg: port should be blocked
+port_closed: "127.0.0.1:` + closedTCPPort + `"`,
+		},
+		{
+			name: "TCP Port Closed (No)",
+			contentStr: `msg: port should be blocked
+port_closed: ` + openTCPAddr,
+			expectVerifyError: true,
+		},
+		{
+			name: "UDP Port Open (Yes)",
+			contentStr: `msg: udp listener should be up
+port_open: ` + openUDPAddr + `
+protocol: udp
+timeout: 200ms`,
+		},
+		{
+			name: "UDP Port Closed (Yes)",
+			contentStr: `msg: udp port should be closed
+port_closed: ` + closedUDPPort + `
+protocol: udp`,
+		},
+		{
+			name: "Invalid Protocol",
+			contentStr: `msg: bad protocol
+port_open: ` + openTCPPort + `
+protocol: sctp`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Timeout",
+			contentStr: `msg: bad timeout
+port_open: ` + openTCPPort + `
+timeout: soon`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Backend Without Network Support",
+			contentStr: `msg: listener should be up
+port_open: ` + openTCPPort,
+			backend:           true,
+			expectVerifyError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			err := yaml.Unmarshal([]byte(tc.contentStr), &check)
+			require.NoError(t, err)
+
+			ctx := VerificationContext{ExpandVariables: expandVariables}
+			if tc.backend {
+				ctx.Backend = &fakeProcessBackend{}
+			}
+			err = check.Verify(ctx)
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPortSpecDefaultsToLoopback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	spec := PortSpec{}
+	addr, open, err := spec.probe(VerificationContext{}, strconv.Itoa(port))
+	require.NoError(t, err)
+	require.True(t, open)
+	require.Equal(t, listener.Addr().String(), addr)
+}
+
+// fakeDialerBackend is an ExecutionBackend whose
+// connections all fail with the given error
+type fakeDialerBackend struct {
+	fakeProcessBackend
+	dialErr error
+}
+
+func (f *fakeDialerBackend) DialContext(_ context.Context, _, _ string) (net.Conn, error) {
+	return nil, f.dialErr
+}
+
+func TestPortClosedDialErrors(t *testing.T) {
+	testCases := []struct {
+		name              string
+		dialErr           error
+		expectVerifyError bool
+	}{
+		{
+			name:    "Refused",
+			dialErr: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+		},
+		{
+			name:    "Timed Out",
+			dialErr: context.DeadlineExceeded,
+		},
+		{
+			name:    "Refused On Remote Host",
+			dialErr: &ssh.OpenChannelError{Reason: ssh.ConnectionFailed, Message: "Connection refused"},
+		},
+		{
+			name:              "Forwarding Prohibited",
+			dialErr:           &ssh.OpenChannelError{Reason: ssh.Prohibited, Message: "administratively prohibited"},
+			expectVerifyError: true,
+		},
+		{
+			name:              "Unknown Host",
+			dialErr:           &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true},
+			expectVerifyError: true,
+		},
+		{
+			name:              "Connection Lost",
+			dialErr:           errors.New("ssh: unexpected packet in response to channel open"),
+			expectVerifyError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			require.NoError(t, yaml.Unmarshal([]byte(`msg: port should be blocked
+port_closed: "10.0.0.1:4444"`), &check))
+
+			err := check.Verify(VerificationContext{Backend: &fakeDialerBackend{dialErr: tc.dialErr}})
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				assert.False(t, isCheckFailure(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPortCheckValidateRemote(t *testing.T) {
+	testCases := []struct {
+		name       string
+		contentStr string
+		stepRemote string
+		wantError  bool
+	}{
+		{
+			name: "TCP On Remote Step",
+			contentStr: `msg: listener should be up
+port_open: "4444"`,
+			stepRemote: "target",
+		},
+		{
+			name: "UDP Locally",
+			contentStr: `msg: listener should be up
+port_open: "53"
+protocol: udp`,
+		},
+		{
+			name: "UDP On Remote Step",
+			contentStr: `msg: listener should be up
+port_open: "53"
+protocol: udp`,
+			stepRemote: "target",
+			wantError:  true,
+		},
+		{
+			name: "UDP Forced Local On Remote Step",
+			contentStr: `msg: listener should be up
+port_open: "53"
+protocol: udp
+remote: local`,
+			stepRemote: "target",
+		},
+		{
+			name: "UDP Nested In Remote Check",
+			contentStr: `msg: resolver should be down
+remote: target
+not:
+  port_open: "53"
+  protocol: udp`,
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			require.NoError(t, yaml.Unmarshal([]byte(tc.contentStr), &check))
+			err := check.ValidateRemote(tc.stepRemote)
+			if tc.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"gopkg.in/yaml.v3"
+)
+
+// ProcessSelector identifies the processes targeted by a process check.
+// Exactly one of Name, PID or CmdlineRegex must be set.
+type ProcessSelector struct {
+	Name         string `yaml:"name,omitempty"`
+	PID          string `yaml:"pid,omitempty"`
+	CmdlineRegex string `yaml:"cmdline_regex,omitempty"`
+}
+
+// UnmarshalYAML allows a process to be selected
+// by name using the shorthand `process_running: sshd`
+func (p *ProcessSelector) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Name = node.Value
+		return nil
+	}
+	type rawSelector ProcessSelector
+	return node.Decode((*rawSelector)(p))
+}
+
+// String describes the selector for use in error messages
+func (p *ProcessSelector) String() string {
+	switch {
+	case p.PID != "":
+		return fmt.Sprintf("with PID %s", p.PID)
+	case p.CmdlineRegex != "":
+		return fmt.Sprintf("with command line matching %q", p.CmdlineRegex)
+	default:
+		return fmt.Sprintf("named %q", p.Name)
+	}
+}
+
+// validate ensures that exactly one way of selecting processes is used
+func (p *ProcessSelector) validate() error {
+	numSet := 0
+	for _, field := range []string{p.Name, p.PID, p.CmdlineRegex} {
+		if field != "" {
+			numSet++
+		}
+	}
+	if numSet != 1 {
+		return errors.New("process checks must specify exactly one of name, pid, or cmdline_regex")
+	}
+	return nil
+}
+
+// findProcesses returns the PIDs of all running processes matched by the
+// selector. References to step outputs (such as a PID) are expanded first.
+func (p *ProcessSelector) findProcesses(ctx VerificationContext) ([]int, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	resolved := *p
+	for _, field := range []*string{&resolved.Name, &resolved.PID, &resolved.CmdlineRegex} {
+		expanded, err := expandCheckField(ctx, *field)
+		if err != nil {
+			return nil, err
+		}
+		*field = expanded
+	}
+
+	backend := ctx.Backend
+	if backend == nil {
+		backend = backends.NewLocalBackend()
+	}
+
+	switch {
+	case resolved.PID != "":
+		pid, err := strconv.Atoi(resolved.PID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID %q: %w", resolved.PID, err)
+		}
+		exists, err := backend.ProcessExists(pid)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, nil
+		}
+		return []int{pid}, nil
+	case resolved.CmdlineRegex != "":
+		if _, err := regexp.Compile(resolved.CmdlineRegex); err != nil {
+			return nil, fmt.Errorf("invalid regex pattern %q: %w", resolved.CmdlineRegex, err)
+		}
+		return noProcessesAsEmpty(backend.FindProcessesByCommandLine(resolved.CmdlineRegex))
+	default:
+		return noProcessesAsEmpty(backend.FindProcessesByName(resolved.Name))
+	}
+}
+
+// noProcessesAsEmpty maps the backends' "no process found" result
+// to an empty match while passing every other lookup error through.
+func noProcessesAsEmpty(pids []int, err error) ([]int, error) {
+	if errors.Is(err, backends.ErrNoProcessFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up processes: %w", err)
+	}
+	return pids, nil
+}
+
+// ProcessRunning is a condition that verifies that at least
+// one process matching the given selector is running
+type ProcessRunning struct {
+	Process *ProcessSelector `yaml:"process_running"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *ProcessRunning) IsNil() bool {
+	return c.Process == nil
+}
+
+// Verify checks the condition and returns an error if it fails
+func (c *ProcessRunning) Verify(ctx VerificationContext) error {
+	pids, err := c.Process.findProcesses(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return failf("no process %v is running", c.Process)
+	}
+	return nil
+}
+
+// ProcessNotRunning is a condition that verifies that no
+// process matching the given selector is running - for example,
+// to confirm that a payload was killed by a security tool
+type ProcessNotRunning struct {
+	Process *ProcessSelector `yaml:"process_not_running"`
+}
+
+// IsNil checks if the condition is empty or uninitialized
+func (c *ProcessNotRunning) IsNil() bool {
+	return c.Process == nil
+}
+
+// Verify checks the condition and returns an error if it fails
+func (c *ProcessNotRunning) Verify(ctx VerificationContext) error {
+	pids, err := c.Process.findProcesses(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pids) > 0 {
+		return failf("process %v is still running (PIDs: %v)", c.Process, pids)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/facebookincubator/ttpforge/pkg/backends"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeProcessBackend is an ExecutionBackend whose
+// process table is a map from PID to command line
+type fakeProcessBackend struct {
+	processes map[int]string
+	// findErr, when set, is returned by every process lookup
+	findErr error
+}
+
+func (f *fakeProcessBackend) RunCommand(_ context.Context, _ string, _ string, _ []string, _ []string, _ string, _ io.Writer, _ io.Writer) (string, string, error) {
+	return "", "", nil
+}
+func (f *fakeProcessBackend) GetFs() (afero.Fs, error) { return afero.NewMemMapFs(), nil }
+func (f *fakeProcessBackend) KillProcess(_ int) error  { return nil }
+func (f *fakeProcessBackend) Close() error             { return nil }
+
+func (f *fakeProcessBackend) FindProcessesByName(name string) ([]int, error) {
+	if f.findErr != nil {
+		return nil, f.findErr
+	}
+	var pids []int
+	for pid, cmdline := range f.processes {
+		if strings.Fields(cmdline)[0] == name {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("%w with name: %s", backends.ErrNoProcessFound, name)
+	}
+	return pids, nil
+}
+
+func (f *fakeProcessBackend) FindProcessesByCommandLine(pattern string) ([]int, error) {
+	if f.findErr != nil {
+		return nil, f.findErr
+	}
+	re := regexp.MustCompile(pattern)
+	var pids []int
+	for pid, cmdline := range f.processes {
+		if re.MatchString(cmdline) {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("%w with command line matching: %s", backends.ErrNoProcessFound, pattern)
+	}
+	return pids, nil
+}
+
+func (f *fakeProcessBackend) ProcessExists(pid int) (bool, error) {
+	_, ok := f.processes[pid]
+	return ok, nil
+}
+
+func TestProcessChecks(t *testing.T) {
+	backend := &fakeProcessBackend{
+		processes: map[int]string{
+			100: "sshd -D",
+			200: "python3 /tmp/beacon.py --interval 5",
+		},
+	}
+	expandVariables := func(input string) (string, error) {
+		return strings.ReplaceAll(input, "$forge.steps.launch.outputs.pid", "200"), nil
+	}
+
+	testCases := []struct {
+		name                 string
+		contentStr           string
+		expectUnmarshalError bool
+		expectVerifyError    bool
+	}{
+		{
+			name: "Process Running By Name (Yes)",
+			contentStr: `msg: sshd should be running
+process_running:
+  name: sshd`,
+		},
+		{
+			name: "Process Running By Name Shorthand (No)",
+			contentStr: `msg: payload should be running
+process_running: payload`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Process Running By PID From Output (Yes)",
+			contentStr: `msg: beacon should be running
+process_running:
+  pid: $forge.steps.launch.outputs.pid`,
+		},
+		{
+			name: "Process Not Running By PID (Yes)",
+			contentStr: `msg: payload should have been killed
+process_not_running:
+  pid: "300"`,
+		},
+		{
+			name: "Process Not Running By Command Line (No)",
+			contentStr: `msg: beacon should have been killed
+process_not_running:
+  cmdline_regex: 'beacon\.py'`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Process Not Running By Command Line (Yes)",
+			contentStr: `msg: implant should have been killed
+process_not_running:
+  cmdline_regex: 'implant'`,
+		},
+		{
+			name: "Invalid PID",
+			contentStr: `msg: bad pid
+process_running:
+  pid: notanumber`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Invalid Command Line Regex",
+			contentStr: `msg: bad regex
+process_running:
+  cmdline_regex: '[invalid'`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Multiple Selectors",
+			contentStr: `msg: ambiguous selector
+process_running:
+  name: sshd
+  pid: "100"`,
+			expectVerifyError: true,
+		},
+		{
+			name: "Running And Not Running",
+			contentStr: `msg: ambiguous check
+process_running: sshd
+process_not_running: payload`,
+			expectUnmarshalError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			err := yaml.Unmarshal([]byte(tc.contentStr), &check)
+			if tc.expectUnmarshalError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			err = check.Verify(VerificationContext{
+				Backend:         backend,
+				ExpandVariables: expandVariables,
+			})
+			if tc.expectVerifyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProcessChecksBackendError(t *testing.T) {
+	backend := &fakeProcessBackend{
+		findErr: errors.New("connection reset by peer"),
+	}
+
+	testCases := []struct {
+		name       string
+		contentStr string
+	}{
+		{
+			name: "Process Running By Name",
+			contentStr: `msg: sshd should be running
+process_running: sshd`,
+		},
+		{
+			name: "Process Not Running By Name",
+			contentStr: `msg: payload should have been killed
+process_not_running: payload`,
+		},
+		{
+			name: "Process Not Running By Command Line",
+			contentStr: `msg: beacon should have been killed
+process_not_running:
+  cmdline_regex: 'beacon\.py'`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var check Check
+			require.NoError(t, yaml.Unmarshal([]byte(tc.contentStr), &check))
+
+			err := check.Verify(VerificationContext{Backend: backend})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "connection reset by peer")
+		})
+	}
+}
+
+func TestProcessRunningLocal(t *testing.T) {
+	var check Check
+	err := yaml.Unmarshal([]byte(`msg: test process should be running
+process_running:
+  pid: "`+strconv.Itoa(os.Getpid())+`"`), &check)
+	require.NoError(t, err)
+	assert.NoError(t, check.Verify(VerificationContext{}))
+
+	err = yaml.Unmarshal([]byte(`msg: test process should be running
+process_running:
+  cmdline_regex: '`+regexp.QuoteMeta(os.Args[0])+`'`), &check)
+	require.NoError(t, err)
+	assert.NoError(t, check.Verify(VerificationContext{}))
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package detections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxLatency is how long an expected alert may take to
+// be raised when the detection does not set max_latency
+const DefaultMaxLatency = 5 * time.Minute
+
+// Expectation is a detection that a TTP is expected to trigger,
+// as declared in the detections: section of the TTP preamble
+type Expectation struct {
+	RuleID     string `yaml:"rule_id,omitempty"`
+	RuleName   string `yaml:"rule_name,omitempty"`
+	MaxLatency string `yaml:"max_latency,omitempty"`
+}
+
+// Alert is a single alert retrieved from an AlertSource
+type Alert struct {
+	RuleID   string
+	RuleName string
+	// Time is when the alert was raised - it is
+	// the zero time if the source did not provide it
+	Time time.Time
+}
+
+// AlertSource is implemented by each system (such as
+// a SIEM or EDR) from which alerts can be retrieved
+type AlertSource interface {
+	// Alerts returns all of the alerts raised since the given time
+	Alerts(ctx context.Context, since time.Time) ([]Alert, error)
+}
+
+// Result records whether an expected detection was observed
+type Result struct {
+	Expectation Expectation
+	Hit         bool
+	// MaxLatency is how long the alert was allowed to take
+	MaxLatency time.Duration
+	// Latency is the time between the start of
+	// the run and the alert, for detections that hit
+	Latency time.Duration
+}
+
+// Validate checks that the expectation identifies a rule
+// and that its max_latency (if set) is a valid duration
+func (e *Expectation) Validate() error {
+	if e.RuleID == "" && e.RuleName == "" {
+		return errors.New("detection must set rule_id or rule_name")
+	}
+	if _, err := e.maxLatency(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// String returns a human-readable identifier for the expected rule
+func (e Expectation) String() string {
+	switch {
+	case e.RuleID != "" && e.RuleName != "":
+		return fmt.Sprintf("%v (%v)", e.RuleID, e.RuleName)
+	case e.RuleID != "":
+		return e.RuleID
+	default:
+		return e.RuleName
+	}
+}
+
+func (e *Expectation) maxLatency() (time.Duration, error) {
+	if e.MaxLatency == "" {
+		return DefaultMaxLatency, nil
+	}
+	d, err := time.ParseDuration(e.MaxLatency)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("detection %v has invalid max_latency %q", e, e.MaxLatency)
+	}
+	return d, nil
+}
+
+// matches reports whether the alert was raised by the expected rule
+func (e *Expectation) matches(alert Alert) bool {
+	if e.RuleID != "" && alert.RuleID != e.RuleID {
+		return false
+	}
+	if e.RuleName != "" && alert.RuleName != e.RuleName {
+		return false
+	}
+	return true
+}
+
+// Verify polls the alert source until every expected detection has
+// been observed or the largest max_latency since start has elapsed.
+// An alert only counts if it was raised within the max_latency of its
+// expectation - alerts without a time are assumed to have been raised
+// when they were first retrieved. Errors from the source are retried
+// until the deadline and returned only if no poll succeeded.
+func Verify(ctx context.Context, source AlertSource, expectations []Expectation, start time.Time, pollInterval time.Duration) ([]Result, error) {
+	results := make([]Result, len(expectations))
+	var deadline time.Time
+	for i, e := range expectations {
+		latency, err := e.maxLatency()
+		if err != nil {
+			return nil, err
+		}
+		results[i] = Result{Expectation: e, MaxLatency: latency}
+		if start.Add(latency).After(deadline) {
+			deadline = start.Add(latency)
+		}
+	}
+
+	var lastErr error
+	polledOK := false
+	for {
+		polledAt := time.Now()
+		alerts, err := source.Alerts(ctx, start)
+		if err != nil {
+			lastErr = err
+		} else {
+			polledOK = true
+			markHits(results, alerts, start, polledAt)
+		}
+
+		pending := false
+		for _, r := range results {
+			if !r.Hit {
+				pending = true
+				break
+			}
+		}
+		if !pending || !time.Now().Add(pollInterval).Before(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return results, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+	if !polledOK && lastErr != nil {
+		return results, fmt.Errorf("failed to retrieve alerts: %w", lastErr)
+	}
+	return results, nil
+}
+
+// markHits records each expectation that one of the
+// alerts satisfies, keeping the lowest latency seen
+func markHits(results []Result, alerts []Alert, start, polledAt time.Time) {
+	for i := range results {
+		for _, alert := range alerts {
+			if !results[i].Expectation.matches(alert) {
+				continue
+			}
+			alertTime := alert.Time
+			if alertTime.IsZero() {
+				alertTime = polledAt
+			}
+			latency := alertTime.Sub(start)
+			if latency < 0 || latency > results[i].MaxLatency {
+				continue
+			}
+			if !results[i].Hit || latency < results[i].Latency {
+				results[i].Hit = true
+				results[i].Latency = latency
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package detections
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeSource returns a fixed set of alerts once it has
+// been polled more than delayPolls times, to simulate
+// the time taken for alerts to be raised
+type fakeSource struct {
+	alerts     []Alert
+	err        error
+	delayPolls int
+	polls      int
+}
+
+func (s *fakeSource) Alerts(_ context.Context, _ time.Time) ([]Alert, error) {
+	s.polls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.polls <= s.delayPolls {
+		return nil, nil
+	}
+	return s.alerts, nil
+}
+
+func TestExpectationValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		content     string
+		expectError bool
+	}{
+		{
+			name:    "Rule ID",
+			content: `rule_id: T1059-001`,
+		},
+		{
+			name: "Rule Name With Latency",
+			content: `rule_name: Suspicious Curl
+max_latency: 2m`,
+		},
+		{
+			name:        "No Rule",
+			content:     `max_latency: 2m`,
+			expectError: true,
+		},
+		{
+			name: "Invalid Latency",
+			content: `rule_id: T1059-001
+max_latency: soon`,
+			expectError: true,
+		},
+		{
+			name: "Negative Latency",
+			content: `rule_id: T1059-001
+max_latency: -1m`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var e Expectation
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &e))
+			err := e.Validate()
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// the run started long enough ago that every max_latency
+	// below has already elapsed, so Verify polls exactly once
+	start := time.Now().Add(-time.Hour)
+
+	testCases := []struct {
+		name            string
+		alerts          []Alert
+		sourceErr       error
+		expectations    []Expectation
+		expectedHits    []bool
+		expectedLatency []time.Duration
+		expectError     bool
+	}{
+		{
+			name: "All Detections Hit",
+			alerts: []Alert{
+				{RuleID: "other", Time: start.Add(time.Second)},
+				{RuleID: "r1", RuleName: "Curl", Time: start.Add(2 * time.Second)},
+				{RuleID: "r2", RuleName: "Wget", Time: start.Add(3 * time.Second)},
+			},
+			expectations: []Expectation{
+				{RuleID: "r1", MaxLatency: "1m"},
+				{RuleName: "Wget", MaxLatency: "1m"},
+			},
+			expectedHits:    []bool{true, true},
+			expectedLatency: []time.Duration{2 * time.Second, 3 * time.Second},
+		},
+		{
+			name: "Alert After Max Latency",
+			alerts: []Alert{
+				{RuleID: "r1", Time: start.Add(2 * time.Minute)},
+			},
+			expectations: []Expectation{{RuleID: "r1", MaxLatency: "1m"}},
+			expectedHits: []bool{false},
+		},
+		{
+			name: "Rule ID And Name Must Both Match",
+			alerts: []Alert{
+				{RuleID: "r1", RuleName: "Wget", Time: start.Add(time.Second)},
+			},
+			expectations: []Expectation{
+				{RuleID: "r1", RuleName: "Curl", MaxLatency: "1m"},
+				{RuleID: "r1", RuleName: "Wget", MaxLatency: "1m"},
+			},
+			expectedHits:    []bool{false, true},
+			expectedLatency: []time.Duration{0, time.Second},
+		},
+		{
+			name: "Earliest Alert Sets Latency",
+			alerts: []Alert{
+				{RuleID: "r1", Time: start.Add(5 * time.Second)},
+				{RuleID: "r1", Time: start.Add(2 * time.Second)},
+			},
+			expectations:    []Expectation{{RuleID: "r1", MaxLatency: "1m"}},
+			expectedHits:    []bool{true},
+			expectedLatency: []time.Duration{2 * time.Second},
+		},
+		{
+			name: "Alert Before Start Ignored",
+			alerts: []Alert{
+				{RuleID: "r1", Time: start.Add(-time.Minute)},
+			},
+			expectations: []Expectation{{RuleID: "r1", MaxLatency: "1m"}},
+			expectedHits: []bool{false},
+		},
+		{
+			name:         "Source Error",
+			sourceErr:    errors.New("connection refused"),
+			expectations: []Expectation{{RuleID: "r1", MaxLatency: "1m"}},
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := &fakeSource{alerts: tc.alerts, err: tc.sourceErr}
+			results, err := Verify(context.Background(), source, tc.expectations, start, time.Millisecond)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, source.polls)
+			require.Len(t, results, len(tc.expectations))
+			for i, result := range results {
+				assert.Equal(t, tc.expectedHits[i], result.Hit, "detection %v", result.Expectation)
+				if result.Hit {
+					assert.Equal(t, tc.expectedLatency[i], result.Latency)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyPollsUntilHit(t *testing.T) {
+	start := time.Now()
+	// this alert has no time, so it counts as
+	// being raised when it was first retrieved
+	source := &fakeSource{
+		alerts:     []Alert{{RuleID: "r1"}},
+		delayPolls: 3,
+	}
+	results, err := Verify(context.Background(), source, []Expectation{{RuleID: "r1", MaxLatency: "10s"}}, start, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Hit)
+	assert.Equal(t, 4, source.polls)
+	assert.Less(t, results[0].Latency, 10*time.Second)
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package detections
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxResponseSize limits how much of the endpoint's response is read
+const maxResponseSize = 64 * 1024 * 1024
+
+// HTTPSource retrieves alerts from an HTTP endpoint that returns
+// them as JSON, such as the search API of a SIEM or a small adapter
+// service in front of one
+type HTTPSource struct {
+	URL                string
+	Headers            map[string]string
+	ResultsPath        string
+	SinceParam         string
+	Timeout            time.Duration
+	InsecureSkipVerify bool
+	Fields             FieldMapping
+}
+
+// Alerts queries the endpoint and returns the alerts raised since the given time
+func (s *HTTPSource) Alerts(ctx context.Context, since time.Time) ([]Alert, error) {
+	reqURL, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert source url %q: %w", s.URL, err)
+	}
+	if s.SinceParam != "" {
+		query := reqURL.Query()
+		query.Set(s.SinceParam, since.UTC().Format(time.RFC3339))
+		reqURL.RawQuery = query.Encode()
+	}
+
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = defaultSourceTimeout
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid alert source request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{}
+	if s.InsecureSkipVerify {
+		// #nosec G402
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert source: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("alert source returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert source response: %w", err)
+	}
+	if !gjson.ValidBytes(body) {
+		return nil, fmt.Errorf("alert source returned invalid JSON")
+	}
+
+	results := gjson.ParseBytes(body)
+	if s.ResultsPath != "" {
+		results = results.Get(s.ResultsPath)
+	}
+	if !results.IsArray() {
+		return nil, fmt.Errorf("alert source response does not contain a list of alerts")
+	}
+	var alerts []Alert
+	for _, record := range results.Array() {
+		alerts = append(alerts, s.Fields.parseAlert(record))
+	}
+	return keepSince(alerts, since), nil
+}
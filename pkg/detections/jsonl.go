@@ -0,0 +1,67 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package detections
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tidwall/gjson"
+)
+
+// JSONLinesSource reads alerts from a file containing one JSON
+// alert per line, such as the alert log written by an EDR agent
+type JSONLinesSource struct {
+	Path   string
+	Fields FieldMapping
+	Fs     afero.Fs
+}
+
+// Alerts returns the alerts in the file raised since the given time.
+// Lines that are not valid JSON are skipped, and a missing file is
+// treated as containing no alerts since it may not have been created yet.
+func (s *JSONLinesSource) Alerts(_ context.Context, since time.Time) ([]Alert, error) {
+	f, err := s.Fs.Open(s.Path)
+	if err != nil {
+		if exists, _ := afero.Exists(s.Fs, s.Path); !exists {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open alert file %q: %w", s.Path, err)
+	}
+	defer f.Close()
+
+	var alerts []Alert
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !gjson.Valid(line) {
+			continue
+		}
+		alerts = append(alerts, s.Fields.parseAlert(gjson.Parse(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read alert file %q: %w", s.Path, err)
+	}
+	return keepSince(alerts, since), nil
+}
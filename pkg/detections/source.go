@@ -0,0 +1,172 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package detections
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	defaultPollInterval  = 10 * time.Second
+	defaultSourceTimeout = 30 * time.Second
+)
+
+// FieldMapping names the fields of an alert record
+// that hold its rule ID, rule name and time. Each field
+// is a gjson path, such as "rule.id".
+type FieldMapping struct {
+	RuleIDField   string `yaml:"rule_id_field,omitempty"`
+	RuleNameField string `yaml:"rule_name_field,omitempty"`
+	TimeField     string `yaml:"time_field,omitempty"`
+}
+
+// SourceConfig configures the alert source used to verify
+// detections - it is read from the alert_source: section
+// of the TTPForge config file
+type SourceConfig struct {
+	// Type is either "jsonl" or "http"
+	Type string `yaml:"type"`
+	// Path is the JSON-lines alert file (jsonl only)
+	Path string `yaml:"path,omitempty"`
+	// URL is the endpoint that returns alerts as JSON (http only)
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// ResultsPath is the gjson path of the list of alerts
+	// in the response - by default, the response must be a list
+	ResultsPath string `yaml:"results_path,omitempty"`
+	// SinceParam optionally names a query parameter used to
+	// pass the start of the run to the endpoint in RFC3339 format
+	SinceParam         string `yaml:"since_param,omitempty"`
+	Timeout            string `yaml:"timeout,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	PollInterval       string `yaml:"poll_interval,omitempty"`
+	FieldMapping       `yaml:",inline"`
+}
+
+// NewSource creates the alert source described by the config
+func NewSource(cfg SourceConfig) (AlertSource, error) {
+	if _, err := cfg.GetPollInterval(); err != nil {
+		return nil, err
+	}
+	switch cfg.Type {
+	case "jsonl":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("jsonl alert source requires a path")
+		}
+		return &JSONLinesSource{
+			Path:   cfg.Path,
+			Fields: cfg.FieldMapping,
+			Fs:     afero.NewOsFs(),
+		}, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("http alert source requires a url")
+		}
+		timeout := defaultSourceTimeout
+		if cfg.Timeout != "" {
+			var err error
+			timeout, err = time.ParseDuration(cfg.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid alert source timeout %q", cfg.Timeout)
+			}
+		}
+		// headers usually hold API tokens, so
+		// allow them to come from the environment
+		headers := make(map[string]string, len(cfg.Headers))
+		for name, value := range cfg.Headers {
+			headers[name] = os.ExpandEnv(value)
+		}
+		return &HTTPSource{
+			URL:                cfg.URL,
+			Headers:            headers,
+			ResultsPath:        cfg.ResultsPath,
+			SinceParam:         cfg.SinceParam,
+			Timeout:            timeout,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+			Fields:             cfg.FieldMapping,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid alert source type %q - must be jsonl or http", cfg.Type)
+	}
+}
+
+// GetPollInterval returns the time between polls of the alert source
+func (cfg *SourceConfig) GetPollInterval() (time.Duration, error) {
+	if cfg.PollInterval == "" {
+		return defaultPollInterval, nil
+	}
+	d, err := time.ParseDuration(cfg.PollInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid alert source poll_interval %q", cfg.PollInterval)
+	}
+	return d, nil
+}
+
+// parseAlert extracts an alert from a single JSON record
+func (m FieldMapping) parseAlert(record gjson.Result) Alert {
+	alert := Alert{
+		RuleID:   record.Get(withDefault(m.RuleIDField, "rule_id")).String(),
+		RuleName: record.Get(withDefault(m.RuleNameField, "rule_name")).String(),
+	}
+	alert.Time = parseAlertTime(record.Get(withDefault(m.TimeField, "timestamp")))
+	return alert
+}
+
+// parseAlertTime converts an RFC3339 string or a Unix timestamp in
+// seconds or milliseconds to a time, returning the zero time if the
+// value is missing or invalid
+func parseAlertTime(value gjson.Result) time.Time {
+	switch value.Type {
+	case gjson.Number:
+		if value.Float() > 1e11 {
+			return time.UnixMilli(value.Int())
+		}
+		sec := value.Int()
+		return time.Unix(sec, int64((value.Float()-float64(sec))*1e9))
+	case gjson.String:
+		if t, err := time.Parse(time.RFC3339Nano, value.String()); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// keepSince drops alerts that were raised before since
+func keepSince(alerts []Alert, since time.Time) []Alert {
+	var kept []Alert
+	for _, alert := range alerts {
+		if alert.Time.IsZero() || !alert.Time.Before(since) {
+			kept = append(kept, alert)
+		}
+	}
+	return kept
+}
+
+func withDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
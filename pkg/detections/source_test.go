@@ -0,0 +1,226 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package detections
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewSource(t *testing.T) {
+	testCases := []struct {
+		name        string
+		content     string
+		expectError bool
+	}{
+		{
+			name: "JSON Lines Source",
+			content: `type: jsonl
+path: /var/log/edr/alerts.json`,
+		},
+		{
+			name: "HTTP Source",
+			content: `type: http
+url: https://siem.example.com/api/alerts
+timeout: 5s
+poll_interval: 30s`,
+		},
+		{
+			name:        "JSON Lines Source Without Path",
+			content:     `type: jsonl`,
+			expectError: true,
+		},
+		{
+			name:        "HTTP Source Without URL",
+			content:     `type: http`,
+			expectError: true,
+		},
+		{
+			name: "Invalid Timeout",
+			content: `type: http
+url: https://siem.example.com/api/alerts
+timeout: forever`,
+			expectError: true,
+		},
+		{
+			name: "Invalid Poll Interval",
+			content: `type: jsonl
+path: /var/log/edr/alerts.json
+poll_interval: 0s`,
+			expectError: true,
+		},
+		{
+			name:        "Invalid Type",
+			content:     `type: carrier_pigeon`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var cfg SourceConfig
+			require.NoError(t, yaml.Unmarshal([]byte(tc.content), &cfg))
+			_, err := NewSource(cfg)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestJSONLinesSource(t *testing.T) {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	fsys := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fsys, "/alerts.json", []byte(`{"rule": {"id": "r0"}, "@timestamp": "2024-06-01T11:00:00Z"}
+{"rule": {"id": "r1", "name": "Curl"}, "@timestamp": "2024-06-01T12:00:05Z"}
+not json
+{"rule": {"id": "r2", "name": "Wget"}, "@timestamp": 1717243210000}
+`), 0644))
+
+	source := &JSONLinesSource{
+		Path: "/alerts.json",
+		Fields: FieldMapping{
+			RuleIDField:   "rule.id",
+			RuleNameField: "rule.name",
+			TimeField:     "@timestamp",
+		},
+		Fs: fsys,
+	}
+	alerts, err := source.Alerts(context.Background(), since)
+	require.NoError(t, err)
+	assert.Equal(t, []Alert{
+		{RuleID: "r1", RuleName: "Curl", Time: since.Add(5 * time.Second)},
+		{RuleID: "r2", RuleName: "Wget", Time: time.UnixMilli(1717243210000)},
+	}, alerts)
+
+	// the alert file may not have been created yet
+	source.Path = "/missing.json"
+	alerts, err = source.Alerts(context.Background(), since)
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+}
+
+func TestHTTPSource(t *testing.T) {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	t.Setenv("TTPFORGE_TEST_SIEM_TOKEN", "s3cret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/alerts":
+			if r.Header.Get("Authorization") != "Bearer s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("from") != "2024-06-01T12:00:00Z" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"data": {"alerts": [
+				{"rule_id": "r0", "timestamp": "2024-06-01T11:59:00Z"},
+				{"rule_id": "r1", "rule_name": "Curl", "timestamp": 1717243205}
+			]}}`)
+		case "/list":
+			fmt.Fprint(w, `[{"rule_id": "r1"}]`)
+		case "/invalid":
+			fmt.Fprint(w, `{"data": `)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name           string
+		cfg            SourceConfig
+		expectedAlerts []Alert
+		expectError    bool
+	}{
+		{
+			name: "Nested Results With Auth And Since",
+			cfg: SourceConfig{
+				URL:         server.URL + "/alerts",
+				Headers:     map[string]string{"Authorization": "Bearer ${TTPFORGE_TEST_SIEM_TOKEN}"},
+				ResultsPath: "data.alerts",
+				SinceParam:  "from",
+			},
+			expectedAlerts: []Alert{
+				{RuleID: "r1", RuleName: "Curl", Time: time.Unix(1717243205, 0)},
+			},
+		},
+		{
+			name: "Top Level List Without Time",
+			cfg: SourceConfig{
+				URL: server.URL + "/list",
+			},
+			expectedAlerts: []Alert{{RuleID: "r1"}},
+		},
+		{
+			name: "Missing Token",
+			cfg: SourceConfig{
+				URL:         server.URL + "/alerts",
+				ResultsPath: "data.alerts",
+			},
+			expectError: true,
+		},
+		{
+			name: "Results Path Not A List",
+			cfg: SourceConfig{
+				URL:         server.URL + "/alerts",
+				Headers:     map[string]string{"Authorization": "Bearer ${TTPFORGE_TEST_SIEM_TOKEN}"},
+				ResultsPath: "data",
+				SinceParam:  "from",
+			},
+			expectError: true,
+		},
+		{
+			name: "Invalid JSON",
+			cfg: SourceConfig{
+				URL: server.URL + "/invalid",
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.Type = "http"
+			source, err := NewSource(tc.cfg)
+			require.NoError(t, err)
+			alerts, err := source.Alerts(context.Background(), since)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedAlerts, alerts)
+		})
+	}
+}
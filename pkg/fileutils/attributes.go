@@ -0,0 +1,107 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package fileutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/spf13/afero"
+)
+
+// OwnerIDs returns the numeric user and group IDs of the
+// owner of a file, from either the local or SFTP filesystem
+func OwnerIDs(info os.FileInfo) (int, int, bool) {
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		return int(stat.UID), int(stat.GID), true
+	}
+	return platformOwnerIDs(info.Sys())
+}
+
+// AccessTime returns the last access time of a file,
+// from either the local or SFTP filesystem
+func AccessTime(info os.FileInfo) (time.Time, bool) {
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		return time.Unix(int64(stat.Atime), 0), true
+	}
+	return platformAccessTime(info.Sys())
+}
+
+// LookupID resolves a user or group to its numeric ID. Names are
+// looked up in the user database of the host that owns fsys -
+// for remote filesystems, this means reading /etc/passwd or
+// /etc/group from the remote host.
+func LookupID(fsys afero.Fs, nameOrID string, isGroup bool) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	kind := "user"
+	if isGroup {
+		kind = "group"
+	}
+
+	if _, ok := fsys.(*afero.OsFs); ok {
+		var idStr string
+		if isGroup {
+			g, err := user.LookupGroup(nameOrID)
+			if err != nil {
+				return 0, fmt.Errorf("failed to look up %v %q: %w", kind, nameOrID, err)
+			}
+			idStr = g.Gid
+		} else {
+			u, err := user.Lookup(nameOrID)
+			if err != nil {
+				return 0, fmt.Errorf("failed to look up %v %q: %w", kind, nameOrID, err)
+			}
+			idStr = u.Uid
+		}
+		return strconv.Atoi(idStr)
+	}
+
+	dbPath := "/etc/passwd"
+	if isGroup {
+		dbPath = "/etc/group"
+	}
+	f, err := fsys.Open(dbPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up %v %q: %w", kind, nameOrID, err)
+	}
+	defer f.Close()
+
+	// both files use the format name:password:id:...
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= 3 && fields[0] == nameOrID {
+			return strconv.Atoi(fields[2])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read %v: %w", dbPath, err)
+	}
+	return 0, fmt.Errorf("%v %q not found in %v", kind, nameOrID, dbPath)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package fileutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupIDFromFileSystem(t *testing.T) {
+	fsys := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fsys, "/etc/passwd", []byte(strings.Join([]string{
+		"root:x:0:0:root:/root:/bin/bash",
+		"svc_backup:x:1005:1005::/home/svc_backup:/bin/sh",
+	}, "\n")), 0644))
+	require.NoError(t, afero.WriteFile(fsys, "/etc/group", []byte("root:x:0:\nwheel:x:10:svc_backup\n"), 0644))
+
+	uid, err := LookupID(fsys, "svc_backup", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1005, uid)
+
+	gid, err := LookupID(fsys, "wheel", true)
+	require.NoError(t, err)
+	assert.Equal(t, 10, gid)
+
+	id, err := LookupID(fsys, "42", false)
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+
+	_, err = LookupID(fsys, "nobody", false)
+	require.Error(t, err)
+}
+
+func TestAccessTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("contents"), 0644))
+	atime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(path, atime, time.Now()))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	got, ok := AccessTime(info)
+	require.True(t, ok)
+	assert.True(t, atime.Equal(got), "expected %v, got %v", atime, got)
+
+	// in-memory files do not record an access time
+	fsys := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fsys, "/file.txt", []byte("contents"), 0644))
+	info, err = fsys.Stat("/file.txt")
+	require.NoError(t, err)
+	_, ok = AccessTime(info)
+	assert.False(t, ok)
+}
@@ -22,6 +22,8 @@ package outputs
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/tidwall/gjson"
 	"gopkg.in/yaml.v3"
@@ -44,7 +46,7 @@ func Parse(specs map[string]Spec, inStr string) (map[string]string, error) {
 	for name, spec := range specs {
 		outStr, err := spec.Apply(inStr)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to extract output %q: %w", name, err)
 		}
 		outputs[name] = outStr
 	}
@@ -55,12 +57,21 @@ func Parse(specs map[string]Spec, inStr string) (map[string]string, error) {
 // a given step's stdout should be scanned
 type Spec struct {
 	Filters []Filter `yaml:"filters"`
+	Type    string   `yaml:"type,omitempty"`
 }
 
 // Filter can be used to extract an output value
 // from the provided string using Apply(...)
 type Filter interface {
 	Apply(inStr string) (string, error)
+	IsNil() bool
+}
+
+// compiler is implemented by filters with patterns that
+// are compiled when the output spec is loaded, so that
+// invalid patterns are reported before the TTP runs
+type compiler interface {
+	compile() error
 }
 
 // Apply applies all filters in this output spec
@@ -68,10 +79,10 @@ type Filter interface {
 func (s *Spec) Apply(inStr string) (string, error) {
 	var err error
 	curStr := inStr
-	for _, f := range s.Filters {
+	for idx, f := range s.Filters {
 		curStr, err = f.Apply(curStr)
 		if err != nil {
-			return "", err
+			return "", fmt.Errorf("output filter #%d failed: %w", idx+1, err)
 		}
 	}
 	return curStr, nil
@@ -87,40 +98,90 @@ type JSONFilter struct {
 func (s *Spec) UnmarshalYAML(node *yaml.Node) error {
 	type SpecTmp struct {
 		FilterNodes []yaml.Node `yaml:"filters"`
+		Type        string      `yaml:"type"`
 	}
 
 	var tmp SpecTmp
 	if err := node.Decode(&tmp); err != nil {
 		return err
 	}
+	if tmp.Type != "" && !slices.Contains(validTypes, tmp.Type) {
+		return fmt.Errorf("invalid output type %q - must be one of %v", tmp.Type, strings.Join(validTypes, ", "))
+	}
 
 	var filters []Filter
-	for _, fn := range tmp.FilterNodes {
-		filterTypes := []Filter{&JSONFilter{}}
-		var alreadyFound bool
+	for idx, fn := range tmp.FilterNodes {
+		filterTypes := []Filter{
+			&JSONFilter{},
+			&YAMLFilter{},
+			&XPathFilter{},
+			&CSVFilter{},
+			&KeyValueFilter{},
+			&RegexFilter{},
+			&LineFilter{},
+			&GrepFilter{},
+			&FieldFilter{},
+			&TrimFilter{},
+			&ReplaceFilter{},
+			&Base64DecodeFilter{},
+			&DefaultFilter{},
+		}
+		var found Filter
 		for _, ft := range filterTypes {
-			if err := fn.Decode(ft); err == nil {
-				if alreadyFound {
-					return errors.New("output spec contains filter with ambiguous type")
+			if err := fn.Decode(ft); err == nil && !ft.IsNil() {
+				if found != nil {
+					return fmt.Errorf("output spec filter #%d has ambiguous type", idx+1)
 				}
-				filters = append(filters, ft)
-				alreadyFound = true
+				found = ft
 			}
 		}
+		if found == nil {
+			return fmt.Errorf("output spec filter #%d did not match any valid filter type", idx+1)
+		}
+		if c, ok := found.(compiler); ok {
+			if err := c.compile(); err != nil {
+				return fmt.Errorf("output spec filter #%d is invalid: %w", idx+1, err)
+			}
+		}
+		filters = append(filters, found)
 	}
 	if len(filters) == 0 {
 		return errors.New("no valid filters found in output spec")
 	}
 	s.Filters = filters
+	s.Type = tmp.Type
 	return nil
 }
 
+// IsNil checks if the filter is empty or uninitialized
+func (f *JSONFilter) IsNil() bool {
+	return f.Path == ""
+}
+
 // Apply applies this filters to the target string
 // and produces a new string
 func (f *JSONFilter) Apply(inStr string) (string, error) {
+	result, err := f.get(inStr)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// ApplyTyped applies this filter to the target string and
+// produces a value that retains its JSON type
+func (f *JSONFilter) ApplyTyped(inStr string) (any, error) {
+	result, err := f.get(inStr)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value(), nil
+}
+
+func (f *JSONFilter) get(inStr string) (gjson.Result, error) {
 	result := gjson.Get(inStr, f.Path)
 	if !result.Exists() {
-		return "", fmt.Errorf("json path not found: %v", f.Path)
+		return result, fmt.Errorf("json path not found: %v", f.Path)
 	}
-	return result.String(), nil
+	return result, nil
 }
@@ -0,0 +1,296 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package outputs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLFilter will parse a YAML string and extract the value
+// at the provided path. Paths use the same syntax as JSONFilter.
+type YAMLFilter struct {
+	Path string `yaml:"yaml_path"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *YAMLFilter) IsNil() bool {
+	return f.Path == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *YAMLFilter) Apply(inStr string) (string, error) {
+	result, err := f.get(inStr)
+	if err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// ApplyTyped applies this filter to the target string and
+// produces a value that retains its type
+func (f *YAMLFilter) ApplyTyped(inStr string) (any, error) {
+	result, err := f.get(inStr)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value(), nil
+}
+
+func (f *YAMLFilter) get(inStr string) (gjson.Result, error) {
+	jsonBytes, err := YAMLToJSON(inStr)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	result := gjson.GetBytes(jsonBytes, f.Path)
+	if !result.Exists() {
+		return result, fmt.Errorf("yaml path not found: %v", f.Path)
+	}
+	return result, nil
+}
+
+// YAMLToJSON converts a YAML document to JSON, so
+// that it can be queried with gjson paths
+func YAMLToJSON(inStr string) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal([]byte(inStr), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	jsonBytes, err := json.Marshal(normalizeYAML(doc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+// normalizeYAML converts maps with non-string keys
+// (which YAML permits) into maps that can be
+// serialized as JSON
+func normalizeYAML(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			val[k] = normalizeYAML(item)
+		}
+		return val
+	case map[any]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = normalizeYAML(item)
+		}
+		return m
+	case []any:
+		for i, item := range val {
+			val[i] = normalizeYAML(item)
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// XPathFilter will parse an XML string and extract the
+// value of the first node matching the provided XPath
+// expression. Attribute nodes produce the attribute value;
+// element nodes produce their text content.
+type XPathFilter struct {
+	Path string `yaml:"xpath"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *XPathFilter) IsNil() bool {
+	return f.Path == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *XPathFilter) Apply(inStr string) (string, error) {
+	doc, err := xmlquery.Parse(strings.NewReader(inStr))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse XML: %w", err)
+	}
+	node, err := xmlquery.Query(doc, f.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid xpath %q: %w", f.Path, err)
+	}
+	if node == nil {
+		return "", fmt.Errorf("xpath not found: %v", f.Path)
+	}
+	return node.InnerText(), nil
+}
+
+// CSVFilter will parse a CSV string and select a column,
+// a row, or a single cell. Column may be a column number
+// (starting at 1) or, if Header is set, the name of a column
+// in the header row. Row selects a data row (starting at 1,
+// not counting the header row); negative numbers count back
+// from the last row.
+//
+// If only Column is set, all values in that column are
+// returned, one per line. If only Row is set, the row is
+// returned as a line of CSV.
+type CSVFilter struct {
+	Column    string `yaml:"csv_column,omitempty"`
+	Row       int    `yaml:"csv_row,omitempty"`
+	Header    bool   `yaml:"csv_header,omitempty"`
+	Delimiter string `yaml:"csv_delimiter,omitempty"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *CSVFilter) IsNil() bool {
+	return f.Column == "" && f.Row == 0
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *CSVFilter) Apply(inStr string) (string, error) {
+	reader := csv.NewReader(strings.NewReader(inStr))
+	reader.FieldsPerRecord = -1
+	delimiter := ','
+	if f.Delimiter != "" {
+		runes := []rune(f.Delimiter)
+		if len(runes) != 1 {
+			return "", fmt.Errorf("csv_delimiter must be a single character, got %q", f.Delimiter)
+		}
+		delimiter = runes[0]
+		reader.Comma = delimiter
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse CSV: %w", err)
+	}
+
+	var header []string
+	if f.Header {
+		if len(records) == 0 {
+			return "", fmt.Errorf("csv input has no header row")
+		}
+		header, records = records[0], records[1:]
+	}
+
+	if f.Row != 0 {
+		idx, ok := resolveIndex(f.Row, len(records))
+		if !ok {
+			return "", fmt.Errorf("csv row %d out of range (input has %d rows)", f.Row, len(records))
+		}
+		records = records[idx : idx+1]
+	}
+
+	if f.Column == "" {
+		var sb strings.Builder
+		writer := csv.NewWriter(&sb)
+		writer.Comma = delimiter
+		if err := writer.Write(records[0]); err != nil {
+			return "", err
+		}
+		writer.Flush()
+		return strings.TrimSuffix(sb.String(), "\n"), writer.Error()
+	}
+
+	colIdx, err := f.columnIndex(header)
+	if err != nil {
+		return "", err
+	}
+	values := make([]string, 0, len(records))
+	for rowNum, record := range records {
+		if colIdx >= len(record) {
+			return "", fmt.Errorf("csv row %d has no column %v", rowNum+1, f.Column)
+		}
+		values = append(values, record[colIdx])
+	}
+	return strings.Join(values, "\n"), nil
+}
+
+func (f *CSVFilter) columnIndex(header []string) (int, error) {
+	if colNum, err := strconv.Atoi(f.Column); err == nil {
+		if colNum < 1 {
+			return 0, fmt.Errorf("invalid csv column %d: column numbers start at 1", colNum)
+		}
+		return colNum - 1, nil
+	}
+	if header == nil {
+		return 0, fmt.Errorf("csv column %q is a name, so csv_header must be set", f.Column)
+	}
+	for idx, name := range header {
+		if strings.TrimSpace(name) == f.Column {
+			return idx, nil
+		}
+	}
+	return 0, fmt.Errorf("csv column not found: %v", f.Column)
+}
+
+// KeyValueFilter extracts the value for the provided key
+// from `key=value` pairs. By default pairs are separated by
+// newlines and keys from values by `=`; use PairDelimiter and
+// Separator to change this. Matching quotes around the value
+// are removed.
+type KeyValueFilter struct {
+	Key           string `yaml:"key_value"`
+	Separator     string `yaml:"separator,omitempty"`
+	PairDelimiter string `yaml:"pair_delimiter,omitempty"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *KeyValueFilter) IsNil() bool {
+	return f.Key == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *KeyValueFilter) Apply(inStr string) (string, error) {
+	separator := f.Separator
+	if separator == "" {
+		separator = "="
+	}
+	var pairs []string
+	if f.PairDelimiter == "" {
+		pairs = splitLines(inStr)
+	} else {
+		pairs = strings.Split(strings.TrimRight(inStr, "\r\n"), f.PairDelimiter)
+	}
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, separator)
+		if !found || strings.TrimSpace(key) != f.Key {
+			continue
+		}
+		return unquote(strings.TrimSpace(value)), nil
+	}
+	return "", fmt.Errorf("key not found: %v", f.Key)
+}
+
+// unquote removes matching single or double quotes
+// surrounding a value
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
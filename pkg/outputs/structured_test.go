@@ -0,0 +1,254 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package outputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestStructuredFilters(t *testing.T) {
+	nmapOutput := `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap">
+  <host>
+    <address addr="10.0.0.5" addrtype="ipv4"/>
+    <ports>
+      <port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>
+      <port protocol="tcp" portid="80"><state state="closed"/><service name="http"/></port>
+    </ports>
+  </host>
+</nmaprun>`
+	csvOutput := `name,pid,user
+bash,1234,root
+"sleep, forever",5678,nobody
+`
+	yamlOutput := `server:
+  listen:
+    - port: 8080
+      tls: false
+    - port: 8443
+      tls: true
+1: numeric key
+`
+
+	testCases := []struct {
+		name           string
+		input          string
+		spec           string
+		result         string
+		wantApplyError bool
+	}{
+		{
+			name:  "YAML Path",
+			input: yamlOutput,
+			spec: `filters:
+  - yaml_path: server.listen.1.port`,
+			result: "8443",
+		},
+		{
+			name:  "YAML Path Query",
+			input: yamlOutput,
+			spec: `filters:
+  - yaml_path: server.listen.#(tls==true).port`,
+			result: "8443",
+		},
+		{
+			name:  "YAML Path Numeric Key",
+			input: yamlOutput,
+			spec: `filters:
+  - yaml_path: "1"`,
+			result: "numeric key",
+		},
+		{
+			name:  "YAML Path Not Found",
+			input: yamlOutput,
+			spec: `filters:
+  - yaml_path: server.hostname`,
+			wantApplyError: true,
+		},
+		{
+			name:  "YAML Invalid Input",
+			input: "foo: [bar",
+			spec: `filters:
+  - yaml_path: foo`,
+			wantApplyError: true,
+		},
+		{
+			name:  "XPath Attribute",
+			input: nmapOutput,
+			spec: `filters:
+  - xpath: //address[@addrtype='ipv4']/@addr`,
+			result: "10.0.0.5",
+		},
+		{
+			name:  "XPath Predicate",
+			input: nmapOutput,
+			spec: `filters:
+  - xpath: //port[state/@state='open']/service/@name`,
+			result: "ssh",
+		},
+		{
+			name:  "XPath Element Text",
+			input: `<config><user>admin</user></config>`,
+			spec: `filters:
+  - xpath: /config/user`,
+			result: "admin",
+		},
+		{
+			name:  "XPath Not Found",
+			input: nmapOutput,
+			spec: `filters:
+  - xpath: //port[@portid='443']/@portid`,
+			wantApplyError: true,
+		},
+		{
+			name:  "XPath Invalid Expression",
+			input: nmapOutput,
+			spec: `filters:
+  - xpath: //port[`,
+			wantApplyError: true,
+		},
+		{
+			name:  "CSV Named Column And Row",
+			input: csvOutput,
+			spec: `filters:
+  - csv_column: pid
+    csv_row: 2
+    csv_header: true`,
+			result: "5678",
+		},
+		{
+			name:  "CSV Numbered Column",
+			input: csvOutput,
+			spec: `filters:
+  - csv_column: 1
+    csv_header: true`,
+			result: "bash\nsleep, forever",
+		},
+		{
+			name:  "CSV Row Without Header",
+			input: csvOutput,
+			spec: `filters:
+  - csv_row: -1`,
+			result: `"sleep, forever",5678,nobody`,
+		},
+		{
+			name:  "CSV Custom Delimiter",
+			input: "a;b;c\n1;2;3\n",
+			spec: `filters:
+  - csv_column: c
+    csv_row: 1
+    csv_header: true
+    csv_delimiter: ";"`,
+			result: "3",
+		},
+		{
+			name:  "CSV Named Column Requires Header",
+			input: csvOutput,
+			spec: `filters:
+  - csv_column: pid`,
+			wantApplyError: true,
+		},
+		{
+			name:  "CSV Column Not Found",
+			input: csvOutput,
+			spec: `filters:
+  - csv_column: ppid
+    csv_header: true`,
+			wantApplyError: true,
+		},
+		{
+			name:  "CSV Row Out Of Range",
+			input: csvOutput,
+			spec: `filters:
+  - csv_row: 3
+    csv_header: true`,
+			wantApplyError: true,
+		},
+		{
+			name:  "Key Value Lines",
+			input: "NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nID=ubuntu\n",
+			spec: `filters:
+  - key_value: VERSION_ID`,
+			result: "22.04",
+		},
+		{
+			name:  "Key Value Custom Delimiters",
+			input: "user: alice; role: admin; shell: /bin/zsh",
+			spec: `filters:
+  - key_value: role
+    separator: ":"
+    pair_delimiter: ";"`,
+			result: "admin",
+		},
+		{
+			name:  "Key Value Not Found",
+			input: "a=1\nb=2",
+			spec: `filters:
+  - key_value: c`,
+			wantApplyError: true,
+		},
+		{
+			name:  "Chained Key Value And Regex",
+			input: "uid=0(root) gid=0(root) groups=0(root)",
+			spec: `filters:
+  - key_value: gid
+    pair_delimiter: " "
+  - regex: '\((\w+)\)'`,
+			result: "root",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var spec Spec
+			err := yaml.Unmarshal([]byte(tc.spec), &spec)
+			require.NoError(t, err)
+
+			result, err := spec.Apply(tc.input)
+			if tc.wantApplyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func TestFilterErrorsReportIndex(t *testing.T) {
+	var spec Spec
+	err := yaml.Unmarshal([]byte(`filters:
+  - xpath: /config
+  - yaml_path: missing.key`), &spec)
+	require.NoError(t, err)
+
+	_, err = spec.Apply(`<config>foo: bar</config>`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "output filter #2 failed")
+	assert.Contains(t, err.Error(), "yaml path not found: missing.key")
+
+	_, err = Parse(map[string]Spec{"value": spec}, `<config>foo: bar</config>`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `failed to extract output "value"`)
+}
@@ -0,0 +1,335 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package outputs
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RegexFilter extracts a capture group from the first
+// match of the provided regular expression. Group may be
+// either the name or the number of the capture group - if it
+// is omitted, the first capture group is used (or the whole
+// match if the expression contains no capture groups).
+type RegexFilter struct {
+	Regex string `yaml:"regex"`
+	Group string `yaml:"group,omitempty"`
+
+	re       *regexp.Regexp
+	groupIdx int
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *RegexFilter) IsNil() bool {
+	return f.Regex == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *RegexFilter) Apply(inStr string) (string, error) {
+	if f.re == nil {
+		if err := f.compile(); err != nil {
+			return "", err
+		}
+	}
+	match := f.re.FindStringSubmatch(inStr)
+	if match == nil {
+		return "", fmt.Errorf("regex %q did not match", f.Regex)
+	}
+	return match[f.groupIdx], nil
+}
+
+// compile compiles the regex and resolves the capture group
+func (f *RegexFilter) compile() error {
+	re, err := regexp.Compile(f.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex %q: %w", f.Regex, err)
+	}
+	groupIdx, err := f.groupIndex(re)
+	if err != nil {
+		return err
+	}
+	f.re = re
+	f.groupIdx = groupIdx
+	return nil
+}
+
+func (f *RegexFilter) groupIndex(re *regexp.Regexp) (int, error) {
+	if f.Group == "" {
+		if re.NumSubexp() > 0 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if idx, err := strconv.Atoi(f.Group); err == nil {
+		if idx < 0 || idx > re.NumSubexp() {
+			return 0, fmt.Errorf("regex %q has no capture group %d", f.Regex, idx)
+		}
+		return idx, nil
+	}
+	idx := re.SubexpIndex(f.Group)
+	if idx < 0 {
+		return 0, fmt.Errorf("regex %q has no capture group named %q", f.Regex, f.Group)
+	}
+	return idx, nil
+}
+
+// LineFilter selects a single line from the target string.
+// Line may be "first", "last", or a line number - line numbers
+// start at 1 and negative numbers count back from the last line.
+type LineFilter struct {
+	Line string `yaml:"line"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *LineFilter) IsNil() bool {
+	return f.Line == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *LineFilter) Apply(inStr string) (string, error) {
+	lines := splitLines(inStr)
+	var lineNum int
+	switch f.Line {
+	case "first":
+		lineNum = 1
+	case "last":
+		lineNum = -1
+	default:
+		var err error
+		lineNum, err = strconv.Atoi(f.Line)
+		if err != nil || lineNum == 0 {
+			return "", fmt.Errorf("invalid line %q: must be first, last, or a non-zero line number", f.Line)
+		}
+	}
+	idx, ok := resolveIndex(lineNum, len(lines))
+	if !ok {
+		return "", fmt.Errorf("line %v out of range (input has %d lines)", f.Line, len(lines))
+	}
+	return lines[idx], nil
+}
+
+// GrepFilter keeps only the lines of the target string
+// that match the provided regular expression. If Invert is
+// set, it keeps only the lines that do not match instead.
+type GrepFilter struct {
+	Pattern string `yaml:"grep"`
+	Invert  bool   `yaml:"invert,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *GrepFilter) IsNil() bool {
+	return f.Pattern == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *GrepFilter) Apply(inStr string) (string, error) {
+	if f.re == nil {
+		if err := f.compile(); err != nil {
+			return "", err
+		}
+	}
+	var matched []string
+	for _, line := range splitLines(inStr) {
+		if f.re.MatchString(line) != f.Invert {
+			matched = append(matched, line)
+		}
+	}
+	return strings.Join(matched, "\n"), nil
+}
+
+// compile compiles the grep pattern
+func (f *GrepFilter) compile() error {
+	re, err := regexp.Compile(f.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid grep pattern %q: %w", f.Pattern, err)
+	}
+	f.re = re
+	return nil
+}
+
+// FieldFilter splits the target string on Split (or on
+// runs of whitespace if Split is omitted, like awk) and
+// selects a field. Fields start at 1 and negative numbers
+// count back from the last field.
+type FieldFilter struct {
+	Field int    `yaml:"field"`
+	Split string `yaml:"split,omitempty"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *FieldFilter) IsNil() bool {
+	return f.Field == 0
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *FieldFilter) Apply(inStr string) (string, error) {
+	var fields []string
+	if f.Split == "" {
+		fields = strings.Fields(inStr)
+	} else {
+		fields = strings.Split(strings.TrimRight(inStr, "\r\n"), f.Split)
+	}
+	idx, ok := resolveIndex(f.Field, len(fields))
+	if !ok {
+		return "", fmt.Errorf("field %d out of range (input has %d fields)", f.Field, len(fields))
+	}
+	return fields[idx], nil
+}
+
+// TrimFilter removes leading and trailing characters
+// from the target string. By default whitespace is
+// removed - set Cutset to remove other characters.
+type TrimFilter struct {
+	Trim   bool   `yaml:"trim"`
+	Cutset string `yaml:"cutset,omitempty"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *TrimFilter) IsNil() bool {
+	return !f.Trim
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *TrimFilter) Apply(inStr string) (string, error) {
+	if f.Cutset == "" {
+		return strings.TrimSpace(inStr), nil
+	}
+	return strings.Trim(inStr, f.Cutset), nil
+}
+
+// ReplaceFilter replaces all matches of the provided
+// regular expression with With, which may reference
+// capture groups ($1, ${name}).
+type ReplaceFilter struct {
+	Replace string `yaml:"replace"`
+	With    string `yaml:"with"`
+
+	re *regexp.Regexp
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *ReplaceFilter) IsNil() bool {
+	return f.Replace == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *ReplaceFilter) Apply(inStr string) (string, error) {
+	if f.re == nil {
+		if err := f.compile(); err != nil {
+			return "", err
+		}
+	}
+	return f.re.ReplaceAllString(inStr, f.With), nil
+}
+
+// compile compiles the replace pattern
+func (f *ReplaceFilter) compile() error {
+	re, err := regexp.Compile(f.Replace)
+	if err != nil {
+		return fmt.Errorf("invalid replace pattern %q: %w", f.Replace, err)
+	}
+	f.re = re
+	return nil
+}
+
+// Base64DecodeFilter decodes a base64 string. Both the
+// standard and URL-safe alphabets are accepted, with
+// or without padding.
+type Base64DecodeFilter struct {
+	Base64Decode bool `yaml:"base64_decode"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *Base64DecodeFilter) IsNil() bool {
+	return !f.Base64Decode
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *Base64DecodeFilter) Apply(inStr string) (string, error) {
+	encoded := strings.TrimSpace(inStr)
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	}
+	for _, enc := range encodings {
+		if decoded, err := enc.DecodeString(encoded); err == nil {
+			return string(decoded), nil
+		}
+	}
+	return "", fmt.Errorf("input is not valid base64")
+}
+
+// DefaultFilter replaces an empty (or whitespace-only)
+// string with the provided default value.
+type DefaultFilter struct {
+	Default string `yaml:"default"`
+}
+
+// IsNil checks if the filter is empty or uninitialized
+func (f *DefaultFilter) IsNil() bool {
+	return f.Default == ""
+}
+
+// Apply applies this filter to the target string
+// and produces a new string
+func (f *DefaultFilter) Apply(inStr string) (string, error) {
+	if strings.TrimSpace(inStr) == "" {
+		return f.Default, nil
+	}
+	return inStr, nil
+}
+
+// splitLines splits a string into lines, ignoring
+// a trailing newline and any carriage returns
+func splitLines(inStr string) []string {
+	lines := strings.Split(strings.TrimRight(inStr, "\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// resolveIndex converts a 1-based (or negative, counting
+// from the end) position into a slice index
+func resolveIndex(pos, length int) (int, bool) {
+	idx := pos - 1
+	if pos < 0 {
+		idx = length + pos
+	}
+	if idx < 0 || idx >= length {
+		return 0, false
+	}
+	return idx, true
+}
@@ -0,0 +1,347 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package outputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTextFilters(t *testing.T) {
+	psOutput := `  PID TTY          TIME CMD
+ 1234 pts/0    00:00:00 bash
+ 5678 pts/0    00:00:01 sleep
+ 9012 pts/0    00:00:00 ps
+`
+
+	testCases := []struct {
+		name           string
+		input          string
+		spec           string
+		result         string
+		wantApplyError bool
+	}{
+		{
+			name:  "Regex Numbered Group",
+			input: `<input name="csrf" value="abc123">`,
+			spec: `filters:
+  - regex: 'value="([^"]+)"'`,
+			result: "abc123",
+		},
+		{
+			name:  "Regex Named Group",
+			input: "user=alice uid=1001",
+			spec: `filters:
+  - regex: 'user=(?P<user>\w+) uid=(?P<uid>\d+)'
+    group: uid`,
+			result: "1001",
+		},
+		{
+			name:  "Regex Whole Match",
+			input: "version 1.2.3 installed",
+			spec: `filters:
+  - regex: '\d+\.\d+\.\d+'`,
+			result: "1.2.3",
+		},
+		{
+			name:  "Regex Explicit Group Zero",
+			input: "key=value",
+			spec: `filters:
+  - regex: 'key=(\w+)'
+    group: "0"`,
+			result: "key=value",
+		},
+		{
+			name:  "Regex No Match",
+			input: "nothing here",
+			spec: `filters:
+  - regex: '\d+'`,
+			wantApplyError: true,
+		},
+		{
+			name:  "First Line",
+			input: psOutput,
+			spec: `filters:
+  - line: first`,
+			result: "  PID TTY          TIME CMD",
+		},
+		{
+			name:  "Last Line",
+			input: psOutput,
+			spec: `filters:
+  - line: last`,
+			result: " 9012 pts/0    00:00:00 ps",
+		},
+		{
+			name:  "Nth Line",
+			input: psOutput,
+			spec: `filters:
+  - line: 2`,
+			result: " 1234 pts/0    00:00:00 bash",
+		},
+		{
+			name:  "Negative Line",
+			input: psOutput,
+			spec: `filters:
+  - line: -2`,
+			result: " 5678 pts/0    00:00:01 sleep",
+		},
+		{
+			name:  "Line Out Of Range",
+			input: psOutput,
+			spec: `filters:
+  - line: 10`,
+			wantApplyError: true,
+		},
+		{
+			name:  "Invalid Line",
+			input: psOutput,
+			spec: `filters:
+  - line: middle`,
+			wantApplyError: true,
+		},
+		{
+			name:  "Grep",
+			input: psOutput,
+			spec: `filters:
+  - grep: 'bash|sleep'`,
+			result: " 1234 pts/0    00:00:00 bash\n 5678 pts/0    00:00:01 sleep",
+		},
+		{
+			name:  "Grep Inverted",
+			input: psOutput,
+			spec: `filters:
+  - grep: 'PID|ps$'
+    invert: true`,
+			result: " 1234 pts/0    00:00:00 bash\n 5678 pts/0    00:00:01 sleep",
+		},
+		{
+			name:  "Grep No Match",
+			input: psOutput,
+			spec: `filters:
+  - grep: nginx`,
+			result: "",
+		},
+		{
+			name:  "Whitespace Field",
+			input: " 5678 pts/0    00:00:01 sleep",
+			spec: `filters:
+  - field: 1`,
+			result: "5678",
+		},
+		{
+			name:  "Separator Field",
+			input: "root:x:0:0:root:/root:/bin/bash\n",
+			spec: `filters:
+  - field: -1
+    split: ":"`,
+			result: "/bin/bash",
+		},
+		{
+			name:  "Field Out Of Range",
+			input: "a,b",
+			spec: `filters:
+  - field: 3
+    split: ","`,
+			wantApplyError: true,
+		},
+		{
+			name:  "Trim Whitespace",
+			input: "  \tvalue\n",
+			spec: `filters:
+  - trim: true`,
+			result: "value",
+		},
+		{
+			name:  "Trim Cutset",
+			input: `"quoted"`,
+			spec: `filters:
+  - trim: true
+    cutset: '"'`,
+			result: "quoted",
+		},
+		{
+			name:  "Replace",
+			input: "2024-01-31",
+			spec: `filters:
+  - replace: '(\d+)-(\d+)-(\d+)'
+    with: '$3/$2/$1'`,
+			result: "31/01/2024",
+		},
+		{
+			name:  "Replace With Empty String",
+			input: "a-b-c",
+			spec: `filters:
+  - replace: '-'`,
+			result: "abc",
+		},
+		{
+			name:  "Base64 Decode",
+			input: "aGVsbG8gd29ybGQ=\n",
+			spec: `filters:
+  - base64_decode: true`,
+			result: "hello world",
+		},
+		{
+			name:  "Base64 Decode URL Safe Unpadded",
+			input: "Pz8_",
+			spec: `filters:
+  - base64_decode: true`,
+			result: "???",
+		},
+		{
+			name:  "Base64 Decode Invalid",
+			input: "not base64!",
+			spec: `filters:
+  - base64_decode: true`,
+			wantApplyError: true,
+		},
+		{
+			name:  "Default On Empty",
+			input: "  \n",
+			spec: `filters:
+  - default: fallback`,
+			result: "fallback",
+		},
+		{
+			name:  "Default Not Needed",
+			input: "value",
+			spec: `filters:
+  - default: fallback`,
+			result: "value",
+		},
+		{
+			name:  "Chained Filters",
+			input: psOutput,
+			spec: `filters:
+  - grep: sleep
+  - line: first
+  - field: 1`,
+			result: "5678",
+		},
+		{
+			name:  "Chained Filters With Default",
+			input: psOutput,
+			spec: `filters:
+  - grep: nginx
+  - default: "0"`,
+			result: "0",
+		},
+		{
+			name:  "Chained JSON And Text Filters",
+			input: `{"token":"Bearer ZGVhZGJlZWY="}`,
+			spec: `filters:
+  - json_path: token
+  - field: 2
+  - base64_decode: true`,
+			result: "deadbeef",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var spec Spec
+			err := yaml.Unmarshal([]byte(tc.spec), &spec)
+			require.NoError(t, err)
+
+			result, err := spec.Apply(tc.input)
+			if tc.wantApplyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.result, result)
+		})
+	}
+}
+
+func TestSpecUnmarshalErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		errContains string
+	}{
+		{
+			name: "Ambiguous Filter",
+			spec: `filters:
+  - json_path: foo
+    regex: bar`,
+			errContains: "filter #1 has ambiguous type",
+		},
+		{
+			name: "Unknown Filter",
+			spec: `filters:
+  - trim: true
+  - not_a_filter: foo`,
+			errContains: "filter #2 did not match any valid filter type",
+		},
+		{
+			name:        "No Filters",
+			spec:        `filters: []`,
+			errContains: "no valid filters found",
+		},
+		{
+			name: "Regex Invalid",
+			spec: `filters:
+  - trim: true
+  - regex: '(abc'`,
+			errContains: `filter #2 is invalid: invalid regex "(abc"`,
+		},
+		{
+			name: "Regex Missing Named Group",
+			spec: `filters:
+  - regex: '(?P<foo>abc)'
+    group: bar`,
+			errContains: `has no capture group named "bar"`,
+		},
+		{
+			name: "Regex Missing Numbered Group",
+			spec: `filters:
+  - regex: '(abc)'
+    group: 2`,
+			errContains: "has no capture group 2",
+		},
+		{
+			name: "Grep Invalid",
+			spec: `filters:
+  - grep: '[a-'`,
+			errContains: `invalid grep pattern "[a-"`,
+		},
+		{
+			name: "Replace Invalid",
+			spec: `filters:
+  - replace: '*foo'
+    with: bar`,
+			errContains: `invalid replace pattern "*foo"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var spec Spec
+			err := yaml.Unmarshal([]byte(tc.spec), &spec)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errContains)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package outputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Supported values for the `type:` field of an output spec
+const (
+	TypeString = "string"
+	TypeNumber = "number"
+	TypeBool   = "bool"
+	TypeList   = "list"
+	TypeObject = "object"
+)
+
+// validTypes lists the values accepted for the `type:` field
+var validTypes = []string{TypeString, TypeNumber, TypeBool, TypeList, TypeObject}
+
+// TypedFilter is implemented by filters that can produce
+// values which retain their JSON type (lists, objects,
+// numbers and booleans) rather than only strings
+type TypedFilter interface {
+	Filter
+	ApplyTyped(inStr string) (any, error)
+}
+
+// ParseTyped works like Parse, but also returns the value of each
+// output with its type retained. If a spec sets `type:`, the output
+// is converted to that type; otherwise, outputs produced by a
+// TypedFilter (such as json_path) keep their JSON type and all
+// other outputs are strings.
+//
+// **Parameters:**
+//
+// specs: the specs for the outputs to be extracted
+// inStr: the raw stdout string from the step whose outputs will be extracted
+//
+// **Returns:**
+//
+// map[string]string: the output keys and values coerced to strings
+// map[string]any: the output keys and typed values
+// error: an error if there is a problem
+func ParseTyped(specs map[string]Spec, inStr string) (map[string]string, map[string]any, error) {
+	strOutputs := make(map[string]string)
+	typedOutputs := make(map[string]any)
+	for name, spec := range specs {
+		outStr, outVal, err := spec.ApplyTyped(inStr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to extract output %q: %w", name, err)
+		}
+		strOutputs[name] = outStr
+		typedOutputs[name] = outVal
+	}
+	return strOutputs, typedOutputs, nil
+}
+
+// ApplyTyped applies all filters in this output spec to the target
+// string in order. It returns both the resulting string (exactly as
+// Apply would) and the resulting value with its type retained.
+func (s *Spec) ApplyTyped(inStr string) (string, any, error) {
+	curStr := inStr
+	var curVal any
+	for idx, f := range s.Filters {
+		prevStr := curStr
+		var err error
+		curStr, err = f.Apply(prevStr)
+		if err != nil {
+			return "", nil, fmt.Errorf("output filter #%d failed: %w", idx+1, err)
+		}
+		curVal = curStr
+
+		tf, ok := f.(TypedFilter)
+		if ok && s.Type == "" && idx == len(s.Filters)-1 {
+			curVal, err = tf.ApplyTyped(prevStr)
+			if err != nil {
+				return "", nil, fmt.Errorf("output filter #%d failed: %w", idx+1, err)
+			}
+		}
+	}
+
+	if s.Type != "" {
+		var err error
+		curVal, err = convertType(curStr, s.Type)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return curStr, curVal, nil
+}
+
+// convertType converts the string produced by
+// an output's filters into the requested type
+func convertType(inStr string, typ string) (any, error) {
+	trimmed := strings.TrimSpace(inStr)
+	switch typ {
+	case TypeString:
+		return inStr, nil
+	case TypeNumber:
+		num, err := strconv.ParseFloat(trimmed, 64)
+		if err != nil {
+			return nil, fmt.Errorf("output value %q is not a number", trimmed)
+		}
+		return num, nil
+	case TypeBool:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("output value %q is not a bool", trimmed)
+		}
+		return b, nil
+	case TypeList:
+		// JSON arrays are decoded as-is - anything
+		// else is treated as one item per line
+		if strings.HasPrefix(trimmed, "[") {
+			var list []any
+			if err := json.Unmarshal([]byte(trimmed), &list); err == nil {
+				return list, nil
+			}
+		}
+		list := []any{}
+		if trimmed == "" {
+			return list, nil
+		}
+		for _, line := range splitLines(inStr) {
+			list = append(list, line)
+		}
+		return list, nil
+	case TypeObject:
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+			return nil, fmt.Errorf("output value is not a JSON object: %w", err)
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("invalid output type %q - must be one of %v", typ, strings.Join(validTypes, ", "))
+	}
+}
+
+// Stringify coerces a typed output value to a string. Strings are
+// returned unchanged, numbers and booleans are formatted as they
+// would be in JSON, and lists and objects are serialized as JSON.
+//
+// **Parameters:**
+//
+// val: the typed output value
+//
+// **Returns:**
+//
+// string: the value coerced to a string
+func Stringify(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int64, int32, uint, uint64, uint32:
+		return fmt.Sprint(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
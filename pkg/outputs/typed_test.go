@@ -0,0 +1,183 @@
+/*
+Copyright © 2024-present, Meta Platforms, Inc. and affiliates
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package outputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTypedOutputs(t *testing.T) {
+	jsonOutput := `{"pids": [1234, 5678], "user": {"name": "root", "uid": 0}, "count": 2, "ok": true}`
+
+	testCases := []struct {
+		name             string
+		input            string
+		spec             string
+		wantUnmarshalErr bool
+		wantApplyError   bool
+		expectedString   string
+		expectedTypedVal any
+	}{
+		{
+			name:  "JSON Path List",
+			input: jsonOutput,
+			spec: `filters:
+  - json_path: pids`,
+			expectedString:   "[1234, 5678]",
+			expectedTypedVal: []any{float64(1234), float64(5678)},
+		},
+		{
+			name:  "JSON Path Object",
+			input: jsonOutput,
+			spec: `filters:
+  - json_path: user`,
+			expectedString:   `{"name": "root", "uid": 0}`,
+			expectedTypedVal: map[string]any{"name": "root", "uid": float64(0)},
+		},
+		{
+			name:  "JSON Path Number",
+			input: jsonOutput,
+			spec: `filters:
+  - json_path: count`,
+			expectedString:   "2",
+			expectedTypedVal: float64(2),
+		},
+		{
+			name:  "Untyped Text Filter",
+			input: "a\nb",
+			spec: `filters:
+  - line: 2`,
+			expectedString:   "b",
+			expectedTypedVal: "b",
+		},
+		{
+			name:  "List From Grep Lines",
+			input: "root 1\nnobody 2\nroot 3\n",
+			spec: `filters:
+  - grep: root
+type: list`,
+			expectedString:   "root 1\nroot 3",
+			expectedTypedVal: []any{"root 1", "root 3"},
+		},
+		{
+			name:             "List From Empty Output",
+			input:            "",
+			spec:             "filters:\n  - trim: true\ntype: list",
+			expectedString:   "",
+			expectedTypedVal: []any{},
+		},
+		{
+			name:             "Number Conversion",
+			input:            " 42.5\n",
+			spec:             "filters:\n  - trim: true\ntype: number",
+			expectedString:   "42.5",
+			expectedTypedVal: 42.5,
+		},
+		{
+			name:             "Bool Conversion",
+			input:            "true",
+			spec:             "filters:\n  - trim: true\ntype: bool",
+			expectedString:   "true",
+			expectedTypedVal: true,
+		},
+		{
+			name:             "Object Conversion",
+			input:            `{"a": "b"}`,
+			spec:             "filters:\n  - trim: true\ntype: object",
+			expectedString:   `{"a": "b"}`,
+			expectedTypedVal: map[string]any{"a": "b"},
+		},
+		{
+			name:  "Explicit String Overrides JSON Type",
+			input: jsonOutput,
+			spec: `filters:
+  - json_path: count
+type: string`,
+			expectedString:   "2",
+			expectedTypedVal: "2",
+		},
+		{
+			name:           "Invalid Number",
+			input:          "not a number",
+			spec:           "filters:\n  - trim: true\ntype: number",
+			wantApplyError: true,
+		},
+		{
+			name:           "Invalid Object",
+			input:          "[1, 2]",
+			spec:           "filters:\n  - trim: true\ntype: object",
+			wantApplyError: true,
+		},
+		{
+			name:             "Invalid Type",
+			input:            "foo",
+			spec:             "filters:\n  - trim: true\ntype: dict",
+			wantUnmarshalErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var spec Spec
+			err := yaml.Unmarshal([]byte(tc.spec), &spec)
+			if tc.wantUnmarshalErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			strOutputs, typedOutputs, err := ParseTyped(map[string]Spec{"out": spec}, tc.input)
+			if tc.wantApplyError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedString, strOutputs["out"])
+			assert.Equal(t, tc.expectedTypedVal, typedOutputs["out"])
+		})
+	}
+}
+
+func TestStringify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		val      any
+		expected string
+	}{
+		{name: "Nil", val: nil, expected: ""},
+		{name: "String", val: "foo", expected: "foo"},
+		{name: "Integral Float", val: float64(8080), expected: "8080"},
+		{name: "Fractional Float", val: 1.5, expected: "1.5"},
+		{name: "Bool", val: false, expected: "false"},
+		{name: "Int", val: 7, expected: "7"},
+		{name: "List", val: []any{"a", float64(1)}, expected: `["a",1]`},
+		{name: "Object", val: map[string]any{"k": "v"}, expected: `{"k":"v"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Stringify(tc.val))
+		})
+	}
+}
@@ -20,10 +20,16 @@ THE SOFTWARE.
 package processutils
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+
 	"github.com/shirou/gopsutil/v4/process"
 )
 
+// ErrNoProcessFound is returned when a process lookup matches no processes.
+var ErrNoProcessFound = errors.New("no process found")
+
 // GetPIDsByName returns a list of process IDs that match the given process name
 func GetPIDsByName(processName string) ([]int32, error) {
 	processes, err := process.Processes()
@@ -38,7 +44,27 @@ func GetPIDsByName(processName string) ([]int32, error) {
 		}
 	}
 	if len(pids) == 0 {
-		return nil, fmt.Errorf("no process found with name: %s", processName)
+		return nil, fmt.Errorf("%w with name: %s", ErrNoProcessFound, processName)
+	}
+	return pids, nil
+}
+
+// GetPIDsByCommandLine returns a list of process IDs whose
+// full command line matches the given regular expression
+func GetPIDsByCommandLine(pattern *regexp.Regexp) ([]int32, error) {
+	processes, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	var pids []int32
+	for _, proc := range processes {
+		cmdline, err := proc.Cmdline()
+		if err == nil && cmdline != "" && pattern.MatchString(cmdline) {
+			pids = append(pids, proc.Pid)
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("%w with command line matching: %s", ErrNoProcessFound, pattern)
 	}
 	return pids, nil
 }
@@ -56,3 +82,26 @@ func VerifyPIDExists(pid int) error {
 	}
 	return fmt.Errorf("no process found with PID: %d", pid)
 }
+
+// GetProcessTree returns the given process ID followed by the IDs of
+// all of its descendants, with each parent listed before its children
+func GetProcessTree(pid int32) ([]int32, error) {
+	processes, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int32][]int32)
+	for _, proc := range processes {
+		ppid, err := proc.Ppid()
+		// a process can be its own parent (such as pid 0 on macOS)
+		if err == nil && ppid != proc.Pid {
+			children[ppid] = append(children[ppid], proc.Pid)
+		}
+	}
+
+	tree := []int32{pid}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree, nil
+}
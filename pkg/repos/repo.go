@@ -128,6 +128,16 @@ func (r *repo) FindTTP(ttpRef string) (string, error) {
 
 // FindTemplate locates a template if it exists in this repo
 func (r *repo) FindTemplate(templatePath string) (string, error) {
+	if strings.Contains(templatePath, RepoPrefixSep) {
+		// a template reference ([repo]//path/to/template)
+		// TTP partials loaded with `include` use this path
+		tokens := strings.SplitN(templatePath, RepoPrefixSep, 2)
+		repoName := tokens[0]
+		if repoName != "" && repoName != r.spec.Name {
+			return "", fmt.Errorf("invalid template reference %q; repo name %q does not match %q", templatePath, repoName, r.spec.Name)
+		}
+		templatePath = tokens[1]
+	}
 	return r.search(r.TemplateSearchPaths, templatePath)
 }
 
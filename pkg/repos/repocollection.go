@@ -35,6 +35,7 @@ type RepoCollection interface {
 	AddRepo(r Repo) error
 	GetRepo(repoName string) (Repo, error)
 	ResolveTTPRef(ttpRef string) (Repo, string, error)
+	ResolveTemplateRef(templateRef string) (Repo, string, error)
 	ListTTPs() ([]string, error)
 	ListRepos() []string
 	FindParentRepo(absPath string) (Repo, error)
@@ -246,6 +247,44 @@ func (rc *repoCollection) ResolveTTPRef(ttpRef string) (Repo, string, error) {
 	return repo, absPath, nil
 }
 
+// ResolveTemplateRef turns a provided template reference into
+// a Repo and a verified absolute template file path.
+// Templates are searched for in the `template_search_paths`
+// of the referenced repository.
+//
+// **Parameters:**
+//
+// templateRef: a reference of the form repo//path/to/template
+//
+// **Returns:**
+//
+// Repo: the located repo
+// string: the absolute path to the specified template
+// error: an error if there is a problem
+func (rc *repoCollection) ResolveTemplateRef(templateRef string) (Repo, string, error) {
+	repoName, scopedRef, found := strings.Cut(templateRef, RepoPrefixSep)
+	if !found || repoName == "" {
+		return nil, "", fmt.Errorf("template reference %q must be of the form repo%vpath/to/template", templateRef, RepoPrefixSep)
+	}
+
+	repo, err := rc.GetRepo(repoName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	absPath, err := repo.FindTemplate(scopedRef)
+	if err != nil {
+		return nil, "", err
+	}
+
+	absPath, err = rc.resolveAbsPath(absPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return repo, absPath, nil
+}
+
 // ListTTPs lists all TTPs in the RepoCollection
 //
 // **Returns:**
@@ -35,6 +35,8 @@ func makeRepoCollectionTestFs(t *testing.T) afero.Fs {
 		"repos/a/more/ttps/absolute/victory.yaml":   []byte("placeholder"),
 		"repos/b/" + RepoConfigFileName:             []byte(`ttp_search_paths: ["even/more/ttps"]`),
 		"repos/b/even/more/ttps/attempt/again.yaml": []byte(`ttp_search_paths: ["even/more/ttps"]`),
+		"repos/c/" + RepoConfigFileName:             []byte(`template_search_paths: ["partials"]`),
+		"repos/c/partials/common/setup.yaml":        []byte("placeholder"),
 		"not-a-repo/my-ttp.yaml":                    []byte("placeholder"),
 	},
 	)
@@ -228,3 +230,73 @@ func TestResolveTTPRef(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveTemplateRef(t *testing.T) {
+	specs := []Spec{
+		{
+			Name: "default",
+			Path: "repos/a",
+		},
+		{
+			Name: "partials",
+			Path: "repos/c",
+		},
+	}
+
+	tests := []struct {
+		name               string
+		templateRef        string
+		expectResolveError bool
+		expectedRepoName   string
+		expectedPath       string
+	}{
+		{
+			name:             "Valid Template Ref",
+			templateRef:      "partials//common/setup.yaml",
+			expectedRepoName: "partials",
+			expectedPath:     "repos/c/partials/common/setup.yaml",
+		},
+		{
+			name:               "Missing Repo Name",
+			templateRef:        "//common/setup.yaml",
+			expectResolveError: true,
+		},
+		{
+			name:               "Plain Path",
+			templateRef:        "common/setup.yaml",
+			expectResolveError: true,
+		},
+		{
+			name:               "Non-Existent Repo",
+			templateRef:        "notreal//common/setup.yaml",
+			expectResolveError: true,
+		},
+		{
+			name:               "Repo Without Template Search Paths",
+			templateRef:        "default//common/setup.yaml",
+			expectResolveError: true,
+		},
+		{
+			name:               "Non-Existent Template",
+			templateRef:        "partials//common/notreal.yaml",
+			expectResolveError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, err := NewRepoCollection(makeRepoCollectionTestFs(t), specs, "")
+			require.NoError(t, err)
+
+			r, absPath, err := rc.ResolveTemplateRef(tc.templateRef)
+			if tc.expectResolveError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedRepoName, r.GetName())
+			assert.Equal(t, tc.expectedPath, absPath)
+		})
+	}
+}
@@ -25,9 +25,7 @@ import (
 	"io"
 	"path/filepath"
 	"strings"
-	"text/template"
 
-	"github.com/Masterminds/sprig/v3"
 	"github.com/facebookincubator/ttpforge/pkg/blocks"
 	"github.com/facebookincubator/ttpforge/pkg/logging"
 	"github.com/facebookincubator/ttpforge/pkg/parseutils"
@@ -73,8 +71,9 @@ func readTTPBytesForValidation(ttpFilePath string, fsys afero.Fs) ([]byte, error
 
 // renderTemplatedTTPForValidation renders templates with dummy values for args
 // This allows template control structures ({{ if }}, {{ range }}) to be evaluated
-// while preventing validation errors from <no value> substitutions
-func renderTemplatedTTPForValidation(ttpStr string, rp blocks.RenderParameters) (*blocks.TTP, error) {
+// while preventing validation errors from <no value> substitutions.
+// Partials loaded with `include` are resolved against repo and repoCollection.
+func renderTemplatedTTPForValidation(ttpStr string, rp blocks.RenderParameters, repo repos.Repo, repoCollection repos.RepoCollection) (*blocks.TTP, error) {
 	// First, extract arg definitions from the YAML
 	dummyArgs := extractDummyArgsFromTTP(ttpStr)
 
@@ -90,7 +89,7 @@ func renderTemplatedTTPForValidation(ttpStr string, rp blocks.RenderParameters)
 
 	// Now render the template with dummy args
 	logging.L().Debugf("Rendering template with dummy args: %+v", rp.Args)
-	tmpl, err := template.New("ttp").Funcs(sprig.TxtFuncMap()).Parse(ttpStr)
+	tmpl, err := blocks.NewTTPTemplate(repo, repoCollection).Parse(ttpStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -176,13 +175,13 @@ func generateDummyValueForType(argType string) any {
 // Note: The blocks package validation methods log errors to stderr using logging.L().Error().
 // These ERROR logs will appear during validation but the actual validation results are
 // captured in the Result object and displayed in the structured output at the end.
-func ValidateIntegration(ttpFilePath string, ttpBytes []byte, repo repos.Repo, result *Result) {
+func ValidateIntegration(ttpFilePath string, ttpBytes []byte, repo repos.Repo, repoCollection repos.RepoCollection, result *Result) {
 	rp := blocks.RenderParameters{
 		Args:     map[string]any{},
 		Platform: platforms.GetCurrentPlatformSpec(),
 	}
 
-	ttp, err := renderTemplatedTTPForValidation(string(ttpBytes), rp)
+	ttp, err := renderTemplatedTTPForValidation(string(ttpBytes), rp, repo, repoCollection)
 	if err != nil {
 		if isTemplateRelatedError(err) {
 			result.AddWarning(fmt.Sprintf("TTP rendering with dummy args (templates may need real values): %v", err))
@@ -190,12 +189,13 @@ func ValidateIntegration(ttpFilePath string, ttpBytes []byte, repo repos.Repo, r
 			result.AddError(fmt.Sprintf("TTP rendering: %v", err))
 		}
 		// If we can't parse the full TTP, try to validate individual steps
-		validateIndividualSteps(ttpBytes, repo, result)
+		validateIndividualSteps(ttpBytes, repo, repoCollection, result)
 		return
 	}
 
 	execCtx := blocks.NewTTPExecutionContext()
 	execCtx.Cfg.Repo = repo
+	execCtx.Cfg.RepoCollection = repoCollection
 
 	absPath, err := filepath.Abs(ttpFilePath)
 	if err == nil {
@@ -288,7 +288,7 @@ func validateSingleStep(stepVal any, idx int, execCtx blocks.TTPExecutionContext
 
 // validateIndividualSteps attempts to validate steps one by one
 // This is a fallback when the full TTP can't be parsed
-func validateIndividualSteps(ttpBytes []byte, repo repos.Repo, result *Result) {
+func validateIndividualSteps(ttpBytes []byte, repo repos.Repo, repoCollection repos.RepoCollection, result *Result) {
 	stepsList, err := extractStepsFromYAML(ttpBytes)
 	if err != nil {
 		return // Can't extract steps
@@ -296,6 +296,7 @@ func validateIndividualSteps(ttpBytes []byte, repo repos.Repo, result *Result) {
 
 	execCtx := blocks.NewTTPExecutionContext()
 	execCtx.Cfg.Repo = repo
+	execCtx.Cfg.RepoCollection = repoCollection
 
 	// Validate each step individually
 	for idx, stepVal := range stepsList {
@@ -87,8 +87,10 @@ func (vr *Result) Print() {
 	}
 }
 
-// ValidateTTP performs comprehensive validation using all checks
-func ValidateTTP(ttpFilePath string, fsys afero.Fs, repo repos.Repo) *Result {
+// ValidateTTP performs comprehensive validation using all checks.
+// Partials loaded with `include` are resolved against repo and,
+// for references to other repositories, repoCollection.
+func ValidateTTP(ttpFilePath string, fsys afero.Fs, repo repos.Repo, repoCollection repos.RepoCollection) *Result {
 	result := &Result{}
 
 	// Read file once and cache the content
@@ -128,7 +130,7 @@ func ValidateTTP(ttpFilePath string, fsys afero.Fs, repo repos.Repo) *Result {
 	}
 
 	// Integration validation — best-effort full parse with dummy args
-	ValidateIntegration(ttpFilePath, ttpBytes, repo, result)
+	ValidateIntegration(ttpFilePath, ttpBytes, repo, repoCollection, result)
 
 	return result
 }